@@ -2,20 +2,51 @@ package lambdamux
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
+	"go.jasdel.dev/aws/lambda-mux/headers"
 )
 
 // APIGatewayProxy provides an Lambda Handler for proxied Lambda invokes from
 // API Gateway.
 type APIGatewayProxy struct {
 	Handler ResourceHandler
+
+	// Strict rejects events that are not well formed API Gateway Proxy
+	// events. By default, the sample events of the Lambda console's test
+	// feature are accepted and normalized: events missing the
+	// multiValueHeaders, or multiValueQueryStringParameters, maps have them
+	// filled from their single value maps, and JSON object bodies are
+	// converted to strings.
+	Strict bool
+
+	// DisallowUnknownFields rejects events with fields unknown to the API
+	// Gateway Proxy event schema, for test environments validating the
+	// events they send. By default unknown fields, e.g. payload additions
+	// made by API Gateway, are ignored. Unknown fields are available to
+	// handlers via EventDiagnosticsFromContext either way, and routes may
+	// reject them individually with WithStrictEvent.
+	DisallowUnknownFields bool
+
+	// ErrorHandler optionally translates errors returned by the Handler into
+	// responses, instead of failing the Lambda invoke, which API Gateway
+	// responds to with a 502 Bad Gateway. Without an ErrorHandler only
+	// StatusError errors are translated, into JSON error responses.
+	ErrorHandler ErrorHandlerFunc
 }
 
+// ErrorHandlerFunc translates an error returned by a resource handler into
+// the request's response.
+type ErrorHandlerFunc func(ctx context.Context, req APIGatewayProxyRequest, err error) APIGatewayProxyResponse
+
 // APIGatewayProxyRequest provides a proxy request wrapper for deserializing
 // the events.APIGatewayProxyRequest with Go's http.Header formated headers.
 // Simplifies the conversion between Go's http.Header and lambda's events multi
@@ -23,6 +54,17 @@ type APIGatewayProxy struct {
 type APIGatewayProxyRequest struct {
 	events.APIGatewayProxyRequest
 	HTTPHeader http.Header `json:"-"`
+
+	// PathValues are the typed path parameter values converted by the
+	// resource pattern's parameter types, e.g. "/users/{id:int}" provides
+	// the "id" value as an int64.
+	PathValues map[string]interface{} `json:"-"`
+
+	// RawQuery is the request's query string as received, without the "?",
+	// of requests whose event provides it, e.g. HTTP API, and function URL,
+	// events, and requests served by AsHTTPHandler. Empty for REST API
+	// events, which only provide the decoded query string parameters.
+	RawQuery string `json:"-"`
 }
 
 // UnmarshalJSON unmarshals APIGatewayProxyRequest with the MultiValueHeaders
@@ -32,14 +74,74 @@ func (r *APIGatewayProxyRequest) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	r.HTTPHeader = http.Header{}
-	for header, values := range r.MultiValueHeaders {
-		for _, value := range values {
-			r.HTTPHeader.Add(header, value)
-		}
+	r.HTTPHeader = headers.FromMultiValue(r.MultiValueHeaders)
+	r.initMaps()
+	return nil
+}
+
+// initMaps replaces the request's nil maps with empty maps, as events from
+// various sources omit, or provide null for, maps without values. Handlers
+// can then read, and write, the maps without checking for nil.
+func (r *APIGatewayProxyRequest) initMaps() {
+	if r.HTTPHeader == nil {
+		r.HTTPHeader = http.Header{}
+	}
+	if r.Headers == nil {
+		r.Headers = map[string]string{}
+	}
+	if r.MultiValueHeaders == nil {
+		r.MultiValueHeaders = map[string][]string{}
+	}
+	if r.QueryStringParameters == nil {
+		r.QueryStringParameters = map[string]string{}
+	}
+	if r.MultiValueQueryStringParameters == nil {
+		r.MultiValueQueryStringParameters = map[string][]string{}
 	}
+	if r.PathParameters == nil {
+		r.PathParameters = map[string]string{}
+	}
+	if r.StageVariables == nil {
+		r.StageVariables = map[string]string{}
+	}
+	if r.RequestContext.Authorizer == nil {
+		r.RequestContext.Authorizer = map[string]interface{}{}
+	}
+}
 
-	return nil
+// BodyBytes returns the request's body, decoding it if base64 encoded, as
+// API Gateway encodes binary bodies. Returns an error if the body is not
+// valid base64.
+func (r APIGatewayProxyRequest) BodyBytes() ([]byte, error) {
+	return requestBody(r)
+}
+
+// requestBody returns the request's body, decoding it if base64 encoded.
+func requestBody(req APIGatewayProxyRequest) ([]byte, error) {
+	if !req.IsBase64Encoded {
+		return []byte(req.Body), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 encoded body, %w", err)
+	}
+	return b, nil
+}
+
+// requestForm returns the URL encoded form of the request's body, or an
+// error if the body is not a URL encoded form.
+func requestForm(req APIGatewayProxyRequest) (url.Values, error) {
+	mediaType, _, err := mime.ParseMediaType(req.HTTPHeader.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		return nil, fmt.Errorf("request body is not a URL encoded form")
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	return url.ParseQuery(string(body))
 }
 
 // APIGatewayProxyResponse serializes the events.APIGatewayResponse with Go's
@@ -50,9 +152,22 @@ type APIGatewayProxyResponse struct {
 	HTTPHeader http.Header `json:"-"`
 }
 
+// statusResponse returns a plain text response for the HTTP status code.
+func statusResponse(code int) APIGatewayProxyResponse {
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: code,
+			Body:       http.StatusText(code),
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{"text/plain; charset=utf-8"},
+		},
+	}
+}
+
 // MarshalJSON marshals the response as an JSON document.
 func (r APIGatewayProxyResponse) MarshalJSON() ([]byte, error) {
-	r.MultiValueHeaders = map[string][]string(r.HTTPHeader)
+	r.MultiValueHeaders = headers.ToMultiValue(r.HTTPHeader)
 
 	return json.Marshal(r.APIGatewayProxyResponse)
 }
@@ -65,13 +180,42 @@ func (r APIGatewayProxyResponse) MarshalJSON() ([]byte, error) {
 func (p APIGatewayProxy) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
 	var req APIGatewayProxyRequest
 
+	if !p.Strict {
+		var err error
+		if payload, err = normalizeConsoleEvent(payload); err != nil {
+			return nil, fmt.Errorf("invalid lambda event, expect %T, %w", req, err)
+		}
+	}
+
 	if err := json.Unmarshal(payload, &req); err != nil {
 		return nil, fmt.Errorf("invalid lambda event, expect %T, %w", req, err)
 	}
 
+	if p.Strict {
+		if err := req.checkMultiValueMaps(); err != nil {
+			return nil, err
+		}
+	} else {
+		req.fillMultiValueMaps()
+	}
+
+	diagnostics := newEventDiagnostics(p, payload)
+	if p.DisallowUnknownFields {
+		if unknown := diagnostics.UnknownFields(); len(unknown) != 0 {
+			return nil, fmt.Errorf("invalid lambda event, unknown fields %s",
+				strings.Join(unknown, ", "))
+		}
+	}
+	ctx = context.WithValue(ctx, eventDiagnosticsKey{}, diagnostics)
+
+	ctx, done := withRequestDone(ctx)
+	defer done()
+
 	resp, err := p.Handler.ServeResource(ctx, req)
 	if err != nil {
-		return nil, err
+		if resp, err = handlerErrorResponse(ctx, p.ErrorHandler, req, err); err != nil {
+			return nil, err
+		}
 	}
 
 	out, err := json.Marshal(resp)
@@ -92,69 +236,274 @@ type ResourceHandler interface {
 // API Gateway request resources by exact name. Delegates to the resource
 // handler by name.
 //
-// Resource name must match exactly, including path parameters.
+// Resource name must match exactly, including path parameters. Path
+// parameters may declare a type, "/users/{id:int}", "/files/{path:*}", or
+// "/orders/{id:uuid}", in which case the request's path parameter value must
+// match the type, or the request fails routing with a 404 Not Found
+// response. Custom types are added with RegisterParamType. Types that are not
+// registered are used as regular expressions the value must match, e.g.
+// "/reports/{date:\d{4}-\d{2}-\d{2}}".
+//
+// Path parameter values are percent-decoded before they are converted and
+// passed to the resource handler. Encoded slashes are left encoded unless the
+// route is added with a different EncodedSlashPolicy via WithEncodedSlash.
+// Requests with invalid percent-encoded values are responded to with a 400
+// Bad Request response.
 type ServeResource struct {
-	resources map[string]ResourceHandler
+	resources  map[string]resourceRoute
+	middleware []Middleware
+	errs       registrationErrors
+
+	// DuplicatePolicy is the policy for resources added that already have
+	// a handler. Defaults to DuplicatePanic.
+	DuplicatePolicy DuplicatePolicy
+
+	// NotFoundHandler serves requests for resources without a handler.
+	// Defaults to a 404 Not Found response.
+	NotFoundHandler ResourceHandler
+}
+
+type resourceRoute struct {
+	pattern routePattern
+	options routeOptions
+	handler ResourceHandler
+
+	// serve is the handler wrapped by the router's middleware.
+	serve ResourceHandler
 }
 
 // NewServeResource initializes and returns a ServeResource that resource
 // handlers can be added to via the Handle method.
 func NewServeResource() *ServeResource {
-	return &ServeResource{resources: map[string]ResourceHandler{}}
+	return &ServeResource{resources: map[string]resourceRoute{}}
 }
 
 // ServeResource implements the ResourceHandler interface, and delegates the
-// requests to the registered handler. If no handler is found returns an error.
+// requests to the registered handler. If no handler is found, or the path
+// parameters do not match the resource's parameter types, the request is
+// served by the NotFoundHandler.
 func (s *ServeResource) ServeResource(
 	ctx context.Context, req APIGatewayProxyRequest,
 ) (resp APIGatewayProxyResponse, err error) {
-	h, ok := s.resources[req.Resource]
+	r, ok := s.resources[req.Resource]
 	if !ok {
-		return resp, fmt.Errorf("resource handler not found for %s", req.Resource)
+		return serveNotFound(ctx, s.NotFoundHandler, req)
 	}
-	return h.ServeResource(ctx, req)
+
+	req.PathParameters, err = decodePathParams(req.PathParameters, r.options.encodedSlash)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	// Values not matching their parameter's type are not a match of the
+	// resource, and served as resources without a handler are.
+	values, ok := r.pattern.convert(req.PathParameters)
+	if !ok {
+		return serveNotFound(ctx, s.NotFoundHandler, req)
+	}
+	if len(values) != 0 {
+		req.PathValues = values
+	}
+
+	ctx = withRouteTypes(ctx, r.options.types)
+	return r.serve.ServeResource(ctx, req)
 }
 
-// Handle adds a new resource handler for the resource.
-func (s *ServeResource) Handle(resource string, handler ResourceHandler) *ServeResource {
-	s.resources[resource] = handler
+// Handle adds a new resource handler for the resource, configured with the
+// route options. Panics if the resource's pattern is invalid, or its regular
+// expression parameter types fail to compile. Resources that already have a
+// handler are handled according to the DuplicatePolicy.
+func (s *ServeResource) Handle(resource string, handler ResourceHandler, opts ...RouteOption) *ServeResource {
+	pattern, err := parseRoutePattern(resource)
+	if err != nil {
+		panic(err)
+	}
+	if _, ok := s.resources[pattern.resource]; ok &&
+		!s.DuplicatePolicy.duplicate(&s.errs, "ServeResource", pattern.resource) {
+		return s
+	}
+
+	options := newRouteOptions(opts)
+	handler = options.wrap(handler)
+	s.resources[pattern.resource] = resourceRoute{
+		pattern: pattern,
+		options: options,
+		handler: handler,
+		serve:   options.chain(s.middleware, handler),
+	}
 	return s
 }
 
+// Use adds middleware applied to the handlers of all resources, including
+// resources added before Use is called. Middleware are applied in the order
+// they are added, with the first middleware the outermost, and see requests
+// after the resource's path parameters are decoded.
+func (s *ServeResource) Use(middleware ...Middleware) *ServeResource {
+	s.middleware = append(s.middleware, middleware...)
+	for k, r := range s.resources {
+		r.serve = r.options.chain(s.middleware, r.handler)
+		s.resources[k] = r
+	}
+	return s
+}
+
+// Err returns the errors of duplicate resources added with the
+// DuplicateError policy, or nil if there were none.
+func (s *ServeResource) Err() error {
+	return s.errs.err()
+}
+
 // ServeMethod is an API Gateway Proxy resource handler delegating resource
 // requests to resource handlers filtered by HTTP request method.
 type ServeMethod struct {
-	methods map[string]ResourceHandler
+	methods    map[string]methodRoute
+	middleware []Middleware
+	errs       registrationErrors
+
+	// DuplicatePolicy is the policy for methods added that already have a
+	// handler. Defaults to DuplicatePanic.
+	DuplicatePolicy DuplicatePolicy
+
+	// MethodNotAllowedHandler serves requests for methods without a
+	// handler. Defaults to a 405 Method Not Allowed response. The response
+	// has an Allow header of the methods with handlers, unless the handler
+	// sets one.
+	MethodNotAllowedHandler ResourceHandler
+}
+
+type methodRoute struct {
+	options routeOptions
+	handler ResourceHandler
+
+	// serve is the handler wrapped by the router's middleware.
+	serve ResourceHandler
 }
 
 // NewServeMethod initializes and returns a ServeMethod that HTTP methods can
 // be added to via the Handle method.
 func NewServeMethod() *ServeMethod {
-	return &ServeMethod{methods: map[string]ResourceHandler{}}
+	return &ServeMethod{methods: map[string]methodRoute{}}
 }
 
 // ServeResource implements the ResourceHandler interface, delegating resource
 // requests to the ResourceHandler associated with the HTTP request method.
+// If no handler is associated with the method the request is served by the
+// MethodNotAllowedHandler.
+//
+// CORS preflight requests served by the CORS middleware are answered with a
+// 204 No Content response, with an Allow header of the methods with
+// handlers, if no handler is associated with the OPTIONS method.
 func (s *ServeMethod) ServeResource(
 	ctx context.Context, req APIGatewayProxyRequest,
 ) (resp APIGatewayProxyResponse, err error) {
-	h, ok := s.methods[req.HTTPMethod]
+	r, ok := s.methods[req.HTTPMethod]
+	if !ok && req.HTTPMethod == http.MethodOptions && isCORSPreflight(ctx) {
+		return s.preflightResponse(), nil
+	}
 	if !ok {
-		return resp, fmt.Errorf("method handler not found for %s:%s", req.Resource, req.HTTPMethod)
+		return s.methodNotAllowed(ctx, req)
 	}
-	return h.ServeResource(ctx, req)
+
+	ctx = withRouteTypes(ctx, r.options.types)
+	return r.serve.ServeResource(ctx, req)
 }
 
-// Handle adds a new ResourceHandler associated with a HTTP request method.
-// Replaces existing methods that match.
+// Handle adds a new ResourceHandler associated with a HTTP request method,
+// configured with the route options. Methods that already have a handler are
+// handled according to the DuplicatePolicy.
 //
 // HTTP request methods are not case sensitive.
-func (s *ServeMethod) Handle(method string, handler ResourceHandler) *ServeMethod {
-	s.methods[strings.ToUpper(method)] = handler
+func (s *ServeMethod) Handle(method string, handler ResourceHandler, opts ...RouteOption) *ServeMethod {
+	method = strings.ToUpper(method)
+	if _, ok := s.methods[method]; ok &&
+		!s.DuplicatePolicy.duplicate(&s.errs, "ServeMethod", method) {
+		return s
+	}
+
+	options := newRouteOptions(opts)
+	handler = options.wrap(handler)
+	s.methods[method] = methodRoute{
+		options: options,
+		handler: handler,
+		serve:   options.chain(s.middleware, handler),
+	}
 
 	return s
 }
 
+// Use adds middleware applied to the handlers of all methods, including
+// methods added before Use is called. Middleware are applied in the order
+// they are added, with the first middleware the outermost.
+func (s *ServeMethod) Use(middleware ...Middleware) *ServeMethod {
+	s.middleware = append(s.middleware, middleware...)
+	for k, r := range s.methods {
+		r.serve = r.options.chain(s.middleware, r.handler)
+		s.methods[k] = r
+	}
+	return s
+}
+
+// preflightResponse returns the response to CORS preflight requests, with
+// the methods that have handlers.
+func (s *ServeMethod) preflightResponse() APIGatewayProxyResponse {
+	resp := NoContent()
+	resp.HTTPHeader.Set("Allow", s.allow(http.MethodOptions))
+	return resp
+}
+
+// methodNotAllowed serves the request with the MethodNotAllowedHandler,
+// adding the Allow header to its response.
+func (s *ServeMethod) methodNotAllowed(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if s.MethodNotAllowedHandler == nil {
+		resp := statusResponse(http.StatusMethodNotAllowed)
+		resp.HTTPHeader.Set("Allow", s.allow())
+		return resp, nil
+	}
+
+	resp, err := s.MethodNotAllowedHandler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	if len(resp.HTTPHeader.Get("Allow")) == 0 {
+		resp.HTTPHeader.Set("Allow", s.allow())
+	}
+	return resp, nil
+}
+
+// allow returns the Allow header value of the methods with handlers, and
+// the additional methods.
+func (s *ServeMethod) allow(additional ...string) string {
+	methods := make([]string, 0, len(s.methods)+len(additional))
+	for m := range s.methods {
+		methods = append(methods, m)
+	}
+	methods = append(methods, additional...)
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// serveNotFound serves the request with the router's not found handler, or
+// a 404 Not Found response if the router has none.
+func serveNotFound(
+	ctx context.Context, handler ResourceHandler, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if handler == nil {
+		return statusResponse(http.StatusNotFound), nil
+	}
+	return handler.ServeResource(ctx, req)
+}
+
+// Err returns the errors of duplicate methods added with the DuplicateError
+// policy, or nil if there were none.
+func (s *ServeMethod) Err() error {
+	return s.errs.err()
+}
+
 // ResourceHandlerFunc provides wrapping of a function as the ResourceHandler.
 type ResourceHandlerFunc func(context.Context, APIGatewayProxyRequest) (
 	resp APIGatewayProxyResponse, err error,
@@ -0,0 +1,154 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.jasdel.dev/aws/lambda-mux/headers"
+)
+
+// APIGatewayV2Proxy provides a Lambda Handler for proxied Lambda invokes
+// from API Gateway HTTP APIs, with the version 2.0 payload format.
+//
+// Requests are converted to APIGatewayProxyRequest, and served by the
+// Handler, so the same resource handlers, and routers, serve both REST, and
+// HTTP, APIs. The request's Resource is the path of the HTTP API route's
+// route key, e.g. "/users/{id}" of "GET /users/{id}", or the request's path
+// for the "$default" route. The original event is available to handlers via
+// APIGatewayV2RequestFromContext.
+type APIGatewayV2Proxy struct {
+	Handler ResourceHandler
+
+	// ErrorHandler optionally translates errors returned by the Handler into
+	// responses, instead of failing the Lambda invoke.
+	ErrorHandler ErrorHandlerFunc
+}
+
+type apiGatewayV2RequestKey struct{}
+
+// APIGatewayV2RequestFromContext returns the API Gateway HTTP API event of
+// the request, if the request is served by APIGatewayV2Proxy.
+func APIGatewayV2RequestFromContext(ctx context.Context) (events.APIGatewayV2HTTPRequest, bool) {
+	v, ok := ctx.Value(apiGatewayV2RequestKey{}).(events.APIGatewayV2HTTPRequest)
+	return v, ok
+}
+
+// Invoke invokes the API Gateway HTTP API call. Implements lambda's Handler
+// interface.
+//
+// Deserializes the request as an events.APIGatewayV2HTTPRequest, and
+// serializes the response as an events.APIGatewayV2HTTPResponse.
+func (p APIGatewayV2Proxy) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var event events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid lambda event, expect %T, %w", event, err)
+	}
+
+	req := proxyRequestFromV2(event)
+
+	ctx = context.WithValue(ctx, apiGatewayV2RequestKey{}, event)
+
+	ctx, done := withRequestDone(ctx)
+	defer done()
+
+	resp, err := p.Handler.ServeResource(ctx, req)
+	if err != nil {
+		if resp, err = handlerErrorResponse(ctx, p.ErrorHandler, req, err); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := json.Marshal(proxyResponseToV2(resp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T, %w", resp, err)
+	}
+
+	return out, nil
+}
+
+// proxyRequestFromV2 returns the APIGatewayProxyRequest of the API Gateway
+// HTTP API event. Query parameters of the raw query string that cannot be
+// parsed, e.g. with invalid escapes, are skipped, as API Gateway skips them
+// in the event's query string parameters, instead of failing the invoke.
+func proxyRequestFromV2(event events.APIGatewayV2HTTPRequest) APIGatewayProxyRequest {
+	query, _ := url.ParseQuery(event.RawQueryString)
+
+	method := event.RequestContext.HTTP.Method
+	resource := event.RawPath
+	if i := strings.IndexByte(event.RouteKey, ' '); i >= 0 {
+		resource = event.RouteKey[i+1:]
+	}
+
+	h := headers.FromV2(event.Headers, event.Cookies)
+
+	var req APIGatewayProxyRequest
+	req.Resource = resource
+	req.Path = event.RawPath
+	req.HTTPMethod = method
+	req.HTTPHeader = h
+	req.Headers = headers.ToSingleValue(h)
+	req.MultiValueHeaders = headers.ToMultiValue(h)
+	req.QueryStringParameters = event.QueryStringParameters
+	req.MultiValueQueryStringParameters = query
+	req.RawQuery = event.RawQueryString
+	req.PathParameters = event.PathParameters
+	req.StageVariables = event.StageVariables
+	req.Body = event.Body
+	req.IsBase64Encoded = event.IsBase64Encoded
+
+	rc := event.RequestContext
+	req.RequestContext.AccountID = rc.AccountID
+	req.RequestContext.Stage = rc.Stage
+	req.RequestContext.RequestID = rc.RequestID
+	req.RequestContext.APIID = rc.APIID
+	req.RequestContext.DomainName = rc.DomainName
+	req.RequestContext.DomainPrefix = rc.DomainPrefix
+	req.RequestContext.HTTPMethod = method
+	req.RequestContext.ResourcePath = resource
+	req.RequestContext.Protocol = rc.HTTP.Protocol
+	req.RequestContext.RequestTime = rc.Time
+	req.RequestContext.RequestTimeEpoch = rc.TimeEpoch
+	req.RequestContext.Identity.SourceIP = rc.HTTP.SourceIP
+	req.RequestContext.Identity.UserAgent = rc.HTTP.UserAgent
+
+	if rc.Authorizer != nil {
+		claims := make(map[string]interface{}, len(rc.Authorizer.JWT.Claims))
+		for k, v := range rc.Authorizer.JWT.Claims {
+			claims[k] = v
+		}
+		req.RequestContext.Authorizer = map[string]interface{}{
+			"claims": claims,
+			"scopes": rc.Authorizer.JWT.Scopes,
+		}
+	}
+
+	req.initMaps()
+	return req
+}
+
+// proxyResponseToV2 returns the API Gateway HTTP API response of the
+// APIGatewayProxyResponse. Set-Cookie headers are returned as the response's
+// cookies. The response's HTTPHeader is merged with its MultiValueHeaders,
+// and Headers, for headers not set in HTTPHeader.
+func proxyResponseToV2(resp APIGatewayProxyResponse) events.APIGatewayV2HTTPResponse {
+	merged := headers.FromEvent(resp.Headers, resp.MultiValueHeaders)
+	for k, v := range resp.HTTPHeader {
+		if len(v) != 0 {
+			merged[textproto.CanonicalMIMEHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+
+	h, cookies := headers.ToV2(merged)
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      resp.StatusCode,
+		Headers:         h,
+		Body:            resp.Body,
+		IsBase64Encoded: resp.IsBase64Encoded,
+		Cookies:         cookies,
+	}
+}
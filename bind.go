@@ -0,0 +1,182 @@
+package lambdamux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Bind decodes the request into the struct pointed to by v, by the
+// request's Content-Type. JSON bodies, "application/json" or "+json" media
+// types, are decoded with BindJSON, and URL encoded form bodies with
+// BindForm. Requests without a body are decoded from their query string
+// with BindQuery.
+//
+// Errors decoding the request are StatusErrors with messages describing the
+// invalid value, so handlers can return them to respond with a 400 Bad
+// Request, or 415 Unsupported Media Type if the body's Content-Type is not
+// supported.
+func (r APIGatewayProxyRequest) Bind(v interface{}) error {
+	if len(r.Body) == 0 {
+		return r.BindQuery(v)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.HTTPHeader.Get("Content-Type"))
+	switch {
+	case err != nil:
+		return &StatusError{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    "request Content-Type missing or invalid",
+			Err:        err,
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return r.BindJSON(v)
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.BindForm(v)
+	default:
+		return NewStatusError(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("unsupported request Content-Type %s", mediaType))
+	}
+}
+
+// BindJSON decodes the request's JSON body, decoding it first if base64
+// encoded, into v. The body must be a single JSON value. Returns a 400 Bad
+// Request StatusError describing the invalid JSON, or value, if the body
+// cannot be decoded.
+func (r APIGatewayProxyRequest) BindJSON(v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot bind to %T, expect pointer", v)
+	}
+
+	body, err := requestBody(r)
+	if err != nil {
+		return &StatusError{StatusCode: http.StatusBadRequest, Message: "invalid request body encoding", Err: err}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if err := dec.Decode(v); err != nil {
+		return &StatusError{StatusCode: http.StatusBadRequest, Message: jsonErrorMessage(err), Err: err}
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return BadRequest("invalid JSON request body, unexpected data after JSON value")
+	}
+	return nil
+}
+
+// BindForm sets the fields of the struct pointed to by v from the request's
+// URL encoded form body. Fields are named by the "form" struct tag, e.g.
+// `form:"email"`, and the "default" struct tag provides the value of fields
+// not in the form. Fields with multiple values in the form are set from the
+// comma joined values.
+//
+// Supported field types are strings, bools, integers, floats,
+// time.Duration, slices of those parsed from comma separated values, and
+// types implementing encoding.TextUnmarshaler. Returns a 400 Bad Request
+// StatusError if a value is invalid for its field, or the body is not a
+// URL encoded form.
+func (r APIGatewayProxyRequest) BindForm(v interface{}) error {
+	if _, err := bindTarget(v); err != nil {
+		return err
+	}
+
+	form, err := requestForm(r)
+	if err != nil {
+		return &StatusError{StatusCode: http.StatusBadRequest, Message: "invalid request form", Err: err}
+	}
+	return bindRequestValues(v, "form", form)
+}
+
+// BindQuery sets the fields of the struct pointed to by v from the
+// request's query string. Fields are named by the "query" struct tag, e.g.
+// `query:"limit"`, and the "default" struct tag provides the value of
+// fields not in the query. Fields with multiple values in the query are set
+// from the comma joined values.
+//
+// Supported field types are the same as BindForm. Returns a 400 Bad Request
+// StatusError if a value is invalid for its field.
+func (r APIGatewayProxyRequest) BindQuery(v interface{}) error {
+	if _, err := bindTarget(v); err != nil {
+		return err
+	}
+	return bindRequestValues(v, "query", requestQuery(r))
+}
+
+// BindPath sets the fields of the struct pointed to by v from the request's
+// path parameters. Fields are named by the "path" struct tag, e.g.
+// `path:"id"`.
+//
+// Supported field types are the same as BindForm. Returns a 400 Bad Request
+// StatusError if a value is invalid for its field.
+func (r APIGatewayProxyRequest) BindPath(v interface{}) error {
+	if _, err := bindTarget(v); err != nil {
+		return err
+	}
+
+	params := make(map[string][]string, len(r.PathParameters))
+	for k, p := range r.PathParameters {
+		params[k] = []string{p}
+	}
+	return bindRequestValues(v, "path", params)
+}
+
+// BindHeader sets the fields of the struct pointed to by v from the
+// request's headers. Fields are named by the "header" struct tag, e.g.
+// `header:"X-Request-Id"`, matched case insensitively. Fields of headers
+// with multiple values are set from the comma joined values.
+//
+// Supported field types are the same as BindForm. Returns a 400 Bad Request
+// StatusError if a value is invalid for its field.
+func (r APIGatewayProxyRequest) BindHeader(v interface{}) error {
+	if _, err := bindTarget(v); err != nil {
+		return err
+	}
+
+	err := bindValues(v, "header", func(name string) (string, bool) {
+		vs := r.HTTPHeader.Values(name)
+		return strings.Join(vs, ","), len(vs) != 0
+	})
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	return nil
+}
+
+func bindRequestValues(v interface{}, key string, values map[string][]string) error {
+	err := bindValues(v, key, func(name string) (string, bool) {
+		vs, ok := values[name]
+		return strings.Join(vs, ","), ok
+	})
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+	return nil
+}
+
+// jsonErrorMessage returns the public message describing the error decoding
+// a JSON request body.
+func jsonErrorMessage(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "request body is empty, expect JSON"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "invalid JSON request body, unexpected end of body"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("invalid JSON request body at offset %d, %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr):
+		if len(typeErr.Field) != 0 {
+			return fmt.Sprintf("invalid JSON request body, %s is a %s, expect %s",
+				typeErr.Field, typeErr.Value, typeErr.Type)
+		}
+		return fmt.Sprintf("invalid JSON request body, body is a %s, expect %s", typeErr.Value, typeErr.Type)
+	default:
+		return "invalid JSON request body, " + err.Error()
+	}
+}
@@ -0,0 +1,137 @@
+package lambdamux
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// bindValues sets the fields of the struct pointed to by v from the string
+// values returned by lookup. Fields are named by the struct tag key, with the
+// field's name used if the tag is not set, and fields tagged "-" are
+// skipped. The "default" struct tag provides the value of fields without a
+// value.
+//
+// Supported field types are strings, bools, integers, floats,
+// time.Duration, slices of those parsed from comma separated values, and
+// types implementing encoding.TextUnmarshaler.
+func bindValues(v interface{}, key string, lookup func(name string) (string, bool)) error {
+	return bindStructValues(v, key, false, lookup)
+}
+
+// bindTaggedValues sets the fields of the struct pointed to by v, as
+// bindValues does, but only the fields with the struct tag key set, so
+// untagged fields, e.g. of a JSON body, are not set from values of the same
+// name.
+func bindTaggedValues(v interface{}, key string, lookup func(name string) (string, bool)) error {
+	return bindStructValues(v, key, true, lookup)
+}
+
+func bindStructValues(v interface{}, key string, tagged bool, lookup func(name string) (string, bool)) error {
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := f.Tag.Get(key)
+		if name == "-" || (tagged && len(name) == 0) {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			if value, ok = f.Tag.Lookup("default"); !ok {
+				continue
+			}
+		}
+
+		if err := setFieldValue(rv.Field(i), value); err != nil {
+			return fmt.Errorf("invalid %s value for %s, %w", key, name, err)
+		}
+	}
+	return nil
+}
+
+// bindTarget returns the struct pointed to by v, or an error if v is not a
+// pointer to a struct.
+func bindTarget(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("cannot bind to %T, expect pointer to struct", v)
+	}
+	return rv.Elem(), nil
+}
+
+func setFieldValue(fv reflect.Value, value string) error {
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	if fv.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		var parts []string
+		if len(value) != 0 {
+			parts = strings.Split(value, ",")
+		}
+		s := reflect.MakeSlice(fv.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			if err := setFieldValue(s.Index(i), strings.TrimSpace(p)); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// BodyTransformer is the interface for transformations of a response's body
+// after the handler has returned, e.g. to convert the casing of JSON keys.
+// Transformers are expected to skip responses whose content type they do not
+// apply to.
+type BodyTransformer interface {
+	TransformBody(ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse) error
+}
+
+// BodyTransformerFunc provides wrapping of a function as the
+// BodyTransformer.
+type BodyTransformerFunc func(context.Context, APIGatewayProxyRequest, *APIGatewayProxyResponse) error
+
+// TransformBody implements the BodyTransformer interface and delegates to
+// the function.
+func (f BodyTransformerFunc) TransformBody(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	return f(ctx, req, resp)
+}
+
+// WithBodyTransforms returns a RouteOption applying the transformers, in
+// order, to the body of the route's responses.
+func WithBodyTransforms(transformers ...BodyTransformer) RouteOption {
+	return func(o *routeOptions) {
+		o.transforms = append(o.transforms, transformers...)
+	}
+}
+
+type bodyTransformHandler struct {
+	Transformers []BodyTransformer
+	Handler      ResourceHandler
+}
+
+// ResourceHandlerWithBodyTransforms provides a resource handler that applies
+// the transformers, in order, to the body of handler's responses. Responses
+// with base64 encoded bodies are not transformed.
+func ResourceHandlerWithBodyTransforms(handler ResourceHandler, transformers ...BodyTransformer) ResourceHandler {
+	return bodyTransformHandler{
+		Transformers: transformers,
+		Handler:      handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, and transforms its
+// response's body.
+func (h bodyTransformHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	resp, err = h.Handler.ServeResource(ctx, req)
+	if err != nil || resp.IsBase64Encoded || len(resp.Body) == 0 {
+		return resp, err
+	}
+
+	for _, t := range h.Transformers {
+		if err := t.TransformBody(ctx, req, &resp); err != nil {
+			return APIGatewayProxyResponse{}, fmt.Errorf("failed to transform response body, %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// responseMediaType returns the media type of the response's Content-Type
+// header.
+func responseMediaType(resp *APIGatewayProxyResponse) string {
+	mediaType, _, _ := mime.ParseMediaType(resp.HTTPHeader.Get("Content-Type"))
+	return mediaType
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// JSONKeyCase is a BodyTransformer converting the keys of JSON object
+// members in JSON responses with the Convert function. Object members are
+// written sorted by key.
+type JSONKeyCase struct {
+	Convert func(string) string
+}
+
+// SnakeCaseJSON converts the keys of JSON responses to snake_case.
+var SnakeCaseJSON = JSONKeyCase{Convert: SnakeCase}
+
+// CamelCaseJSON converts the keys of JSON responses to camelCase.
+var CamelCaseJSON = JSONKeyCase{Convert: CamelCase}
+
+// TransformBody implements the BodyTransformer interface.
+func (c JSONKeyCase) TransformBody(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	if !isJSONMediaType(responseMediaType(resp)) {
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(resp.Body))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON response body, %w", err)
+	}
+
+	b, err := json.Marshal(c.convertKeys(v))
+	if err != nil {
+		return err
+	}
+	resp.Body = string(b)
+	return nil
+}
+
+func (c JSONKeyCase) convertKeys(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(tv))
+		for k, mv := range tv {
+			m[c.Convert(k)] = c.convertKeys(mv)
+		}
+		return m
+	case []interface{}:
+		for i, lv := range tv {
+			tv[i] = c.convertKeys(lv)
+		}
+	}
+	return v
+}
+
+// SnakeCase returns the identifier in snake_case, e.g. "userID" is
+// converted to "user_id".
+func SnakeCase(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteByte('_')
+			continue
+		case unicode.IsUpper(r):
+			if i > 0 && runes[i-1] != '_' && runes[i-1] != '-' && runes[i-1] != ' ' {
+				prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+				nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+				if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+					b.WriteByte('_')
+				}
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// CamelCase returns the identifier in camelCase, e.g. "user_id" is
+// converted to "userId".
+func CamelCase(s string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range s {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			upper = b.Len() != 0
+			continue
+		case upper:
+			r = unicode.ToUpper(r)
+			upper = false
+		case i == 0:
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MinifyJSON is a BodyTransformer removing insignificant whitespace from
+// JSON responses.
+var MinifyJSON = BodyTransformerFunc(func(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	if !isJSONMediaType(responseMediaType(resp)) {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(resp.Body)); err != nil {
+		return fmt.Errorf("invalid JSON response body, %w", err)
+	}
+	resp.Body = buf.String()
+	return nil
+})
+
+var (
+	htmlCommentPattern    = regexp.MustCompile(`<!--[^\[][\s\S]*?-->`)
+	htmlRawElementPattern = regexp.MustCompile(`(?is)<(pre|textarea|script|style)\b.*?</(pre|textarea|script|style)\s*>`)
+	htmlWhitespacePattern = regexp.MustCompile(`\s{2,}`)
+)
+
+// MinifyHTML is a BodyTransformer removing comments, and collapsing runs of
+// whitespace to a single space, in HTML responses. The content of pre,
+// textarea, script, and style elements, and conditional comments, are not
+// modified.
+var MinifyHTML = BodyTransformerFunc(func(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	if responseMediaType(resp) != "text/html" {
+		return nil
+	}
+
+	doc := resp.Body
+	raw := htmlRawElementPattern.FindAllStringIndex(doc, -1)
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range raw {
+		b.WriteString(minifyHTMLText(doc[last:loc[0]]))
+		b.WriteString(doc[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(minifyHTMLText(doc[last:]))
+
+	resp.Body = b.String()
+	return nil
+})
+
+func minifyHTMLText(s string) string {
+	s = htmlCommentPattern.ReplaceAllString(s, "")
+	return htmlWhitespacePattern.ReplaceAllString(s, " ")
+}
+
+// Envelope is a BodyTransformer wrapping the body of successful JSON
+// responses in an object member, e.g. {"data": ...}. Responses with status
+// codes outside of the 2xx range are not wrapped.
+type Envelope struct {
+	// Key of the member the body is wrapped in. Defaults to "data".
+	Key string
+
+	// Meta returns additional members of the envelope, e.g. the request
+	// ID. Optional.
+	Meta func(ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse) map[string]interface{}
+}
+
+// TransformBody implements the BodyTransformer interface.
+func (e Envelope) TransformBody(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || !isJSONMediaType(responseMediaType(resp)) {
+		return nil
+	}
+
+	key := e.Key
+	if len(key) == 0 {
+		key = "data"
+	}
+
+	if !json.Valid([]byte(resp.Body)) {
+		return fmt.Errorf("invalid JSON response body")
+	}
+	envelope := map[string]interface{}{}
+	if e.Meta != nil {
+		for k, v := range e.Meta(ctx, req, resp) {
+			envelope[k] = v
+		}
+	}
+	envelope[key] = json.RawMessage(resp.Body)
+
+	b, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	resp.Body = string(b)
+	return nil
+}
@@ -0,0 +1,146 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BulkResult is the result of an item of a bulk request.
+type BulkResult struct {
+	// Index of the item in the request's array.
+	Index int `json:"index"`
+
+	// HTTP status code of the item, e.g. 201 for a created item.
+	Status int `json:"status"`
+
+	// Code, and Message, of a failed item, from the handler's Mapper.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+
+	// Result of a successful item, if any.
+	Result interface{} `json:"result,omitempty"`
+}
+
+// BulkHandler is a resource handler for bulk create, or update, endpoints,
+// receiving a JSON array of items, and processing each item separately, with
+// bounded concurrency. Responds with a 207 Multi-Status response of the
+// result of each item, in the order of the request's items:
+//
+//	{"results": [{"index": 0, "status": 201, "result": {...}},
+//	             {"index": 1, "status": 409, "code": "CONFLICT", "message": "Conflict"}]}
+//
+// Requests whose body is not a JSON array, or with too many items, are
+// responded to with a 400 Bad Request response.
+type BulkHandler struct {
+	// NewItem returns a pointer to a new value an item is decoded into, e.g.
+	// func() interface{} { return &CreateUserInput{} }. Items failing to
+	// decode have a 400 Bad Request status.
+	NewItem func() interface{}
+
+	// Process processes the decoded item, returning the item's result.
+	Process func(ctx context.Context, item interface{}) (interface{}, error)
+
+	// Mapper maps the errors of Process to the item's status, code, and
+	// message. Errors not mapped have a 500 Internal Server Error status.
+	Mapper ErrorMapper
+
+	// Status of successful items. Defaults to 200 OK.
+	Status int
+
+	// Concurrency is the maximum number of items processed concurrently.
+	// Defaults to 4.
+	Concurrency int
+
+	// MaxItems is the maximum number of items of a request. Defaults to
+	// 100.
+	MaxItems int
+}
+
+// ServeResource implements the ResourceHandler interface, processing the
+// request's items.
+func (h BulkHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	var items []json.RawMessage
+	if err := decodeRequestJSON(req, &items); err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	maxItems := h.MaxItems
+	if maxItems == 0 {
+		maxItems = 100
+	}
+	if len(items) > maxItems {
+		return newResponse(http.StatusBadRequest, "text/plain; charset=utf-8",
+			fmt.Sprintf("too many items, maximum %d", maxItems)), nil
+	}
+
+	concurrency := h.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	results := make([]BulkResult, len(items))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, raw := range items {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, raw json.RawMessage) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i] = h.process(ctx, i, raw)
+		}(i, raw)
+	}
+	wg.Wait()
+
+	body, err := json.Marshal(struct {
+		Results []BulkResult `json:"results"`
+	}{results})
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to marshal bulk results, %w", err)
+	}
+	return newResponse(http.StatusMultiStatus, "application/json", string(body)), nil
+}
+
+func (h BulkHandler) process(ctx context.Context, i int, raw json.RawMessage) BulkResult {
+	item := h.NewItem()
+	if err := json.Unmarshal(raw, item); err != nil {
+		return BulkResult{
+			Index:   i,
+			Status:  http.StatusBadRequest,
+			Message: http.StatusText(http.StatusBadRequest),
+		}
+	}
+
+	result, err := h.Process(ctx, item)
+	if err == nil {
+		status := h.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		return BulkResult{Index: i, Status: status, Result: result}
+	}
+
+	mapping := ErrorMapping{StatusCode: http.StatusInternalServerError}
+	if h.Mapper != nil {
+		if m, ok := h.Mapper.MapError(err); ok {
+			mapping = m
+		}
+	}
+	message := mapping.Message
+	if len(message) == 0 {
+		message = http.StatusText(mapping.StatusCode)
+	}
+	return BulkResult{
+		Index:   i,
+		Status:  mapping.StatusCode,
+		Code:    mapping.Code,
+		Message: message,
+	}
+}
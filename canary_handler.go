@@ -0,0 +1,82 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+)
+
+// DeploymentVariantStageVariable is the stage variable identifying the
+// deployment variant serving a request. API Gateway does not include canary
+// metadata in proxy events, so the canary settings of the stage are expected
+// to override the stage variable, e.g.
+//
+//	"canarySettings": {
+//	  "stageVariableOverrides": {"deploymentVariant": "canary"}
+//	}
+const DeploymentVariantStageVariable = "deploymentVariant"
+
+// Deployment variants of an API Gateway stage.
+const (
+	DeploymentVariantPrimary = "primary"
+	DeploymentVariantCanary  = "canary"
+)
+
+// DeploymentVariant returns the deployment variant serving the request,
+// DeploymentVariantCanary if the request's DeploymentVariantStageVariable
+// stage variable is "canary", otherwise DeploymentVariantPrimary.
+func (r APIGatewayProxyRequest) DeploymentVariant() string {
+	if r.StageVariables[DeploymentVariantStageVariable] == DeploymentVariantCanary {
+		return DeploymentVariantCanary
+	}
+	return DeploymentVariantPrimary
+}
+
+// DeploymentVariantDimension is a DimensionFunc extracting the deployment
+// variant serving the request, so canary, and primary, metrics are compared.
+func DeploymentVariantDimension(ctx context.Context, req APIGatewayProxyRequest) string {
+	return req.DeploymentVariant()
+}
+
+type deploymentVariantKey struct{}
+
+// DeploymentVariantFromContext returns the deployment variant of the request
+// set by the deployment variant middleware, or empty string if the request
+// is not served by the middleware.
+func DeploymentVariantFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(deploymentVariantKey{}).(string)
+	return v
+}
+
+type deploymentVariantHandler struct {
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithDeploymentVariant provides a resource handler that tags
+// the requests served by handler with their deployment variant. The variant
+// is passed to handler via the context, for the handler's logs, and set as
+// the response's "X-Deployment-Variant" header, for client side canary
+// analysis.
+func ResourceHandlerWithDeploymentVariant(handler ResourceHandler) ResourceHandler {
+	return deploymentVariantHandler{
+		Handler: handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, tagging the request, and
+// response, with the request's deployment variant.
+func (h deploymentVariantHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	variant := req.DeploymentVariant()
+
+	resp, err = h.Handler.ServeResource(context.WithValue(ctx, deploymentVariantKey{}, variant), req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	resp.HTTPHeader.Set("X-Deployment-Variant", variant)
+	return resp, nil
+}
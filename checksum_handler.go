@@ -0,0 +1,230 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// Checksum configures the verification of request body checksums, and the
+// digests of response bodies, for clients requiring end-to-end integrity.
+type Checksum struct {
+	// Require rejects requests with bodies without a supported checksum
+	// header with 400 Bad Request. By default only requests with checksum
+	// headers are verified.
+	Require bool
+
+	// ResponseDigests are the RFC 9530 digest algorithms, "sha-256", or
+	// "sha-512", of the Repr-Digest header added to responses. Algorithms
+	// requested by the request's Want-Repr-Digest header are added as well.
+	ResponseDigests []string
+
+	// LegacyDigest adds the RFC 3230 Digest header of the response body's
+	// SHA-256, e.g. "SHA-256=X48E9q...", alongside the Repr-Digest header,
+	// for clients not supporting RFC 9530.
+	LegacyDigest bool
+}
+
+// digestAlgorithms are the supported RFC 9530 digest algorithms.
+var digestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+type checksumHandler struct {
+	Checksum Checksum
+	Handler  ResourceHandler
+}
+
+// ResourceHandlerWithChecksum provides a resource handler verifying the
+// checksums of request bodies before serving requests with handler, and
+// adding the digests of its response bodies.
+//
+// The request body is verified against its Content-MD5, X-Amz-Content-Sha256,
+// Content-Digest, and Repr-Digest headers, if present. Requests whose body
+// does not match a checksum, or with malformed checksum headers, are
+// responded to with 400 Bad Request. X-Amz-Content-Sha256 values that are
+// not a checksum, e.g. "UNSIGNED-PAYLOAD", and unsupported digest
+// algorithms, are ignored.
+//
+// Response digests are computed over the decoded body, the representation,
+// so the middleware should be wrapped by content encoding middleware, e.g.
+// ResourceHandlerWithCompression.
+func ResourceHandlerWithChecksum(checksum Checksum, handler ResourceHandler) ResourceHandler {
+	for _, alg := range checksum.ResponseDigests {
+		if _, ok := digestAlgorithms[strings.ToLower(alg)]; !ok {
+			panic("unsupported response digest algorithm " + alg)
+		}
+	}
+
+	return checksumHandler{
+		Checksum: checksum,
+		Handler:  handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, if the request's body
+// matches its checksums, adding the digests of the response's body.
+func (h checksumHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	body, err := req.BodyBytes()
+	if err != nil {
+		return APIGatewayProxyResponse{}, BadRequest(err.Error())
+	}
+
+	verified, err := verifyBodyChecksums(req.HTTPHeader, body)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	if !verified && h.Checksum.Require && len(body) != 0 {
+		return APIGatewayProxyResponse{}, BadRequest("request body checksum required")
+	}
+
+	resp, err := h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	algs := append([]string(nil), h.Checksum.ResponseDigests...)
+	algs = append(algs, wantedDigests(req.HTTPHeader.Values("Want-Repr-Digest"))...)
+	if len(algs) == 0 && !h.Checksum.LegacyDigest {
+		return resp, nil
+	}
+
+	respBody, err := resp.BodyBytes()
+	if err != nil {
+		return resp, err
+	}
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+
+	seen := map[string]struct{}{}
+	var digests []string
+	for _, alg := range algs {
+		alg = strings.ToLower(alg)
+		if _, ok := seen[alg]; ok {
+			continue
+		}
+		seen[alg] = struct{}{}
+		digests = append(digests, alg+"=:"+base64.StdEncoding.EncodeToString(digest(alg, respBody))+":")
+	}
+	if len(digests) != 0 {
+		resp.HTTPHeader.Set("Repr-Digest", strings.Join(digests, ", "))
+	}
+	if h.Checksum.LegacyDigest {
+		resp.HTTPHeader.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(digest("sha-256", respBody)))
+	}
+	return resp, nil
+}
+
+// verifyBodyChecksums verifies the body against the checksum headers.
+// Returns if any checksum was verified, or a 400 Bad Request StatusError if
+// a checksum does not match, or is malformed.
+func verifyBodyChecksums(header http.Header, body []byte) (bool, error) {
+	var verified bool
+
+	if v := header.Get("Content-Md5"); len(v) != 0 {
+		want, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return false, BadRequest("invalid Content-MD5 header")
+		}
+		sum := md5.Sum(body)
+		if !checksumEqual(want, sum[:]) {
+			return false, BadRequest("request body does not match Content-MD5")
+		}
+		verified = true
+	}
+
+	if v := header.Get("X-Amz-Content-Sha256"); len(v) == sha256.Size*2 {
+		want, err := hex.DecodeString(v)
+		if err != nil {
+			return false, BadRequest("invalid X-Amz-Content-Sha256 header")
+		}
+		sum := sha256.Sum256(body)
+		if !checksumEqual(want, sum[:]) {
+			return false, BadRequest("request body does not match X-Amz-Content-Sha256")
+		}
+		verified = true
+	}
+
+	for _, name := range []string{"Content-Digest", "Repr-Digest"} {
+		for _, v := range header.Values(name) {
+			ok, err := verifyDigests(v, body)
+			if err != nil {
+				return false, BadRequest("request body does not match " + name + ", " + err.Error())
+			}
+			verified = verified || ok
+		}
+	}
+
+	return verified, nil
+}
+
+// verifyDigests verifies the body against the RFC 9530 digest dictionary,
+// e.g. "sha-256=:X48E9q...:". Returns if any digest was verified, or an
+// error if a supported digest does not match, or is malformed.
+func verifyDigests(dict string, body []byte) (bool, error) {
+	var verified bool
+	for _, member := range strings.Split(dict, ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(member), "=")
+		if !ok {
+			return false, fmt.Errorf("malformed digest %s", member)
+		}
+		alg = strings.ToLower(alg)
+		if _, ok := digestAlgorithms[alg]; !ok {
+			continue
+		}
+
+		if len(value) < 2 || value[0] != ':' || value[len(value)-1] != ':' {
+			return false, fmt.Errorf("malformed %s digest", alg)
+		}
+		want, err := base64.StdEncoding.DecodeString(value[1 : len(value)-1])
+		if err != nil {
+			return false, fmt.Errorf("malformed %s digest", alg)
+		}
+		if !checksumEqual(want, digest(alg, body)) {
+			return false, fmt.Errorf("%s digest mismatch", alg)
+		}
+		verified = true
+	}
+	return verified, nil
+}
+
+// wantedDigests returns the supported algorithms of the Want-Repr-Digest
+// header values, e.g. "sha-512=3, sha-256=10", with preferences greater
+// than 0.
+func wantedDigests(values []string) []string {
+	var algs []string
+	for _, v := range values {
+		for _, member := range strings.Split(v, ",") {
+			alg, pref, _ := strings.Cut(strings.TrimSpace(member), "=")
+			alg = strings.ToLower(alg)
+			if _, ok := digestAlgorithms[alg]; !ok || pref == "0" {
+				continue
+			}
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// digest returns the digest of the body with the supported algorithm.
+func digest(alg string, body []byte) []byte {
+	h := digestAlgorithms[alg]()
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+func checksumEqual(a, b []byte) bool {
+	return subtle.ConstantTimeCompare(a, b) == 1
+}
@@ -0,0 +1,138 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type cleanPathHandler struct {
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithCleanPath provides a resource handler that normalizes
+// the request's path before passing the request to handler. Duplicate
+// slashes and dot segments are removed, "//a/../b" becomes "/b", and
+// percent-encoding is normalized to upper case hex digits, with unreserved
+// characters decoded.
+//
+// Requests with paths that look like traversal attempts are responded to
+// with a 400 Bad Request response. This includes dot segments that would
+// move above the root, percent-encoded dot segments, encoded slashes or
+// backslashes, backslashes, control characters, and invalid
+// percent-encoding.
+//
+// The handler should wrap routers that match on the request's path, so
+// routing is performed on the cleaned path.
+func ResourceHandlerWithCleanPath(handler ResourceHandler) ResourceHandler {
+	return cleanPathHandler{
+		Handler: handler,
+	}
+}
+
+// ServeResource cleans the request's path, and delegates to the wrapped
+// handler.
+func (h cleanPathHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	p, ok := cleanPath(req.Path)
+	if !ok {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	req.Path = p
+	return h.Handler.ServeResource(ctx, req)
+}
+
+// cleanPath returns the cleaned path, or false if the path is suspicious.
+func cleanPath(p string) (string, bool) {
+	segments := strings.Split(p, "/")
+	cleaned := make([]string, 0, len(segments))
+
+	for _, segment := range segments {
+		s, encodedDot, ok := normalizeSegment(segment)
+		if !ok {
+			return "", false
+		}
+
+		switch s {
+		case "", ".":
+			if encodedDot {
+				return "", false
+			}
+		case "..":
+			if encodedDot || len(cleaned) == 0 {
+				return "", false
+			}
+			cleaned = cleaned[:len(cleaned)-1]
+		default:
+			cleaned = append(cleaned, s)
+		}
+	}
+
+	out := "/" + strings.Join(cleaned, "/")
+	if strings.HasSuffix(p, "/") && out != "/" {
+		out += "/"
+	}
+	return out, true
+}
+
+// normalizeSegment normalizes the percent-encoding of a path segment.
+// Returns if the segment contains a percent-encoded dot, and false if the
+// segment is invalid.
+func normalizeSegment(s string) (out string, encodedDot bool, ok bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c == '\\' {
+			return "", false, false
+		}
+		if c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return "", false, false
+		}
+		decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+		i += 2
+
+		switch {
+		case decoded == '/' || decoded == '\\' || decoded < 0x20 || decoded == 0x7f:
+			return "", false, false
+		case decoded == '.':
+			encodedDot = true
+			b.WriteByte(decoded)
+		case isUnreserved(decoded):
+			b.WriteByte(decoded)
+		default:
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i-1 : i+1]))
+		}
+	}
+
+	return b.String(), encodedDot, true
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isUnreserved returns if the character is an RFC 3986 unreserved
+// character.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
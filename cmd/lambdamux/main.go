@@ -0,0 +1,109 @@
+// Command lambdamux provides tooling for lambdamux Lambda function projects.
+//
+// Usage:
+//
+//	lambdamux new [-module path] [-function id] <dir>
+//	lambdamux openapi [-package name] [-o file] <openapi.json>
+//
+// The new command generates the skeleton of a project in the directory,
+// with a main.go, route table, example handler, tests, and SAM template.
+//
+// The openapi command generates the models, Server interface, and handler,
+// of an OpenAPI 3 JSON document, writing them to the file, or stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.jasdel.dev/aws/lambda-mux/openapi"
+	"go.jasdel.dev/aws/lambda-mux/scaffold"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "new":
+		if err := newProject(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lambdamux new: %v\n", err)
+			os.Exit(1)
+		}
+	case "openapi":
+		if err := generateOpenAPI(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "lambdamux openapi: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: lambdamux new [-module path] [-function id] <dir>")
+	fmt.Fprintln(os.Stderr, "       lambdamux openapi [-package name] [-o file] <openapi.json>")
+	os.Exit(2)
+}
+
+func newProject(args []string) error {
+	var p scaffold.Project
+
+	fs := flag.NewFlagSet("new", flag.ExitOnError)
+	fs.StringVar(&p.Module, "module", "", "Go module path of the project, defaults to the directory's name")
+	fs.StringVar(&p.Function, "function", "", "logical ID of the function in the SAM template")
+	fs.Usage = usage
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	p.Dir = fs.Arg(0)
+
+	files, err := scaffold.New(p)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Println(filepath.Join(p.Dir, f))
+	}
+
+	fmt.Printf("\nNext steps:\n\tcd %s\n\tgo mod tidy\n\tgo test ./...\n\tsam build && sam deploy --guided\n", p.Dir)
+	return nil
+}
+
+func generateOpenAPI(args []string) error {
+	var cfg openapi.Config
+	var output string
+
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	fs.StringVar(&cfg.Package, "package", "api", "package name of the generated code")
+	fs.StringVar(&output, "o", "", "file to write the generated code to, defaults to stdout")
+	fs.Usage = usage
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+
+	b, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	doc, err := openapi.Parse(b)
+	if err != nil {
+		return err
+	}
+	src, err := openapi.Generate(doc, cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(output) == 0 {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
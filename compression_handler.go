@@ -0,0 +1,210 @@
+package lambdamux
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression configures the compression of responses.
+type Compression struct {
+	// Level of gzip compression. Defaults to gzip.DefaultCompression.
+	Level int
+
+	// MinSize is the minimum size of response bodies, in bytes, that are
+	// compressed. Defaults to 1024.
+	MinSize int
+
+	// Exclude are the resources, e.g. "/events/{id}", whose responses are
+	// not compressed, e.g. responses already compressed by the handler, or
+	// too small to benefit from compression.
+	Exclude []string
+
+	// ContentTypes are the media types of compressed responses. Defaults to
+	// text media types, JSON, XML, and JavaScript. Media types ending with
+	// "/*" match all subtypes, e.g. "text/*".
+	ContentTypes []string
+}
+
+var defaultCompressionContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/problem+json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/javascript",
+	"image/svg+xml",
+}
+
+type compressionHandler struct {
+	Compression Compression
+	Handler     ResourceHandler
+
+	exclude map[string]struct{}
+}
+
+// ResourceHandlerWithCompression provides a resource handler that gzip
+// compresses the responses of handler for requests accepting gzip encoding.
+//
+// Responses of compressible media types, that are not excluded, have the
+// "Vary: Accept-Encoding" header set whether they are compressed or not, so
+// caches store the compressed, and uncompressed, representations
+// separately. Strong ETags of compressed responses are weakened, e.g.
+// `"abc"` becomes `W/"abc"`, as the compressed representation is not byte
+// identical to the one the ETag was computed for.
+//
+// Responses already content encoded, partial content responses, and
+// responses to HEAD requests are not compressed.
+func ResourceHandlerWithCompression(compression Compression, handler ResourceHandler) ResourceHandler {
+	if compression.Level == 0 {
+		compression.Level = gzip.DefaultCompression
+	}
+	if _, err := gzip.NewWriterLevel(nil, compression.Level); err != nil {
+		panic(fmt.Sprintf("invalid compression level, %v", err))
+	}
+	if compression.MinSize == 0 {
+		compression.MinSize = 1024
+	}
+	if len(compression.ContentTypes) == 0 {
+		compression.ContentTypes = defaultCompressionContentTypes
+	}
+
+	exclude := make(map[string]struct{}, len(compression.Exclude))
+	for _, resource := range compression.Exclude {
+		exclude[resource] = struct{}{}
+	}
+
+	return compressionHandler{
+		Compression: compression,
+		Handler:     handler,
+		exclude:     exclude,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, compressing the response if
+// the request accepts gzip encoding.
+func (h compressionHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	resp, err = h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if _, ok := h.exclude[req.Resource]; ok {
+		return resp, nil
+	}
+	if resp.StatusCode == http.StatusPartialContent || req.HTTPMethod == http.MethodHead {
+		return resp, nil
+	}
+	if resp.HTTPHeader == nil || len(resp.HTTPHeader.Get("Content-Encoding")) != 0 {
+		return resp, nil
+	}
+	if !h.compressible(resp.HTTPHeader.Get("Content-Type")) {
+		return resp, nil
+	}
+
+	addVary(resp.HTTPHeader, "Accept-Encoding")
+
+	if !acceptsEncoding(req.HTTPHeader, "gzip") {
+		return resp, nil
+	}
+
+	body, err := resp.BodyBytes()
+	if err != nil {
+		return resp, err
+	}
+	if len(body) < h.Compression.MinSize {
+		return resp, nil
+	}
+
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, h.Compression.Level)
+	if _, err := w.Write(body); err != nil {
+		return resp, fmt.Errorf("failed to compress response body, %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return resp, fmt.Errorf("failed to compress response body, %w", err)
+	}
+
+	resp.Body = base64.StdEncoding.EncodeToString(buf.Bytes())
+	resp.IsBase64Encoded = true
+	resp.HTTPHeader.Set("Content-Encoding", "gzip")
+	resp.HTTPHeader.Del("Content-Length")
+
+	if etag := resp.HTTPHeader.Get("ETag"); len(etag) != 0 && !strings.HasPrefix(etag, "W/") {
+		resp.HTTPHeader.Set("ETag", "W/"+etag)
+	}
+
+	return resp, nil
+}
+
+func (h compressionHandler) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range h.Compression.ContentTypes {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(mediaType, t[:len(t)-1]) {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding returns if the request's Accept-Encoding header accepts the
+// content coding, either by name or by the "*" wildcard, with a non-zero
+// quality value.
+func acceptsEncoding(header http.Header, coding string) bool {
+	accepted := false
+	for _, v := range header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			params := strings.Split(part, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			if name != coding && name != "*" {
+				continue
+			}
+
+			q := 1.0
+			for _, p := range params[1:] {
+				p = strings.TrimSpace(p)
+				if strings.HasPrefix(p, "q=") {
+					if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+						q = f
+					}
+				}
+			}
+
+			// An explicit coding takes precedence over the wildcard.
+			if name == coding {
+				return q > 0
+			}
+			accepted = q > 0
+		}
+	}
+	return accepted
+}
+
+// addVary adds the header name to the Vary header, if not already present.
+func addVary(header http.Header, name string) {
+	for _, v := range header.Values("Vary") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "*" || strings.EqualFold(part, name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
@@ -0,0 +1,199 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrPreconditionFailed is returned, or wrapped, by handlers served by the
+// conditional write middleware when the resource's current version does not
+// match the request's precondition, e.g. a DynamoDB conditional check
+// failing. The middleware responds with a 412 Precondition Failed response.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// WritePrecondition is the precondition of a conditional write request, from
+// its If-Match, and If-Unmodified-Since, headers.
+type WritePrecondition struct {
+	// Versions are the opaque values of the If-Match entity tags, without
+	// quotes, e.g. "v3" for If-Match: "v3". Weak entity tags are not
+	// included, as they never match a write precondition.
+	Versions []string
+
+	// Any is if the request's If-Match is "*", matching any current version
+	// of the resource, but not a resource that does not exist.
+	Any bool
+
+	// UnmodifiedSince is the time of the request's If-Unmodified-Since
+	// header, or zero if the request has none, or has an If-Match header.
+	UnmodifiedSince time.Time
+}
+
+// Version returns the first version of the If-Match header, the version
+// token handlers update the resource conditionally on, e.g. a DynamoDB
+// condition expression on the item's version attribute.
+func (p WritePrecondition) Version() string {
+	if len(p.Versions) == 0 {
+		return ""
+	}
+	return p.Versions[0]
+}
+
+// Matches returns if the precondition is met by the resource's current
+// version, and last modified time. Zero values are ignored by the
+// precondition that would use them.
+func (p WritePrecondition) Matches(version string, modified time.Time) bool {
+	if p.Any {
+		return true
+	}
+	if len(p.Versions) != 0 {
+		for _, v := range p.Versions {
+			if v == version {
+				return true
+			}
+		}
+		return false
+	}
+	if !p.UnmodifiedSince.IsZero() && !modified.IsZero() {
+		return !modified.Truncate(time.Second).After(p.UnmodifiedSince)
+	}
+	return true
+}
+
+// VersionETag returns the strong entity tag of the version, for the ETag
+// header of responses, so clients can make conditional writes with it.
+func VersionETag(version string) string {
+	return `"` + version + `"`
+}
+
+// requestWritePrecondition returns the precondition of the request's
+// headers, and false if the request has none. Per RFC 7232,
+// If-Unmodified-Since is ignored if the request has an If-Match header.
+func requestWritePrecondition(header http.Header) (WritePrecondition, bool) {
+	var p WritePrecondition
+
+	if ifMatch := header.Values("If-Match"); len(ifMatch) != 0 {
+		for _, v := range strings.Split(strings.Join(ifMatch, ","), ",") {
+			v = strings.TrimSpace(v)
+			switch {
+			case v == "*":
+				p.Any = true
+			case len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"':
+				p.Versions = append(p.Versions, v[1:len(v)-1])
+			}
+		}
+		return p, true
+	}
+
+	if v := header.Get("If-Unmodified-Since"); len(v) != 0 {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			// Invalid dates are ignored, per RFC 7232.
+			return p, false
+		}
+		p.UnmodifiedSince = t
+		return p, true
+	}
+
+	return p, false
+}
+
+type writePreconditionKey struct{}
+
+// WritePreconditionFromContext returns the precondition of the request
+// served by the conditional write middleware, and false if the request has
+// no precondition.
+func WritePreconditionFromContext(ctx context.Context) (WritePrecondition, bool) {
+	v, ok := ctx.Value(writePreconditionKey{}).(WritePrecondition)
+	return v, ok
+}
+
+// ConditionalWrite is the configuration of the conditional write
+// middleware.
+type ConditionalWrite struct {
+	// Methods are the request methods the preconditions are applied to.
+	// Defaults to PUT, and PATCH.
+	Methods []string
+
+	// Required is if requests must have a precondition. Requests without one
+	// are responded to with a 428 Precondition Required response, so clients
+	// cannot overwrite changes they have not seen.
+	Required bool
+
+	// Current optionally returns the current version, and last modified
+	// time, of the request's resource, so the precondition is checked
+	// before the handler is called. Returns ErrObjectNotFound, or an error
+	// wrapping it, if the resource does not exist.
+	Current func(ctx context.Context, req APIGatewayProxyRequest) (version string, modified time.Time, err error)
+}
+
+type conditionalWriteHandler struct {
+	Config  ConditionalWrite
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithConditionalWrite provides a resource handler for
+// optimistic concurrency of REST updates. The If-Match, and
+// If-Unmodified-Since, headers of update requests are translated into a
+// WritePrecondition available to handler via WritePreconditionFromContext.
+//
+// Requests whose precondition is not met, either by the resource's version
+// returned by the config's Current, or by handler returning an error
+// wrapping ErrPreconditionFailed, are responded to with a 412 Precondition
+// Failed response.
+func ResourceHandlerWithConditionalWrite(cfg ConditionalWrite, handler ResourceHandler) ResourceHandler {
+	if len(cfg.Methods) == 0 {
+		cfg.Methods = []string{http.MethodPut, http.MethodPatch}
+	}
+
+	return conditionalWriteHandler{
+		Config:  cfg,
+		Handler: handler,
+	}
+}
+
+// ServeResource checks the request's write precondition, and delegates to
+// the wrapped handler.
+func (h conditionalWriteHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	var conditional bool
+	for _, m := range h.Config.Methods {
+		if strings.EqualFold(m, req.HTTPMethod) {
+			conditional = true
+			break
+		}
+	}
+	if !conditional {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	precondition, ok := requestWritePrecondition(req.HTTPHeader)
+	if !ok {
+		if h.Config.Required {
+			return statusResponse(http.StatusPreconditionRequired), nil
+		}
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	if h.Config.Current != nil {
+		version, modified, err := h.Config.Current(ctx, req)
+		if errors.Is(err, ErrObjectNotFound) {
+			return statusResponse(http.StatusPreconditionFailed), nil
+		} else if err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+		if !precondition.Matches(version, modified) {
+			return statusResponse(http.StatusPreconditionFailed), nil
+		}
+	}
+
+	ctx = context.WithValue(ctx, writePreconditionKey{}, precondition)
+	resp, err := h.Handler.ServeResource(ctx, req)
+	if errors.Is(err, ErrPreconditionFailed) {
+		return statusResponse(http.StatusPreconditionFailed), nil
+	}
+	return resp, err
+}
@@ -0,0 +1,61 @@
+package lambdamux
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"go.jasdel.dev/aws/lambda-mux/headers"
+)
+
+// normalizeConsoleEvent returns the payload with the body of sample events
+// that have a JSON object, or array, body, instead of a string, converted to
+// a string, as commonly written in Lambda console test events.
+func normalizeConsoleEvent(payload []byte) ([]byte, error) {
+	var event map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+
+	body, ok := event["body"]
+	if !ok || len(body) == 0 || (body[0] != '{' && body[0] != '[') {
+		return payload, nil
+	}
+
+	b, err := json.Marshal(string(body))
+	if err != nil {
+		return nil, err
+	}
+	event["body"] = b
+	return json.Marshal(event)
+}
+
+// fillMultiValueMaps fills the request's multi value headers, and query
+// string parameters, from their single value maps when the event does not
+// provide them, e.g. Lambda console test events.
+func (r *APIGatewayProxyRequest) fillMultiValueMaps() {
+	if len(r.MultiValueHeaders) == 0 && len(r.Headers) != 0 {
+		r.MultiValueHeaders = headers.ToMultiValue(headers.FromSingleValue(r.Headers))
+		r.HTTPHeader = headers.FromMultiValue(r.MultiValueHeaders)
+	}
+
+	if len(r.MultiValueQueryStringParameters) == 0 && len(r.QueryStringParameters) != 0 {
+		query := url.Values{}
+		for k, v := range r.QueryStringParameters {
+			query.Set(k, v)
+		}
+		r.MultiValueQueryStringParameters = query
+	}
+}
+
+// checkMultiValueMaps returns an error if the event provides single value
+// headers, or query string parameters, without their multi value maps.
+func (r *APIGatewayProxyRequest) checkMultiValueMaps() error {
+	if len(r.MultiValueHeaders) == 0 && len(r.Headers) != 0 {
+		return fmt.Errorf("invalid lambda event, headers without multiValueHeaders")
+	}
+	if len(r.MultiValueQueryStringParameters) == 0 && len(r.QueryStringParameters) != 0 {
+		return fmt.Errorf("invalid lambda event, queryStringParameters without multiValueQueryStringParameters")
+	}
+	return nil
+}
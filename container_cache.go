@@ -0,0 +1,187 @@
+package lambdamux
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// ContainerCacheConfig configures a ContainerCache.
+type ContainerCacheConfig struct {
+	// TTL is the duration entries are cached for after being set. Zero
+	// caches entries until evicted.
+	TTL time.Duration
+
+	// MaxEntries is the maximum number of entries cached, evicting the least
+	// recently used entry when exceeded. Zero does not bound the entries.
+	MaxEntries int
+
+	// Name is the prefix of the custom metrics of the cache's lookups,
+	// "<Name>CacheHit", and "<Name>CacheMiss", added to the request's
+	// metrics, as AddMetric does. Empty records no metrics.
+	Name string
+}
+
+// CacheStats are the counts of a ContainerCache's lookups, and evictions,
+// across the container's lifetime.
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Expired   int64
+}
+
+// ContainerCache is an in-memory cache of values reused across the
+// invokes served by a Lambda container, e.g. configuration, compiled
+// regular expressions, or tokens, bounded by TTL, and number of entries,
+// instead of ad-hoc package level maps that grow for the container's
+// lifetime. The cache is lost when the container is shutdown, so it must
+// only cache values that can be loaded again.
+//
+// A ContainerCache is safe for concurrent use.
+type ContainerCache[T any] struct {
+	cfg ContainerCacheConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	stats   CacheStats
+}
+
+type containerCacheEntry[T any] struct {
+	key     string
+	value   T
+	expires time.Time
+}
+
+// NewContainerCache returns an initialized ContainerCache configured by
+// cfg, typically assigned to a package level variable, or a field of the
+// application's handler initialized once per container.
+func NewContainerCache[T any](cfg ContainerCacheConfig) *ContainerCache[T] {
+	return &ContainerCache[T]{
+		cfg:     cfg,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// Get returns the key's cached value, and true, or false if the key is not
+// cached, or its entry expired. The lookup is added to the request's
+// metrics, if the cache is named.
+func (c *ContainerCache[T]) Get(ctx context.Context, key string) (T, bool) {
+	v, ok := c.get(key)
+	if len(c.cfg.Name) != 0 {
+		if ok {
+			AddMetric(ctx, c.cfg.Name+"CacheHit", 1)
+		} else {
+			AddMetric(ctx, c.cfg.Name+"CacheMiss", 1)
+		}
+	}
+	return v, ok
+}
+
+func (c *ContainerCache[T]) get(key string) (v T, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return v, false
+	}
+
+	entry := elem.Value.(*containerCacheEntry[T])
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.remove(elem)
+		c.stats.Expired++
+		c.stats.Misses++
+		return v, false
+	}
+
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return entry.value, true
+}
+
+// Set caches the key's value, replacing its entry if cached, and evicting
+// the least recently used entry if the cache is full.
+func (c *ContainerCache[T]) Set(key string, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.cfg.TTL > 0 {
+		expires = time.Now().Add(c.cfg.TTL)
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*containerCacheEntry[T])
+		entry.value, entry.expires = value, expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&containerCacheEntry[T]{
+		key:     key,
+		value:   value,
+		expires: expires,
+	})
+	for c.cfg.MaxEntries > 0 && c.lru.Len() > c.cfg.MaxEntries {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// GetOrLoad returns the key's cached value, or the value loaded by load,
+// caching it. Errors loading the value are returned, and not cached, so the
+// value is loaded again by the next lookup. Concurrent lookups of a key not
+// cached may each load the value.
+func (c *ContainerCache[T]) GetOrLoad(
+	ctx context.Context, key string, load func(context.Context) (T, error),
+) (T, error) {
+	if v, ok := c.Get(ctx, key); ok {
+		return v, nil
+	}
+
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	c.Set(key, v)
+	return v, nil
+}
+
+// Delete removes the key's entry from the cache, if cached.
+func (c *ContainerCache[T]) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the number of entries cached, including expired entries not
+// yet removed.
+func (c *ContainerCache[T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+// Stats returns the counts of the cache's lookups, and evictions.
+func (c *ContainerCache[T]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// remove removes the entry's element from the cache. Must be called with
+// the cache's lock held.
+func (c *ContainerCache[T]) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*containerCacheEntry[T]).key)
+}
@@ -0,0 +1,77 @@
+package lambdamux
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type contentTypeHandler struct {
+	ContentTypes []string
+	Handler      ResourceHandler
+}
+
+// ResourceHandlerWithContentTypes provides a resource handler that only
+// accepts requests whose body has one of the media types provided. Media
+// types may use a subtype wildcard, e.g. "text/*". Requests with a body of
+// any other media type are responded to with a 415 Unsupported Media Type
+// response, with the Accept-Post or Accept-Patch header listing the accepted
+// types for POST and PATCH requests.
+//
+// Requests without a body are not restricted.
+func ResourceHandlerWithContentTypes(contentTypes []string, handler ResourceHandler) ResourceHandler {
+	types := make([]string, 0, len(contentTypes))
+	for _, t := range contentTypes {
+		types = append(types, strings.ToLower(t))
+	}
+
+	return contentTypeHandler{
+		ContentTypes: types,
+		Handler:      handler,
+	}
+}
+
+// ServeResource validates the request's content type before delegating to
+// the wrapped handler.
+func (h contentTypeHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	if len(req.Body) == 0 || h.accepts(req.HTTPHeader.Get("Content-Type")) {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	resp = statusResponse(http.StatusUnsupportedMediaType)
+	switch strings.ToUpper(req.HTTPMethod) {
+	case http.MethodPost:
+		resp.HTTPHeader.Set("Accept-Post", strings.Join(h.ContentTypes, ", "))
+	case http.MethodPatch:
+		resp.HTTPHeader.Set("Accept-Patch", strings.Join(h.ContentTypes, ", "))
+	}
+
+	return resp, nil
+}
+
+func (h contentTypeHandler) accepts(contentType string) bool {
+	return matchMediaType(h.ContentTypes, contentType)
+}
+
+// matchMediaType returns if the media type of the content type matches one
+// of the media types, which may be wildcards, e.g. "image/*", or "*/*".
+func matchMediaType(mediaTypes []string, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, t := range mediaTypes {
+		if t == mediaType || t == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") &&
+			strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
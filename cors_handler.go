@@ -0,0 +1,164 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS is the configuration of the CORS middleware.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests,
+	// e.g. "https://app.example.com", or "*" for any origin.
+	AllowedOrigins []string
+
+	// AllowOrigin optionally returns if the origin is allowed, in addition
+	// to AllowedOrigins, e.g. for preview deployment subdomains.
+	AllowOrigin func(origin string) bool
+
+	// AllowedMethods are the methods allowed in cross-origin requests.
+	// Defaults to the methods of the resource's ServeMethod for preflight
+	// requests answered by the ServeMethod.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. Defaults to the headers requested by the preflight request.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers exposed to the origin, in
+	// addition to the CORS safelisted response headers.
+	ExposedHeaders []string
+
+	// AllowCredentials is if requests with credentials, e.g. cookies, are
+	// allowed. The origin is reflected in responses instead of "*" if set.
+	AllowCredentials bool
+
+	// MaxAge is how long preflight responses may be cached. Not sent if
+	// zero.
+	MaxAge time.Duration
+}
+
+func (c CORS) allowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return c.AllowOrigin != nil && c.AllowOrigin(origin)
+}
+
+func (c CORS) anyOrigin() bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type corsPreflightKey struct{}
+
+// isCORSPreflight returns if the context is of a CORS preflight request
+// served by the CORS middleware, which ServeMethod answers if it has no
+// OPTIONS handler.
+func isCORSPreflight(ctx context.Context) bool {
+	v, _ := ctx.Value(corsPreflightKey{}).(bool)
+	return v
+}
+
+// isCORSPreflightRequest returns if the request is a CORS preflight request.
+func isCORSPreflightRequest(req APIGatewayProxyRequest) bool {
+	return req.HTTPMethod == http.MethodOptions &&
+		len(req.HTTPHeader.Get("Origin")) != 0 &&
+		len(req.HTTPHeader.Get("Access-Control-Request-Method")) != 0
+}
+
+type corsHandler struct {
+	Config  CORS
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithCORS provides a resource handler that adds the CORS
+// headers of the config to the responses of handler for cross-origin
+// requests from allowed origins.
+//
+// Preflight requests are passed to handler, so resources may answer them
+// explicitly. A ServeMethod without an OPTIONS handler answers preflight
+// requests served by the middleware with a 204 No Content response, allowing
+// the methods it has handlers for.
+func ResourceHandlerWithCORS(cfg CORS, handler ResourceHandler) ResourceHandler {
+	return corsHandler{
+		Config:  cfg,
+		Handler: handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, and adds the CORS headers
+// to its response.
+func (h corsHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	origin := req.HTTPHeader.Get("Origin")
+	if len(origin) == 0 {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	preflight := isCORSPreflightRequest(req)
+	if preflight {
+		ctx = context.WithValue(ctx, corsPreflightKey{}, true)
+	}
+
+	resp, err := h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+
+	cfg := h.Config
+	if !cfg.anyOrigin() || cfg.AllowCredentials {
+		addVary(resp.HTTPHeader, "Origin")
+	}
+	if !cfg.allowed(origin) {
+		return resp, nil
+	}
+
+	header := resp.HTTPHeader
+	if cfg.anyOrigin() && !cfg.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cfg.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(cfg.ExposedHeaders) != 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cfg.ExposedHeaders, ", "))
+		}
+		return resp, nil
+	}
+
+	if len(cfg.AllowedMethods) != 0 {
+		header.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+	} else if allow := header.Get("Allow"); len(allow) != 0 {
+		header.Set("Access-Control-Allow-Methods", allow)
+	}
+
+	if len(cfg.AllowedHeaders) != 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+	} else if requested := req.HTTPHeader.Get("Access-Control-Request-Headers"); len(requested) != 0 {
+		header.Set("Access-Control-Allow-Headers", requested)
+		addVary(header, "Access-Control-Request-Headers")
+	}
+
+	if cfg.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge/time.Second)))
+	}
+
+	return resp, nil
+}
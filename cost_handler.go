@@ -0,0 +1,118 @@
+package lambdamux
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// Default prices, in USD, of the us-east-1 region for x86 functions, and
+// REST APIs.
+const (
+	DefaultPricePerGBSecond          = 0.0000166667
+	DefaultPricePerInvoke            = 0.0000002
+	DefaultAPIGatewayPricePerRequest = 0.0000035
+	DefaultHTTPAPIPricePerRequest    = 0.000001
+)
+
+// Cost configures the estimation of the cost of requests.
+type Cost struct {
+	// MemoryMB is the function's configured memory. Defaults to the
+	// function's memory limit provided by the Lambda runtime, or 128 if
+	// unknown.
+	MemoryMB int
+
+	// PricePerGBSecond is the price of a GB-second of duration. Defaults to
+	// DefaultPricePerGBSecond. Set the price of arm64 functions, or other
+	// regions, explicitly.
+	PricePerGBSecond float64
+
+	// PricePerInvoke is the price of a Lambda invoke. Defaults to
+	// DefaultPricePerInvoke.
+	PricePerInvoke float64
+
+	// APIGatewayPricePerRequest is the price of the API Gateway request.
+	// Defaults to DefaultAPIGatewayPricePerRequest, the price of REST API
+	// requests. Set DefaultHTTPAPIPricePerRequest for HTTP APIs, or a
+	// negative price for invokes without API Gateway, e.g. function URLs.
+	APIGatewayPricePerRequest float64
+
+	// MetricName is the name of the custom metric the estimated cost is
+	// added to. Defaults to "EstimatedCost".
+	MetricName string
+}
+
+type costHandler struct {
+	Cost    Cost
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithCost provides a resource handler estimating the cost,
+// in USD, of each request served by handler, the request's duration
+// rounded up to the millisecond, as Lambda bills it, times the function's
+// memory, plus the price of the invoke, and the API Gateway request.
+//
+// The estimate is added to the request's custom metric, as AddMetric does,
+// so it is recorded by the route's metrics, when the handler is wrapped by
+// ResourceHandlerWithMetrics, for chargeback of the function's cost per
+// endpoint. Durations outside of the handler, e.g. the function's init, and
+// the proxy's event decoding, are not included.
+func ResourceHandlerWithCost(cost Cost, handler ResourceHandler) ResourceHandler {
+	return costHandler{
+		Cost:    cost.withDefaults(),
+		Handler: handler,
+	}
+}
+
+// withDefaults returns the cost with the defaults of its unset fields.
+func (c Cost) withDefaults() Cost {
+	if c.MemoryMB == 0 {
+		c.MemoryMB = lambdacontext.MemoryLimitInMB
+	}
+	if c.MemoryMB == 0 {
+		c.MemoryMB = 128
+	}
+	if c.PricePerGBSecond == 0 {
+		c.PricePerGBSecond = DefaultPricePerGBSecond
+	}
+	if c.PricePerInvoke == 0 {
+		c.PricePerInvoke = DefaultPricePerInvoke
+	}
+	if c.APIGatewayPricePerRequest == 0 {
+		c.APIGatewayPricePerRequest = DefaultAPIGatewayPricePerRequest
+	}
+	if len(c.MetricName) == 0 {
+		c.MetricName = "EstimatedCost"
+	}
+	return c
+}
+
+// ServeResource delegates to the wrapped handler, adding the estimated cost
+// of the request to its metrics.
+func (h costHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	start := time.Now()
+	resp, err := h.Handler.ServeResource(ctx, req)
+	AddMetric(ctx, h.Cost.MetricName, h.Cost.Estimate(time.Since(start)))
+	return resp, err
+}
+
+// Estimate returns the estimated cost, in USD, of a request with the
+// duration, with the defaults of the cost's unset fields.
+func (c Cost) Estimate(d time.Duration) float64 {
+	c = c.withDefaults()
+
+	billed := (d + time.Millisecond - 1) / time.Millisecond
+	if billed < 1 {
+		billed = 1
+	}
+
+	gbSeconds := float64(billed) / 1000 * float64(c.MemoryMB) / 1024
+	estimate := gbSeconds*c.PricePerGBSecond + c.PricePerInvoke
+	if c.APIGatewayPricePerRequest > 0 {
+		estimate += c.APIGatewayPricePerRequest
+	}
+	return estimate
+}
@@ -0,0 +1,120 @@
+package lambdamux
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CSVEncoder writes rows as text/csv to an underlying writer, e.g. a buffer
+// for a buffered response, or a ResponseStream for a streamed response.
+type CSVEncoder struct {
+	// Header is the header row written before the first row, or by Flush if
+	// no rows are written. No header row is written if empty.
+	Header []string
+
+	// EscapeFormulas prefixes fields starting with "=", "+", "-", "@", tab,
+	// or carriage return with a single quote, so spreadsheet applications
+	// opening the CSV do not evaluate them as formulas.
+	EscapeFormulas bool
+
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSVEncoder returns a CSVEncoder writing to w, with the header row.
+func NewCSVEncoder(w io.Writer, header []string) *CSVEncoder {
+	return &CSVEncoder{
+		Header: header,
+		w:      csv.NewWriter(w),
+	}
+}
+
+// Encode writes the row, writing the header row first if not already
+// written. Fields are quoted as needed.
+func (e *CSVEncoder) Encode(row []string) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.w.Write(e.escape(row))
+}
+
+// Flush writes the buffered rows, and the header row if not already written,
+// to the underlying writer.
+func (e *CSVEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *CSVEncoder) writeHeader() error {
+	if e.wroteHeader || len(e.Header) == 0 {
+		return nil
+	}
+	e.wroteHeader = true
+	return e.w.Write(e.escape(e.Header))
+}
+
+func (e *CSVEncoder) escape(row []string) []string {
+	if !e.EscapeFormulas {
+		return row
+	}
+
+	escaped := row
+	for i, field := range row {
+		if len(field) == 0 || !strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+			continue
+		}
+		if &escaped[0] == &row[0] {
+			escaped = append([]string(nil), row...)
+		}
+		escaped[i] = "'" + field
+	}
+	return escaped
+}
+
+// CSVResponse returns a text/csv response of the header row, and the rows
+// encoded by the rows callback. The callback calls encode for each row,
+// stopping on the first error. If filename is not empty the response is
+// sent as an attachment with the filename.
+func CSVResponse(
+	filename string, header []string,
+	rows func(encode func(row []string) error) error,
+) (APIGatewayProxyResponse, error) {
+	var buf bytes.Buffer
+	enc := NewCSVEncoder(&buf, header)
+	enc.EscapeFormulas = true
+
+	if err := rows(enc.Encode); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	if err := enc.Flush(); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	return exportResponse("text/csv; charset=utf-8", filename, buf.String()), nil
+}
+
+// exportResponse returns a 200 OK response of the body with the content
+// type, sent as an attachment if filename is not empty.
+func exportResponse(contentType, filename, body string) APIGatewayProxyResponse {
+	resp := APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       body,
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{contentType},
+		},
+	}
+	if len(filename) != 0 {
+		resp.HTTPHeader.Set("Content-Disposition", contentDisposition(filename))
+	}
+	return resp
+}
@@ -0,0 +1,152 @@
+package lambdamux
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrCursorVersion is returned when decoding a cursor encoded with a
+// different version of the cursor codec, e.g. by a previous deployment whose
+// cursor struct changed. Handlers may restart from the first page.
+var ErrCursorVersion = errors.New("cursor version mismatch")
+
+// CursorCodec encodes pagination cursors, e.g. a DynamoDB LastEvaluatedKey,
+// or a query's offset, as opaque URL safe strings, and decodes them back.
+//
+// Cursors are the JSON encoding of the cursor value, with the codec's
+// version, as base64. If the codec has a Sealer, cursors are encrypted, so
+// clients cannot read them, otherwise if the codec has a Secret, cursors are
+// signed, so clients cannot modify them.
+type CursorCodec struct {
+	// Version of the cursors encoded. Cursors of another version fail to
+	// decode with ErrCursorVersion. Incremented when the cursor value's
+	// struct changes incompatibly.
+	Version int
+
+	// Sealer optionally encrypts cursors.
+	Sealer *StateSealer
+
+	// Secret optionally signs cursors with HMAC-SHA256, if the codec has no
+	// Sealer.
+	Secret []byte
+
+	// Param is the query parameter of the cursor in requests. Defaults to
+	// "cursor".
+	Param string
+}
+
+type cursorEnvelope struct {
+	Version int             `json:"v"`
+	Value   json.RawMessage `json:"c"`
+}
+
+// cursorAdditionalData is the additional data cursors are sealed with, so
+// other state sealed with the same keys is not accepted as a cursor.
+var cursorAdditionalData = []byte("lambdamux-cursor")
+
+// Encode returns the cursor of the value.
+func (c CursorCodec) Encode(v interface{}) (string, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor, %w", err)
+	}
+	b, err := json.Marshal(cursorEnvelope{Version: c.Version, Value: value})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor, %w", err)
+	}
+
+	if c.Sealer != nil {
+		return c.Sealer.Seal(b, cursorAdditionalData)
+	}
+
+	cursor := base64.RawURLEncoding.EncodeToString(b)
+	if len(c.Secret) != 0 {
+		cursor += "." + c.signature(cursor)
+	}
+	return cursor, nil
+}
+
+// Decode decodes the cursor into v. Returns ErrCursorVersion if the cursor
+// was encoded with another version, or an error if the cursor is invalid.
+func (c CursorCodec) Decode(cursor string, v interface{}) error {
+	var b []byte
+	var err error
+	if c.Sealer != nil {
+		b, err = c.Sealer.Open(cursor, cursorAdditionalData)
+		if err != nil {
+			return fmt.Errorf("invalid cursor, %w", err)
+		}
+	} else {
+		if len(c.Secret) != 0 {
+			i := strings.LastIndexByte(cursor, '.')
+			if i < 0 || !hmac.Equal([]byte(cursor[i+1:]), []byte(c.signature(cursor[:i]))) {
+				return fmt.Errorf("invalid cursor signature")
+			}
+			cursor = cursor[:i]
+		}
+		b, err = base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return fmt.Errorf("invalid cursor, %w", err)
+		}
+	}
+
+	var envelope cursorEnvelope
+	if err := json.Unmarshal(b, &envelope); err != nil {
+		return fmt.Errorf("invalid cursor, %w", err)
+	}
+	if envelope.Version != c.Version {
+		return ErrCursorVersion
+	}
+	if err := json.Unmarshal(envelope.Value, v); err != nil {
+		return fmt.Errorf("invalid cursor, %w", err)
+	}
+	return nil
+}
+
+// FromRequest decodes the request's cursor query parameter into v. Returns
+// false if the request has no cursor, e.g. for the first page.
+func (c CursorCodec) FromRequest(req APIGatewayProxyRequest, v interface{}) (bool, error) {
+	cursor := requestQuery(req).Get(c.param())
+	if len(cursor) == 0 {
+		return false, nil
+	}
+	if err := c.Decode(cursor, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// NextURL returns the URL of the request's next page, the request's path,
+// and query parameters, with the cursor query parameter set to the cursor
+// of the value.
+func (c CursorCodec) NextURL(req APIGatewayProxyRequest, v interface{}) (string, error) {
+	cursor, err := c.Encode(v)
+	if err != nil {
+		return "", err
+	}
+
+	query := requestQuery(req)
+	query.Set(c.param(), cursor)
+
+	u := url.URL{Path: req.Path, RawQuery: query.Encode()}
+	return u.String(), nil
+}
+
+func (c CursorCodec) signature(cursor string) string {
+	mac := hmac.New(sha256.New, c.Secret)
+	mac.Write([]byte(cursor))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (c CursorCodec) param() string {
+	if len(c.Param) == 0 {
+		return "cursor"
+	}
+	return c.Param
+}
@@ -0,0 +1,97 @@
+package lambdamux
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy is the policy of a router for a route added via its Handle
+// method when a route already exists for the same resource, method, or
+// query constraint.
+type DuplicatePolicy int
+
+const (
+	// DuplicatePanic panics when a duplicate route is added, so that
+	// conflicting registrations fail at startup. This is the default
+	// policy.
+	DuplicatePanic DuplicatePolicy = iota
+
+	// DuplicateReplace replaces the existing route with the duplicate.
+	DuplicateReplace
+
+	// DuplicateError keeps the existing route, and records the duplicate
+	// as an error returned by the router's Err method.
+	DuplicateError
+)
+
+// DuplicateRouteError is the error of a duplicate route added to a router.
+type DuplicateRouteError struct {
+	// Router the route was added to, e.g. "ServeResource".
+	Router string
+
+	// Route that was duplicated, e.g. the resource, or HTTP method.
+	Route string
+}
+
+func (e *DuplicateRouteError) Error() string {
+	return fmt.Sprintf("%s duplicate route %s", e.Router, e.Route)
+}
+
+// registrationErrors are the errors recorded by a router when routes are
+// added with the DuplicateError policy. Each of the errors is found by
+// errors.Is, and errors.As, e.g. a *DuplicateRouteError.
+type registrationErrors []error
+
+func (errs registrationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is returns if any of the recorded errors match the target, as errors.Is
+// does.
+func (errs registrationErrors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the recorded errors matching the target, and sets
+// the target to it, as errors.As does.
+func (errs registrationErrors) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// err returns the recorded errors, or nil if there are none.
+func (errs registrationErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// duplicate applies the policy to the duplicate route, returning if the
+// existing route should be replaced.
+func (p DuplicatePolicy) duplicate(errs *registrationErrors, router, route string) bool {
+	err := &DuplicateRouteError{Router: router, Route: route}
+	switch p {
+	case DuplicateReplace:
+		return true
+	case DuplicateError:
+		*errs = append(*errs, err)
+		return false
+	default:
+		panic(err.Error())
+	}
+}
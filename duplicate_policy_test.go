@@ -0,0 +1,77 @@
+package lambdamux
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	handler := ResourceHandlerFunc(nil)
+
+	cases := map[string]struct {
+		policy      DuplicatePolicy
+		duplicates  int
+		expectPanic bool
+		expectErr   string
+	}{
+		"panic": {
+			policy: DuplicatePanic, duplicates: 1, expectPanic: true,
+		},
+		"replace": {
+			policy: DuplicateReplace, duplicates: 2,
+		},
+		"error": {
+			policy: DuplicateError, duplicates: 1,
+			expectErr: "ServeMethod duplicate route GET",
+		},
+		"multiple errors": {
+			policy: DuplicateError, duplicates: 2,
+			expectErr: "ServeMethod duplicate route GET; ServeMethod duplicate route GET",
+		},
+		"no duplicates": {
+			policy: DuplicateError,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if v := recover(); (v != nil) != c.expectPanic {
+					t.Errorf("expect panic %v, got %v", c.expectPanic, v)
+				}
+			}()
+
+			s := NewServeMethod()
+			s.DuplicatePolicy = c.policy
+			s.Handle("GET", handler)
+			for i := 0; i < c.duplicates; i++ {
+				s.Handle("GET", handler)
+			}
+
+			err := s.Err()
+			if len(c.expectErr) == 0 {
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expect error, got none")
+			}
+			if e, a := c.expectErr, err.Error(); e != a {
+				t.Errorf("expect %q error, got %q", e, a)
+			}
+
+			var dupErr *DuplicateRouteError
+			if !errors.As(err, &dupErr) {
+				t.Fatalf("expect %T error, got %T", dupErr, err)
+			}
+			if e, a := "GET", dupErr.Route; e != a {
+				t.Errorf("expect %q route, got %q", e, a)
+			}
+			if !errors.Is(err, dupErr) {
+				t.Errorf("expect error to match %v", dupErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package lambdamux
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrorCode is a domain error code registered with an ErrorCatalog.
+type ErrorCode struct {
+	// Code identifying the error, e.g. "ORDER_NOT_FOUND".
+	Code string
+
+	// HTTP status code errors with the code are responded with. Defaults to
+	// 500 Internal Server Error.
+	StatusCode int
+
+	// Public message describing the error. Defaults to the status code's
+	// text.
+	Message string
+
+	// URL of documentation describing the error.
+	DocsURL string
+}
+
+// DomainError is an error identified by a domain error code. Handlers return
+// domain errors, created with Err or WrapErr, and the ErrorCatalog the code
+// is registered with maps the error to its response.
+type DomainError struct {
+	Code string
+	Err  error
+}
+
+// Err returns a DomainError for the error code, e.g.
+// lambdamux.Err("ORDER_NOT_FOUND").
+func Err(code string) error {
+	return &DomainError{Code: code}
+}
+
+// WrapErr returns a DomainError for the error code wrapping the underlying
+// cause of the error.
+func WrapErr(code string, err error) error {
+	return &DomainError{Code: code, Err: err}
+}
+
+func (e *DomainError) Error() string {
+	if e.Err == nil {
+		return e.Code
+	}
+	return e.Code + ", " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCatalog is a registry of domain error codes. The catalog is an
+// ErrorMapper that maps DomainError to the registered code's status and
+// public message, and counts the number of errors mapped per code.
+//
+// DomainError with codes that are not registered are mapped to 500 Internal
+// Server Error.
+type ErrorCatalog struct {
+	mu     sync.RWMutex
+	codes  map[string]ErrorCode
+	counts map[string]int64
+}
+
+// NewErrorCatalog initializes and returns an ErrorCatalog that error codes
+// can be added to via the Register method.
+func NewErrorCatalog() *ErrorCatalog {
+	return &ErrorCatalog{
+		codes:  map[string]ErrorCode{},
+		counts: map[string]int64{},
+	}
+}
+
+// DefaultErrorCatalog is the ErrorCatalog error codes are registered with
+// via RegisterErrorCode.
+var DefaultErrorCatalog = NewErrorCatalog()
+
+// RegisterErrorCode registers the error code with the DefaultErrorCatalog.
+func RegisterErrorCode(code ErrorCode) {
+	DefaultErrorCatalog.Register(code)
+}
+
+// Register adds the error code to the catalog, replacing any existing
+// registration of the code.
+func (c *ErrorCatalog) Register(code ErrorCode) *ErrorCatalog {
+	if code.StatusCode == 0 {
+		code.StatusCode = http.StatusInternalServerError
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.codes[code.Code] = code
+	return c
+}
+
+// Lookup returns the registered error code, or false if the code is not
+// registered.
+func (c *ErrorCatalog) Lookup(code string) (ErrorCode, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ec, ok := c.codes[code]
+	return ec, ok
+}
+
+// MapError implements the ErrorMapper interface, mapping DomainError by
+// their error code.
+func (c *ErrorCatalog) MapError(err error) (ErrorMapping, bool) {
+	var domainErr *DomainError
+	if !errors.As(err, &domainErr) {
+		return ErrorMapping{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[domainErr.Code]++
+
+	code, ok := c.codes[domainErr.Code]
+	if !ok {
+		return ErrorMapping{
+			StatusCode: http.StatusInternalServerError,
+			Code:       domainErr.Code,
+		}, true
+	}
+
+	return ErrorMapping{
+		StatusCode: code.StatusCode,
+		Code:       code.Code,
+		Message:    code.Message,
+		DocsURL:    code.DocsURL,
+	}, true
+}
+
+// Counts returns the number of errors mapped by the catalog per error code,
+// for the lifetime of the catalog.
+func (c *ErrorCatalog) Counts() map[string]int64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	counts := make(map[string]int64, len(c.counts))
+	for k, v := range c.counts {
+		counts[k] = v
+	}
+	return counts
+}
@@ -0,0 +1,364 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+// ErrorMapping is the HTTP response an error returned by a resource handler
+// is translated to.
+type ErrorMapping struct {
+	// HTTP status code of the response.
+	StatusCode int
+
+	// Machine readable code identifying the error, e.g. "NOT_FOUND".
+	Code string
+
+	// Public message describing the error. If empty the status code's text
+	// is used. Never populated from the error itself, so internal error
+	// details are not leaked to clients.
+	Message string
+
+	// URL of documentation describing the error, if any.
+	DocsURL string
+
+	// Additional headers to include in the response, e.g. Retry-After.
+	Header http.Header
+}
+
+// ErrorMapper is the interface for translating errors returned by resource
+// handlers into HTTP responses.
+type ErrorMapper interface {
+	// MapError returns the mapping for the error, or false if the error is
+	// not one the mapper recognizes.
+	MapError(err error) (ErrorMapping, bool)
+}
+
+// ErrorMapperFunc provides wrapping of a function as the ErrorMapper.
+type ErrorMapperFunc func(error) (ErrorMapping, bool)
+
+// MapError implements the ErrorMapper interface and delegates to the
+// function.
+func (f ErrorMapperFunc) MapError(err error) (ErrorMapping, bool) {
+	return f(err)
+}
+
+// ErrorMappers is a list of ErrorMapper that are tried in order. The first
+// mapper to recognize an error maps it.
+type ErrorMappers []ErrorMapper
+
+// MapError implements the ErrorMapper interface, returning the mapping of
+// the first mapper that recognizes the error.
+func (ms ErrorMappers) MapError(err error) (ErrorMapping, bool) {
+	for _, m := range ms {
+		if mapping, ok := m.MapError(err); ok {
+			return mapping, true
+		}
+	}
+	return ErrorMapping{}, false
+}
+
+// ErrorResponder is an API Gateway Proxy resource handler translating errors
+// returned by its Handler into HTTP responses using its Mapper. Errors the
+// Mapper does not recognize are returned unchanged.
+//
+// Mapped errors are encoded into the response by the Encoder. If Encoder is
+// nil, JSONErrorEncoder is used.
+type ErrorResponder struct {
+	Handler ResourceHandler
+	Mapper  ErrorMapper
+	Encoder ErrorEncoder
+}
+
+// ServeResource implements the ResourceHandler interface, delegating to the
+// wrapped handler and translating its errors.
+func (r ErrorResponder) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	resp, err = r.Handler.ServeResource(ctx, req)
+	if err == nil || r.Mapper == nil {
+		return resp, err
+	}
+
+	mapping, ok := r.Mapper.MapError(err)
+	if !ok {
+		return resp, err
+	}
+
+	encoder := r.Encoder
+	if encoder == nil {
+		encoder = JSONErrorEncoder{}
+	}
+	return encodeError(ctx, encoder, req, mapping, err)
+}
+
+// MappedErrorHandler returns an ErrorHandlerFunc translating errors with the
+// mapper, and encoder, as ErrorResponder does. Errors the mapper does not
+// recognize are translated by the StatusErrorMapper, and otherwise, or if
+// they fail to encode, to a 500 Internal Server Error response. If encoder
+// is nil, JSONErrorEncoder is used.
+func MappedErrorHandler(mapper ErrorMapper, encoder ErrorEncoder) ErrorHandlerFunc {
+	if encoder == nil {
+		encoder = JSONErrorEncoder{}
+	}
+
+	return func(ctx context.Context, req APIGatewayProxyRequest, err error) APIGatewayProxyResponse {
+		mapping, ok := ErrorMapping{}, false
+		if mapper != nil {
+			mapping, ok = mapper.MapError(err)
+		}
+		if !ok {
+			mapping, ok = StatusErrorMapper{}.MapError(err)
+		}
+		if !ok {
+			mapping = ErrorMapping{StatusCode: http.StatusInternalServerError}
+		}
+
+		resp, err := encodeError(ctx, encoder, req, mapping, err)
+		if err != nil {
+			return statusResponse(http.StatusInternalServerError)
+		}
+		return resp
+	}
+}
+
+// encodeError encodes the mapped error using the encoder, adding the
+// mapping's headers to the response.
+func encodeError(
+	ctx context.Context, encoder ErrorEncoder, req APIGatewayProxyRequest,
+	mapping ErrorMapping, err error,
+) (APIGatewayProxyResponse, error) {
+	message := mapping.Message
+	if len(message) == 0 {
+		message = http.StatusText(mapping.StatusCode)
+	}
+
+	resp, encErr := encoder.EncodeError(ctx, req, ErrorInfo{
+		StatusCode: mapping.StatusCode,
+		Code:       mapping.Code,
+		Message:    message,
+		DocsURL:    mapping.DocsURL,
+		RequestID:  req.RequestContext.RequestID,
+		Err:        err,
+	})
+	if encErr != nil {
+		return resp, fmt.Errorf("failed to encode error response, %v, %w", encErr, err)
+	}
+
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	for k, vs := range mapping.Header {
+		resp.HTTPHeader[k] = append([]string(nil), vs...)
+	}
+	return resp, nil
+}
+
+// ErrorInfo describes an error to be encoded into a response.
+type ErrorInfo struct {
+	StatusCode int
+	Code       string
+	Message    string
+	DocsURL    string
+
+	// Request ID of the API Gateway request, for correlating the error.
+	RequestID string
+
+	// The error returned by the resource handler. Provided for inspection,
+	// and should not be rendered into the response, as it may include
+	// internal details.
+	Err error `json:"-"`
+}
+
+// ErrorEncoder is the interface for encoding errors into HTTP responses,
+// allowing the error response body to match an organization's error
+// schema.
+type ErrorEncoder interface {
+	EncodeError(context.Context, APIGatewayProxyRequest, ErrorInfo) (APIGatewayProxyResponse, error)
+}
+
+// ErrorEncoderFunc provides wrapping of a function as the ErrorEncoder.
+type ErrorEncoderFunc func(context.Context, APIGatewayProxyRequest, ErrorInfo) (
+	APIGatewayProxyResponse, error,
+)
+
+// EncodeError implements the ErrorEncoder interface and delegates to the
+// function.
+func (f ErrorEncoderFunc) EncodeError(
+	ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo,
+) (APIGatewayProxyResponse, error) {
+	return f(ctx, req, info)
+}
+
+// JSONErrorEncoder encodes errors as a JSON document:
+//
+//	{"code": "NOT_FOUND", "message": "Not Found", "requestId": "abc123"}
+//
+// The "docs" member is included if the error has a documentation URL. The
+// extension members of errors implementing ProblemExtender, e.g. the
+// "errors" of a ValidationError, are included as additional members.
+type JSONErrorEncoder struct{}
+
+type jsonErrorBody struct {
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+	DocsURL   string `json:"docs,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// EncodeError implements the ErrorEncoder interface.
+func (JSONErrorEncoder) EncodeError(
+	ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo,
+) (APIGatewayProxyResponse, error) {
+	var doc interface{} = jsonErrorBody{
+		Code:      info.Code,
+		Message:   info.Message,
+		DocsURL:   info.DocsURL,
+		RequestID: info.RequestID,
+	}
+
+	var extender ProblemExtender
+	if errors.As(info.Err, &extender) {
+		members := map[string]interface{}{}
+		for k, v := range extender.ProblemExtensions() {
+			members[k] = v
+		}
+		members["message"] = info.Message
+		for k, v := range map[string]string{
+			"code": info.Code, "docs": info.DocsURL, "requestId": info.RequestID,
+		} {
+			if len(v) != 0 {
+				members[k] = v
+			}
+		}
+		doc = members
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	return newResponse(info.StatusCode, "application/json", string(body)), nil
+}
+
+// TemplateErrorEncoder encodes errors by executing its Template with the
+// ErrorInfo. The "json" template function encodes a value as JSON, e.g.
+//
+//	{"error": {{json .Code}}, "detail": {{json .Message}}, "traceId": {{json .RequestID}}}
+type TemplateErrorEncoder struct {
+	Template *template.Template
+
+	// Content type of the response. Defaults to application/json.
+	ContentType string
+}
+
+// NewTemplateErrorEncoder parses the text and returns a TemplateErrorEncoder
+// for it. Returns an error if the template cannot be parsed.
+func NewTemplateErrorEncoder(text string) (*TemplateErrorEncoder, error) {
+	t, err := template.New("error").Funcs(template.FuncMap{
+		"json": templateJSON,
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &TemplateErrorEncoder{Template: t}, nil
+}
+
+func templateJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// EncodeError implements the ErrorEncoder interface.
+func (e *TemplateErrorEncoder) EncodeError(
+	ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo,
+) (APIGatewayProxyResponse, error) {
+	var body strings.Builder
+	if err := e.Template.Execute(&body, info); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	contentType := e.ContentType
+	if len(contentType) == 0 {
+		contentType = "application/json"
+	}
+	return newResponse(info.StatusCode, contentType, body.String()), nil
+}
+
+// AWSErrorMapper is an ErrorMapper recognizing AWS SDK API errors by their
+// error code. Errors are recognized if they, or an error they wrap,
+// implement the AWS SDK for Go v2 smithy.APIError interface's ErrorCode
+// method.
+//
+// The default mappings translate conditional check failures to 409
+// Conflict, missing resources to 404 Not Found, throttling to 429 Too Many
+// Requests with a Retry-After header, and access denied to 403 Forbidden.
+// Additional mappings are added via the Map method.
+type AWSErrorMapper struct {
+	codes map[string]ErrorMapping
+}
+
+// NewAWSErrorMapper initializes and returns an AWSErrorMapper with the
+// default mappings.
+func NewAWSErrorMapper() *AWSErrorMapper {
+	m := &AWSErrorMapper{codes: map[string]ErrorMapping{}}
+
+	conflict := ErrorMapping{StatusCode: http.StatusConflict, Code: "CONFLICT"}
+	notFound := ErrorMapping{StatusCode: http.StatusNotFound, Code: "NOT_FOUND"}
+	throttled := ErrorMapping{
+		StatusCode: http.StatusTooManyRequests,
+		Code:       "THROTTLED",
+		Header:     http.Header{"Retry-After": []string{"1"}},
+	}
+	accessDenied := ErrorMapping{StatusCode: http.StatusForbidden, Code: "ACCESS_DENIED"}
+
+	for code, mapping := range map[string]ErrorMapping{
+		"ConditionalCheckFailedException":        conflict,
+		"TransactionConflictException":           conflict,
+		"ResourceNotFoundException":              notFound,
+		"NoSuchKey":                              notFound,
+		"NotFound":                               notFound,
+		"Throttling":                             throttled,
+		"ThrottlingException":                    throttled,
+		"ThrottledException":                     throttled,
+		"TooManyRequestsException":               throttled,
+		"RequestLimitExceeded":                   throttled,
+		"ProvisionedThroughputExceededException": throttled,
+		"SlowDown":                               throttled,
+		"AccessDenied":                           accessDenied,
+		"AccessDeniedException":                  accessDenied,
+		"UnauthorizedOperation":                  accessDenied,
+	} {
+		m.codes[code] = mapping
+	}
+
+	return m
+}
+
+// Map adds a mapping for the AWS API error code, replacing any existing
+// mapping for the code.
+func (m *AWSErrorMapper) Map(code string, mapping ErrorMapping) *AWSErrorMapper {
+	m.codes[code] = mapping
+	return m
+}
+
+// MapError implements the ErrorMapper interface, mapping AWS API errors by
+// their error code.
+func (m *AWSErrorMapper) MapError(err error) (ErrorMapping, bool) {
+	var apiErr interface {
+		error
+		ErrorCode() string
+	}
+	if !errors.As(err, &apiErr) {
+		return ErrorMapping{}, false
+	}
+
+	mapping, ok := m.codes[apiErr.ErrorCode()]
+	return mapping, ok
+}
@@ -0,0 +1,143 @@
+package lambdamux
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ErrorRateAlert configures alerting when the rate of 5xx responses of a
+// route exceeds a threshold within a time window. Rates are tracked per warm
+// Lambda container, not across containers.
+type ErrorRateAlert struct {
+	// Window requests are tracked within. Defaults to one minute.
+	Window time.Duration
+
+	// Fraction of requests within the window responded to with a 5xx status,
+	// or an error, that triggers the alert, e.g. 0.25.
+	Threshold float64
+
+	// Minimum number of requests within the window before the rate is
+	// evaluated. Defaults to 10.
+	MinRequests int
+
+	// Minimum time between alerts for the same route. Defaults to Window.
+	Cooldown time.Duration
+
+	// OnAlert is called when a route's error rate exceeds the threshold,
+	// e.g. to publish the alert to SNS or Slack. Called synchronously
+	// before the response is returned.
+	OnAlert func(context.Context, ErrorRateAlertEvent)
+}
+
+// ErrorRateAlertEvent describes a route's error rate that exceeded the
+// alert's threshold.
+type ErrorRateAlertEvent struct {
+	// Route is the HTTP method and resource, e.g. "GET /users/{id}".
+	Route    string
+	Requests int
+	Errors   int
+	Rate     float64
+	Window   time.Duration
+	Time     time.Time
+}
+
+type errorRateHandler struct {
+	Alert   ErrorRateAlert
+	Handler ResourceHandler
+
+	mu     sync.Mutex
+	routes map[string]*routeErrorRate
+}
+
+type routeErrorRate struct {
+	samples   []errorRateSample
+	lastAlert time.Time
+}
+
+type errorRateSample struct {
+	time   time.Time
+	failed bool
+}
+
+// ResourceHandlerWithErrorRateAlert provides a resource handler that tracks
+// the rate of 5xx responses per route of handler, calling the alert's
+// OnAlert callback when the rate exceeds the alert's threshold.
+func ResourceHandlerWithErrorRateAlert(alert ErrorRateAlert, handler ResourceHandler) ResourceHandler {
+	if alert.Window == 0 {
+		alert.Window = time.Minute
+	}
+	if alert.MinRequests == 0 {
+		alert.MinRequests = 10
+	}
+	if alert.Cooldown == 0 {
+		alert.Cooldown = alert.Window
+	}
+
+	return &errorRateHandler{
+		Alert:   alert,
+		Handler: handler,
+		routes:  map[string]*routeErrorRate{},
+	}
+}
+
+// ServeResource delegates to the wrapped handler, tracking the error rate of
+// the request's route.
+func (h *errorRateHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	resp, err = h.Handler.ServeResource(ctx, req)
+
+	failed := err != nil || resp.StatusCode >= 500
+	if event, ok := h.record(req.HTTPMethod+" "+req.Resource, failed); ok && h.Alert.OnAlert != nil {
+		h.Alert.OnAlert(ctx, event)
+	}
+
+	return resp, err
+}
+
+// record records the request's outcome for the route, returning an alert
+// event if the route's error rate exceeds the threshold.
+func (h *errorRateHandler) record(route string, failed bool) (ErrorRateAlertEvent, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	r, ok := h.routes[route]
+	if !ok {
+		r = &routeErrorRate{}
+		h.routes[route] = r
+	}
+
+	cutoff := now.Add(-h.Alert.Window)
+	var i int
+	for i < len(r.samples) && r.samples[i].time.Before(cutoff) {
+		i++
+	}
+	r.samples = append(r.samples[i:], errorRateSample{time: now, failed: failed})
+
+	if !failed || len(r.samples) < h.Alert.MinRequests || now.Sub(r.lastAlert) < h.Alert.Cooldown {
+		return ErrorRateAlertEvent{}, false
+	}
+
+	var errs int
+	for _, s := range r.samples {
+		if s.failed {
+			errs++
+		}
+	}
+	rate := float64(errs) / float64(len(r.samples))
+	if rate < h.Alert.Threshold {
+		return ErrorRateAlertEvent{}, false
+	}
+
+	r.lastAlert = now
+	return ErrorRateAlertEvent{
+		Route:    route,
+		Requests: len(r.samples),
+		Errors:   errs,
+		Rate:     rate,
+		Window:   h.Alert.Window,
+		Time:     now,
+	}, true
+}
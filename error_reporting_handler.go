@@ -0,0 +1,156 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"runtime/debug"
+	"time"
+)
+
+// ErrorReport describes an error, or panic, captured while serving a
+// request.
+type ErrorReport struct {
+	Err error
+
+	// Value recovered from the panic, and the stack trace of the panic, if
+	// the report is for a panic.
+	Panic interface{}
+	Stack []byte
+
+	Time           time.Time
+	RequestID      string
+	Resource       string
+	Method         string
+	Path           string
+	PathParameters map[string]string
+
+	// User identified by the request's authorizer claims, if any.
+	User string
+
+	// Request body sanitized of sensitive values.
+	Body string
+}
+
+// ErrorReporter is the interface for error reporting services, e.g. Sentry
+// or Bugsnag. Reporters are expected to batch reports, and send them when
+// Flush is called. Flush is called before the invocation ends, so reports
+// are not lost when the Lambda container is frozen.
+type ErrorReporter interface {
+	Report(context.Context, ErrorReport)
+	Flush(context.Context) error
+}
+
+type errorReportingHandler struct {
+	Reporter ErrorReporter
+	Handler  ResourceHandler
+}
+
+// ResourceHandlerWithErrorReporting provides a resource handler that reports
+// errors returned by, and panics of, handler to the reporter. Panics are
+// reported and then re-panicked. The reporter is flushed before the
+// resource handler returns.
+func ResourceHandlerWithErrorReporting(reporter ErrorReporter, handler ResourceHandler) ResourceHandler {
+	return errorReportingHandler{
+		Reporter: reporter,
+		Handler:  handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, reporting its errors and
+// panics.
+func (h errorReportingHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	defer h.Reporter.Flush(ctx)
+	defer func() {
+		if v := recover(); v != nil {
+			report := newErrorReport(req, fmt.Errorf("panic: %v", v))
+			report.Panic = v
+			report.Stack = debug.Stack()
+			h.Reporter.Report(ctx, report)
+			panic(v)
+		}
+	}()
+
+	resp, err = h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		h.Reporter.Report(ctx, newErrorReport(req, err))
+	}
+	return resp, err
+}
+
+func newErrorReport(req APIGatewayProxyRequest, err error) ErrorReport {
+	return ErrorReport{
+		Err:            err,
+		Time:           time.Now(),
+		RequestID:      req.RequestContext.RequestID,
+		Resource:       req.Resource,
+		Method:         req.HTTPMethod,
+		Path:           req.Path,
+		PathParameters: req.PathParameters,
+		User:           authorizerUser(req),
+		Body:           sanitizeBody(req),
+	}
+}
+
+// authorizerUser returns the user identified by the request's authorizer,
+// either the "sub" claim of a JWT authorizer, or a custom authorizer's
+// principal ID.
+func authorizerUser(req APIGatewayProxyRequest) string {
+	if claims, ok := req.RequestContext.Authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok {
+			return sub
+		}
+	}
+	if id, ok := req.RequestContext.Authorizer["principalId"].(string); ok {
+		return id
+	}
+	return ""
+}
+
+const maxReportBodyLen = 4096
+
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)pass|secret|token|auth|key|card|ssn`)
+
+// sanitizeBody returns the request body with the values of JSON members
+// whose names look sensitive redacted. Bodies that are not JSON are omitted.
+func sanitizeBody(req APIGatewayProxyRequest) string {
+	body, err := requestBody(req)
+	if err != nil {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return ""
+	}
+
+	b, err := json.Marshal(redactSensitive(v))
+	if err != nil {
+		return ""
+	}
+	if len(b) > maxReportBodyLen {
+		b = b[:maxReportBodyLen]
+	}
+	return string(b)
+}
+
+func redactSensitive(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for k, mv := range tv {
+			if sensitiveKeyPattern.MatchString(k) {
+				tv[k] = "[REDACTED]"
+			} else {
+				tv[k] = redactSensitive(mv)
+			}
+		}
+	case []interface{}:
+		for i, lv := range tv {
+			tv[i] = redactSensitive(lv)
+		}
+	}
+	return v
+}
@@ -0,0 +1,193 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EventDiagnostics describes how the Lambda event of the request being
+// served was unmarshaled by APIGatewayProxy.
+type EventDiagnostics struct {
+	// Strict is if the proxy's Strict mode was used, rejecting events that
+	// are not well formed API Gateway Proxy events.
+	Strict bool
+
+	// DisallowUnknownFields is if the proxy rejects events with fields
+	// unknown to the event's schema.
+	DisallowUnknownFields bool
+
+	// payload of the event, for the lazily found unknown fields.
+	payload []byte
+
+	once    *sync.Once
+	unknown *[]string
+}
+
+// UnknownFields returns the fields of the event unknown to the API Gateway
+// Proxy event schema, as JSON paths, e.g. "requestContext.newField", sorted.
+// Unknown fields are usually payload additions made by API Gateway after the
+// events package was released. Fields are found when first requested.
+func (d EventDiagnostics) UnknownFields() []string {
+	if d.once == nil {
+		return nil
+	}
+	d.once.Do(func() {
+		*d.unknown = unknownEventFields(d.payload)
+	})
+	return *d.unknown
+}
+
+func newEventDiagnostics(p APIGatewayProxy, payload []byte) EventDiagnostics {
+	return EventDiagnostics{
+		Strict:                p.Strict,
+		DisallowUnknownFields: p.DisallowUnknownFields,
+		payload:               payload,
+		once:                  &sync.Once{},
+		unknown:               new([]string),
+	}
+}
+
+type eventDiagnosticsKey struct{}
+
+// EventDiagnosticsFromContext returns the diagnostics of the request's
+// Lambda event, if the request is served by APIGatewayProxy.
+func EventDiagnosticsFromContext(ctx context.Context) (EventDiagnostics, bool) {
+	d, ok := ctx.Value(eventDiagnosticsKey{}).(EventDiagnostics)
+	return d, ok
+}
+
+// WithStrictEvent returns a RouteOption rejecting requests to the route
+// whose Lambda event has fields unknown to the API Gateway Proxy event
+// schema with a 400 Bad Request response, e.g. for routes exercised by test
+// environments validating the events they send. Other routes continue to
+// tolerate unknown fields.
+func WithStrictEvent() RouteOption {
+	return func(o *routeOptions) {
+		o.strictEvent = true
+	}
+}
+
+type strictEventHandler struct {
+	Handler ResourceHandler
+}
+
+// ServeResource rejects requests whose event has unknown fields, and
+// delegates to the wrapped handler.
+func (h strictEventHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if d, ok := EventDiagnosticsFromContext(ctx); ok {
+		if unknown := d.UnknownFields(); len(unknown) != 0 {
+			return newResponse(http.StatusBadRequest, "text/plain; charset=utf-8",
+				"unknown event fields: "+strings.Join(unknown, ", ")), nil
+		}
+	}
+	return h.Handler.ServeResource(ctx, req)
+}
+
+var proxyEventType = reflect.TypeOf(events.APIGatewayProxyRequest{})
+
+// unknownEventFields returns the JSON paths of the payload's fields unknown
+// to the API Gateway Proxy event type.
+func unknownEventFields(payload []byte) []string {
+	var unknown []string
+	unknownJSONFields("", payload, proxyEventType, &unknown)
+	sort.Strings(unknown)
+	return unknown
+}
+
+// unknownJSONFields adds the paths of the JSON value's object members that
+// do not match a field of the type, as encoding/json matches them, to
+// unknown.
+func unknownJSONFields(prefix string, raw json.RawMessage, t reflect.Type, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &members); err != nil {
+			return
+		}
+		fields := jsonFields(t)
+		for name, v := range members {
+			f, ok := fields[name]
+			if !ok {
+				for k, kf := range fields {
+					if strings.EqualFold(k, name) {
+						f, ok = kf, true
+						break
+					}
+				}
+			}
+			if !ok {
+				*unknown = append(*unknown, prefix+name)
+				continue
+			}
+			unknownJSONFields(prefix+name+".", v, f.Type, unknown)
+		}
+
+	case reflect.Map:
+		var members map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &members); err != nil {
+			return
+		}
+		for name, v := range members {
+			unknownJSONFields(prefix+name+".", v, t.Elem(), unknown)
+		}
+
+	case reflect.Slice, reflect.Array:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return
+		}
+		for _, v := range elems {
+			unknownJSONFields(prefix, v, t.Elem(), unknown)
+		}
+	}
+}
+
+// jsonFields returns the fields of the struct type by their JSON name,
+// including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range jsonFields(ft) {
+					if _, ok := fields[k]; !ok {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+		if len(f.PkgPath) != 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
@@ -0,0 +1,234 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Event is a Lambda event, or a record of a batch event, routed by the
+// EventMux.
+type Event struct {
+	// Source of the event, e.g. "aws:sqs", "aws:s3", or for EventBridge
+	// events the event's source, e.g. "aws.ec2", or "com.example.orders".
+	Source string
+
+	// Payload of the event, the record of batch events, e.g. an
+	// events.SQSMessage, or events.S3EventRecord, or the whole EventBridge
+	// event, e.g. an events.CloudWatchEvent.
+	Payload json.RawMessage
+
+	// Envelopes are the sources of the envelopes the event was unwrapped
+	// from, outermost first, e.g. ["aws:sqs", "aws:sns"] for an S3 event
+	// published to an SNS topic, delivered to an SQS queue.
+	Envelopes []string
+}
+
+// EventHandler is the interface for handlers of events routed by the
+// EventMux.
+type EventHandler interface {
+	ServeEvent(context.Context, Event) error
+}
+
+// EventHandlerFunc provides wrapping of a function as the EventHandler.
+type EventHandlerFunc func(context.Context, Event) error
+
+// ServeEvent implements the EventHandler interface and delegates to the
+// function.
+func (f EventHandlerFunc) ServeEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// EventMux is a Lambda Handler routing events by their source to event
+// handlers, e.g. SQS messages, S3 notifications, or EventBridge events, and
+// delegating invocations that are not events, e.g. API Gateway requests, to
+// Next. The records of batch events are routed individually.
+//
+// Events wrapped in envelopes, e.g. S3 notifications published to an SNS
+// topic, SNS notifications delivered to an SQS queue, or EventBridge events
+// targeting an SQS queue, are unwrapped, and the inner events routed,
+// unless a handler is added for the envelope's source.
+type EventMux struct {
+	handlers map[string]EventHandler
+
+	// Next serves invocations that are not events. If nil, such
+	// invocations fail.
+	Next lambda.Handler
+}
+
+// NewEventMux initializes and returns an EventMux that event handlers can be
+// added to via the Handle method.
+func NewEventMux() *EventMux {
+	return &EventMux{handlers: map[string]EventHandler{}}
+}
+
+// Handle adds the event handler for the event source, e.g. "aws:sqs", or
+// "com.example.orders". Panics if the source already has a handler.
+func (m *EventMux) Handle(source string, handler EventHandler) *EventMux {
+	if _, ok := m.handlers[source]; ok {
+		panic(fmt.Sprintf("event handler already added for %s", source))
+	}
+	m.handlers[source] = handler
+	return m
+}
+
+// Invoke implements the lambda.Handler interface.
+func (m *EventMux) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	events, ok := parseEvents(payload)
+	if !ok {
+		if m.Next == nil {
+			return nil, fmt.Errorf("invalid lambda event, expect event")
+		}
+		return m.Next.Invoke(ctx, payload)
+	}
+
+	ctx, done := withRequestDone(ctx)
+	defer done()
+
+	for _, event := range events {
+		if err := m.ServeEvent(ctx, event); err != nil {
+			return nil, err
+		}
+	}
+	return []byte("null"), nil
+}
+
+// ServeEvent implements the EventHandler interface, routing the event to
+// the handler of its source, or unwrapping the event, if it is an envelope
+// without a handler. Returns an error if the event has no handler.
+func (m *EventMux) ServeEvent(ctx context.Context, event Event) error {
+	if h, ok := m.handlers[event.Source]; ok {
+		return h.ServeEvent(ctx, event)
+	}
+
+	inner, ok := unwrapEvent(event)
+	if !ok {
+		return fmt.Errorf("event handler not found for %s", event.Source)
+	}
+	for _, e := range inner {
+		if err := m.ServeEvent(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseEvents returns the events of the payload, the records of a batch
+// event, or an EventBridge event, and false if the payload is not an event.
+func parseEvents(payload []byte) ([]Event, bool) {
+	var v struct {
+		Records    []json.RawMessage `json:"Records"`
+		Source     string            `json:"source"`
+		DetailType string            `json:"detail-type"`
+	}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, false
+	}
+
+	if len(v.DetailType) != 0 && len(v.Source) != 0 {
+		return []Event{{Source: v.Source, Payload: payload}}, true
+	}
+	if len(v.Records) == 0 {
+		return nil, false
+	}
+
+	events := make([]Event, 0, len(v.Records))
+	for _, record := range v.Records {
+		var r struct {
+			EventSource string `json:"eventSource"`
+
+			// SNS records capitalize the member name.
+			SNSEventSource string `json:"EventSource"`
+		}
+		if err := json.Unmarshal(record, &r); err != nil {
+			return nil, false
+		}
+		source := r.EventSource
+		if len(source) == 0 {
+			source = r.SNSEventSource
+		}
+		if len(source) == 0 {
+			return nil, false
+		}
+		events = append(events, Event{Source: source, Payload: record})
+	}
+	return events, true
+}
+
+// unwrapEvent returns the events wrapped in the SQS message, or SNS
+// notification, event, and false if the event is not an envelope of events.
+func unwrapEvent(event Event) ([]Event, bool) {
+	var message string
+	switch event.Source {
+	case "aws:sqs":
+		var r struct {
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal(event.Payload, &r); err != nil {
+			return nil, false
+		}
+		message = r.Body
+
+		// SNS notifications delivered to SQS without raw message delivery.
+		var n struct {
+			Type     string `json:"Type"`
+			TopicArn string `json:"TopicArn"`
+		}
+		if err := json.Unmarshal([]byte(message), &n); err == nil &&
+			n.Type == "Notification" && len(n.TopicArn) != 0 {
+			inner := Event{
+				Source:    "aws:sns",
+				Payload:   snsRecordOfNotification([]byte(message)),
+				Envelopes: appendEnvelope(event.Envelopes, event.Source),
+			}
+			return []Event{inner}, true
+		}
+
+	case "aws:sns":
+		var r struct {
+			SNS struct {
+				Message string `json:"Message"`
+			} `json:"Sns"`
+		}
+		if err := json.Unmarshal(event.Payload, &r); err != nil {
+			return nil, false
+		}
+		message = r.SNS.Message
+
+	default:
+		return nil, false
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(message), "{") {
+		return nil, false
+	}
+	inner, ok := parseEvents([]byte(message))
+	if !ok {
+		return nil, false
+	}
+	for i := range inner {
+		inner[i].Envelopes = appendEnvelope(event.Envelopes, event.Source)
+	}
+	return inner, true
+}
+
+// snsRecordOfNotification returns the SNS event record of the SNS
+// notification delivered to SQS, so SNS handlers receive the same payload
+// however the notification was delivered.
+func snsRecordOfNotification(notification []byte) json.RawMessage {
+	b, err := json.Marshal(struct {
+		EventSource string          `json:"EventSource"`
+		SNS         json.RawMessage `json:"Sns"`
+	}{"aws:sns", notification})
+	if err != nil {
+		return notification
+	}
+	return b
+}
+
+func appendEnvelope(envelopes []string, source string) []string {
+	return append(append([]string(nil), envelopes...), source)
+}
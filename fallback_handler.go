@@ -0,0 +1,62 @@
+package lambdamux
+
+import (
+	"context"
+)
+
+// FallbackMetric is the custom metric added, via AddMetric, for requests
+// served by a fallback handler.
+const FallbackMetric = "Fallback"
+
+// FallbackPredicate returns if the response, and error, of the primary
+// handler should be replaced by the fallback handler's.
+type FallbackPredicate func(resp APIGatewayProxyResponse, err error) bool
+
+// FallbackOnFailure is the default FallbackPredicate, falling back if the
+// primary handler returns an error, or a 5xx status response.
+func FallbackOnFailure(resp APIGatewayProxyResponse, err error) bool {
+	return err != nil || resp.StatusCode >= 500
+}
+
+type fallbackHandler struct {
+	Fallback  ResourceHandler
+	Predicate FallbackPredicate
+	Handler   ResourceHandler
+}
+
+// ResourceHandlerWithFallback provides a resource handler that serves
+// requests with the primary handler, and if the predicate is true of its
+// response, with the fallback handler instead, e.g. serving stale cached
+// data, or a static response, when a dependency is unavailable. The
+// predicate defaults to FallbackOnFailure.
+//
+// For the fallback to be used when the primary times out, the primary
+// should be wrapped with ResourceHandlerWithTimeout, so the fallback is
+// served with the request's remaining time. Requests served by the fallback
+// add the FallbackMetric custom metric.
+func ResourceHandlerWithFallback(primary, fallback ResourceHandler, predicate FallbackPredicate) ResourceHandler {
+	if predicate == nil {
+		predicate = FallbackOnFailure
+	}
+
+	return fallbackHandler{
+		Fallback:  fallback,
+		Predicate: predicate,
+		Handler:   primary,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, falling back to the
+// fallback handler if the predicate is true of the wrapped handler's
+// response.
+func (h fallbackHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	resp, err := h.Handler.ServeResource(ctx, req)
+	if !h.Predicate(resp, err) || ctx.Err() != nil {
+		return resp, err
+	}
+
+	AddMetric(ctx, FallbackMetric, 1)
+	return h.Fallback.ServeResource(ctx, req)
+}
@@ -0,0 +1,79 @@
+package lambdamux
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// File sets the response to a file download of the data, with the
+// Content-Disposition header's filename set to name, and the status code
+// 200 OK if not already set.
+//
+// If contentType is empty it is detected from the name's extension, falling
+// back to sniffing the data. The body is base64 encoded unless the content
+// type is textual, so binary files, e.g. PDFs, pass through API Gateway
+// unchanged. API Gateway REST APIs must also have the content type
+// configured as a binary media type.
+func (r *APIGatewayProxyResponse) File(name, contentType string, data []byte) {
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(name))
+	}
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+
+	if r.StatusCode == 0 {
+		r.StatusCode = http.StatusOK
+	}
+	if r.HTTPHeader == nil {
+		r.HTTPHeader = http.Header{}
+	}
+	r.HTTPHeader.Set("Content-Type", contentType)
+	r.HTTPHeader.Set("Content-Disposition", contentDisposition(name))
+	r.HTTPHeader.Set("Content-Length", strconv.Itoa(len(data)))
+
+	if isTextMediaType(contentType) {
+		r.Body = string(data)
+		r.IsBase64Encoded = false
+		return
+	}
+	r.Body = base64.StdEncoding.EncodeToString(data)
+	r.IsBase64Encoded = true
+}
+
+// isTextMediaType returns if the media type is textual, and can be sent as
+// a string body.
+func isTextMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") || isJSONMediaType(mediaType) ||
+		mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/javascript"
+}
+
+// contentDisposition returns the attachment Content-Disposition header value
+// of the filename, with the RFC 5987 encoded filename* parameter for
+// non-ASCII filenames.
+func contentDisposition(filename string) string {
+	filename = path.Base(strings.Replace(filename, "\\", "/", -1))
+
+	ascii := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, filename)
+
+	v := `attachment; filename="` + ascii + `"`
+	if ascii != filename {
+		v += "; filename*=UTF-8''" + url.PathEscape(filename)
+	}
+	return v
+}
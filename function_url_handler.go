@@ -0,0 +1,140 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FunctionURLProxy provides a Lambda Handler for Lambda function URL
+// invokes. Function URL events use the API Gateway HTTP API version 2.0
+// payload format, without a route key, or path parameters.
+//
+// Requests are converted to APIGatewayProxyRequest, and served by the
+// Handler, the same as APIGatewayV2Proxy, so resource handlers move between
+// API Gateway, and function URLs, unchanged. As function URLs have no
+// routes, the request's rawPath is matched against the resources of the
+// Handler's routes, e.g. "/users/{id}", to provide the request's Resource,
+// and PathParameters. Literal path segments are preferred over parameters.
+// Requests not matching a resource have the rawPath as their Resource.
+//
+// The original event is available to handlers via
+// APIGatewayV2RequestFromContext.
+type FunctionURLProxy struct {
+	handler  ResourceHandler
+	patterns []routePattern
+
+	// ErrorHandler optionally translates errors returned by the handler
+	// into responses, instead of failing the Lambda invoke.
+	ErrorHandler ErrorHandlerFunc
+}
+
+// NewFunctionURLProxy returns a FunctionURLProxy serving requests with the
+// handler. The handler's resources are read from its routes, with Routes,
+// when the proxy is created. Resources added to the handler later are not
+// matched.
+func NewFunctionURLProxy(handler ResourceHandler) *FunctionURLProxy {
+	p := &FunctionURLProxy{handler: handler}
+
+	seen := map[string]bool{}
+	for _, route := range Routes(handler) {
+		if len(route.Resource) == 0 || seen[route.Resource] {
+			continue
+		}
+		seen[route.Resource] = true
+
+		pattern, err := parseRoutePattern(route.Resource)
+		if err != nil {
+			continue
+		}
+		p.patterns = append(p.patterns, pattern)
+	}
+
+	return p
+}
+
+// Invoke invokes the function URL call. Implements lambda's Handler
+// interface.
+//
+// Deserializes the request as an events.APIGatewayV2HTTPRequest, and
+// serializes the response as an events.APIGatewayV2HTTPResponse.
+func (p *FunctionURLProxy) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var event events.APIGatewayV2HTTPRequest
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid lambda event, expect %T, %w", event, err)
+	}
+
+	req := proxyRequestFromV2(event)
+	req.Resource, req.PathParameters = p.match(event.RawPath)
+	req.RequestContext.ResourcePath = req.Resource
+
+	ctx = context.WithValue(ctx, apiGatewayV2RequestKey{}, event)
+
+	ctx, done := withRequestDone(ctx)
+	defer done()
+
+	resp, err := p.handler.ServeResource(ctx, req)
+	if err != nil {
+		if resp, err = handlerErrorResponse(ctx, p.ErrorHandler, req, err); err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := json.Marshal(proxyResponseToV2(resp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %T, %w", resp, err)
+	}
+
+	return out, nil
+}
+
+// match returns the resource, and path parameters, of the most specific
+// resource pattern matching the path, or the path if none match.
+func (p *FunctionURLProxy) match(path string) (string, map[string]string) {
+	var best *routePattern
+	var bestParams map[string]string
+
+	for i := range p.patterns {
+		pattern := &p.patterns[i]
+		params, ok := pattern.matchPath(path)
+		if !ok {
+			continue
+		}
+		if best == nil || moreSpecific(pattern.segments, best.segments) {
+			best, bestParams = pattern, params
+		}
+	}
+
+	if best == nil {
+		return path, map[string]string{}
+	}
+	return best.resource, bestParams
+}
+
+// moreSpecific returns if the segments of a pattern are more specific than
+// the segments of b, comparing segments in order: literals are more
+// specific than parameters, parameters than wildcards, and wildcards than
+// greedy parameters.
+func moreSpecific(a, b []patternSegment) bool {
+	rank := func(s patternSegment) int {
+		switch {
+		case s.greedy:
+			return 0
+		case s.wildcard:
+			return 1
+		case len(s.param) != 0:
+			return 2
+		default:
+			return 3
+		}
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if ra, rb := rank(a[i]), rank(b[i]); ra != rb {
+			return ra > rb
+		}
+	}
+	return len(a) > len(b)
+}
@@ -0,0 +1,141 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GatewayValidationError is the error of a request failing validation, in
+// the format of API Gateway REST API request validators, so APIs validating
+// requests in the handler, e.g. HTTP APIs, which have no request validators,
+// respond to invalid requests as REST APIs do.
+type GatewayValidationError struct {
+	// Message of the error, e.g. "Invalid request body", or "Missing
+	// required request parameters: [id]".
+	Message string
+
+	// ValidationErrorString describes the validation failures, as API
+	// Gateway's $context.error.validationErrorString, e.g.
+	// `[object has missing required properties (["name"])]`.
+	ValidationErrorString string
+}
+
+func (e *GatewayValidationError) Error() string {
+	if len(e.ValidationErrorString) == 0 {
+		return e.Message
+	}
+	return e.Message + ", " + e.ValidationErrorString
+}
+
+// ProblemExtensions implements the ProblemExtender interface, returning the
+// error's validation error string as the "validationErrorString" member.
+func (e *GatewayValidationError) ProblemExtensions() map[string]interface{} {
+	if len(e.ValidationErrorString) == 0 {
+		return nil
+	}
+	return map[string]interface{}{"validationErrorString": e.ValidationErrorString}
+}
+
+// MissingRequestParameters returns the 400 Bad Request StatusError of the
+// missing required request parameters, e.g. "method.request.path.id", in
+// the format of API Gateway's parameter validation, "Missing required
+// request parameters: [id]".
+func MissingRequestParameters(names ...string) error {
+	message := "Missing required request parameters: [" + strings.Join(names, ", ") + "]"
+	return &StatusError{
+		StatusCode: http.StatusBadRequest,
+		Message:    message,
+		Err:        &GatewayValidationError{Message: message},
+	}
+}
+
+// InvalidRequestBody returns the 400 Bad Request StatusError of the fields
+// failing validation, in the format of API Gateway's body validation,
+// "Invalid request body", with the validation error string of the fields.
+// Missing required fields are described as API Gateway's JSON schema
+// validator does, and other failures by their messages.
+func InvalidRequestBody(fields ...FieldError) error {
+	const message = "Invalid request body"
+	return &StatusError{
+		StatusCode: http.StatusBadRequest,
+		Message:    message,
+		Err: &GatewayValidationError{
+			Message:               message,
+			ValidationErrorString: validationErrorString(fields),
+		},
+	}
+}
+
+// validationErrorString returns the API Gateway validation error string of
+// the fields failing validation.
+func validationErrorString(fields []FieldError) string {
+	var missing []string
+	var msgs []string
+	for _, f := range fields {
+		if f.Rule == "required" {
+			missing = append(missing, strconv.Quote(f.Field))
+			continue
+		}
+		msgs = append(msgs, f.Message)
+	}
+	if len(missing) != 0 {
+		msgs = append([]string{
+			"object has missing required properties ([" + strings.Join(missing, ",") + "])",
+		}, msgs...)
+	}
+	return "[" + strings.Join(msgs, ", ") + "]"
+}
+
+// GatewayValidator returns a Validator validating values with the
+// validator, or TagValidator if nil, translating ValidationErrors into
+// InvalidRequestBody errors, so requests failing validation are responded
+// to with 400 Bad Request, as API Gateway REST API request validators do.
+func GatewayValidator(validator Validator) Validator {
+	if validator == nil {
+		validator = TagValidator{}
+	}
+	return ValidatorFunc(func(v interface{}) error {
+		err := validator.Validate(v)
+		var validationErr *ValidationError
+		if err != nil && errors.As(err, &validationErr) {
+			return InvalidRequestBody(validationErr.Fields...)
+		}
+		return err
+	})
+}
+
+// GatewayErrorEncoder encodes errors as API Gateway's default gateway
+// responses, e.g. of REST API request validators:
+//
+//	{"message": "Invalid request body"}
+//
+// The validation error string of GatewayValidationErrors is included as
+// the "validationErrorString" member if IncludeValidationErrorString is set,
+// as gateway responses customized with $context.error.validationErrorString
+// do.
+type GatewayErrorEncoder struct {
+	IncludeValidationErrorString bool
+}
+
+// EncodeError implements the ErrorEncoder interface.
+func (e GatewayErrorEncoder) EncodeError(
+	ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo,
+) (APIGatewayProxyResponse, error) {
+	doc := map[string]string{"message": info.Message}
+
+	var validationErr *GatewayValidationError
+	if e.IncludeValidationErrorString && errors.As(info.Err, &validationErr) &&
+		len(validationErr.ValidationErrorString) != 0 {
+		doc["validationErrorString"] = validationErr.ValidationErrorString
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	return newResponse(info.StatusCode, "application/json", string(body)), nil
+}
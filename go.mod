@@ -1,5 +1,5 @@
 module go.jasdel.dev/aws/lambda-mux
 
-go 1.15
+go 1.18
 
 require github.com/aws/aws-lambda-go v1.19.1
@@ -0,0 +1,151 @@
+package lambdamux
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// HandlerNode kinds.
+const (
+	HandlerNodeRouter    = "router"
+	HandlerNodeDecorator = "decorator"
+	HandlerNodeHandler   = "handler"
+)
+
+// HandlerNode is a node of a handler tree's graph, describing a router,
+// decorator, or handler. The graph can be serialized as JSON, or Graphviz
+// DOT with WriteDOT, to visualize what wraps each route of large
+// applications.
+type HandlerNode struct {
+	// Kind of the node, router, decorator, or handler.
+	Kind string `json:"kind"`
+
+	// Go type of the node's handler, e.g. "*lambdamux.ServeMethod".
+	Type string `json:"type"`
+
+	// Route the parent router delegates to the node for, e.g. the
+	// resource, HTTP method, or query constraint. Empty for the root, and
+	// nodes wrapped by decorators.
+	Route string `json:"route,omitempty"`
+
+	Children []*HandlerNode `json:"children,omitempty"`
+}
+
+// HandlerGraph returns the graph of the handler tree. Routers are expanded
+// into their routes, and decorators are unwrapped by their exported Handler
+// field.
+func HandlerGraph(handler ResourceHandler) *HandlerNode {
+	n := &HandlerNode{Type: handlerTypeName(handler)}
+
+	addChild := func(route string, h ResourceHandler) {
+		c := HandlerGraph(h)
+		c.Route = route
+		n.Children = append(n.Children, c)
+	}
+
+	switch h := handler.(type) {
+	case *Router:
+		n.Kind = HandlerNodeRouter
+		addChild("", h.Handler())
+
+	case *ServeResource:
+		n.Kind = HandlerNodeRouter
+		resources := make([]string, 0, len(h.resources))
+		for k := range h.resources {
+			resources = append(resources, k)
+		}
+		sort.Strings(resources)
+		for _, k := range resources {
+			addChild(k, h.resources[k].handler)
+		}
+
+	case *ServeMethod:
+		n.Kind = HandlerNodeRouter
+		methods := make([]string, 0, len(h.methods))
+		for k := range h.methods {
+			methods = append(methods, k)
+		}
+		sort.Strings(methods)
+		for _, k := range methods {
+			addChild(k, h.methods[k].handler)
+		}
+
+	case *ServePath:
+		n.Kind = HandlerNodeRouter
+		for _, r := range h.routes {
+			addChild(r.template, r.handler)
+		}
+
+	case *ServeQuery:
+		n.Kind = HandlerNodeRouter
+		for _, r := range h.routes {
+			addChild("?"+r.query, r.handler)
+		}
+
+	default:
+		if inner := unwrapHandler(handler); inner != nil {
+			n.Kind = HandlerNodeDecorator
+			addChild("", inner)
+		} else {
+			n.Kind = HandlerNodeHandler
+		}
+	}
+
+	return n
+}
+
+func handlerTypeName(handler ResourceHandler) string {
+	if handler == nil {
+		return "<nil>"
+	}
+	return reflect.TypeOf(handler).String()
+}
+
+// WriteDOT writes the graph to the writer as a Graphviz DOT digraph.
+// Routers, decorators, and handlers are drawn with different shapes, and
+// edges are labeled with their route.
+func (n *HandlerNode) WriteDOT(w io.Writer) error {
+	if _, err := io.WriteString(w, "digraph handlers {\n\trankdir=LR;\n"); err != nil {
+		return err
+	}
+
+	var id int
+	var walk func(*HandlerNode) (int, error)
+	walk = func(node *HandlerNode) (int, error) {
+		nodeID := id
+		id++
+
+		shape := "box"
+		switch node.Kind {
+		case HandlerNodeRouter:
+			shape = "diamond"
+		case HandlerNodeDecorator:
+			shape = "ellipse"
+		}
+		if _, err := fmt.Fprintf(w, "\tn%d [label=%s, shape=%s];\n",
+			nodeID, strconv.Quote(node.Type), shape); err != nil {
+			return 0, err
+		}
+
+		for _, c := range node.Children {
+			childID, err := walk(c)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := fmt.Fprintf(w, "\tn%d -> n%d [label=%s];\n",
+				nodeID, childID, strconv.Quote(c.Route)); err != nil {
+				return 0, err
+			}
+		}
+		return nodeID, nil
+	}
+
+	if _, err := walk(n); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
@@ -0,0 +1,157 @@
+// Package headers provides conversions between Go's http.Header and the
+// header representations of Lambda HTTP events: the single value Headers and
+// MultiValueHeaders maps of API Gateway REST API events, and the comma joined
+// headers and cookies array of API Gateway HTTP API, and Lambda function URL,
+// events.
+package headers
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// hopByHop are the hop-by-hop headers that are only meaningful for a single
+// connection, and must not be forwarded by proxies.
+var hopByHop = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// FromMultiValue returns the http.Header of the multi value headers map.
+// Header names are canonicalized.
+func FromMultiValue(multi map[string][]string) http.Header {
+	h := make(http.Header, len(multi))
+	for k, values := range multi {
+		for _, v := range values {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
+// FromSingleValue returns the http.Header of the single value headers map.
+// Header names are canonicalized. Values are not split on commas.
+func FromSingleValue(single map[string]string) http.Header {
+	h := make(http.Header, len(single))
+	for k, v := range single {
+		h.Add(k, v)
+	}
+	return h
+}
+
+// FromEvent returns the http.Header of a REST API event's headers. The
+// multi value headers are used for headers present in both maps, as the
+// single value map only contains the last value of repeated headers.
+func FromEvent(single map[string]string, multi map[string][]string) http.Header {
+	h := FromMultiValue(multi)
+	for k, v := range single {
+		if _, ok := h[textproto.CanonicalMIMEHeaderKey(k)]; !ok {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
+// ToMultiValue returns the multi value headers map of the http.Header.
+func ToMultiValue(h http.Header) map[string][]string {
+	multi := make(map[string][]string, len(h))
+	for k, values := range h {
+		if len(values) != 0 {
+			multi[k] = append([]string(nil), values...)
+		}
+	}
+	return multi
+}
+
+// ToSingleValue returns the single value headers map of the http.Header.
+// Repeated headers are joined with a comma, except Set-Cookie, which cannot
+// be joined, and only the last value is kept.
+func ToSingleValue(h http.Header) map[string]string {
+	single := make(map[string]string, len(h))
+	for k, values := range h {
+		if len(values) == 0 {
+			continue
+		}
+		if k == "Set-Cookie" {
+			single[k] = values[len(values)-1]
+			continue
+		}
+		single[k] = strings.Join(values, ",")
+	}
+	return single
+}
+
+// FromV2 returns the http.Header of an HTTP API, or function URL, request
+// event's headers and cookies. The cookies are joined into the Cookie
+// header. Header values are not split on commas.
+func FromV2(headers map[string]string, cookies []string) http.Header {
+	h := FromSingleValue(headers)
+	if len(cookies) != 0 {
+		h.Set("Cookie", strings.Join(cookies, "; "))
+	}
+	return h
+}
+
+// ToV2 returns the headers and cookies of an HTTP API, or function URL,
+// response event for the http.Header. Set-Cookie values are returned as
+// the cookies, and other repeated headers are joined with a comma.
+func ToV2(h http.Header) (headers map[string]string, cookies []string) {
+	headers = make(map[string]string, len(h))
+	for k, values := range h {
+		if len(values) == 0 {
+			continue
+		}
+		if k == "Set-Cookie" {
+			cookies = append(cookies, values...)
+			continue
+		}
+		headers[k] = strings.Join(values, ",")
+	}
+	return headers, cookies
+}
+
+// RemoveHopByHop removes the hop-by-hop headers from h, including headers
+// named by the Connection header.
+func RemoveHopByHop(h http.Header) {
+	for _, v := range h["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); len(name) != 0 {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHop {
+		h.Del(name)
+	}
+}
+
+// Copy adds the values of the src headers to dst, skipping hop-by-hop
+// headers.
+func Copy(dst, src http.Header) {
+	skip := map[string]struct{}{}
+	for _, name := range hopByHop {
+		skip[name] = struct{}{}
+	}
+	for _, v := range src["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			skip[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] = struct{}{}
+		}
+	}
+
+	for k, values := range src {
+		if _, ok := skip[textproto.CanonicalMIMEHeaderKey(k)]; ok {
+			continue
+		}
+		for _, v := range values {
+			dst.Add(k, v)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package headers
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestFromMultiValue(t *testing.T) {
+	cases := map[string]struct {
+		multi  map[string][]string
+		get    string
+		expect []string
+	}{
+		"canonical": {
+			multi:  map[string][]string{"Content-Type": {"text/plain"}},
+			get:    "content-type",
+			expect: []string{"text/plain"},
+		},
+		"lower case": {
+			multi:  map[string][]string{"x-request-id": {"abc"}},
+			get:    "X-Request-Id",
+			expect: []string{"abc"},
+		},
+		"repeated": {
+			multi:  map[string][]string{"accept": {"text/html", "application/json"}},
+			get:    "ACCEPT",
+			expect: []string{"text/html", "application/json"},
+		},
+		"mixed case names merged": {
+			multi:  map[string][]string{"X-Tag": {"a"}, "x-tag": {"a"}},
+			get:    "x-TAG",
+			expect: []string{"a", "a"},
+		},
+		"missing": {
+			multi: map[string][]string{"X-Other": {"a"}},
+			get:   "X-Tag",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := FromMultiValue(c.multi)
+			if e, a := c.expect, h.Values(c.get); !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestFromSingleValue(t *testing.T) {
+	h := FromSingleValue(map[string]string{
+		"content-type": "application/json",
+		"Accept":       "text/html, application/json",
+	})
+
+	if e, a := "application/json", h.Get("Content-Type"); e != a {
+		t.Errorf("expect %q, got %q", e, a)
+	}
+	if e, a := []string{"text/html, application/json"}, h.Values("accept"); !reflect.DeepEqual(e, a) {
+		t.Errorf("expect values not split, %v, got %v", e, a)
+	}
+
+	h.Set("CONTENT-TYPE", "text/plain")
+	if e, a := "text/plain", h.Get("content-type"); e != a {
+		t.Errorf("expect %q, got %q", e, a)
+	}
+	if e, a := 2, len(h); e != a {
+		t.Errorf("expect %v headers, got %v, %v", e, a, h)
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	cases := map[string]struct {
+		single map[string]string
+		multi  map[string][]string
+		expect http.Header
+	}{
+		"single only": {
+			single: map[string]string{"x-a": "1"},
+			expect: http.Header{"X-A": {"1"}},
+		},
+		"multi only": {
+			multi:  map[string][]string{"x-a": {"1", "2"}},
+			expect: http.Header{"X-A": {"1", "2"}},
+		},
+		"multi preferred": {
+			single: map[string]string{"X-A": "2"},
+			multi:  map[string][]string{"x-a": {"1", "2"}},
+			expect: http.Header{"X-A": {"1", "2"}},
+		},
+		"merged": {
+			single: map[string]string{"x-a": "1", "x-b": "2"},
+			multi:  map[string][]string{"X-B": {"3", "4"}, "x-c": {"5"}},
+			expect: http.Header{"X-A": {"1"}, "X-B": {"3", "4"}, "X-C": {"5"}},
+		},
+		"empty": {
+			expect: http.Header{},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if e, a := c.expect, FromEvent(c.single, c.multi); !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestToMultiValue(t *testing.T) {
+	h := http.Header{
+		"Accept": {"text/html", "application/json"},
+		"Empty":  {},
+	}
+
+	multi := ToMultiValue(h)
+	expect := map[string][]string{"Accept": {"text/html", "application/json"}}
+	if !reflect.DeepEqual(expect, multi) {
+		t.Errorf("expect %v, got %v", expect, multi)
+	}
+
+	multi["Accept"][0] = "changed"
+	if e, a := "text/html", h.Get("Accept"); e != a {
+		t.Errorf("expect values copied, %q, got %q", e, a)
+	}
+}
+
+func TestToSingleValue(t *testing.T) {
+	cases := map[string]struct {
+		header http.Header
+		expect map[string]string
+	}{
+		"joined": {
+			header: http.Header{"Accept": {"text/html", "application/json"}},
+			expect: map[string]string{"Accept": "text/html,application/json"},
+		},
+		"set-cookie last value": {
+			header: http.Header{"Set-Cookie": {"a=1", "b=2"}},
+			expect: map[string]string{"Set-Cookie": "b=2"},
+		},
+		"empty values skipped": {
+			header: http.Header{"X-Empty": {}, "X-A": {"1"}},
+			expect: map[string]string{"X-A": "1"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if e, a := c.expect, ToSingleValue(c.header); !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestFromV2(t *testing.T) {
+	cases := map[string]struct {
+		headers map[string]string
+		cookies []string
+		expect  http.Header
+	}{
+		"headers": {
+			headers: map[string]string{"content-type": "application/json", "accept": "a, b"},
+			expect:  http.Header{"Content-Type": {"application/json"}, "Accept": {"a, b"}},
+		},
+		"cookies joined": {
+			headers: map[string]string{"x-a": "1"},
+			cookies: []string{"a=1", "b=2"},
+			expect:  http.Header{"X-A": {"1"}, "Cookie": {"a=1; b=2"}},
+		},
+		"cookies replace cookie header": {
+			headers: map[string]string{"cookie": "old=1"},
+			cookies: []string{"a=1"},
+			expect:  http.Header{"Cookie": {"a=1"}},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if e, a := c.expect, FromV2(c.headers, c.cookies); !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestToV2(t *testing.T) {
+	cases := map[string]struct {
+		header        http.Header
+		expect        map[string]string
+		expectCookies []string
+	}{
+		"joined": {
+			header: http.Header{"Vary": {"Accept", "Origin"}},
+			expect: map[string]string{"Vary": "Accept,Origin"},
+		},
+		"set-cookie as cookies": {
+			header:        http.Header{"Set-Cookie": {"a=1", "b=2"}, "X-A": {"1"}},
+			expect:        map[string]string{"X-A": "1"},
+			expectCookies: []string{"a=1", "b=2"},
+		},
+		"merged event headers": {
+			header: FromEvent(
+				map[string]string{"x-single": "1"},
+				map[string][]string{"x-multi": {"a", "b"}, "set-cookie": {"c=1"}},
+			),
+			expect:        map[string]string{"X-Single": "1", "X-Multi": "a,b"},
+			expectCookies: []string{"c=1"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			headers, cookies := ToV2(c.header)
+			if e, a := c.expect, headers; !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v headers, got %v", e, a)
+			}
+			if e, a := c.expectCookies, cookies; !reflect.DeepEqual(e, a) {
+				t.Errorf("expect %v cookies, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestV2RoundTrip(t *testing.T) {
+	h := http.Header{
+		"Content-Type": {"application/json"},
+		"Accept":       {"text/html", "application/json"},
+	}
+
+	single, _ := ToV2(h)
+	actual := FromV2(single, nil)
+	if e, a := "application/json", actual.Get("content-type"); e != a {
+		t.Errorf("expect %q, got %q", e, a)
+	}
+	if e, a := "text/html,application/json", actual.Get("accept"); e != a {
+		t.Errorf("expect %q, got %q", e, a)
+	}
+}
+
+func TestRemoveHopByHop(t *testing.T) {
+	h := http.Header{
+		"Connection":        {"keep-alive, X-Private"},
+		"Keep-Alive":        {"timeout=5"},
+		"Transfer-Encoding": {"chunked"},
+		"X-Private":         {"secret"},
+		"Content-Type":      {"text/plain"},
+	}
+	RemoveHopByHop(h)
+
+	if e, a := []string{"Content-Type"}, headerNames(h); !reflect.DeepEqual(e, a) {
+		t.Errorf("expect %v, got %v", e, a)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := http.Header{
+		"Connection": {"X-Private"},
+		"Upgrade":    {"websocket"},
+		"X-Private":  {"secret"},
+		"x-public":   {"b"},
+		"Accept":     {"text/html", "application/json"},
+	}
+	dst := http.Header{"X-Public": {"a"}}
+	Copy(dst, src)
+
+	expect := http.Header{
+		"X-Public": {"a", "b"},
+		"Accept":   {"text/html", "application/json"},
+	}
+	if !reflect.DeepEqual(expect, dst) {
+		t.Errorf("expect %v, got %v", expect, dst)
+	}
+}
+
+func headerNames(h http.Header) []string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
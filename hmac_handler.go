@@ -0,0 +1,129 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// SignatureEncoding is the encoding of a HMAC signature in a request.
+type SignatureEncoding int
+
+const (
+	// HexSignature signatures are hex encoded.
+	HexSignature SignatureEncoding = iota
+
+	// Base64Signature signatures are standard base64 encoded.
+	Base64Signature
+)
+
+// HMACVerifier verifies the HMAC signature of requests, e.g. signed webhook
+// deliveries.
+type HMACVerifier struct {
+	Secret []byte
+
+	// Hash function of the HMAC. Defaults to SHA-256.
+	Hash func() hash.Hash
+
+	// Header the signature is read from, with the Prefix, e.g.
+	// "sha256=", removed.
+	Header string
+	Prefix string
+
+	// Encoding of the signature. Defaults to hex.
+	Encoding SignatureEncoding
+
+	// Signatures returns the request's encoded signatures, overriding the
+	// Header and Prefix. The request is verified if any signature matches.
+	Signatures func(req APIGatewayProxyRequest) []string
+
+	// Message returns the signed message of the request. Defaults to the
+	// request's body. Returning an error fails verification, e.g. when a
+	// signed timestamp is outside the allowed tolerance.
+	Message func(req APIGatewayProxyRequest, body []byte) ([]byte, error)
+}
+
+// Verify returns an error if the request's HMAC signature is missing, or
+// does not match the signature computed for the request.
+func (v HMACVerifier) Verify(req APIGatewayProxyRequest) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	return v.verify(req, body)
+}
+
+func (v HMACVerifier) verify(req APIGatewayProxyRequest, body []byte) error {
+	var signatures []string
+	if v.Signatures != nil {
+		signatures = v.Signatures(req)
+	} else if sig := req.HTTPHeader.Get(v.Header); strings.HasPrefix(sig, v.Prefix) {
+		signatures = []string{strings.TrimPrefix(sig, v.Prefix)}
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("request signature missing")
+	}
+
+	message := body
+	if v.Message != nil {
+		var err error
+		if message, err = v.Message(req, body); err != nil {
+			return err
+		}
+	}
+
+	hashFn := v.Hash
+	if hashFn == nil {
+		hashFn = sha256.New
+	}
+	mac := hmac.New(hashFn, v.Secret)
+	mac.Write(message)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		var actual []byte
+		var err error
+		switch v.Encoding {
+		case Base64Signature:
+			actual, err = base64.StdEncoding.DecodeString(sig)
+		default:
+			actual, err = hex.DecodeString(sig)
+		}
+		if err == nil && hmac.Equal(actual, expected) {
+			return nil
+		}
+	}
+	return fmt.Errorf("request signature mismatch")
+}
+
+type hmacHandler struct {
+	Verifier HMACVerifier
+	Handler  ResourceHandler
+}
+
+// ResourceHandlerWithHMAC provides a resource handler that verifies the
+// HMAC signature of requests before passing them to handler. Requests that
+// fail verification are responded to with a 401 Unauthorized response.
+func ResourceHandlerWithHMAC(verifier HMACVerifier, handler ResourceHandler) ResourceHandler {
+	return hmacHandler{
+		Verifier: verifier,
+		Handler:  handler,
+	}
+}
+
+// ServeResource verifies the request's signature, and delegates to the
+// wrapped handler.
+func (h hmacHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	if err := h.Verifier.Verify(req); err != nil {
+		return statusResponse(http.StatusUnauthorized), nil
+	}
+	return h.Handler.ServeResource(ctx, req)
+}
@@ -0,0 +1,122 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// CSPNonceFuncs returns the template functions HTMLTemplate binds to the
+// request, for templates to be parsed with. The "cspNonce" function returns
+// the request's content security policy nonce, e.g. for
+// `<link rel="preload" nonce="{{cspNonce}}">`.
+func CSPNonceFuncs() template.FuncMap {
+	return template.FuncMap{
+		"cspNonce": func() string { return "" },
+	}
+}
+
+// HTMLTemplate renders html/template templates as HTML responses.
+//
+// When the request is served by the security headers middleware with a
+// content security policy nonce, the nonce is added to the inline, and
+// external, script and style elements of the rendered template, so they are
+// permitted by the policy. Elements that already have a nonce attribute are
+// not modified.
+type HTMLTemplate struct {
+	Template *template.Template
+}
+
+// Render executes the named template with the data, and returns it as the
+// body of a response with the status code.
+func (t HTMLTemplate) Render(
+	ctx context.Context, status int, name string, data interface{},
+) (APIGatewayProxyResponse, error) {
+	// The template is cloned for each render, since html/template templates
+	// cannot be cloned once executed, and the nonce is bound to the clone.
+	tmpl, err := t.Template.Clone()
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to clone template, %w", err)
+	}
+	nonce := CSPNonceFromContext(ctx)
+	if len(nonce) != 0 {
+		tmpl.Funcs(template.FuncMap{
+			"cspNonce": func() string { return nonce },
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to render template %s, %w", name, err)
+	}
+
+	body := buf.String()
+	if len(nonce) != 0 {
+		body = addCSPNonce(body, nonce)
+	}
+
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: status,
+			Body:       body,
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{"text/html; charset=utf-8"},
+		},
+	}, nil
+}
+
+// addCSPNonce adds the nonce attribute to the script and style elements of
+// the HTML document that do not have one. Since the document is rendered by
+// html/template, elements can only be introduced by the template, and not
+// its data.
+func addCSPNonce(doc, nonce string) string {
+	lower := strings.ToLower(doc)
+
+	var b strings.Builder
+	b.Grow(len(doc))
+
+	i := 0
+	for i < len(doc) {
+		idx := strings.IndexByte(lower[i:], '<')
+		if idx < 0 {
+			break
+		}
+		start := i + idx
+
+		var tagLen int
+		for _, tag := range []string{"<script", "<style"} {
+			if strings.HasPrefix(lower[start:], tag) && start+len(tag) < len(doc) {
+				if c := doc[start+len(tag)]; c == '>' || c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '/' {
+					tagLen = len(tag)
+				}
+			}
+		}
+		if tagLen == 0 {
+			b.WriteString(doc[i : start+1])
+			i = start + 1
+			continue
+		}
+
+		end := strings.IndexByte(lower[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(doc[i : start+tagLen])
+		if !strings.Contains(lower[start:end], " nonce=") {
+			b.WriteString(` nonce="` + nonce + `"`)
+		}
+		b.WriteString(doc[start+tagLen : end+1])
+		i = end + 1
+	}
+
+	b.WriteString(doc[i:])
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package lambdamux
+
+import (
+	"context"
+	"html/template"
+	"testing"
+)
+
+func TestHTMLTemplateRender(t *testing.T) {
+	tmpl := HTMLTemplate{
+		Template: template.Must(template.New("p").Funcs(CSPNonceFuncs()).Parse(
+			`<p>{{.}}</p><script>run()</script><link rel="preload" nonce="{{cspNonce}}">`)),
+	}
+
+	cases := []struct {
+		name   string
+		nonce  string
+		expect string
+	}{
+		{
+			name:   "plain",
+			expect: `<p>a&lt;b</p><script>run()</script><link rel="preload" nonce="">`,
+		},
+		{
+			name:   "nonce after plain",
+			nonce:  "abc",
+			expect: `<p>a&lt;b</p><script nonce="abc">run()</script><link rel="preload" nonce="abc">`,
+		},
+		{
+			name:   "other nonce",
+			nonce:  "def",
+			expect: `<p>a&lt;b</p><script nonce="def">run()</script><link rel="preload" nonce="def">`,
+		},
+		{
+			name:   "plain after nonce",
+			expect: `<p>a&lt;b</p><script>run()</script><link rel="preload" nonce="">`,
+		},
+	}
+
+	// The cases are rendered in order, with the same template, so renders
+	// with a nonce follow renders without one.
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			if len(c.nonce) != 0 {
+				ctx = context.WithValue(ctx, cspNonceKey{}, c.nonce)
+			}
+
+			resp, err := tmpl.Render(ctx, 200, "p", "a<b")
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := 200, resp.StatusCode; e != a {
+				t.Errorf("expect %v status, got %v", e, a)
+			}
+			if e, a := "text/html; charset=utf-8", resp.HTTPHeader.Get("Content-Type"); e != a {
+				t.Errorf("expect %q content type, got %q", e, a)
+			}
+			if e, a := c.expect, resp.Body; e != a {
+				t.Errorf("expect body\n%s\ngot\n%s", e, a)
+			}
+		})
+	}
+}
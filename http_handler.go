@@ -0,0 +1,297 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go.jasdel.dev/aws/lambda-mux/headers"
+)
+
+type proxyRequestKey struct{}
+
+// ProxyRequestFromContext returns the API Gateway Proxy request an
+// http.Handler wrapped by WrapHTTPHandler is serving, e.g. for the
+// request's authorizer context, or false if the context is not of a
+// wrapped handler's request.
+func ProxyRequestFromContext(ctx context.Context) (APIGatewayProxyRequest, bool) {
+	req, ok := ctx.Value(proxyRequestKey{}).(APIGatewayProxyRequest)
+	return req, ok
+}
+
+type httpHandler struct {
+	handler http.Handler
+}
+
+// WrapHTTPHandler returns a resource handler serving API Gateway Proxy
+// requests with the http.Handler, e.g. a chi, or gorilla/mux, router, or
+// http.ServeMux, so existing net/http applications are served by the mux.
+//
+// The http.Request is built from the proxy request's method, path, query,
+// headers, and body, decoded if base64 encoded, with its RemoteAddr set to
+// the request's source IP. The proxy request is available to the handler
+// via ProxyRequestFromContext. The handler's response is captured, and
+// returned as the APIGatewayProxyResponse, with binary bodies base64
+// encoded, as Bytes does. The Content-Type of responses without one is
+// detected from the body, as net/http does.
+func WrapHTTPHandler(handler http.Handler) ResourceHandler {
+	return httpHandler{handler: handler}
+}
+
+// ServeResource implements the ResourceHandler interface, serving the
+// request with the http.Handler.
+func (h httpHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	r, err := newHTTPRequest(ctx, req)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	w := &httpResponseWriter{header: http.Header{}}
+	h.handler.ServeHTTP(w, r)
+	return w.response(), nil
+}
+
+// newHTTPRequest returns the http.Request of the API Gateway Proxy request.
+func newHTTPRequest(ctx context.Context, req APIGatewayProxyRequest) (*http.Request, error) {
+	body, err := req.BodyBytes()
+	if err != nil {
+		return nil, BadRequest(err.Error())
+	}
+
+	u := &url.URL{
+		Path:     req.Path,
+		RawQuery: requestQuery(req).Encode(),
+	}
+	requestURI := u.RequestURI()
+
+	ctx = context.WithValue(ctx, proxyRequestKey{}, req)
+	r, err := http.NewRequestWithContext(ctx, req.HTTPMethod, requestURI, bytes.NewReader(body))
+	if err != nil {
+		return nil, BadRequest("invalid request, " + err.Error())
+	}
+
+	r.RequestURI = requestURI
+	r.Header = req.HTTPHeader.Clone()
+	if r.Header == nil {
+		r.Header = http.Header{}
+	}
+	r.Host = r.Header.Get("Host")
+	if len(r.Host) == 0 {
+		r.Host = req.RequestContext.DomainName
+	}
+	r.URL.Host = r.Host
+	if proto := r.Header.Get("X-Forwarded-Proto"); len(proto) != 0 {
+		r.URL.Scheme = proto
+	}
+	r.RemoteAddr = req.RequestContext.Identity.SourceIP
+	r.ContentLength = int64(len(body))
+	return r, nil
+}
+
+// httpResponseWriter is the http.ResponseWriter capturing the response of
+// a wrapped http.Handler.
+type httpResponseWriter struct {
+	header      http.Header
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+}
+
+func (w *httpResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *httpResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.statusCode = statusCode
+	w.wroteHeader = true
+}
+
+func (w *httpResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+// response returns the APIGatewayProxyResponse of the captured response.
+func (w *httpResponseWriter) response() APIGatewayProxyResponse {
+	w.WriteHeader(http.StatusOK)
+
+	body := w.body.Bytes()
+	contentType := w.header.Get("Content-Type")
+	if len(contentType) == 0 && len(body) != 0 {
+		contentType = http.DetectContentType(body)
+	}
+
+	resp := Bytes(w.statusCode, contentType, body)
+	resp.HTTPHeader = w.header.Clone()
+	if len(contentType) != 0 {
+		resp.HTTPHeader.Set("Content-Type", contentType)
+	}
+	if _, ok := resp.HTTPHeader["Content-Length"]; !ok && len(body) != 0 {
+		resp.HTTPHeader.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	return resp
+}
+
+type resourceHTTPHandler struct {
+	handler ResourceHandler
+}
+
+// AsHTTPHandler returns an http.Handler serving HTTP requests with the
+// resource handler, so handlers written for the mux can be mounted in an
+// HTTP server, e.g. for hybrid deployments, and tests with httptest.
+//
+// An API Gateway Proxy event is synthesized from the request, with the
+// request's path as the event's Resource, and no path parameters. Bodies
+// with content types that are not textual are base64 encoded, as API
+// Gateway encodes binary bodies. Errors returned by the handler are
+// translated as the APIGatewayProxy does without an ErrorHandler, other
+// errors are logged, and responded to with 502 Bad Gateway, as API Gateway
+// responds to failed invokes.
+func AsHTTPHandler(handler ResourceHandler) http.Handler {
+	return resourceHTTPHandler{handler: handler}
+}
+
+// ServeHTTP implements the http.Handler interface, serving the request with
+// the resource handler.
+func (h resourceHTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := proxyRequestFromHTTP(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, done := withRequestDone(r.Context())
+	defer done()
+
+	resp, err := h.handler.ServeResource(ctx, req)
+	if err != nil {
+		if resp, err = handlerErrorResponse(ctx, nil, req, err); err != nil {
+			log.Printf("%s %s %s failed, %v", req.RequestContext.RequestID,
+				req.HTTPMethod, req.Path, err)
+			resp = statusResponse(http.StatusBadGateway)
+		}
+	}
+
+	writeProxyResponse(w, resp)
+}
+
+// proxyRequestFromHTTP returns the API Gateway Proxy request synthesized
+// from the HTTP request, with the request's path as its Resource. Returns
+// an error if the request's body cannot be read.
+func proxyRequestFromHTTP(r *http.Request) (APIGatewayProxyRequest, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return APIGatewayProxyRequest{}, fmt.Errorf("failed to read request body, %w", err)
+		}
+	}
+
+	h := r.Header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	if len(r.Host) != 0 {
+		h.Set("Host", r.Host)
+	}
+	query := r.URL.Query()
+	single := make(map[string]string, len(query))
+	for k, v := range query {
+		single[k] = v[len(v)-1]
+	}
+
+	var req APIGatewayProxyRequest
+	req.Resource = r.URL.Path
+	req.Path = r.URL.Path
+	req.HTTPMethod = r.Method
+	req.HTTPHeader = h
+	req.Headers = headers.ToSingleValue(h)
+	req.MultiValueHeaders = headers.ToMultiValue(h)
+	req.QueryStringParameters = single
+	req.MultiValueQueryStringParameters = query
+	req.RawQuery = r.URL.RawQuery
+
+	contentType := h.Get("Content-Type")
+	if len(body) == 0 || isTextMediaType(contentType) ||
+		contentType == "application/x-www-form-urlencoded" {
+		req.Body = string(body)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.IsBase64Encoded = true
+	}
+
+	sourceIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		sourceIP = host
+	}
+	now := time.Now().UTC()
+
+	rc := &req.RequestContext
+	rc.RequestID = newLocalRequestID()
+	rc.DomainName = r.Host
+	rc.HTTPMethod = r.Method
+	rc.ResourcePath = req.Resource
+	rc.Protocol = r.Proto
+	rc.RequestTime = now.Format("02/Jan/2006:15:04:05 -0700")
+	rc.RequestTimeEpoch = now.UnixNano() / int64(time.Millisecond)
+	rc.Identity.SourceIP = sourceIP
+	rc.Identity.UserAgent = r.UserAgent()
+
+	req.initMaps()
+	return req, nil
+}
+
+// newLocalRequestID returns a random request ID for requests synthesized
+// from HTTP requests.
+func newLocalRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// writeProxyResponse writes the APIGatewayProxyResponse to w, merging the
+// response's single value headers, as API Gateway does.
+func writeProxyResponse(w http.ResponseWriter, resp APIGatewayProxyResponse) {
+	body, err := resp.BodyBytes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	h := w.Header()
+	for k, v := range resp.HTTPHeader {
+		h[k] = append([]string(nil), v...)
+	}
+	for k, v := range resp.MultiValueHeaders {
+		if _, ok := resp.HTTPHeader[http.CanonicalHeaderKey(k)]; !ok {
+			h[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+	for k, v := range resp.Headers {
+		if len(h.Values(k)) == 0 {
+			h.Set(k, v)
+		}
+	}
+
+	statusCode := resp.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+	w.Write(body)
+}
@@ -0,0 +1,378 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // decode GIF originals
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrObjectNotFound is returned, or wrapped, by S3ObjectAPI implementations
+// for objects that do not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// S3ObjectAPI is the interface for the S3 object operations used by
+// ImageHandler. Implemented by the application with the AWS SDK's S3
+// GetObject, and PutObject, operations. GetObject returns an error wrapping
+// ErrObjectNotFound if the object does not exist.
+type S3ObjectAPI interface {
+	GetObject(ctx context.Context, bucket, key string) (body []byte, contentType string, err error)
+	PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error
+}
+
+// ImageHandler is a resource handler serving images from S3, resized, and
+// converted, on the fly by the request's query parameters:
+//
+//	w       maximum width, in pixels
+//	h       maximum height, in pixels
+//	fit     "contain" (default) fits the image within the width and
+//	        height, "cover" crops the image to fill them, and "fill"
+//	        stretches the image to them
+//	format  "jpeg", or "png", defaults to the original's format
+//	q       JPEG quality, 1 to 100
+//
+// Requests without transform parameters are served the original image.
+// Transformed images, derivatives, are written back to S3 under the
+// DerivativePrefix, and served from there by later requests. Responses are
+// cacheable, with an ETag of the image's content.
+type ImageHandler struct {
+	Client S3ObjectAPI
+	Bucket string
+
+	// Key returns the object key of the request's original image. Defaults
+	// to the request's "key", or "proxy", path parameter, e.g. the
+	// resource "/images/{key+}".
+	Key func(APIGatewayProxyRequest) string
+
+	// DerivativePrefix is the key prefix of derivatives. Defaults to
+	// "derivatives/".
+	DerivativePrefix string
+
+	// MaxWidth and MaxHeight limit the requested dimensions. Default to
+	// 4096.
+	MaxWidth, MaxHeight int
+
+	// Quality is the default JPEG quality. Defaults to 85.
+	Quality int
+
+	// CacheMaxAge is the max-age of the Cache-Control header. Defaults to
+	// 24 hours.
+	CacheMaxAge time.Duration
+}
+
+type imageTransform struct {
+	width, height int
+	fit           string
+	format        string
+	quality       int
+}
+
+// ServeResource serves the request's image.
+func (h ImageHandler) ServeResource(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	key := h.key(req)
+	if len(key) == 0 || strings.Contains(key, "..") {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	t, ok, err := h.transform(req)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	if !ok {
+		body, contentType, err := h.Client.GetObject(ctx, h.Bucket, key)
+		if errors.Is(err, ErrObjectNotFound) {
+			return statusResponse(http.StatusNotFound), nil
+		} else if err != nil {
+			return APIGatewayProxyResponse{}, fmt.Errorf("failed to get image %q, %w", key, err)
+		}
+		return h.response(req, body, contentType), nil
+	}
+
+	derivative := h.derivativePrefix() + key + "/" + t.String()
+	body, contentType, err := h.Client.GetObject(ctx, h.Bucket, derivative)
+	if err == nil {
+		return h.response(req, body, contentType), nil
+	} else if !errors.Is(err, ErrObjectNotFound) {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to get image derivative %q, %w", derivative, err)
+	}
+
+	original, _, err := h.Client.GetObject(ctx, h.Bucket, key)
+	if errors.Is(err, ErrObjectNotFound) {
+		return statusResponse(http.StatusNotFound), nil
+	} else if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to get image %q, %w", key, err)
+	}
+
+	body, contentType, err = t.apply(original)
+	if err != nil {
+		return statusResponse(http.StatusUnprocessableEntity), nil
+	}
+
+	// Writing back the derivative is best effort, it is generated again by
+	// the next request if the write fails.
+	h.Client.PutObject(ctx, h.Bucket, derivative, body, contentType)
+
+	return h.response(req, body, contentType), nil
+}
+
+func (h ImageHandler) key(req APIGatewayProxyRequest) string {
+	if h.Key != nil {
+		return h.Key(req)
+	}
+	if key, ok := req.PathParameters["key"]; ok {
+		return key
+	}
+	return req.PathParameters["proxy"]
+}
+
+func (h ImageHandler) derivativePrefix() string {
+	if len(h.DerivativePrefix) == 0 {
+		return "derivatives/"
+	}
+	return h.DerivativePrefix
+}
+
+// transform returns the request's image transform, and if the request has
+// transform parameters.
+func (h ImageHandler) transform(req APIGatewayProxyRequest) (imageTransform, bool, error) {
+	query := requestQuery(req)
+
+	t := imageTransform{
+		fit:     query.Get("fit"),
+		format:  query.Get("format"),
+		quality: h.Quality,
+	}
+	if t.quality == 0 {
+		t.quality = 85
+	}
+
+	ok := false
+	for _, p := range []struct {
+		name string
+		v    *int
+		max  int
+	}{
+		{"w", &t.width, h.MaxWidth},
+		{"h", &t.height, h.MaxHeight},
+		{"q", &t.quality, 100},
+	} {
+		s := query.Get(p.name)
+		if len(s) == 0 {
+			continue
+		}
+		if p.max == 0 {
+			p.max = 4096
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > p.max {
+			return t, false, fmt.Errorf("invalid %s parameter, %q", p.name, s)
+		}
+		*p.v = n
+		ok = true
+	}
+
+	switch t.fit {
+	case "":
+		t.fit = "contain"
+	case "contain", "cover", "fill":
+		ok = true
+	default:
+		return t, false, fmt.Errorf("invalid fit parameter, %q", t.fit)
+	}
+	switch t.format {
+	case "":
+	case "jpeg", "png":
+		ok = true
+	default:
+		return t, false, fmt.Errorf("invalid format parameter, %q", t.format)
+	}
+
+	return t, ok, nil
+}
+
+// String returns the canonical form of the transform, used in the
+// derivative's key.
+func (t imageTransform) String() string {
+	return fmt.Sprintf("w=%d,h=%d,fit=%s,format=%s,q=%d", t.width, t.height, t.fit, t.format, t.quality)
+}
+
+func (t imageTransform) apply(original []byte) ([]byte, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(t.format) != 0 {
+		format = t.format
+	}
+
+	dst := resizeImage(src, t.width, t.height, t.fit)
+
+	var buf bytes.Buffer
+	if format == "png" {
+		err = png.Encode(&buf, dst)
+	} else {
+		format = "jpeg"
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: t.quality})
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/" + format, nil
+}
+
+func (h ImageHandler) response(req APIGatewayProxyRequest, body []byte, contentType string) APIGatewayProxyResponse {
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+
+	maxAge := h.CacheMaxAge
+	if maxAge == 0 {
+		maxAge = 24 * time.Hour
+	}
+
+	header := http.Header{
+		"Etag":          []string{etag},
+		"Cache-Control": []string{"public, max-age=" + strconv.Itoa(int(maxAge/time.Second))},
+	}
+
+	for _, v := range strings.Split(req.HTTPHeader.Get("If-None-Match"), ",") {
+		if strings.TrimPrefix(strings.TrimSpace(v), "W/") == etag {
+			return APIGatewayProxyResponse{
+				APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+					StatusCode: http.StatusNotModified,
+				},
+				HTTPHeader: header,
+			}
+		}
+	}
+
+	header.Set("Content-Type", contentType)
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode:      http.StatusOK,
+			Body:            base64.StdEncoding.EncodeToString(body),
+			IsBase64Encoded: true,
+		},
+		HTTPHeader: header,
+	}
+}
+
+// resizeImage returns the image resized to the width and height by the fit
+// mode, with bilinear interpolation. Zero width, or height, is derived from
+// the image's aspect ratio. Images are not enlarged by the contain mode.
+func resizeImage(src image.Image, width, height int, fit string) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 || (width == 0 && height == 0) {
+		return src
+	}
+
+	if width == 0 {
+		width = sw * height / sh
+	} else if height == 0 {
+		height = sh * width / sw
+	}
+
+	crop := b
+	switch fit {
+	case "contain":
+		scale := minFloat(float64(width)/float64(sw), float64(height)/float64(sh))
+		if scale > 1 {
+			scale = 1
+		}
+		width, height = int(float64(sw)*scale+0.5), int(float64(sh)*scale+0.5)
+	case "cover":
+		scale := maxFloat(float64(width)/float64(sw), float64(height)/float64(sh))
+		cw, ch := int(float64(width)/scale+0.5), int(float64(height)/scale+0.5)
+		x0, y0 := b.Min.X+(sw-cw)/2, b.Min.Y+(sh-ch)/2
+		crop = image.Rect(x0, y0, x0+cw, y0+ch)
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, crop.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	cw, ch := crop.Dx(), crop.Dy()
+	for y := 0; y < height; y++ {
+		fy := (float64(y)+0.5)*float64(ch)/float64(height) - 0.5
+		for x := 0; x < width; x++ {
+			fx := (float64(x)+0.5)*float64(cw)/float64(width) - 0.5
+			dst.SetRGBA(x, y, bilinear(rgba, fx, fy))
+		}
+	}
+	return dst
+}
+
+func bilinear(img *image.RGBA, fx, fy float64) color.RGBA {
+	b := img.Bounds()
+	clamp := func(v, max int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= max {
+			return max - 1
+		}
+		return v
+	}
+
+	x0, y0 := int(fx), int(fy)
+	if fx < 0 {
+		x0 = -1
+	}
+	if fy < 0 {
+		y0 = -1
+	}
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	c00 := img.RGBAAt(clamp(x0, b.Dx()), clamp(y0, b.Dy()))
+	c10 := img.RGBAAt(clamp(x0+1, b.Dx()), clamp(y0, b.Dy()))
+	c01 := img.RGBAAt(clamp(x0, b.Dx()), clamp(y0+1, b.Dy()))
+	c11 := img.RGBAAt(clamp(x0+1, b.Dx()), clamp(y0+1, b.Dy()))
+
+	mix := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-tx) + float64(b)*tx
+		bottom := float64(c)*(1-tx) + float64(d)*tx
+		return uint8(top*(1-ty) + bottom*ty + 0.5)
+	}
+
+	return color.RGBA{
+		R: mix(c00.R, c10.R, c01.R, c11.R),
+		G: mix(c00.G, c10.G, c01.G, c11.G),
+		B: mix(c00.B, c10.B, c01.B, c11.B),
+		A: mix(c00.A, c10.A, c01.A, c11.A),
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,284 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// traceHeader is the HTTP header of the X-Ray trace ID.
+const traceHeader = "X-Amzn-Trace-Id"
+
+// InternalRequest is a request of one Lambda function to another, invoked
+// directly through the Lambda Invoke API, and routed by the invoked
+// function's mux like an API Gateway request.
+type InternalRequest struct {
+	Method string
+
+	// Resource the request is routed to, e.g. "/users/{id}", with the
+	// resource's path parameters. Defaults to the Path.
+	Resource       string
+	Path           string
+	PathParameters map[string]string
+
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+
+	// Deadline and X-Ray trace ID of the caller, propagated to the
+	// invoked function. Set by the InternalClient from the context.
+	Deadline time.Time
+	TraceID  string
+}
+
+// InternalResponse is the response to an InternalRequest.
+type InternalResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// InternalCodec is the interface for serializing internal requests and
+// responses, e.g. with JSON, gob, or an application provided protobuf
+// mapping. The codec's name identifies it to the invoked function.
+type InternalCodec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(b []byte, v interface{}) error
+}
+
+// JSONInternalCodec serializes internal requests and responses as JSON.
+var JSONInternalCodec InternalCodec = jsonInternalCodec{}
+
+// GobInternalCodec serializes internal requests and responses with
+// encoding/gob.
+var GobInternalCodec InternalCodec = gobInternalCodec{}
+
+type jsonInternalCodec struct{}
+
+func (jsonInternalCodec) Name() string                            { return "json" }
+func (jsonInternalCodec) Marshal(v interface{}) ([]byte, error)   { return json.Marshal(v) }
+func (jsonInternalCodec) Unmarshal(b []byte, v interface{}) error { return json.Unmarshal(b, v) }
+
+type gobInternalCodec struct{}
+
+func (gobInternalCodec) Name() string { return "gob" }
+
+func (gobInternalCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobInternalCodec) Unmarshal(b []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// internalEnvelope is the JSON Lambda payload carrying a serialized internal
+// request, or response. Lambda payloads must be JSON, so the serialized
+// message is carried base64 encoded.
+type internalEnvelope struct {
+	Internal *internalMessage `json:"lambdamuxInternal"`
+}
+
+type internalMessage struct {
+	Codec   string `json:"codec"`
+	Payload []byte `json:"payload"`
+}
+
+// LambdaInvokeAPI is the interface for the Lambda Invoke operation the
+// InternalClient is built on. The package does not depend on the AWS SDK,
+// applications adapt their SDK Lambda client to the interface. Invoke must
+// synchronously invoke the function, and return an error if the invocation
+// failed, including the function returning an error.
+type LambdaInvokeAPI interface {
+	Invoke(ctx context.Context, functionName string, payload []byte) ([]byte, error)
+}
+
+// InternalClient invokes Lambda functions served by an
+// InternalInvokeHandler with internal requests.
+type InternalClient struct {
+	Lambda       LambdaInvokeAPI
+	FunctionName string
+
+	// Codec internal requests are serialized with. Defaults to
+	// JSONInternalCodec.
+	Codec InternalCodec
+}
+
+// Invoke invokes the function with the internal request, returning the
+// function's response. The request's deadline and trace ID are taken from
+// the context if not set.
+func (c InternalClient) Invoke(ctx context.Context, req InternalRequest) (InternalResponse, error) {
+	var resp InternalResponse
+
+	codec := c.Codec
+	if codec == nil {
+		codec = JSONInternalCodec
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && req.Deadline.IsZero() {
+		req.Deadline = deadline
+	}
+	if len(req.TraceID) == 0 {
+		req.TraceID, _ = ctx.Value("x-amzn-trace-id").(string)
+	}
+
+	b, err := codec.Marshal(req)
+	if err != nil {
+		return resp, fmt.Errorf("failed to marshal internal request, %w", err)
+	}
+	payload, err := json.Marshal(internalEnvelope{
+		Internal: &internalMessage{Codec: codec.Name(), Payload: b},
+	})
+	if err != nil {
+		return resp, fmt.Errorf("failed to marshal internal request, %w", err)
+	}
+
+	out, err := c.Lambda.Invoke(ctx, c.FunctionName, payload)
+	if err != nil {
+		return resp, fmt.Errorf("failed to invoke %s, %w", c.FunctionName, err)
+	}
+
+	var envelope internalEnvelope
+	if err := json.Unmarshal(out, &envelope); err != nil || envelope.Internal == nil {
+		return resp, fmt.Errorf("invalid internal response from %s", c.FunctionName)
+	}
+	if err := codec.Unmarshal(envelope.Internal.Payload, &resp); err != nil {
+		return resp, fmt.Errorf("failed to unmarshal internal response, %w", err)
+	}
+	return resp, nil
+}
+
+type internalInvokeKey struct{}
+
+// IsInternalInvoke returns if the request being served is an internal
+// request of another Lambda function, and not an API Gateway request.
+func IsInternalInvoke(ctx context.Context) bool {
+	v, _ := ctx.Value(internalInvokeKey{}).(bool)
+	return v
+}
+
+// InternalInvokeHandler is a Lambda Handler serving internal requests
+// invoked by an InternalClient with the resource handler, and delegating
+// all other invocations to Next, e.g. an APIGatewayProxy. Internal requests
+// are converted to API Gateway Proxy requests, so the application's mux
+// routes them like API requests.
+//
+// The caller's deadline, if earlier than the invocation's, is applied to the
+// context, and its trace ID is set as the X-Amzn-Trace-Id header.
+type InternalInvokeHandler struct {
+	Handler ResourceHandler
+	Next    lambda.Handler
+
+	// Codecs of internal requests supported in addition to JSON and gob.
+	Codecs []InternalCodec
+}
+
+// Invoke implements the lambda.Handler interface.
+func (h InternalInvokeHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	var envelope internalEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil || envelope.Internal == nil {
+		if h.Next == nil {
+			return nil, fmt.Errorf("invalid lambda event, expect internal request")
+		}
+		return h.Next.Invoke(ctx, payload)
+	}
+
+	codec := h.codec(envelope.Internal.Codec)
+	if codec == nil {
+		return nil, fmt.Errorf("unsupported internal request codec %q", envelope.Internal.Codec)
+	}
+
+	var in InternalRequest
+	if err := codec.Unmarshal(envelope.Internal.Payload, &in); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal internal request, %w", err)
+	}
+
+	if !in.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, in.Deadline)
+		defer cancel()
+	}
+	ctx = context.WithValue(ctx, internalInvokeKey{}, true)
+
+	resp, err := h.Handler.ServeResource(ctx, in.proxyRequest())
+	if err != nil {
+		return nil, err
+	}
+
+	out := InternalResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.HTTPHeader,
+	}
+	if out.Body, err = resp.BodyBytes(); err != nil {
+		return nil, err
+	}
+
+	b, err := codec.Marshal(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal internal response, %w", err)
+	}
+	return json.Marshal(internalEnvelope{
+		Internal: &internalMessage{Codec: codec.Name(), Payload: b},
+	})
+}
+
+func (h InternalInvokeHandler) codec(name string) InternalCodec {
+	for _, c := range h.Codecs {
+		if c.Name() == name {
+			return c
+		}
+	}
+	switch name {
+	case JSONInternalCodec.Name():
+		return JSONInternalCodec
+	case GobInternalCodec.Name():
+		return GobInternalCodec
+	}
+	return nil
+}
+
+// proxyRequest returns the API Gateway Proxy request of the internal
+// request.
+func (r InternalRequest) proxyRequest() APIGatewayProxyRequest {
+	header := http.Header{}
+	for k, v := range r.Header {
+		header[k] = v
+	}
+	if len(r.TraceID) != 0 {
+		header.Set(traceHeader, r.TraceID)
+	}
+
+	resource := r.Resource
+	if len(resource) == 0 {
+		resource = r.Path
+	}
+
+	req := APIGatewayProxyRequest{
+		APIGatewayProxyRequest: events.APIGatewayProxyRequest{
+			Resource:                        resource,
+			Path:                            r.Path,
+			HTTPMethod:                      r.Method,
+			PathParameters:                  r.PathParameters,
+			MultiValueQueryStringParameters: r.Query,
+			MultiValueHeaders:               header,
+			Body:                            string(r.Body),
+		},
+		HTTPHeader: header,
+	}
+	req.RequestContext.ResourcePath = resource
+	req.RequestContext.HTTPMethod = r.Method
+	req.initMaps()
+	return req
+}
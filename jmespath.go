@@ -0,0 +1,894 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// JMESPath is a compiled expression of a subset of the JMESPath query
+// language, https://jmespath.org. Supported are identifiers, sub-expressions,
+// index and slice expressions, list and object projections, flatten
+// operators, filter expressions with comparators, multi-select lists and
+// hashes, pipes, the or, and, and not operators, and raw string and JSON
+// literals. Functions and expression references are not supported.
+type JMESPath struct {
+	expr string
+	root jpNode
+}
+
+// CompileJMESPath parses the JMESPath expression, returning an error if the
+// expression is invalid, or uses a feature that is not supported.
+func CompileJMESPath(expr string) (*JMESPath, error) {
+	tokens, err := lexJMESPath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &jpParser{tokens: tokens}
+	root, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.typ != jpEOF {
+		return nil, fmt.Errorf("invalid JMESPath expression, unexpected %q at %d", t.value, t.pos)
+	}
+	return &JMESPath{expr: expr, root: root}, nil
+}
+
+// String returns the expression the JMESPath was compiled from.
+func (j *JMESPath) String() string {
+	return j.expr
+}
+
+// Search evaluates the expression against the JSON data, as decoded by
+// encoding/json into an interface{}.
+func (j *JMESPath) Search(data interface{}) interface{} {
+	return j.root.eval(data)
+}
+
+// JMESPathQuery is a BodyTransformer filtering successful JSON responses
+// with the JMESPath expression of the request's query parameter, e.g.
+// "?query=items[?active].name", letting clients request only the data they
+// need. Responses of requests without the query parameter are not modified.
+// Requests with an invalid expression are responded to with a 400 Bad
+// Request response.
+type JMESPathQuery struct {
+	// Query parameter of the expression. Defaults to "query".
+	Param string
+
+	// Maximum length of expressions. Defaults to 256.
+	MaxLength int
+}
+
+// TransformBody implements the BodyTransformer interface.
+func (q JMESPathQuery) TransformBody(
+	ctx context.Context, req APIGatewayProxyRequest, resp *APIGatewayProxyResponse,
+) error {
+	param := q.Param
+	if len(param) == 0 {
+		param = "query"
+	}
+	expr := requestQuery(req).Get(param)
+	if len(expr) == 0 || resp.StatusCode < 200 || resp.StatusCode > 299 ||
+		!isJSONMediaType(responseMediaType(resp)) {
+		return nil
+	}
+
+	maxLen := q.MaxLength
+	if maxLen == 0 {
+		maxLen = 256
+	}
+	if len(expr) > maxLen {
+		*resp = Text(http.StatusBadRequest, fmt.Sprintf("JMESPath expression longer than %d", maxLen))
+		return nil
+	}
+	path, err := CompileJMESPath(expr)
+	if err != nil {
+		*resp = Text(http.StatusBadRequest, err.Error())
+		return nil
+	}
+
+	dec := json.NewDecoder(strings.NewReader(resp.Body))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		return fmt.Errorf("invalid JSON response body, %w", err)
+	}
+
+	b, err := json.Marshal(path.Search(data))
+	if err != nil {
+		return err
+	}
+	resp.Body = string(b)
+	return nil
+}
+
+type jpTokenType int
+
+const (
+	jpEOF jpTokenType = iota
+	jpIdent
+	jpNumber
+	jpLiteral
+	jpDot
+	jpStar
+	jpLBracket
+	jpRBracket
+	jpFlatten
+	jpFilter
+	jpLBrace
+	jpRBrace
+	jpLParen
+	jpRParen
+	jpComma
+	jpColon
+	jpPipe
+	jpOr
+	jpAnd
+	jpNot
+	jpCurrent
+	jpCompare
+)
+
+type jpToken struct {
+	typ   jpTokenType
+	value string
+	lit   interface{}
+	pos   int
+}
+
+// jpBindingPower is the binding power of tokens in the JMESPath grammar.
+var jpBindingPower = map[jpTokenType]int{
+	jpPipe:     1,
+	jpOr:       2,
+	jpAnd:      3,
+	jpCompare:  5,
+	jpFlatten:  9,
+	jpStar:     20,
+	jpFilter:   21,
+	jpDot:      40,
+	jpNot:      45,
+	jpLBrace:   50,
+	jpLBracket: 55,
+	jpLParen:   60,
+}
+
+func lexJMESPath(expr string) ([]jpToken, error) {
+	var tokens []jpToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+
+		emit := func(typ jpTokenType, n int) {
+			tokens = append(tokens, jpToken{typ: typ, value: expr[i : i+n], pos: start})
+			i += n
+		}
+		next := func(n int) byte {
+			if i+n < len(expr) {
+				return expr[i+n]
+			}
+			return 0
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || c < unicode.MaxASCII && unicode.IsLetter(rune(c)):
+			for i < len(expr) && (expr[i] == '_' || unicode.IsLetter(rune(expr[i])) || unicode.IsDigit(rune(expr[i]))) {
+				i++
+			}
+			tokens = append(tokens, jpToken{typ: jpIdent, value: expr[start:i], pos: start})
+		case c == '-' || c >= '0' && c <= '9':
+			i++
+			for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+				i++
+			}
+			if expr[start:i] == "-" {
+				return nil, fmt.Errorf("invalid JMESPath expression, invalid number at %d", start)
+			}
+			tokens = append(tokens, jpToken{typ: jpNumber, value: expr[start:i], pos: start})
+		case c == '"':
+			end, err := jpScanQuoted(expr, i, '"')
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			if err := json.Unmarshal([]byte(expr[i:end]), &name); err != nil {
+				return nil, fmt.Errorf("invalid JMESPath expression, invalid quoted identifier at %d", start)
+			}
+			tokens = append(tokens, jpToken{typ: jpIdent, value: name, pos: start})
+			i = end
+		case c == '\'':
+			end, err := jpScanQuoted(expr, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			raw := strings.Replace(expr[i+1:end-1], `\'`, `'`, -1)
+			tokens = append(tokens, jpToken{typ: jpLiteral, value: raw, lit: raw, pos: start})
+			i = end
+		case c == '`':
+			end, err := jpScanQuoted(expr, i, '`')
+			if err != nil {
+				return nil, err
+			}
+			raw := strings.Replace(expr[i+1:end-1], "\\`", "`", -1)
+			dec := json.NewDecoder(strings.NewReader(raw))
+			dec.UseNumber()
+			var lit interface{}
+			if err := dec.Decode(&lit); err != nil {
+				return nil, fmt.Errorf("invalid JMESPath expression, invalid JSON literal at %d", start)
+			}
+			tokens = append(tokens, jpToken{typ: jpLiteral, value: raw, lit: lit, pos: start})
+			i = end
+		case c == '.':
+			emit(jpDot, 1)
+		case c == '*':
+			emit(jpStar, 1)
+		case c == '[' && next(1) == ']':
+			emit(jpFlatten, 2)
+		case c == '[' && next(1) == '?':
+			emit(jpFilter, 2)
+		case c == '[':
+			emit(jpLBracket, 1)
+		case c == ']':
+			emit(jpRBracket, 1)
+		case c == '{':
+			emit(jpLBrace, 1)
+		case c == '}':
+			emit(jpRBrace, 1)
+		case c == '(':
+			emit(jpLParen, 1)
+		case c == ')':
+			emit(jpRParen, 1)
+		case c == ',':
+			emit(jpComma, 1)
+		case c == ':':
+			emit(jpColon, 1)
+		case c == '@':
+			emit(jpCurrent, 1)
+		case c == '|' && next(1) == '|':
+			emit(jpOr, 2)
+		case c == '|':
+			emit(jpPipe, 1)
+		case c == '&' && next(1) == '&':
+			emit(jpAnd, 2)
+		case (c == '<' || c == '>' || c == '=' || c == '!') && next(1) == '=':
+			emit(jpCompare, 2)
+		case c == '<' || c == '>':
+			emit(jpCompare, 1)
+		case c == '!':
+			emit(jpNot, 1)
+		default:
+			return nil, fmt.Errorf("invalid JMESPath expression, unexpected %q at %d", c, start)
+		}
+	}
+	return append(tokens, jpToken{typ: jpEOF, pos: len(expr)}), nil
+}
+
+// jpScanQuoted returns the offset after the closing quote of the quoted
+// string starting at i.
+func jpScanQuoted(expr string, i int, quote byte) (int, error) {
+	for j := i + 1; j < len(expr); j++ {
+		switch expr[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid JMESPath expression, unterminated quote at %d", i)
+}
+
+type jpParser struct {
+	tokens []jpToken
+	idx    int
+}
+
+func (p *jpParser) peek() jpToken {
+	return p.tokens[p.idx]
+}
+
+func (p *jpParser) advance() jpToken {
+	t := p.tokens[p.idx]
+	if t.typ != jpEOF {
+		p.idx++
+	}
+	return t
+}
+
+func (p *jpParser) expect(typ jpTokenType) error {
+	if t := p.advance(); t.typ != typ {
+		return p.unexpected(t)
+	}
+	return nil
+}
+
+func (p *jpParser) unexpected(t jpToken) error {
+	if t.typ == jpEOF {
+		return fmt.Errorf("invalid JMESPath expression, unexpected end of expression")
+	}
+	return fmt.Errorf("invalid JMESPath expression, unexpected %q at %d", t.value, t.pos)
+}
+
+func (p *jpParser) parse(bp int) (jpNode, error) {
+	left, err := p.nud(p.advance())
+	if err != nil {
+		return nil, err
+	}
+	for bp < jpBindingPower[p.peek().typ] {
+		if left, err = p.led(p.advance(), left); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *jpParser) nud(t jpToken) (jpNode, error) {
+	switch t.typ {
+	case jpLiteral:
+		return jpLiteralNode{value: t.lit}, nil
+	case jpIdent:
+		return jpField{name: t.value}, nil
+	case jpCurrent:
+		return jpIdentity{}, nil
+	case jpStar:
+		right, err := p.projectionRHS(jpBindingPower[jpStar])
+		if err != nil {
+			return nil, err
+		}
+		return jpObjectProjection{left: jpIdentity{}, right: right}, nil
+	case jpFlatten:
+		right, err := p.projectionRHS(jpBindingPower[jpFlatten])
+		if err != nil {
+			return nil, err
+		}
+		return jpProjection{left: jpFlattenNode{left: jpIdentity{}}, right: right}, nil
+	case jpFilter:
+		return p.filter(jpIdentity{})
+	case jpLBracket:
+		switch p.peek().typ {
+		case jpNumber, jpColon:
+			return p.indexOrSlice(jpIdentity{})
+		case jpStar:
+			if p.tokens[p.idx+1].typ == jpRBracket {
+				p.advance()
+				p.advance()
+				right, err := p.projectionRHS(jpBindingPower[jpStar])
+				if err != nil {
+					return nil, err
+				}
+				return jpProjection{left: jpIdentity{}, right: right}, nil
+			}
+		}
+		return p.multiSelectList()
+	case jpLBrace:
+		return p.multiSelectHash()
+	case jpNot:
+		expr, err := p.parse(jpBindingPower[jpNot])
+		if err != nil {
+			return nil, err
+		}
+		return jpNotNode{expr: expr}, nil
+	case jpLParen:
+		expr, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(jpRParen); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return nil, p.unexpected(t)
+}
+
+func (p *jpParser) led(t jpToken, left jpNode) (jpNode, error) {
+	switch t.typ {
+	case jpDot:
+		if p.peek().typ == jpStar {
+			p.advance()
+			right, err := p.projectionRHS(jpBindingPower[jpStar])
+			if err != nil {
+				return nil, err
+			}
+			return jpObjectProjection{left: left, right: right}, nil
+		}
+		right, err := p.dotRHS(jpBindingPower[jpDot])
+		if err != nil {
+			return nil, err
+		}
+		return jpSubExpression{left: left, right: right}, nil
+	case jpPipe, jpOr, jpAnd:
+		right, err := p.parse(jpBindingPower[t.typ])
+		if err != nil {
+			return nil, err
+		}
+		switch t.typ {
+		case jpPipe:
+			return jpSubExpression{left: left, right: right}, nil
+		case jpOr:
+			return jpOrNode{left: left, right: right}, nil
+		default:
+			return jpAndNode{left: left, right: right}, nil
+		}
+	case jpCompare:
+		right, err := p.parse(jpBindingPower[jpCompare])
+		if err != nil {
+			return nil, err
+		}
+		return jpCompareNode{op: t.value, left: left, right: right}, nil
+	case jpFlatten:
+		right, err := p.projectionRHS(jpBindingPower[jpFlatten])
+		if err != nil {
+			return nil, err
+		}
+		return jpProjection{left: jpFlattenNode{left: left}, right: right}, nil
+	case jpFilter:
+		return p.filter(left)
+	case jpLBracket:
+		switch p.peek().typ {
+		case jpNumber, jpColon:
+			return p.indexOrSlice(left)
+		case jpStar:
+			p.advance()
+			if err := p.expect(jpRBracket); err != nil {
+				return nil, err
+			}
+			right, err := p.projectionRHS(jpBindingPower[jpStar])
+			if err != nil {
+				return nil, err
+			}
+			return jpProjection{left: left, right: right}, nil
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// dotRHS parses the right hand side of a dot, an expression starting with
+// an identifier, or a multi-select list or hash.
+func (p *jpParser) dotRHS(bp int) (jpNode, error) {
+	switch t := p.peek(); t.typ {
+	case jpIdent:
+		return p.parse(bp)
+	case jpLBracket:
+		p.advance()
+		return p.multiSelectList()
+	case jpLBrace:
+		p.advance()
+		return p.multiSelectHash()
+	default:
+		return nil, p.unexpected(t)
+	}
+}
+
+// projectionRHS parses the expression applied to each element of a
+// projection.
+func (p *jpParser) projectionRHS(bp int) (jpNode, error) {
+	switch t := p.peek(); {
+	case jpBindingPower[t.typ] < 10:
+		return jpIdentity{}, nil
+	case t.typ == jpLBracket || t.typ == jpFilter:
+		return p.parse(bp)
+	case t.typ == jpDot:
+		p.advance()
+		return p.dotRHS(bp)
+	default:
+		return nil, p.unexpected(t)
+	}
+}
+
+func (p *jpParser) filter(left jpNode) (jpNode, error) {
+	cond, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(jpRBracket); err != nil {
+		return nil, err
+	}
+	right, err := p.projectionRHS(jpBindingPower[jpFilter])
+	if err != nil {
+		return nil, err
+	}
+	return jpProjection{left: left, cond: cond, right: right}, nil
+}
+
+func (p *jpParser) indexOrSlice(left jpNode) (jpNode, error) {
+	var parts [3]*int
+	part := 0
+	for {
+		switch t := p.advance(); t.typ {
+		case jpNumber:
+			if parts[part] != nil {
+				return nil, p.unexpected(t)
+			}
+			n, err := strconv.Atoi(t.value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JMESPath expression, invalid number %q", t.value)
+			}
+			parts[part] = &n
+		case jpColon:
+			if part++; part > 2 {
+				return nil, p.unexpected(t)
+			}
+		case jpRBracket:
+			if part == 0 {
+				return jpIndex{left: left, index: *parts[0]}, nil
+			}
+			if parts[2] != nil && *parts[2] == 0 {
+				return nil, fmt.Errorf("invalid JMESPath expression, slice step cannot be 0")
+			}
+			slice := jpSlice{left: left, start: parts[0], stop: parts[1], step: parts[2]}
+			right, err := p.projectionRHS(jpBindingPower[jpStar])
+			if err != nil {
+				return nil, err
+			}
+			return jpProjection{left: slice, right: right}, nil
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *jpParser) multiSelectList() (jpNode, error) {
+	var list jpMultiSelectList
+	for {
+		expr, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		list.exprs = append(list.exprs, expr)
+
+		switch t := p.advance(); t.typ {
+		case jpComma:
+		case jpRBracket:
+			return list, nil
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *jpParser) multiSelectHash() (jpNode, error) {
+	hash := jpMultiSelectHash{}
+	for {
+		key := p.advance()
+		if key.typ != jpIdent {
+			return nil, p.unexpected(key)
+		}
+		if err := p.expect(jpColon); err != nil {
+			return nil, err
+		}
+		expr, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		hash.keys = append(hash.keys, key.value)
+		hash.exprs = append(hash.exprs, expr)
+
+		switch t := p.advance(); t.typ {
+		case jpComma:
+		case jpRBrace:
+			return hash, nil
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+type jpNode interface {
+	eval(v interface{}) interface{}
+}
+
+type jpIdentity struct{}
+
+func (jpIdentity) eval(v interface{}) interface{} { return v }
+
+type jpLiteralNode struct{ value interface{} }
+
+func (n jpLiteralNode) eval(interface{}) interface{} { return n.value }
+
+type jpField struct{ name string }
+
+func (n jpField) eval(v interface{}) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[n.name]
+	}
+	return nil
+}
+
+type jpSubExpression struct{ left, right jpNode }
+
+func (n jpSubExpression) eval(v interface{}) interface{} {
+	return n.right.eval(n.left.eval(v))
+}
+
+type jpIndex struct {
+	left  jpNode
+	index int
+}
+
+func (n jpIndex) eval(v interface{}) interface{} {
+	list, ok := n.left.eval(v).([]interface{})
+	if !ok {
+		return nil
+	}
+	i := n.index
+	if i < 0 {
+		i += len(list)
+	}
+	if i < 0 || i >= len(list) {
+		return nil
+	}
+	return list[i]
+}
+
+type jpSlice struct {
+	left              jpNode
+	start, stop, step *int
+}
+
+func (n jpSlice) eval(v interface{}) interface{} {
+	list, ok := n.left.eval(v).([]interface{})
+	if !ok {
+		return nil
+	}
+
+	step := 1
+	if n.step != nil {
+		step = *n.step
+	}
+	bound := func(p *int, def int) int {
+		if p == nil {
+			return def
+		}
+		i := *p
+		if i < 0 {
+			i += len(list)
+		}
+		lo, hi := 0, len(list)
+		if step < 0 {
+			lo, hi = -1, len(list)-1
+		}
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	result := []interface{}{}
+	if step > 0 {
+		for i := bound(n.start, 0); i < bound(n.stop, len(list)); i += step {
+			result = append(result, list[i])
+		}
+	} else {
+		for i := bound(n.start, len(list)-1); i > bound(n.stop, -1); i += step {
+			result = append(result, list[i])
+		}
+	}
+	return result
+}
+
+// jpProjection evaluates right against each element of the list left
+// evaluates to, optionally filtered by cond. Null results are omitted.
+type jpProjection struct {
+	left, cond, right jpNode
+}
+
+func (n jpProjection) eval(v interface{}) interface{} {
+	list, ok := n.left.eval(v).([]interface{})
+	if !ok {
+		return nil
+	}
+	return jpProject(list, n.cond, n.right)
+}
+
+// jpObjectProjection evaluates right against each value of the object left
+// evaluates to, in key order.
+type jpObjectProjection struct {
+	left, right jpNode
+}
+
+func (n jpObjectProjection) eval(v interface{}) interface{} {
+	m, ok := n.left.eval(v).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]interface{}, 0, len(m))
+	for _, k := range keys {
+		list = append(list, m[k])
+	}
+	return jpProject(list, nil, n.right)
+}
+
+func jpProject(list []interface{}, cond, right jpNode) []interface{} {
+	result := []interface{}{}
+	for _, e := range list {
+		if cond != nil && !jpTruthy(cond.eval(e)) {
+			continue
+		}
+		if r := right.eval(e); r != nil {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+type jpFlattenNode struct{ left jpNode }
+
+func (n jpFlattenNode) eval(v interface{}) interface{} {
+	list, ok := n.left.eval(v).([]interface{})
+	if !ok {
+		return nil
+	}
+
+	result := []interface{}{}
+	for _, e := range list {
+		if inner, ok := e.([]interface{}); ok {
+			result = append(result, inner...)
+		} else {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+type jpMultiSelectList struct{ exprs []jpNode }
+
+func (n jpMultiSelectList) eval(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	result := make([]interface{}, len(n.exprs))
+	for i, e := range n.exprs {
+		result[i] = e.eval(v)
+	}
+	return result
+}
+
+type jpMultiSelectHash struct {
+	keys  []string
+	exprs []jpNode
+}
+
+func (n jpMultiSelectHash) eval(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(n.keys))
+	for i, k := range n.keys {
+		result[k] = n.exprs[i].eval(v)
+	}
+	return result
+}
+
+type jpOrNode struct{ left, right jpNode }
+
+func (n jpOrNode) eval(v interface{}) interface{} {
+	if l := n.left.eval(v); jpTruthy(l) {
+		return l
+	}
+	return n.right.eval(v)
+}
+
+type jpAndNode struct{ left, right jpNode }
+
+func (n jpAndNode) eval(v interface{}) interface{} {
+	if l := n.left.eval(v); !jpTruthy(l) {
+		return l
+	}
+	return n.right.eval(v)
+}
+
+type jpNotNode struct{ expr jpNode }
+
+func (n jpNotNode) eval(v interface{}) interface{} {
+	return !jpTruthy(n.expr.eval(v))
+}
+
+type jpCompareNode struct {
+	op          string
+	left, right jpNode
+}
+
+func (n jpCompareNode) eval(v interface{}) interface{} {
+	l, r := n.left.eval(v), n.right.eval(v)
+	switch n.op {
+	case "==":
+		return jpEqual(l, r)
+	case "!=":
+		return !jpEqual(l, r)
+	}
+
+	lf, lok := jpToFloat(l)
+	rf, rok := jpToFloat(r)
+	if !lok || !rok {
+		return nil
+	}
+	switch n.op {
+	case "<":
+		return lf < rf
+	case "<=":
+		return lf <= rf
+	case ">":
+		return lf > rf
+	default:
+		return lf >= rf
+	}
+}
+
+func jpToFloat(v interface{}) (float64, bool) {
+	switch tv := v.(type) {
+	case float64:
+		return tv, true
+	case json.Number:
+		f, err := tv.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jpEqual(a, b interface{}) bool {
+	if af, ok := jpToFloat(a); ok {
+		bf, ok := jpToFloat(b)
+		return ok && af == bf
+	}
+
+	switch ta := a.(type) {
+	case []interface{}:
+		tb, ok := b.([]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for i := range ta {
+			if !jpEqual(ta[i], tb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		tb, ok := b.(map[string]interface{})
+		if !ok || len(ta) != len(tb) {
+			return false
+		}
+		for k, v := range ta {
+			bv, ok := tb[k]
+			if !ok || !jpEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+func jpTruthy(v interface{}) bool {
+	switch tv := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return tv
+	case string:
+		return len(tv) != 0
+	case []interface{}:
+		return len(tv) != 0
+	case map[string]interface{}:
+		return len(tv) != 0
+	}
+	return true
+}
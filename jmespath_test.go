@@ -0,0 +1,133 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJMESPathSearch(t *testing.T) {
+	const doc = `{
+		"items": [
+			{"name": "a", "active": true, "n": 1, "tags": ["x", "y"]},
+			{"name": "b", "active": false, "n": 2, "tags": ["z"]},
+			{"name": "c", "active": true, "n": 3, "tags": []}
+		],
+		"meta": {"total": 3, "next": null}
+	}`
+
+	cases := map[string]struct {
+		expr      string
+		expect    string
+		expectErr bool
+	}{
+		"field":             {expr: "meta.total", expect: `3`},
+		"index":             {expr: "items[0].name", expect: `"a"`},
+		"negative index":    {expr: "items[-1].name", expect: `"c"`},
+		"slice":             {expr: "items[:2].name", expect: `["a","b"]`},
+		"list projection":   {expr: "items[*].n", expect: `[1,2,3]`},
+		"flatten":           {expr: "items[].tags[]", expect: `["x","y","z"]`},
+		"filter":            {expr: "items[?active].name", expect: `["a","c"]`},
+		"filter comparator": {expr: "items[?n > `1`].name", expect: `["b","c"]`},
+		"filter raw string": {expr: "items[?name == 'b'].n", expect: `[2]`},
+		"multi-select list": {expr: "items[0].[name, n]", expect: `["a",1]`},
+		"multi-select hash": {expr: "items[*].{id: name}", expect: `[{"id":"a"},{"id":"b"},{"id":"c"}]`},
+		"pipe":              {expr: "items[*].name | [0]", expect: `"a"`},
+		"or":                {expr: "meta.next || meta.total", expect: `3`},
+		"not":               {expr: "!(meta.next)", expect: `true`},
+		"missing":           {expr: "meta.missing", expect: `null`},
+		"function":          {expr: "length(items)", expectErr: true},
+		"unterminated":      {expr: "items[0", expectErr: true},
+		"trailing token":    {expr: "items ]", expectErr: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			path, err := CompileJMESPath(c.expr)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			dec := json.NewDecoder(strings.NewReader(doc))
+			dec.UseNumber()
+			var data interface{}
+			if err := dec.Decode(&data); err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			b, err := json.Marshal(path.Search(data))
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expect, string(b); e != a {
+				t.Errorf("expect %s, got %s", e, a)
+			}
+		})
+	}
+}
+
+func TestJMESPathQuery(t *testing.T) {
+	cases := map[string]struct {
+		query        string
+		maxLength    int
+		status       int
+		expectStatus int
+		expectBody   string
+	}{
+		"no query": {
+			status: 200, expectStatus: 200, expectBody: `{"a":[1,2]}`,
+		},
+		"query": {
+			query: "a[0]", status: 200, expectStatus: 200, expectBody: `1`,
+		},
+		"error response not modified": {
+			query: "a[0]", status: 500, expectStatus: 500, expectBody: `{"a":[1,2]}`,
+		},
+		"invalid expression": {
+			query: "a[", status: 200, expectStatus: 400,
+		},
+		"too long": {
+			query: "a[0]", maxLength: 3, status: 200, expectStatus: 400,
+			expectBody: "JMESPath expression longer than 3",
+		},
+		"too long invalid expression": {
+			query: strings.Repeat("[", 300), status: 200, expectStatus: 400,
+			expectBody: "JMESPath expression longer than 256",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var req APIGatewayProxyRequest
+			if len(c.query) != 0 {
+				req.QueryStringParameters = map[string]string{"query": c.query}
+			}
+			resp, err := JSON(c.status, map[string][]int{"a": {1, 2}})
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			q := JMESPathQuery{MaxLength: c.maxLength}
+			if err := q.TransformBody(context.Background(), req, &resp); err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expectStatus, resp.StatusCode; e != a {
+				t.Fatalf("expect %v status, got %v, %s", e, a, resp.Body)
+			}
+			if c.expectStatus == http.StatusBadRequest && len(c.expectBody) == 0 {
+				return
+			}
+			if e, a := c.expectBody, resp.Body; e != a {
+				t.Errorf("expect %q body, got %q", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package lambdamux
+
+import (
+	"context"
+	"time"
+)
+
+type lambdaDeadlineHandler struct {
+	Buffer  time.Duration
+	Handler ResourceHandler
+
+	options timeoutOptions
+}
+
+// ResourceHandlerWithLambdaDeadline provides a resource handler serving
+// handler with a timeout of the invoke's remaining time, less the buffer,
+// so the handler stops, and the request is responded to, before Lambda
+// times out the invoke, freezing, or shutting down, the container, instead
+// of a hardcoded duration that must be kept in sync with the function's
+// configured timeout. The buffer must leave time for the middleware
+// wrapping the handler, and the response's encoding.
+//
+// The invoke's deadline is the deadline of the invoke's context, set by the
+// Lambda runtime with the context's lambdacontext.LambdaContext. Requests
+// served without a deadline, e.g. by the LocalServer, are served by handler
+// without a timeout.
+//
+// Timed out requests are handled as ResourceHandlerWithTimeout handles
+// them, configured by the timeout options, e.g. WithTimeoutResponse.
+func ResourceHandlerWithLambdaDeadline(
+	buffer time.Duration, handler ResourceHandler, opts ...TimeoutOption,
+) ResourceHandler {
+	var o timeoutOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	return lambdaDeadlineHandler{
+		Buffer:  buffer,
+		Handler: handler,
+		options: o,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, with a timeout of the
+// invoke's remaining time, less the buffer.
+func (h lambdaDeadlineHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	return timeoutHandler{
+		Timeout: time.Until(deadline.Add(-h.Buffer)),
+		Handler: h.Handler,
+		options: h.options,
+	}.ServeResource(ctx, req)
+}
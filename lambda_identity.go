@@ -0,0 +1,74 @@
+package lambdamux
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+// ClientContextFromContext returns the client context of the Lambda invoke,
+// provided by the AWS Mobile SDK's direct invokes, and false if the invoke
+// has none.
+func ClientContextFromContext(ctx context.Context) (lambdacontext.ClientContext, bool) {
+	lc, ok := lambdacontext.FromContext(ctx)
+	if !ok {
+		return lambdacontext.ClientContext{}, false
+	}
+
+	cc := lc.ClientContext
+	if len(cc.Client.InstallationID) == 0 && len(cc.Client.AppPackageName) == 0 &&
+		len(cc.Env) == 0 && len(cc.Custom) == 0 {
+		return lambdacontext.ClientContext{}, false
+	}
+	return cc, true
+}
+
+// CognitoIdentityFromContext returns the Amazon Cognito identity of the
+// Lambda invoke, provided by the AWS Mobile SDK's direct invokes, and false
+// if the invoke has none.
+func CognitoIdentityFromContext(ctx context.Context) (lambdacontext.CognitoIdentity, bool) {
+	lc, ok := lambdacontext.FromContext(ctx)
+	if !ok || len(lc.Identity.CognitoIdentityID) == 0 {
+		return lambdacontext.CognitoIdentity{}, false
+	}
+	return lc.Identity, true
+}
+
+// requestCognitoIdentity returns the Amazon Cognito identity of the request,
+// from the Lambda invoke, or the API Gateway request's identity for API
+// methods using IAM authorization with Cognito identity pool credentials.
+func requestCognitoIdentity(ctx context.Context, req APIGatewayProxyRequest) (lambdacontext.CognitoIdentity, bool) {
+	if id, ok := CognitoIdentityFromContext(ctx); ok {
+		return id, true
+	}
+
+	identity := req.RequestContext.Identity
+	if len(identity.CognitoIdentityID) == 0 {
+		return lambdacontext.CognitoIdentity{}, false
+	}
+	return lambdacontext.CognitoIdentity{
+		CognitoIdentityID:     identity.CognitoIdentityID,
+		CognitoIdentityPoolID: identity.CognitoIdentityPoolID,
+	}, true
+}
+
+// CognitoIdentityPoolDimension is a DimensionFunc extracting the ID of the
+// Amazon Cognito identity pool of the request's identity.
+func CognitoIdentityPoolDimension(ctx context.Context, req APIGatewayProxyRequest) string {
+	id, _ := requestCognitoIdentity(ctx, req)
+	return id.CognitoIdentityPoolID
+}
+
+// ClientAppDimension is a DimensionFunc extracting the package name, and
+// version code, of the client application of the Lambda invoke's client
+// context, e.g. "com.example.app@42".
+func ClientAppDimension(ctx context.Context, req APIGatewayProxyRequest) string {
+	cc, ok := ClientContextFromContext(ctx)
+	if !ok || len(cc.Client.AppPackageName) == 0 {
+		return ""
+	}
+	if len(cc.Client.AppVersionCode) == 0 {
+		return cc.Client.AppPackageName
+	}
+	return cc.Client.AppPackageName + "@" + cc.Client.AppVersionCode
+}
@@ -0,0 +1,243 @@
+package lambdamuxtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// goldenSuffix is the suffix replacing the ".json" extension of event
+// fixtures for the path of their golden files.
+const goldenSuffix = ".golden.json"
+
+// NormalizeFunc normalizes the decoded JSON response of an invoke, e.g.
+// replacing values differing between invokes, such as timestamps, and
+// request IDs, so responses can be compared with golden files.
+type NormalizeFunc func(resp map[string]interface{})
+
+// Replay invokes a Lambda handler, e.g. a lambdamux.APIGatewayProxy, with
+// event fixtures, e.g. real API Gateway events captured from a deployed
+// API, and compares the responses with golden files, for regression testing
+// the handler's responses.
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	func TestReplay(t *testing.T) {
+//		lambdamuxtest.Replay{
+//			Handler:   lambdamux.APIGatewayProxy{Handler: routes()},
+//			Normalize: []lambdamuxtest.NormalizeFunc{lambdamuxtest.NormalizeHeader("Date")},
+//			Update:    *update,
+//		}.Run(t, "testdata/events/*.json")
+//	}
+type Replay struct {
+	Handler lambda.Handler
+
+	// Normalize are applied, in order, to each response before it is
+	// compared with, or written as, its golden file.
+	Normalize []NormalizeFunc
+
+	// Update writes the responses as the golden files, instead of comparing
+	// them, e.g. when the responses change intentionally.
+	Update bool
+}
+
+// Run invokes the handler with each event fixture matching the glob
+// pattern, as a subtest named by the fixture's file name. The golden file
+// of a fixture is the fixture's path with the ".golden.json" extension,
+// e.g. "get_user.golden.json" of "get_user.json". Golden files are not
+// replayed as fixtures.
+//
+// Responses are compared as indented JSON, with JSON bodies decoded, so
+// golden files are readable, and diffs of bodies are line based.
+func (r Replay) Run(t *testing.T, pattern string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("invalid fixture pattern %q, %v", pattern, err)
+	}
+	var fixtures []string
+	for _, p := range paths {
+		if !strings.HasSuffix(p, goldenSuffix) {
+			fixtures = append(fixtures, p)
+		}
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("expect event fixtures matching %q, got none", pattern)
+	}
+
+	for _, fixture := range fixtures {
+		fixture := fixture
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			r.replay(t, fixture)
+		})
+	}
+}
+
+// replay invokes the handler with the event fixture, comparing the
+// response with the fixture's golden file.
+func (r Replay) replay(t *testing.T, fixture string) {
+	t.Helper()
+
+	event := LoadEvent(t, fixture)
+	out, err := r.Handler.Invoke(context.Background(), event)
+	if err != nil {
+		t.Fatalf("expect no invoke error, got %v", err)
+	}
+
+	var resp map[string]interface{}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		t.Fatalf("expect JSON response, got %v, %q", err, out)
+	}
+	for _, fn := range r.Normalize {
+		fn(resp)
+	}
+	expandJSONBody(resp)
+
+	actual, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal response, %v", err)
+	}
+	actual = append(actual, '\n')
+
+	golden := strings.TrimSuffix(fixture, filepath.Ext(fixture)) + goldenSuffix
+	if r.Update {
+		if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+			t.Fatalf("failed to write golden file, %v", err)
+		}
+		return
+	}
+
+	expect, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("failed to read golden file, %v, run with Update to create it", err)
+	}
+	if !bytes.Equal(expect, actual) {
+		t.Errorf("expect response to match %s\nexpect:\n%s\nactual:\n%s", golden, expect, actual)
+	}
+}
+
+// LoadEvent returns the JSON event fixture read from the file. Fails the
+// test immediately if the file cannot be read, or is not valid JSON.
+func LoadEvent(t testing.TB, path string) []byte {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read event fixture, %v", err)
+	}
+	if !json.Valid(b) {
+		t.Fatalf("expect JSON event fixture %s", path)
+	}
+	return b
+}
+
+// NormalizeHeader returns a NormalizeFunc replacing the values of the
+// response's headers with "<normalized>", e.g. "Date", or "X-Request-Id".
+func NormalizeHeader(names ...string) NormalizeFunc {
+	return func(resp map[string]interface{}) {
+		for _, member := range []string{"headers", "multiValueHeaders"} {
+			headers, _ := resp[member].(map[string]interface{})
+			for k, v := range headers {
+				for _, name := range names {
+					if !strings.EqualFold(k, name) {
+						continue
+					}
+					if _, ok := v.([]interface{}); ok {
+						headers[k] = []interface{}{"<normalized>"}
+					} else {
+						headers[k] = "<normalized>"
+					}
+				}
+			}
+		}
+	}
+}
+
+// NormalizeJSONBody returns a NormalizeFunc replacing the values of the
+// members of the response's JSON object body with "<normalized>", e.g.
+// "requestId", or "createdAt". Nested members are named by their path,
+// e.g. "meta.timestamp".
+func NormalizeJSONBody(members ...string) NormalizeFunc {
+	return func(resp map[string]interface{}) {
+		body, _ := resp["body"].(string)
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &doc); err != nil {
+			return
+		}
+
+		for _, member := range members {
+			normalizeMember(doc, strings.Split(member, "."))
+		}
+
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return
+		}
+		resp["body"] = string(b)
+	}
+}
+
+func normalizeMember(doc map[string]interface{}, path []string) {
+	v, ok := doc[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		doc[path[0]] = "<normalized>"
+		return
+	}
+	if nested, ok := v.(map[string]interface{}); ok {
+		normalizeMember(nested, path[1:])
+	}
+}
+
+// NormalizeRegexp returns a NormalizeFunc replacing the matches of the
+// regular expression in the response's body, and header values, with the
+// replacement, as Regexp's ReplaceAllString does, e.g. of UUIDs.
+func NormalizeRegexp(re *regexp.Regexp, replacement string) NormalizeFunc {
+	return func(resp map[string]interface{}) {
+		if body, ok := resp["body"].(string); ok {
+			resp["body"] = re.ReplaceAllString(body, replacement)
+		}
+		for _, member := range []string{"headers", "multiValueHeaders"} {
+			headers, _ := resp[member].(map[string]interface{})
+			for k, v := range headers {
+				switch v := v.(type) {
+				case string:
+					headers[k] = re.ReplaceAllString(v, replacement)
+				case []interface{}:
+					for i, s := range v {
+						if s, ok := s.(string); ok {
+							v[i] = re.ReplaceAllString(s, replacement)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// expandJSONBody replaces the response's JSON body string with the decoded
+// JSON value, so the body is indented in golden files.
+func expandJSONBody(resp map[string]interface{}) {
+	if encoded, _ := resp["isBase64Encoded"].(bool); encoded {
+		return
+	}
+	body, _ := resp["body"].(string)
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return
+	}
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		resp["body"] = v
+	}
+}
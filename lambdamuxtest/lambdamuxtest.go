@@ -0,0 +1,312 @@
+// Package lambdamuxtest provides utilities for testing lambdamux handlers:
+// builders of API Gateway Proxy requests, assertions on responses, a
+// recorder of the responses of handlers wrapped by middleware, replay of
+// event fixtures against golden files, and in-memory fakes of the AWS
+// service interfaces lambdamux integrates with, so applications can test
+// their handlers without AWS credentials, or network access.
+package lambdamuxtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// S3 is an in-memory fake of S3, implementing lambdamux.S3ObjectAPI, and
+// lambdamux.S3MultipartAPI.
+type S3 struct {
+	mu      sync.Mutex
+	objects map[string]S3Object
+	uploads map[string]*s3Upload
+	nextID  int
+}
+
+// S3Object is an object stored in the S3 fake.
+type S3Object struct {
+	Body        []byte
+	ContentType string
+}
+
+type s3Upload struct {
+	bucket, key, contentType string
+	parts                    map[int][]byte
+}
+
+// NewS3 initializes and returns an empty S3 fake.
+func NewS3() *S3 {
+	return &S3{
+		objects: map[string]S3Object{},
+		uploads: map[string]*s3Upload{},
+	}
+}
+
+func s3ObjectKey(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// Object returns the object, and false if it does not exist.
+func (s *S3) Object(bucket, key string) (S3Object, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.objects[s3ObjectKey(bucket, key)]
+	return o, ok
+}
+
+// Keys returns the keys of the bucket's objects, sorted.
+func (s *S3) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, bucket+"/") {
+			keys = append(keys, strings.TrimPrefix(k, bucket+"/"))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetObject implements the lambdamux.S3ObjectAPI interface.
+func (s *S3) GetObject(ctx context.Context, bucket, key string) ([]byte, string, error) {
+	o, ok := s.Object(bucket, key)
+	if !ok {
+		return nil, "", fmt.Errorf("get s3://%s/%s, %w", bucket, key, lambdamux.ErrObjectNotFound)
+	}
+	return o.Body, o.ContentType, nil
+}
+
+// PutObject implements the lambdamux.S3ObjectAPI interface.
+func (s *S3) PutObject(ctx context.Context, bucket, key string, body []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[s3ObjectKey(bucket, key)] = S3Object{
+		Body:        append([]byte(nil), body...),
+		ContentType: contentType,
+	}
+	return nil
+}
+
+// CreateMultipartUpload implements the lambdamux.S3MultipartAPI interface.
+func (s *S3) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	id := "upload-" + strconv.Itoa(s.nextID)
+	s.uploads[id] = &s3Upload{
+		bucket:      bucket,
+		key:         key,
+		contentType: contentType,
+		parts:       map[int][]byte{},
+	}
+	return id, nil
+}
+
+// PresignUploadPart implements the lambdamux.S3MultipartAPI interface. The
+// URL is not served, parts are uploaded with UploadPart.
+func (s *S3) PresignUploadPart(
+	ctx context.Context, bucket, key, uploadID string, partNumber int, expires time.Duration,
+) (string, error) {
+	if _, err := s.upload(bucket, key, uploadID); err != nil {
+		return "", err
+	}
+
+	u := url.URL{
+		Scheme: "https",
+		Host:   bucket + ".s3.lambdamuxtest.invalid",
+		Path:   "/" + key,
+		RawQuery: url.Values{
+			"partNumber": []string{strconv.Itoa(partNumber)},
+			"uploadId":   []string{uploadID},
+		}.Encode(),
+	}
+	return u.String(), nil
+}
+
+// UploadPart uploads the part of the multipart upload, as a client would
+// with the part's presigned URL, returning the part's ETag.
+func (s *S3) UploadPart(uploadID string, partNumber int, data []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[uploadID]
+	if !ok {
+		return "", fmt.Errorf("upload %s not found", uploadID)
+	}
+	u.parts[partNumber] = append([]byte(nil), data...)
+	return partETag(data), nil
+}
+
+// CompleteMultipartUpload implements the lambdamux.S3MultipartAPI
+// interface, storing the object of the parts, in part number order.
+func (s *S3) CompleteMultipartUpload(
+	ctx context.Context, bucket, key, uploadID string, parts []lambdamux.S3CompletedPart,
+) error {
+	u, err := s.upload(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	var body bytes.Buffer
+	for _, p := range parts {
+		data, ok := u.parts[p.PartNumber]
+		if !ok {
+			return fmt.Errorf("part %d of upload %s not uploaded", p.PartNumber, uploadID)
+		}
+		if p.ETag != partETag(data) {
+			return fmt.Errorf("part %d of upload %s ETag mismatch", p.PartNumber, uploadID)
+		}
+		body.Write(data)
+	}
+
+	s.objects[s3ObjectKey(bucket, key)] = S3Object{Body: body.Bytes(), ContentType: u.contentType}
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+// AbortMultipartUpload implements the lambdamux.S3MultipartAPI interface.
+func (s *S3) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if _, err := s.upload(bucket, key, uploadID); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.uploads, uploadID)
+	return nil
+}
+
+func (s *S3) upload(bucket, key, uploadID string) (*s3Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[uploadID]
+	if !ok || u.bucket != bucket || u.key != key {
+		return nil, fmt.Errorf("upload %s of s3://%s/%s not found", uploadID, bucket, key)
+	}
+	return u, nil
+}
+
+func partETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// DynamoDBTokens is an in-memory fake of the DynamoDB token table,
+// implementing lambdamux.DynamoDBTokenAPI. Expired items are kept until
+// deleted, as DynamoDB deletes expired items lazily.
+type DynamoDBTokens struct {
+	mu    sync.Mutex
+	items map[string]dynamoDBToken
+}
+
+type dynamoDBToken struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewDynamoDBTokens initializes and returns an empty DynamoDBTokens fake.
+func NewDynamoDBTokens() *DynamoDBTokens {
+	return &DynamoDBTokens{items: map[string]dynamoDBToken{}}
+}
+
+// PutToken implements the lambdamux.DynamoDBTokenAPI interface.
+func (d *DynamoDBTokens) PutToken(ctx context.Context, table, key string, value []byte, expires time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.items[table+"#"+key] = dynamoDBToken{value: append([]byte(nil), value...), expires: expires}
+	return nil
+}
+
+// DeleteToken implements the lambdamux.DynamoDBTokenAPI interface.
+func (d *DynamoDBTokens) DeleteToken(ctx context.Context, table, key string) ([]byte, time.Time, bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	item, ok := d.items[table+"#"+key]
+	if !ok {
+		return nil, time.Time{}, false, nil
+	}
+	delete(d.items, table+"#"+key)
+	return item.value, item.expires, true, nil
+}
+
+// Len returns the number of items of the table.
+func (d *DynamoDBTokens) Len(table string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var n int
+	for k := range d.items {
+		if strings.HasPrefix(k, table+"#") {
+			n++
+		}
+	}
+	return n
+}
+
+// KMS is a fake of KMS, implementing lambdamux.KMSDecryptAPI. Ciphertexts
+// are created with Encrypt, and are not encrypted.
+type KMS struct{}
+
+const kmsCiphertextPrefix = "lambdamuxtest-kms:"
+
+// Encrypt returns the fake ciphertext of the plaintext, that Decrypt
+// returns the plaintext of.
+func (KMS) Encrypt(plaintext []byte) []byte {
+	return append([]byte(kmsCiphertextPrefix), plaintext...)
+}
+
+// Decrypt implements the lambdamux.KMSDecryptAPI interface.
+func (KMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if !bytes.HasPrefix(ciphertext, []byte(kmsCiphertextPrefix)) {
+		return nil, fmt.Errorf("invalid ciphertext, not created by KMS fake's Encrypt")
+	}
+	return ciphertext[len(kmsCiphertextPrefix):], nil
+}
+
+// Lambda is a fake of the Lambda Invoke API, implementing
+// lambdamux.LambdaInvokeAPI, invoking the handlers of functions in
+// process, e.g. an InternalInvokeHandler.
+type Lambda struct {
+	// Functions are the handlers of the functions by name.
+	Functions map[string]lambda.Handler
+}
+
+// Invoke implements the lambdamux.LambdaInvokeAPI interface.
+func (l Lambda) Invoke(ctx context.Context, functionName string, payload []byte) ([]byte, error) {
+	h, ok := l.Functions[functionName]
+	if !ok {
+		return nil, fmt.Errorf("function %s not found", functionName)
+	}
+	return h.Invoke(ctx, payload)
+}
+
+var (
+	_ lambdamux.S3ObjectAPI      = (*S3)(nil)
+	_ lambdamux.S3MultipartAPI   = (*S3)(nil)
+	_ lambdamux.DynamoDBTokenAPI = (*DynamoDBTokens)(nil)
+	_ lambdamux.KMSDecryptAPI    = KMS{}
+	_ lambdamux.LambdaInvokeAPI  = Lambda{}
+)
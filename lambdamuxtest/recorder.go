@@ -0,0 +1,121 @@
+package lambdamuxtest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// ResponseRecorder records the response a resource handler produced, as
+// httptest.ResponseRecorder does of HTTP handlers, for unit testing
+// middleware. The recorder also records the request the handler was served,
+// so a recorder wrapping a middleware's wrapped handler observes what the
+// middleware passed downstream.
+//
+//	rec := lambdamuxtest.NewRecorder()
+//	handler := lambdamux.ResourceHandlerWithCompression(cfg, rec.Record(inner))
+//	resp, err := handler.ServeResource(ctx, req)
+//
+// A ResponseRecorder is not safe for handlers served concurrently.
+type ResponseRecorder struct {
+	// Served is whether the handler was served a request.
+	Served bool
+
+	// Request is the request the handler was served.
+	Request lambdamux.APIGatewayProxyRequest
+
+	// Response is the response the handler returned, as returned.
+	Response lambdamux.APIGatewayProxyResponse
+
+	// Err is the error the handler returned.
+	Err error
+
+	// Code is the response's status code.
+	Code int
+
+	// HeaderMap is the response's headers, the response's HTTPHeader merged
+	// with its MultiValueHeaders, and Headers, as API Gateway merges them.
+	HeaderMap http.Header
+
+	// Body is the response's body, decoded if base64 encoded. The body is
+	// not decoded if it is not valid base64.
+	Body *bytes.Buffer
+
+	// IsBase64Encoded is whether the handler base64 encoded the response's
+	// body.
+	IsBase64Encoded bool
+}
+
+// NewRecorder returns an initialized ResponseRecorder.
+func NewRecorder() *ResponseRecorder {
+	return &ResponseRecorder{
+		HeaderMap: http.Header{},
+		Body:      new(bytes.Buffer),
+	}
+}
+
+// Record returns a resource handler delegating to the handler, recording
+// the request it was served, and the response it returned, replacing the
+// recorder's previous recording.
+func (r *ResponseRecorder) Record(handler lambdamux.ResourceHandler) lambdamux.ResourceHandler {
+	return lambdamux.ResourceHandlerFunc(func(
+		ctx context.Context, req lambdamux.APIGatewayProxyRequest,
+	) (lambdamux.APIGatewayProxyResponse, error) {
+		resp, err := handler.ServeResource(ctx, req)
+		r.record(req, resp, err)
+		return resp, err
+	})
+}
+
+// Serve serves the request with the handler, recording the request, and the
+// response the handler returned.
+func (r *ResponseRecorder) Serve(
+	ctx context.Context, handler lambdamux.ResourceHandler, req lambdamux.APIGatewayProxyRequest,
+) {
+	r.Record(handler).ServeResource(ctx, req)
+}
+
+// Header returns the response's headers. Equivalent to HeaderMap.
+func (r *ResponseRecorder) Header() http.Header {
+	return r.HeaderMap
+}
+
+// Result returns the response the handler returned, and its error.
+func (r *ResponseRecorder) Result() (lambdamux.APIGatewayProxyResponse, error) {
+	return r.Response, r.Err
+}
+
+func (r *ResponseRecorder) record(
+	req lambdamux.APIGatewayProxyRequest, resp lambdamux.APIGatewayProxyResponse, err error,
+) {
+	r.Served = true
+	r.Request = req
+	r.Response = resp
+	r.Err = err
+	r.Code = resp.StatusCode
+	r.IsBase64Encoded = resp.IsBase64Encoded
+
+	r.HeaderMap = http.Header{}
+	for k, vs := range resp.HTTPHeader {
+		r.HeaderMap[k] = append([]string(nil), vs...)
+	}
+	for k, vs := range resp.MultiValueHeaders {
+		if len(r.HeaderMap.Values(k)) == 0 {
+			r.HeaderMap[http.CanonicalHeaderKey(k)] = append([]string(nil), vs...)
+		}
+	}
+	for k, v := range resp.Headers {
+		if len(r.HeaderMap.Values(k)) == 0 {
+			r.HeaderMap.Set(k, v)
+		}
+	}
+
+	r.Body = new(bytes.Buffer)
+	if body, err := resp.BodyBytes(); err == nil {
+		r.Body.Write(body)
+	} else {
+		r.Body.WriteString(resp.Body)
+	}
+}
@@ -0,0 +1,236 @@
+package lambdamuxtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// RequestBuilder builds API Gateway Proxy requests to serve with resource
+// handlers in tests, as API Gateway would provide them.
+//
+//	req := lambdamuxtest.NewRequest(http.MethodPut, "/users/{id}").
+//		PathParam("id", "42").
+//		Header("If-Match", `"v1"`).
+//		JSONBody(user).
+//		Build()
+type RequestBuilder struct {
+	method   string
+	resource string
+	query    url.Values
+	params   map[string]string
+	header   http.Header
+	body     string
+	base64   bool
+	stage    map[string]string
+	claims   map[string]interface{}
+	sourceIP string
+	err      string
+}
+
+// NewRequest returns a RequestBuilder of a request for the method, and
+// target. The target is the request's API Gateway resource, and optional
+// query, e.g. "/users/{id}?expand=orders". The request's path is the
+// resource with its parameters substituted by the path parameters of the
+// builder. Resources without parameters are the request's path, e.g.
+// "/users/42", as with the path of a request to a greedy resource served by
+// a ServePath. Build panics if the target cannot be parsed.
+func NewRequest(method, target string) *RequestBuilder {
+	b := &RequestBuilder{
+		method:   method,
+		query:    url.Values{},
+		params:   map[string]string{},
+		header:   http.Header{},
+		stage:    map[string]string{},
+		sourceIP: "192.0.2.1",
+	}
+
+	resource, rawQuery, _ := strings.Cut(target, "?")
+	if !strings.HasPrefix(resource, "/") {
+		b.err = "lambdamuxtest: invalid NewRequest target " + target + ", expect absolute path"
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		b.err = "lambdamuxtest: invalid NewRequest target " + target + ", " + err.Error()
+	}
+	b.resource = resource
+	for k, vs := range query {
+		b.query[k] = vs
+	}
+	return b
+}
+
+// PathParam sets the path parameter's value, substituted into the
+// request's path.
+func (b *RequestBuilder) PathParam(name, value string) *RequestBuilder {
+	b.params[name] = value
+	return b
+}
+
+// Query adds the value to the request's query parameter.
+func (b *RequestBuilder) Query(name, value string) *RequestBuilder {
+	b.query.Add(name, value)
+	return b
+}
+
+// Header adds the value to the request's header.
+func (b *RequestBuilder) Header(name, value string) *RequestBuilder {
+	b.header.Add(name, value)
+	return b
+}
+
+// Body sets the request's body, and its Content-Type, if not empty.
+func (b *RequestBuilder) Body(contentType, body string) *RequestBuilder {
+	if len(contentType) != 0 {
+		b.header.Set("Content-Type", contentType)
+	}
+	b.body, b.base64 = body, false
+	return b
+}
+
+// BinaryBody sets the request's body, base64 encoded as API Gateway encodes
+// binary bodies, and its Content-Type.
+func (b *RequestBuilder) BinaryBody(contentType string, body []byte) *RequestBuilder {
+	b.header.Set("Content-Type", contentType)
+	b.body, b.base64 = base64.StdEncoding.EncodeToString(body), true
+	return b
+}
+
+// JSONBody sets the request's body to the JSON encoding of v, with the
+// application/json Content-Type. Build panics if v cannot be encoded.
+func (b *RequestBuilder) JSONBody(v interface{}) *RequestBuilder {
+	body, err := json.Marshal(v)
+	if err != nil {
+		b.err = "lambdamuxtest: failed to marshal JSONBody " + err.Error()
+	}
+	return b.Body("application/json", string(body))
+}
+
+// FormBody sets the request's body to the URL encoded form, with the
+// application/x-www-form-urlencoded Content-Type.
+func (b *RequestBuilder) FormBody(form url.Values) *RequestBuilder {
+	return b.Body("application/x-www-form-urlencoded", form.Encode())
+}
+
+// StageVariable sets the stage variable's value.
+func (b *RequestBuilder) StageVariable(name, value string) *RequestBuilder {
+	b.stage[name] = value
+	return b
+}
+
+// Claim sets the value of the request's authorizer claim, as a Cognito
+// user pool authorizer provides them, e.g. "sub", or "custom:tenant".
+func (b *RequestBuilder) Claim(name string, value interface{}) *RequestBuilder {
+	if b.claims == nil {
+		b.claims = map[string]interface{}{}
+	}
+	b.claims[name] = value
+	return b
+}
+
+// SourceIP sets the request's source IP. Defaults to "192.0.2.1".
+func (b *RequestBuilder) SourceIP(ip string) *RequestBuilder {
+	b.sourceIP = ip
+	return b
+}
+
+// Build returns the API Gateway Proxy request. Panics if the builder's
+// target, or body, is invalid.
+func (b *RequestBuilder) Build() lambdamux.APIGatewayProxyRequest {
+	if len(b.err) != 0 {
+		panic(b.err)
+	}
+
+	header := b.header.Clone()
+	query := url.Values{}
+	single := make(map[string]string, len(b.query))
+	for k, vs := range b.query {
+		query[k] = append([]string(nil), vs...)
+		single[k] = vs[len(vs)-1]
+	}
+	params := make(map[string]string, len(b.params))
+	for k, v := range b.params {
+		params[k] = v
+	}
+	stage := make(map[string]string, len(b.stage))
+	for k, v := range b.stage {
+		stage[k] = v
+	}
+	authorizer := map[string]interface{}{}
+	if b.claims != nil {
+		claims := make(map[string]interface{}, len(b.claims))
+		for k, v := range b.claims {
+			claims[k] = v
+		}
+		authorizer["claims"] = claims
+	}
+
+	path := expandResource(b.resource, params)
+
+	multi := make(map[string][]string, len(header))
+	headers := make(map[string]string, len(header))
+	for k, vs := range header {
+		multi[k] = append([]string(nil), vs...)
+		headers[k] = vs[len(vs)-1]
+	}
+
+	return lambdamux.APIGatewayProxyRequest{
+		APIGatewayProxyRequest: events.APIGatewayProxyRequest{
+			Resource:                        b.resource,
+			Path:                            path,
+			HTTPMethod:                      b.method,
+			Headers:                         headers,
+			MultiValueHeaders:               multi,
+			QueryStringParameters:           single,
+			MultiValueQueryStringParameters: query,
+			PathParameters:                  params,
+			StageVariables:                  stage,
+			Body:                            b.body,
+			IsBase64Encoded:                 b.base64,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				RequestID:    "lambdamuxtest-request",
+				Stage:        "test",
+				HTTPMethod:   b.method,
+				ResourcePath: b.resource,
+				Authorizer:   authorizer,
+				Identity: events.APIGatewayRequestIdentity{
+					SourceIP: b.sourceIP,
+				},
+			},
+		},
+		HTTPHeader: header,
+	}
+}
+
+// expandResource returns the path of the resource with its parameters,
+// e.g. "{id}", or greedy "{path+}", substituted by the escaped values of the
+// path parameters. Parameters without a value are left unsubstituted.
+func expandResource(resource string, params map[string]string) string {
+	segments := strings.Split(resource, "/")
+	for i, s := range segments {
+		if len(s) < 3 || s[0] != '{' || s[len(s)-1] != '}' {
+			continue
+		}
+		name := s[1 : len(s)-1]
+		greedy := strings.HasSuffix(name, "+")
+		v, ok := params[strings.TrimSuffix(name, "+")]
+		if !ok {
+			continue
+		}
+		if !greedy {
+			segments[i] = url.PathEscape(v)
+			continue
+		}
+		parts := strings.Split(v, "/")
+		for j, p := range parts {
+			parts[j] = url.PathEscape(p)
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+	return strings.Join(segments, "/")
+}
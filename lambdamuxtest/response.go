@@ -0,0 +1,60 @@
+package lambdamuxtest
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+	"testing"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// AssertStatus fails the test if the response's status code is not the
+// expected status code.
+func AssertStatus(t testing.TB, resp lambdamux.APIGatewayProxyResponse, expect int) {
+	t.Helper()
+	if e, a := expect, resp.StatusCode; e != a {
+		t.Errorf("expect %v status, got %v, body %q", e, a, resp.Body)
+	}
+}
+
+// AssertHeader fails the test if the response's header value is not the
+// expected value.
+func AssertHeader(t testing.TB, resp lambdamux.APIGatewayProxyResponse, name, expect string) {
+	t.Helper()
+	if e, a := expect, resp.HTTPHeader.Get(name); e != a {
+		t.Errorf("expect %v %v header, got %v", e, name, a)
+	}
+}
+
+// AssertBody fails the test if the response's body, decoded if base64
+// encoded, is not the expected body.
+func AssertBody(t testing.TB, resp lambdamux.APIGatewayProxyResponse, expect string) {
+	t.Helper()
+	body, err := resp.BodyBytes()
+	if err != nil {
+		t.Fatalf("expect valid body, got %v", err)
+	}
+	if e, a := expect, string(body); e != a {
+		t.Errorf("expect %q body, got %q", e, a)
+	}
+}
+
+// DecodeJSON decodes the response's JSON body into v. Fails the test
+// immediately if the response's Content-Type is not JSON, or the body cannot
+// be decoded.
+func DecodeJSON(t testing.TB, resp lambdamux.APIGatewayProxyResponse, v interface{}) {
+	t.Helper()
+	mediaType, _, _ := mime.ParseMediaType(resp.HTTPHeader.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		t.Fatalf("expect JSON content type, got %q", resp.HTTPHeader.Get("Content-Type"))
+	}
+
+	body, err := resp.BodyBytes()
+	if err != nil {
+		t.Fatalf("expect valid body, got %v", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("expect %T JSON body, got %v, %q", v, err, body)
+	}
+}
@@ -0,0 +1,75 @@
+package lambdamux
+
+import (
+	"sync"
+	"time"
+)
+
+// Backoff durations of Lazy's retries of failed initializations.
+const (
+	lazyMinBackoff = 100 * time.Millisecond
+	lazyMaxBackoff = 30 * time.Second
+)
+
+// Lazy returns a function returning the value initialized by init, once
+// per container, e.g. an SDK client, or database pool, initialized by the
+// first invoke using it, instead of in the function's init, where a
+// failure fails the container's init.
+//
+//	var dbPool = lambdamux.Lazy(func() (*sql.DB, error) {
+//		return sql.Open("postgres", os.Getenv("DATABASE_URL"))
+//	})
+//
+//	db, err := dbPool()
+//
+// Failed initializations are not cached for the container's lifetime, so a
+// transient failure does not poison the container. The failure's error is
+// returned until its backoff elapses, doubling from 100ms, up to 30s, with
+// each consecutive failure, and the value is initialized again by the next
+// call. Calls concurrent with an initialization wait for it. If init
+// panics, the panic is propagated to the caller, and the value initialized
+// again by the next call.
+func Lazy[T any](init func() (T, error)) func() (T, error) {
+	var (
+		mu       sync.Mutex
+		done     bool
+		value    T
+		err      error
+		failures int
+		retryAt  time.Time
+	)
+
+	return func() (T, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if done {
+			return value, nil
+		}
+		if err != nil && time.Now().Before(retryAt) {
+			return value, err
+		}
+
+		value, err = init()
+		if err != nil {
+			failures++
+			retryAt = time.Now().Add(lazyBackoff(failures))
+			return value, err
+		}
+
+		done, failures = true, 0
+		return value, nil
+	}
+}
+
+// lazyBackoff returns the backoff of the consecutive failed initialization.
+func lazyBackoff(failures int) time.Duration {
+	backoff := lazyMinBackoff
+	for i := 1; i < failures && backoff < lazyMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > lazyMaxBackoff {
+		backoff = lazyMaxBackoff
+	}
+	return backoff
+}
@@ -1,12 +1,379 @@
 package lambdamux
 
-import "net/http"
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
-func StartLocalServer(addr string) error {
-	mux := http.NewServeMux()
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.jasdel.dev/aws/lambda-mux/headers"
+)
 
-	return http.ListenAndServe(addr, mux)
+// StartLocalServer starts a LocalServer listening on the TCP network
+// address, serving requests with the resource handler, e.g. for running an
+// application locally with "go run", without SAM, or Docker. Always
+// returns a non-nil error.
+func StartLocalServer(addr string, handler ResourceHandler) error {
+	return NewLocalServer(handler, WithLocalAddr(addr)).ListenAndServe()
 }
 
+// LocalServerOption configures a LocalServer created by NewLocalServer, or
+// NewLocalProxyServer.
+type LocalServerOption func(*LocalServer)
+
+// WithLocalAddr returns a LocalServerOption setting the TCP network address
+// the server listens on. Defaults to ":8080".
+func WithLocalAddr(addr string) LocalServerOption {
+	return func(s *LocalServer) {
+		s.addr = addr
+	}
+}
+
+// WithLocalReadTimeout returns a LocalServerOption setting the maximum
+// duration for reading requests, as http.Server's ReadTimeout.
+func WithLocalReadTimeout(d time.Duration) LocalServerOption {
+	return func(s *LocalServer) {
+		s.readTimeout = d
+	}
+}
+
+// WithLocalWriteTimeout returns a LocalServerOption setting the maximum
+// duration before timing out writes of responses, as http.Server's
+// WriteTimeout.
+func WithLocalWriteTimeout(d time.Duration) LocalServerOption {
+	return func(s *LocalServer) {
+		s.writeTimeout = d
+	}
+}
+
+// LocalServer is an http.Handler serving HTTP requests with an
+// APIGatewayProxy, translating the requests into API Gateway Proxy events,
+// invoking the proxy, and writing the proxy's response, as API Gateway
+// would.
+//
+// The event's Resource, and PathParameters, are set from the route
+// templates of the proxy's handler tree, Routes, the request's path
+// matches, with the same precedence as ServePath, so handlers routing by
+// resource are served as they are behind API Gateway. Requests whose path
+// matches no route are responded to with 404 Not Found. If the handler tree
+// has no routes with resources, e.g. the handler is a ServePath behind a
+// greedy resource, the event's Resource is the request's path.
+//
+// The server's exported fields configure the simulated API Gateway stage,
+// and must not be modified while the server is serving requests.
 type LocalServer struct {
+	// Stage is the name of the API's stage. Defaults to "local".
+	Stage string
+
+	// StageVariables are the stage's variables, set as the events' stage
+	// variables.
+	StageVariables map[string]string
+
+	// Claims are the claims of the fake authorizer, set as the events'
+	// authorizer "claims", as a Cognito user pool authorizer does, e.g.
+	// {"sub": "user-123", "custom:tenant": "acme"}.
+	Claims map[string]interface{}
+
+	// BinaryMediaTypes are the API's binary media types, e.g. "image/*", or
+	// "*/*". If set, request bodies are base64 encoded only if their
+	// Content-Type matches a binary media type, and base64 encoded response
+	// bodies are decoded only if their Content-Type, or the request's
+	// Accept header, matches one, as REST APIs do. Otherwise request bodies
+	// that are not textual are base64 encoded, and response bodies always
+	// decoded.
+	BinaryMediaTypes []string
+
+	// BasePath is the base path of the API's custom domain mapping, e.g.
+	// "/v1". If set, requests with paths outside of the base path are
+	// responded to with 404 Not Found, and resources matched by the path
+	// within the base path. As with REST API custom domains, the events'
+	// Path includes the base path.
+	BasePath string
+
+	invoke   lambda.Handler
+	root     *pathNode
+	catchAll bool
+
+	addr         string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu     sync.Mutex
+	server *http.Server
+
+	routes RouteTable
+	debug  *localDebug
+}
+
+// NewLocalServer returns a LocalServer serving requests with the resource
+// handler, wrapped by an APIGatewayProxy, configured with the options.
+func NewLocalServer(handler ResourceHandler, opts ...LocalServerOption) *LocalServer {
+	return NewLocalProxyServer(APIGatewayProxy{Handler: handler}, opts...)
+}
+
+// NewLocalProxyServer returns a LocalServer serving requests with the
+// APIGatewayProxy, e.g. to serve requests with the proxy's ErrorHandler,
+// configured with the options.
+func NewLocalProxyServer(proxy APIGatewayProxy, opts ...LocalServerOption) *LocalServer {
+	s := &LocalServer{
+		invoke: proxy,
+		root:   &pathNode{},
+		addr:   ":8080",
+	}
+	for _, fn := range opts {
+		fn(s)
+	}
+
+	seen := map[string]struct{}{}
+	s.routes = Routes(proxy.Handler)
+	for _, e := range s.routes {
+		if len(e.Resource) == 0 {
+			s.catchAll = true
+			continue
+		}
+		if _, ok := seen[e.Resource]; ok {
+			continue
+		}
+		seen[e.Resource] = struct{}{}
+
+		pattern, err := parseRoutePattern(e.Resource)
+		if err != nil {
+			continue
+		}
+		s.root.insert(pathRoute{template: e.Resource, pattern: pattern})
+	}
+	if len(seen) == 0 {
+		s.catchAll = true
+	}
+
+	return s
+}
+
+// ListenAndServe listens on the server's TCP network address, and serves
+// requests until the server is shut down. Always returns a non-nil error,
+// http.ErrServerClosed after Shutdown.
+func (s *LocalServer) ListenAndServe() error {
+	return s.httpServer().ListenAndServe()
+}
+
+// ListenAndServeTLS listens on the server's TCP network address, and serves
+// HTTPS requests with the certificate, and key, files until the server is
+// shut down, as http.Server's ListenAndServeTLS does. Always returns a
+// non-nil error, http.ErrServerClosed after Shutdown.
+func (s *LocalServer) ListenAndServeTLS(certFile, keyFile string) error {
+	return s.httpServer().ListenAndServeTLS(certFile, keyFile)
+}
+
+// Serve serves requests accepted on the listener until the server is shut
+// down, e.g. a listener on a random port in tests. Always returns a non-nil
+// error, http.ErrServerClosed after Shutdown.
+func (s *LocalServer) Serve(l net.Listener) error {
+	return s.httpServer().Serve(l)
+}
+
+// Shutdown gracefully shuts the server down, without interrupting requests
+// being served, as http.Server's Shutdown does. Returns the context's error
+// if the context is done before the requests are served.
+func (s *LocalServer) Shutdown(ctx context.Context) error {
+	return s.httpServer().Shutdown(ctx)
+}
+
+// httpServer returns the server's http.Server, creating it on first use.
+func (s *LocalServer) httpServer() *http.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.server == nil {
+		s.server = &http.Server{
+			Addr:         s.addr,
+			Handler:      s,
+			ReadTimeout:  s.readTimeout,
+			WriteTimeout: s.writeTimeout,
+		}
+	}
+	return s.server
+}
+
+// ServeHTTP implements the http.Handler interface, serving the request with
+// the server's APIGatewayProxy.
+func (s *LocalServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.debug != nil && r.URL.Path == LocalDebugPath {
+		s.serveDebug(w)
+		return
+	}
+
+	req, err := proxyRequestFromHTTP(r)
+	if err != nil {
+		localServerError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	escapedPath, ok := s.trimBasePath(r.URL.EscapedPath())
+	if !ok || !s.matchResource(&req, escapedPath) {
+		localServerError(w, http.StatusNotFound, http.StatusText(http.StatusNotFound))
+		return
+	}
+	if err := s.simulateStage(&req); err != nil {
+		localServerError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := s.invokeProxy(r.Context(), req)
+	if err != nil {
+		log.Printf("%s %s %s invoke failed, %v", req.RequestContext.RequestID,
+			req.HTTPMethod, req.Path, err)
+		localServerError(w, http.StatusBadGateway, "Internal server error")
+		return
+	}
+
+	if resp.IsBase64Encoded && s.BinaryMediaTypes != nil &&
+		!matchMediaType(s.BinaryMediaTypes, resp.HTTPHeader.Get("Content-Type")) &&
+		!s.acceptsBinary(r.Header.Values("Accept")) {
+		// REST APIs pass base64 encoded bodies of responses that are not
+		// binary media types through without decoding them.
+		resp.IsBase64Encoded = false
+	}
+
+	writeProxyResponse(w, resp)
+}
+
+// trimBasePath returns the escaped path within the server's base path, and
+// false if the path is outside of the base path.
+func (s *LocalServer) trimBasePath(escapedPath string) (string, bool) {
+	base := strings.TrimSuffix(s.BasePath, "/")
+	if len(base) == 0 {
+		return escapedPath, true
+	}
+	if base[0] != '/' {
+		base = "/" + base
+	}
+	if escapedPath == base {
+		return "/", true
+	}
+	if !strings.HasPrefix(escapedPath, base+"/") {
+		return "", false
+	}
+	return escapedPath[len(base):], true
+}
+
+// simulateStage sets the request's stage, stage variables, and authorizer
+// claims, and encodes the request's body by the server's binary media
+// types.
+func (s *LocalServer) simulateStage(req *APIGatewayProxyRequest) error {
+	req.RequestContext.Stage = s.Stage
+	if len(req.RequestContext.Stage) == 0 {
+		req.RequestContext.Stage = "local"
+	}
+	for k, v := range s.StageVariables {
+		req.StageVariables[k] = v
+	}
+	if s.Claims != nil {
+		claims := make(map[string]interface{}, len(s.Claims))
+		for k, v := range s.Claims {
+			claims[k] = v
+		}
+		req.RequestContext.Authorizer["claims"] = claims
+	}
+
+	if s.BinaryMediaTypes == nil {
+		return nil
+	}
+	body, err := req.BodyBytes()
+	if err != nil {
+		return err
+	}
+	if matchMediaType(s.BinaryMediaTypes, req.HTTPHeader.Get("Content-Type")) {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.IsBase64Encoded = true
+	} else {
+		req.Body = string(body)
+		req.IsBase64Encoded = false
+	}
+	return nil
+}
+
+// acceptsBinary returns if the Accept header values include a binary media
+// type of the server.
+func (s *LocalServer) acceptsBinary(accept []string) bool {
+	for _, v := range accept {
+		for _, t := range strings.Split(v, ",") {
+			if matchMediaType(s.BinaryMediaTypes, strings.TrimSpace(t)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchResource sets the request's Resource, and PathParameters, from the
+// route template the escaped path matches. Returns false if the path
+// matches no route template.
+func (s *LocalServer) matchResource(req *APIGatewayProxyRequest, escapedPath string) bool {
+	var route pathRoute
+	var params map[string]string
+	matched := s.root.match(splitPath(escapedPath), 0, func(r pathRoute) bool {
+		var ok bool
+		params, ok = r.pattern.matchPath(escapedPath)
+		route = r
+		return ok
+	})
+	if !matched {
+		return s.catchAll
+	}
+
+	req.Resource = route.pattern.resource
+	req.RequestContext.ResourcePath = route.pattern.resource
+	req.PathParameters = params
+	return true
+}
+
+// invokeProxy invokes the proxy with the request's event, returning the
+// proxy's response.
+func (s *LocalServer) invokeProxy(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	out, err := s.invoke.Invoke(ctx, payload)
+	if s.debug != nil {
+		invoke := localInvoke{
+			Time:   time.Now().UTC(),
+			Method: req.HTTPMethod,
+			Path:   req.Path,
+			Event:  payload,
+		}
+		if err != nil {
+			invoke.Error = err.Error()
+		} else if json.Valid(out) {
+			invoke.Response = out
+		}
+		s.debug.record(invoke)
+	}
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	var resp APIGatewayProxyResponse
+	if err := json.Unmarshal(out, &resp.APIGatewayProxyResponse); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	resp.HTTPHeader = headers.FromEvent(resp.Headers, resp.MultiValueHeaders)
+	resp.Headers, resp.MultiValueHeaders = nil, nil
+	return resp, nil
+}
+
+// localServerError writes the API Gateway style JSON error response.
+func localServerError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
 }
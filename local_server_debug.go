@@ -0,0 +1,96 @@
+package lambdamux
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LocalDebugPath is the path of the LocalServer's debug endpoint.
+const LocalDebugPath = "/_lambdamux/debug"
+
+// WithLocalDebug returns a LocalServerOption enabling the server's debug
+// endpoint, LocalDebugPath, responding with the JSON document of the last n
+// events synthesized from requests, and the raw responses of the proxy's
+// invokes, newest first, and the route table of the proxy's handler tree:
+//
+//	{"routes": [{"resource": "/users/{id}", "method": "GET"}],
+//	 "invokes": [{"time": "...", "event": {...}, "response": {...}}]}
+//
+// The endpoint helps debug mismatches between local HTTP requests, and the
+// payloads of API Gateway. Requests to the endpoint are not served by the
+// proxy, nor recorded.
+func WithLocalDebug(n int) LocalServerOption {
+	return func(s *LocalServer) {
+		if n > 0 {
+			s.debug = &localDebug{size: n}
+		}
+	}
+}
+
+// localInvoke is an invoke of the LocalServer's proxy recorded for the
+// debug endpoint.
+type localInvoke struct {
+	Time     time.Time       `json:"time"`
+	Method   string          `json:"method"`
+	Path     string          `json:"path"`
+	Event    json.RawMessage `json:"event"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// localDebug is the ring of the last invokes of the LocalServer's proxy.
+type localDebug struct {
+	size int
+
+	mu      sync.Mutex
+	invokes []localInvoke
+	next    int
+}
+
+// record records the invoke, replacing the oldest invoke if the ring is
+// full.
+func (d *localDebug) record(invoke localInvoke) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.invokes) < d.size {
+		d.invokes = append(d.invokes, invoke)
+		return
+	}
+	d.invokes[d.next] = invoke
+	d.next = (d.next + 1) % d.size
+}
+
+// recent returns the recorded invokes, newest first.
+func (d *localDebug) recent() []localInvoke {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	invokes := make([]localInvoke, 0, len(d.invokes))
+	for i := len(d.invokes) - 1; i >= 0; i-- {
+		invokes = append(invokes, d.invokes[(d.next+i)%len(d.invokes)])
+	}
+	return invokes
+}
+
+// serveDebug writes the debug endpoint's JSON document.
+func (s *LocalServer) serveDebug(w http.ResponseWriter) {
+	routes := s.routes
+	if routes == nil {
+		routes = RouteTable{}
+	}
+	b, err := json.MarshalIndent(struct {
+		Routes  RouteTable    `json:"routes"`
+		Invokes []localInvoke `json:"invokes"`
+	}{routes, s.debug.recent()}, "", "  ")
+	if err != nil {
+		localServerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(b)
+}
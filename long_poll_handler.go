@@ -0,0 +1,94 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LongPoll is a resource handler for long-poll endpoints, waiting for data
+// to become available by calling Poll, with backoff, until data is available
+// or the wait ends, responding with a 204 No Content response if no data
+// became available.
+//
+// The wait is bounded by the Lambda invoke's deadline, less the
+// DeadlineBuffer, so the response is returned before the invoke times out,
+// even if the Lambda function's timeout is shorter than the Wait.
+type LongPoll struct {
+	// Poll returns the response for the data, and true, if data is
+	// available. The context's deadline is the end of the wait, so Poll may
+	// block, e.g. with an SQS ReceiveMessage long poll, until then.
+	Poll func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, bool, error)
+
+	// Wait is the maximum time to wait for data. Defaults to 20 seconds.
+	Wait time.Duration
+
+	// DeadlineBuffer is the time reserved before the invoke's deadline to
+	// respond. Defaults to 1 second.
+	DeadlineBuffer time.Duration
+
+	// MinInterval is the interval between the first polls, doubled after
+	// each poll up to MaxInterval. Default to 100 milliseconds, and 2
+	// seconds.
+	MinInterval, MaxInterval time.Duration
+}
+
+// ServeResource implements the ResourceHandler interface, polling for data
+// until it is available, or the wait ends.
+func (p LongPoll) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	wait := p.Wait
+	if wait == 0 {
+		wait = 20 * time.Second
+	}
+	buffer := p.DeadlineBuffer
+	if buffer == 0 {
+		buffer = time.Second
+	}
+	interval := p.MinInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = 2 * time.Second
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Add(-buffer).Before(deadline) {
+		deadline = d.Add(-buffer)
+	}
+
+	pollCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	for {
+		resp, ok, err := p.Poll(pollCtx, req)
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return NoContent(), nil
+			}
+			return APIGatewayProxyResponse{}, err
+		}
+		if ok {
+			return resp, nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return NoContent(), nil
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return APIGatewayProxyResponse{}, ctx.Err()
+		case <-t.C:
+		}
+
+		if interval *= 2; interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
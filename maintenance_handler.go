@@ -0,0 +1,181 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaintenanceState is the state of maintenance mode.
+type MaintenanceState struct {
+	Enabled bool `json:"enabled"`
+
+	// Resources in maintenance. If empty all resources are in maintenance.
+	Resources []string `json:"resources,omitempty"`
+
+	// Seconds clients should wait before retrying, sent as the Retry-After
+	// header.
+	RetryAfter int `json:"retryAfter,omitempty"`
+}
+
+// ParseMaintenanceState parses the maintenance mode state from a value, e.g.
+// an environment variable or SSM parameter. The value is either a boolean,
+// "true", "on", "false", "off", or a JSON document:
+//
+//	{"enabled": true, "resources": ["/orders"], "retryAfter": 300}
+func ParseMaintenanceState(v string) (MaintenanceState, error) {
+	var state MaintenanceState
+
+	v = strings.TrimSpace(v)
+	switch strings.ToLower(v) {
+	case "", "off":
+		return state, nil
+	case "on":
+		state.Enabled = true
+		return state, nil
+	}
+
+	if strings.HasPrefix(v, "{") {
+		if err := json.Unmarshal([]byte(v), &state); err != nil {
+			return state, fmt.Errorf("invalid maintenance state, %w", err)
+		}
+		return state, nil
+	}
+
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return state, fmt.Errorf("invalid maintenance state, %q", v)
+	}
+	state.Enabled = enabled
+	return state, nil
+}
+
+// MaintenanceSource is the interface for providing the current maintenance
+// mode state.
+type MaintenanceSource interface {
+	MaintenanceState(context.Context) (MaintenanceState, error)
+}
+
+// EnvMaintenance is a MaintenanceSource reading the maintenance mode state
+// from the environment variable.
+type EnvMaintenance string
+
+// MaintenanceState implements the MaintenanceSource interface.
+func (e EnvMaintenance) MaintenanceState(ctx context.Context) (MaintenanceState, error) {
+	return ParseMaintenanceState(os.Getenv(string(e)))
+}
+
+// PollingMaintenance is a MaintenanceSource polling the maintenance mode
+// state with its Fetch function, caching the state for the TTL. Fetch is
+// provided by the application, e.g. reading an SSM parameter or AppConfig
+// configuration, and parsing it with ParseMaintenanceState.
+//
+// If Fetch fails the last known state continues to be used.
+type PollingMaintenance struct {
+	Fetch func(context.Context) (MaintenanceState, error)
+
+	// Duration the state is cached for. Defaults to 30 seconds.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	state   MaintenanceState
+	fetched time.Time
+}
+
+// MaintenanceState implements the MaintenanceSource interface.
+func (p *PollingMaintenance) MaintenanceState(ctx context.Context) (MaintenanceState, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ttl := p.TTL
+	if ttl == 0 {
+		ttl = 30 * time.Second
+	}
+	if !p.fetched.IsZero() && time.Since(p.fetched) < ttl {
+		return p.state, nil
+	}
+
+	state, err := p.Fetch(ctx)
+	if err != nil {
+		if p.fetched.IsZero() {
+			return state, err
+		}
+		return p.state, nil
+	}
+
+	p.state, p.fetched = state, time.Now()
+	return state, nil
+}
+
+// Maintenance configures maintenance mode for resource handlers.
+type Maintenance struct {
+	Source MaintenanceSource
+
+	// Resources that are always served, even in maintenance, e.g. health
+	// checks and admin resources.
+	Allow []string
+}
+
+type maintenanceHandler struct {
+	Maintenance Maintenance
+	Handler     ResourceHandler
+}
+
+// ResourceHandlerWithMaintenance provides a resource handler that responds
+// with a 503 Service Unavailable response while the maintenance source
+// reports maintenance mode is enabled for the request's resource. Allows
+// maintenance mode to be toggled without redeploying.
+//
+// If the maintenance state cannot be retrieved requests are served
+// normally.
+func ResourceHandlerWithMaintenance(maintenance Maintenance, handler ResourceHandler) ResourceHandler {
+	return maintenanceHandler{
+		Maintenance: maintenance,
+		Handler:     handler,
+	}
+}
+
+// ServeResource responds with a 503 Service Unavailable response if the
+// request's resource is in maintenance, otherwise delegates to the wrapped
+// handler.
+func (h maintenanceHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	for _, allow := range h.Maintenance.Allow {
+		if allow == req.Resource {
+			return h.Handler.ServeResource(ctx, req)
+		}
+	}
+
+	state, err := h.Maintenance.Source.MaintenanceState(ctx)
+	if err != nil || !state.inMaintenance(req.Resource) {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	resp = statusResponse(http.StatusServiceUnavailable)
+	if state.RetryAfter > 0 {
+		resp.HTTPHeader.Set("Retry-After", strconv.Itoa(state.RetryAfter))
+	}
+	return resp, nil
+}
+
+func (s MaintenanceState) inMaintenance(resource string) bool {
+	if !s.Enabled {
+		return false
+	}
+	if len(s.Resources) == 0 {
+		return true
+	}
+	for _, r := range s.Resources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// MethodOverrideHeader is the HTTP header clients use to override the HTTP
+// method of a POST request.
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// methodOverrideField is the form field clients use to override the HTTP
+// method of a POST request with a form body.
+const methodOverrideField = "_method"
+
+// overridableMethods are the HTTP methods a POST request can be overridden
+// with.
+var overridableMethods = map[string]struct{}{
+	http.MethodPut:    {},
+	http.MethodPatch:  {},
+	http.MethodDelete: {},
+}
+
+type methodOverrideHandler struct {
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithMethodOverride provides a resource handler that honors
+// the X-HTTP-Method-Override header, or "_method" form field, for clients
+// behind proxies that can only send GET and POST requests. The request's
+// HTTP method is rewritten before the request is passed to handler, so it
+// should wrap ServeMethod handlers.
+//
+// Only POST requests are overridden, and only with the PUT, PATCH, or DELETE
+// methods. The header takes precedence over the form field.
+func ResourceHandlerWithMethodOverride(handler ResourceHandler) ResourceHandler {
+	return methodOverrideHandler{
+		Handler: handler,
+	}
+}
+
+// ServeResource rewrites the request's HTTP method if overridden.
+func (h methodOverrideHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	if !strings.EqualFold(req.HTTPMethod, http.MethodPost) {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	method := req.HTTPHeader.Get(MethodOverrideHeader)
+	if len(method) == 0 {
+		method = formMethodOverride(req)
+	}
+
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if _, ok := overridableMethods[method]; ok {
+		req.HTTPMethod = method
+		req.RequestContext.HTTPMethod = method
+	}
+
+	return h.Handler.ServeResource(ctx, req)
+}
+
+// formMethodOverride returns the method override form field of the request
+// body, if the body is a URL encoded form.
+func formMethodOverride(req APIGatewayProxyRequest) string {
+	form, err := requestForm(req)
+	if err != nil {
+		return ""
+	}
+	return form.Get(methodOverrideField)
+}
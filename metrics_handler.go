@@ -0,0 +1,236 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RequestMetrics are the metrics recorded for a request.
+type RequestMetrics struct {
+	Time time.Time
+
+	// Route is the HTTP method and resource, e.g. "GET /users/{id}".
+	Route      string
+	StatusCode int
+	Latency    time.Duration
+
+	// Dimensions extracted from the request by the metrics' dimension
+	// functions, e.g. tenant or plan.
+	Dimensions map[string]string
+
+	// Custom metric values added by handlers via AddMetric.
+	Values map[string]float64
+}
+
+// MetricsRecorder is the interface for recording request metrics, e.g. to
+// CloudWatch.
+type MetricsRecorder interface {
+	RecordMetrics(context.Context, RequestMetrics)
+}
+
+// DimensionFunc extracts a metric dimension's value from a request. Empty
+// values are omitted.
+type DimensionFunc func(context.Context, APIGatewayProxyRequest) string
+
+// HeaderDimension returns a DimensionFunc extracting the dimension from the
+// request's HTTP header.
+func HeaderDimension(header string) DimensionFunc {
+	return func(ctx context.Context, req APIGatewayProxyRequest) string {
+		return req.HTTPHeader.Get(header)
+	}
+}
+
+// ClaimDimension returns a DimensionFunc extracting the dimension from the
+// request's authorizer claim, e.g. "custom:tenant".
+func ClaimDimension(claim string) DimensionFunc {
+	return func(ctx context.Context, req APIGatewayProxyRequest) string {
+		claims, _ := req.RequestContext.Authorizer["claims"].(map[string]interface{})
+		v, _ := claims[claim].(string)
+		return v
+	}
+}
+
+// APIKeyDimension is a DimensionFunc extracting the ID of the API key the
+// request was made with.
+func APIKeyDimension(ctx context.Context, req APIGatewayProxyRequest) string {
+	return req.RequestContext.Identity.APIKeyID
+}
+
+// Metrics configures the metrics recorded for requests.
+type Metrics struct {
+	Recorder MetricsRecorder
+
+	// Dimensions extracted from each request by name, e.g. "Tenant". The
+	// "ClientApp" dimension, ClientAppDimension, is extracted by default, so
+	// direct invokes of mobile applications are recorded by application.
+	Dimensions map[string]DimensionFunc
+
+	// Usage counts requests per the key extracted by UsageKey, if both are
+	// set, so usage based billing can be derived from the counts. Requests
+	// with an empty usage key are not counted.
+	Usage    *QuotaCounter
+	UsageKey DimensionFunc
+}
+
+type metricsHandler struct {
+	Metrics Metrics
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithMetrics provides a resource handler that records
+// metrics for each request served by handler.
+func ResourceHandlerWithMetrics(metrics Metrics, handler ResourceHandler) ResourceHandler {
+	return metricsHandler{
+		Metrics: metrics,
+		Handler: handler,
+	}
+}
+
+type metricValuesKey struct{}
+
+type metricValues struct {
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// AddMetric adds the value to the named custom metric recorded for the
+// request being served. Does nothing if the request is not being served by
+// a metrics resource handler.
+func AddMetric(ctx context.Context, name string, value float64) {
+	v, ok := ctx.Value(metricValuesKey{}).(*metricValues)
+	if !ok {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.values[name] += value
+}
+
+// ServeResource delegates to the wrapped handler, recording the request's
+// metrics.
+func (h metricsHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	values := &metricValues{values: map[string]float64{}}
+	ctx = context.WithValue(ctx, metricValuesKey{}, values)
+
+	start := time.Now()
+	resp, err = h.Handler.ServeResource(ctx, req)
+	latency := time.Since(start)
+
+	if h.Metrics.Usage != nil && h.Metrics.UsageKey != nil {
+		if key := h.Metrics.UsageKey(ctx, req); len(key) != 0 {
+			h.Metrics.Usage.Add(ctx, key, 1)
+		}
+	}
+
+	if h.Metrics.Recorder == nil {
+		return resp, err
+	}
+
+	dims := make(map[string]string, len(h.Metrics.Dimensions))
+	for name, fn := range h.Metrics.Dimensions {
+		if v := fn(ctx, req); len(v) != 0 {
+			dims[name] = v
+		}
+	}
+	if _, ok := h.Metrics.Dimensions["ClientApp"]; !ok {
+		if v := ClientAppDimension(ctx, req); len(v) != 0 {
+			dims["ClientApp"] = v
+		}
+	}
+
+	statusCode := resp.StatusCode
+	if err != nil {
+		statusCode = 502
+	}
+
+	values.mu.Lock()
+	defer values.mu.Unlock()
+
+	h.Metrics.Recorder.RecordMetrics(ctx, RequestMetrics{
+		Time:       start,
+		Route:      req.HTTPMethod + " " + req.Resource,
+		StatusCode: statusCode,
+		Latency:    latency,
+		Dimensions: dims,
+		Values:     values.values,
+	})
+
+	return resp, err
+}
+
+// EMFRecorder is a MetricsRecorder writing metrics to its Writer in the
+// CloudWatch Embedded Metric Format, so metrics are extracted from the
+// Lambda function's logs without calling the CloudWatch API.
+type EMFRecorder struct {
+	Namespace string
+
+	// Writer metrics are written to. Defaults to os.Stdout.
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+// RecordMetrics implements the MetricsRecorder interface.
+func (r *EMFRecorder) RecordMetrics(ctx context.Context, m RequestMetrics) {
+	doc := map[string]interface{}{
+		"Route":      m.Route,
+		"StatusCode": m.StatusCode,
+		"Latency":    float64(m.Latency) / float64(time.Millisecond),
+		"Count":      1,
+	}
+
+	dims := []string{"Route"}
+	for name, v := range m.Dimensions {
+		doc[name] = v
+		dims = append(dims, name)
+	}
+	sort.Strings(dims[1:])
+
+	metrics := []map[string]string{
+		{"Name": "Latency", "Unit": "Milliseconds"},
+		{"Name": "Count", "Unit": "Count"},
+	}
+	if m.StatusCode >= 500 {
+		doc["Fault"] = 1
+		metrics = append(metrics, map[string]string{"Name": "Fault", "Unit": "Count"})
+	}
+	for name, v := range m.Values {
+		doc[name] = v
+		metrics = append(metrics, map[string]string{"Name": name, "Unit": "None"})
+	}
+
+	doc["_aws"] = map[string]interface{}{
+		"Timestamp": m.Time.UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": []interface{}{
+			map[string]interface{}{
+				"Namespace":  r.Namespace,
+				"Dimensions": [][]string{dims},
+				"Metrics":    metrics,
+			},
+		},
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return
+	}
+
+	w := r.Writer
+	if w == nil {
+		w = os.Stdout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w.Write(append(b, '\n'))
+}
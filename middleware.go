@@ -0,0 +1,16 @@
+package lambdamux
+
+// Middleware decorates a resource handler with cross-cutting behavior, e.g.
+// logging, or authentication, returning the decorated handler.
+type Middleware func(ResourceHandler) ResourceHandler
+
+// Chain returns a Middleware applying the middlewares in order, with the
+// first middleware being the outermost, and seeing requests first.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler ResourceHandler) ResourceHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+		return handler
+	}
+}
@@ -0,0 +1,44 @@
+package lambdamux
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// NDJSONEncoder writes values as newline delimited JSON,
+// application/x-ndjson, to an underlying writer, e.g. a buffer for a
+// buffered response, or a ResponseStream for a streamed response.
+type NDJSONEncoder struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONEncoder returns a NDJSONEncoder writing to w.
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONEncoder{enc: enc}
+}
+
+// Encode writes the value as a single line JSON document, terminated by a
+// newline.
+func (e *NDJSONEncoder) Encode(v interface{}) error {
+	return e.enc.Encode(v)
+}
+
+// NDJSONResponse returns an application/x-ndjson response of the values
+// encoded by the rows callback. The callback calls encode for each value,
+// stopping on the first error. If filename is not empty the response is
+// sent as an attachment with the filename.
+func NDJSONResponse(
+	filename string, rows func(encode func(v interface{}) error) error,
+) (APIGatewayProxyResponse, error) {
+	var buf bytes.Buffer
+	enc := NewNDJSONEncoder(&buf)
+
+	if err := rows(enc.Encode); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	return exportResponse("application/x-ndjson", filename, buf.String()), nil
+}
@@ -0,0 +1,428 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session keys the OIDC login flow stores tokens, and its transient state,
+// in.
+const (
+	OIDCSessionSubject      = "oidc_sub"
+	OIDCSessionIDToken      = "oidc_id_token"
+	OIDCSessionAccessToken  = "oidc_access_token"
+	OIDCSessionRefreshToken = "oidc_refresh_token"
+	OIDCSessionExpiry       = "oidc_expiry"
+
+	oidcSessionState    = "oidc_state"
+	oidcSessionVerifier = "oidc_verifier"
+	oidcSessionNonce    = "oidc_nonce"
+	oidcSessionReturnTo = "oidc_return_to"
+)
+
+// OIDCTokens are the tokens of a user logged in by the OIDC login flow.
+type OIDCTokens struct {
+	Subject      string
+	IDToken      string
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// OIDCTokensFromContext returns the tokens stored in the request's Session
+// by the OIDC login flow, and false if the user is not logged in.
+func OIDCTokensFromContext(ctx context.Context) (OIDCTokens, bool) {
+	s := SessionFromContext(ctx)
+	if s == nil || len(s.Get(OIDCSessionSubject)) == 0 {
+		return OIDCTokens{}, false
+	}
+
+	tokens := OIDCTokens{
+		Subject:      s.Get(OIDCSessionSubject),
+		IDToken:      s.Get(OIDCSessionIDToken),
+		AccessToken:  s.Get(OIDCSessionAccessToken),
+		RefreshToken: s.Get(OIDCSessionRefreshToken),
+	}
+	if sec, err := strconv.ParseInt(s.Get(OIDCSessionExpiry), 10, 64); err == nil {
+		tokens.Expiry = time.Unix(sec, 0)
+	}
+	return tokens, true
+}
+
+// OIDC implements the OAuth2 authorization code flow with PKCE against an
+// OpenID Connect identity provider, logging users in to server rendered
+// applications. The provider's endpoints are discovered from the issuer's
+// OpenID configuration document.
+//
+// The login, callback, and logout handlers must be served by the session
+// middleware, ResourceHandlerWithSession, where the flow's state and the
+// user's tokens are stored. Tokens are stored in the session cookie, so
+// providers issuing large tokens may exceed the cookie's size limit.
+type OIDC struct {
+	// Issuer URL of the identity provider.
+	Issuer string
+
+	ClientID     string
+	ClientSecret string
+
+	// URL of the callback handler registered with the identity provider.
+	RedirectURL string
+
+	// Scopes requested. Defaults to "openid", "profile", and "email".
+	Scopes []string
+
+	// URLs the user is redirected to after logging in, and out. Defaults
+	// to "/". Login requests can override the post login URL with a
+	// relative "return_to" query parameter.
+	PostLoginURL  string
+	PostLogoutURL string
+
+	// HTTP client the provider is requested with. Defaults to a client
+	// with a 5 second timeout.
+	Client *http.Client
+
+	discoveryOnce sync.Once
+	discovery     *OpenIDConfiguration
+}
+
+type oidcEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	EndSessionEndpoint    string `json:"end_session_endpoint"`
+}
+
+// Register adds the "/login", "/callback", and "/logout" handlers of the
+// flow to the ServeResource.
+func (o *OIDC) Register(s *ServeResource) *ServeResource {
+	return s.
+		Handle("/login", ResourceHandlerFunc(o.Login)).
+		Handle("/callback", ResourceHandlerFunc(o.Callback)).
+		Handle("/logout", ResourceHandlerFunc(o.Logout))
+}
+
+func (o *OIDC) client() *http.Client {
+	if o.Client == nil {
+		return &http.Client{Timeout: 5 * time.Second}
+	}
+	return o.Client
+}
+
+func (o *OIDC) endpoints(ctx context.Context) (oidcEndpoints, error) {
+	o.discoveryOnce.Do(func() {
+		o.discovery = &OpenIDConfiguration{Issuer: o.Issuer, Client: o.Client}
+	})
+
+	var endpoints oidcEndpoints
+	doc, err := o.discovery.document(ctx)
+	if err != nil {
+		return endpoints, err
+	}
+	if err := json.Unmarshal(doc, &endpoints); err != nil {
+		return endpoints, fmt.Errorf("invalid OpenID configuration, %w", err)
+	}
+	return endpoints, nil
+}
+
+func oidcSession(ctx context.Context) (*Session, error) {
+	s := SessionFromContext(ctx)
+	if s == nil {
+		return nil, fmt.Errorf("OIDC handlers must be served by the session middleware")
+	}
+	return s, nil
+}
+
+// Login redirects the user to the identity provider's authorization
+// endpoint.
+func (o *OIDC) Login(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	session, err := oidcSession(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	endpoints, err := o.endpoints(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	session.Set(oidcSessionState, state)
+	session.Set(oidcSessionVerifier, verifier)
+	session.Set(oidcSessionNonce, nonce)
+	session.Delete(oidcSessionReturnTo)
+	if returnTo := requestQuery(req).Get("return_to"); isLocalRedirect(returnTo) {
+		session.Set(oidcSessionReturnTo, returnTo)
+	}
+
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	query := url.Values{
+		"response_type":         []string{"code"},
+		"client_id":             []string{o.ClientID},
+		"redirect_uri":          []string{o.RedirectURL},
+		"scope":                 []string{strings.Join(scopes, " ")},
+		"state":                 []string{state},
+		"nonce":                 []string{nonce},
+		"code_challenge":        []string{base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": []string{"S256"},
+	}
+	return redirectResponse(appendQuery(endpoints.AuthorizationEndpoint, query)), nil
+}
+
+// Callback completes the flow, exchanging the authorization code for the
+// user's tokens, and storing them in the session. Callbacks with an invalid
+// state, or an error from the identity provider, are responded to with a 400
+// Bad Request response.
+func (o *OIDC) Callback(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	session, err := oidcSession(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	query := requestQuery(req)
+	state := session.Get(oidcSessionState)
+	verifier := session.Get(oidcSessionVerifier)
+	nonce := session.Get(oidcSessionNonce)
+	returnTo := session.Get(oidcSessionReturnTo)
+	for _, k := range []string{oidcSessionState, oidcSessionVerifier, oidcSessionNonce, oidcSessionReturnTo} {
+		session.Delete(k)
+	}
+
+	if len(state) == 0 || subtle.ConstantTimeCompare([]byte(state), []byte(query.Get("state"))) != 1 {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	if len(query.Get("error")) != 0 || len(query.Get("code")) == 0 {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	endpoints, err := o.endpoints(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	tokens, err := o.exchange(ctx, endpoints, query.Get("code"), verifier)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	claims, err := o.validateIDToken(endpoints, tokens.IDToken, nonce)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	session.Set(OIDCSessionSubject, claims.Subject)
+	session.Set(OIDCSessionIDToken, tokens.IDToken)
+	session.Set(OIDCSessionAccessToken, tokens.AccessToken)
+	if len(tokens.RefreshToken) != 0 {
+		session.Set(OIDCSessionRefreshToken, tokens.RefreshToken)
+	}
+	if tokens.ExpiresIn > 0 {
+		expiry := time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second)
+		session.Set(OIDCSessionExpiry, strconv.FormatInt(expiry.Unix(), 10))
+	}
+
+	if len(returnTo) == 0 {
+		returnTo = defaultURL(o.PostLoginURL)
+	}
+	return redirectResponse(returnTo), nil
+}
+
+// Logout clears the session, and redirects the user to the identity
+// provider's end session endpoint, if it has one.
+func (o *OIDC) Logout(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	session, err := oidcSession(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	idToken := session.Get(OIDCSessionIDToken)
+	session.Clear()
+
+	endpoints, err := o.endpoints(ctx)
+	if err != nil || len(endpoints.EndSessionEndpoint) == 0 {
+		return redirectResponse(defaultURL(o.PostLogoutURL)), nil
+	}
+
+	query := url.Values{
+		"client_id": []string{o.ClientID},
+	}
+	if len(idToken) != 0 {
+		query.Set("id_token_hint", idToken)
+	}
+	if len(o.PostLogoutURL) != 0 {
+		query.Set("post_logout_redirect_uri", o.PostLogoutURL)
+	}
+	return redirectResponse(appendQuery(endpoints.EndSessionEndpoint, query)), nil
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (o *OIDC) exchange(
+	ctx context.Context, endpoints oidcEndpoints, code, verifier string,
+) (oidcTokenResponse, error) {
+	var tokens oidcTokenResponse
+
+	form := url.Values{
+		"grant_type":    []string{"authorization_code"},
+		"code":          []string{code},
+		"redirect_uri":  []string{o.RedirectURL},
+		"code_verifier": []string{verifier},
+	}
+	if len(o.ClientSecret) == 0 {
+		form.Set("client_id", o.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, fmt.Errorf("failed to create OIDC token request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if len(o.ClientSecret) != 0 {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+
+	resp, err := o.client().Do(req.WithContext(ctx))
+	if err != nil {
+		return tokens, fmt.Errorf("failed to request OIDC tokens, %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return tokens, fmt.Errorf("failed to read OIDC token response, %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return tokens, fmt.Errorf("failed to request OIDC tokens, status %d, %s", resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return tokens, fmt.Errorf("invalid OIDC token response, %w", err)
+	}
+	if len(tokens.IDToken) == 0 {
+		return tokens, fmt.Errorf("OIDC token response missing id_token")
+	}
+	return tokens, nil
+}
+
+type oidcClaims struct {
+	Issuer   string      `json:"iss"`
+	Subject  string      `json:"sub"`
+	Audience interface{} `json:"aud"`
+	Expires  int64       `json:"exp"`
+	Nonce    string      `json:"nonce"`
+}
+
+// validateIDToken validates the claims of the ID token. The token's
+// signature is not verified, as the token was received directly from the
+// provider's token endpoint over TLS, as permitted by OpenID Connect Core
+// section 3.1.3.7.
+func (o *OIDC) validateIDToken(endpoints oidcEndpoints, idToken, nonce string) (oidcClaims, error) {
+	var claims oidcClaims
+
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("invalid ID token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, fmt.Errorf("invalid ID token payload, %w", err)
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return claims, fmt.Errorf("invalid ID token claims, %w", err)
+	}
+
+	issuer := endpoints.Issuer
+	if len(issuer) == 0 {
+		issuer = o.Issuer
+	}
+	if claims.Issuer != issuer {
+		return claims, fmt.Errorf("ID token issuer mismatch, %q", claims.Issuer)
+	}
+	if !audienceContains(claims.Audience, o.ClientID) {
+		return claims, fmt.Errorf("ID token audience mismatch")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return claims, fmt.Errorf("ID token expired")
+	}
+	if len(nonce) == 0 || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return claims, fmt.Errorf("ID token nonce mismatch")
+	}
+	if len(claims.Subject) == 0 {
+		return claims, fmt.Errorf("ID token missing subject")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// randomToken returns a random, URL safe, 256 bit token.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token, %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func appendQuery(u string, query url.Values) string {
+	if strings.Contains(u, "?") {
+		return u + "&" + query.Encode()
+	}
+	return u + "?" + query.Encode()
+}
+
+func defaultURL(u string) string {
+	if len(u) == 0 {
+		return "/"
+	}
+	return u
+}
+
+func redirectResponse(location string) APIGatewayProxyResponse {
+	resp := statusResponse(http.StatusFound)
+	resp.HTTPHeader.Set("Location", location)
+	resp.HTTPHeader.Set("Cache-Control", "no-store")
+	return resp
+}
@@ -0,0 +1,369 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oidcTestProvider is an identity provider serving the OpenID configuration,
+// and token endpoint, issuing ID tokens with the claims returned by claims.
+type oidcTestProvider struct {
+	*httptest.Server
+
+	// verifier the token endpoint requires the code_verifier to match.
+	verifier string
+	claims   func(issuer string) map[string]interface{}
+}
+
+func newOIDCTestProvider(t *testing.T) *oidcTestProvider {
+	p := &oidcTestProvider{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"end_session_endpoint":   p.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.PostForm.Get("code") != "code" || r.PostForm.Get("code_verifier") != p.verifier {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		claims, _ := json.Marshal(p.claims(p.URL))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"expires_in":    3600,
+			"id_token":      "e30." + base64.RawURLEncoding.EncodeToString(claims) + ".sig",
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func newTestOIDC(issuer string) *OIDC {
+	return &OIDC{
+		Issuer:       issuer,
+		ClientID:     "client",
+		RedirectURL:  "https://app.example.com/callback",
+		PostLoginURL: "/home",
+	}
+}
+
+func withTestSession(ctx context.Context, values map[string]string) (context.Context, *Session) {
+	if values == nil {
+		values = map[string]string{}
+	}
+	s := &Session{values: values}
+	return context.WithValue(ctx, sessionKey{}, s), s
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+
+	cases := map[string]struct {
+		returnTo       string
+		expectReturnTo string
+	}{
+		"no return":           {},
+		"local return":        {returnTo: "/orders?id=1", expectReturnTo: "/orders?id=1"},
+		"scheme relative":     {returnTo: "//evil.example.com"},
+		"backslash":           {returnTo: "/\\evil.example.com"},
+		"absolute":            {returnTo: "https://evil.example.com/"},
+		"relative":            {returnTo: "orders"},
+		"javascript":          {returnTo: "javascript:alert(1)"},
+		"control character":   {returnTo: "/\x7f"},
+		"encoded scheme path": {returnTo: "/a%2F%2Fb", expectReturnTo: "/a%2F%2Fb"},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			o := newTestOIDC(provider.URL)
+			ctx, session := withTestSession(context.Background(), map[string]string{
+				oidcSessionReturnTo: "/stale",
+			})
+
+			var req APIGatewayProxyRequest
+			if len(c.returnTo) != 0 {
+				req.QueryStringParameters = map[string]string{"return_to": c.returnTo}
+			}
+
+			resp, err := o.Login(ctx, req)
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := http.StatusFound, resp.StatusCode; e != a {
+				t.Fatalf("expect %v status, got %v", e, a)
+			}
+
+			location, err := url.Parse(resp.HTTPHeader.Get("Location"))
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := provider.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path; e != a {
+				t.Errorf("expect %q authorization endpoint, got %q", e, a)
+			}
+
+			query := location.Query()
+			if e, a := session.Get(oidcSessionState), query.Get("state"); len(e) == 0 || e != a {
+				t.Errorf("expect %q state, got %q", e, a)
+			}
+			if e, a := session.Get(oidcSessionNonce), query.Get("nonce"); len(e) == 0 || e != a {
+				t.Errorf("expect %q nonce, got %q", e, a)
+			}
+			if e, a := "S256", query.Get("code_challenge_method"); e != a {
+				t.Errorf("expect %q challenge method, got %q", e, a)
+			}
+			challenge := sha256.Sum256([]byte(session.Get(oidcSessionVerifier)))
+			if e, a := base64.RawURLEncoding.EncodeToString(challenge[:]), query.Get("code_challenge"); e != a {
+				t.Errorf("expect %q challenge, got %q", e, a)
+			}
+			if e, a := "openid profile email", query.Get("scope"); e != a {
+				t.Errorf("expect %q scope, got %q", e, a)
+			}
+			if e, a := c.expectReturnTo, session.Get(oidcSessionReturnTo); e != a {
+				t.Errorf("expect %q return to, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestOIDCLoginUniqueState(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	o := newTestOIDC(provider.URL)
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		ctx, session := withTestSession(context.Background(), nil)
+		if _, err := o.Login(ctx, APIGatewayProxyRequest{}); err != nil {
+			t.Fatalf("expect no error, got %v", err)
+		}
+		for _, k := range []string{oidcSessionState, oidcSessionVerifier, oidcSessionNonce} {
+			v := session.Get(k)
+			if seen[v] {
+				t.Errorf("expect unique %s, got %q repeated", k, v)
+			}
+			seen[v] = true
+		}
+	}
+}
+
+func TestOIDCCallback(t *testing.T) {
+	validClaims := func(issuer string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":   issuer,
+			"sub":   "user",
+			"aud":   "client",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	cases := map[string]struct {
+		query          map[string]string
+		session        map[string]string
+		claims         func(map[string]interface{})
+		expectStatus   int
+		expectErr      bool
+		expectLocation string
+	}{
+		"valid": {
+			expectStatus: 302, expectLocation: "/home",
+		},
+		"return to": {
+			session:      map[string]string{oidcSessionReturnTo: "/orders"},
+			expectStatus: 302, expectLocation: "/orders",
+		},
+		"audience list": {
+			claims: func(c map[string]interface{}) {
+				c["aud"] = []string{"other", "client"}
+			},
+			expectStatus: 302, expectLocation: "/home",
+		},
+		"state mismatch": {
+			query:        map[string]string{"state": "other"},
+			expectStatus: 400,
+		},
+		"no session state": {
+			session:      map[string]string{oidcSessionState: ""},
+			query:        map[string]string{"state": ""},
+			expectStatus: 400,
+		},
+		"provider error": {
+			query:        map[string]string{"error": "access_denied", "code": ""},
+			expectStatus: 400,
+		},
+		"missing code": {
+			query:        map[string]string{"code": ""},
+			expectStatus: 400,
+		},
+		"verifier mismatch": {
+			session:   map[string]string{oidcSessionVerifier: "other"},
+			expectErr: true,
+		},
+		"nonce mismatch": {
+			claims: func(c map[string]interface{}) {
+				c["nonce"] = "other"
+			},
+			expectStatus: 400,
+		},
+		"issuer mismatch": {
+			claims: func(c map[string]interface{}) {
+				c["iss"] = "https://evil.example.com"
+			},
+			expectStatus: 400,
+		},
+		"audience mismatch": {
+			claims: func(c map[string]interface{}) {
+				c["aud"] = "other"
+			},
+			expectStatus: 400,
+		},
+		"expired": {
+			claims: func(c map[string]interface{}) {
+				c["exp"] = time.Now().Add(-time.Minute).Unix()
+			},
+			expectStatus: 400,
+		},
+		"missing subject": {
+			claims: func(c map[string]interface{}) {
+				delete(c, "sub")
+			},
+			expectStatus: 400,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			provider := newOIDCTestProvider(t)
+			provider.verifier = "verifier"
+			provider.claims = func(issuer string) map[string]interface{} {
+				claims := validClaims(issuer)
+				if c.claims != nil {
+					c.claims(claims)
+				}
+				return claims
+			}
+			o := newTestOIDC(provider.URL)
+
+			values := map[string]string{
+				oidcSessionState:    "state",
+				oidcSessionVerifier: "verifier",
+				oidcSessionNonce:    "nonce",
+			}
+			for k, v := range c.session {
+				values[k] = v
+			}
+			ctx, session := withTestSession(context.Background(), values)
+
+			query := map[string]string{"state": "state", "code": "code"}
+			for k, v := range c.query {
+				query[k] = v
+			}
+			var req APIGatewayProxyRequest
+			req.QueryStringParameters = query
+
+			resp, err := o.Callback(ctx, req)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+				if e, a := c.expectStatus, resp.StatusCode; e != a {
+					t.Fatalf("expect %v status, got %v", e, a)
+				}
+			}
+
+			for _, k := range []string{oidcSessionState, oidcSessionVerifier, oidcSessionNonce, oidcSessionReturnTo} {
+				if v := session.Get(k); len(v) != 0 {
+					t.Errorf("expect %s cleared, got %q", k, v)
+				}
+			}
+
+			tokens, ok := OIDCTokensFromContext(ctx)
+			if e, a := c.expectStatus == 302, ok; e != a {
+				t.Fatalf("expect %v logged in, got %v", e, a)
+			}
+			if !ok {
+				return
+			}
+			if e, a := c.expectLocation, resp.HTTPHeader.Get("Location"); e != a {
+				t.Errorf("expect %q location, got %q", e, a)
+			}
+			if e, a := "user", tokens.Subject; e != a {
+				t.Errorf("expect %q subject, got %q", e, a)
+			}
+			if e, a := "access", tokens.AccessToken; e != a {
+				t.Errorf("expect %q access token, got %q", e, a)
+			}
+			if e, a := "refresh", tokens.RefreshToken; e != a {
+				t.Errorf("expect %q refresh token, got %q", e, a)
+			}
+			if tokens.Expiry.Before(time.Now()) {
+				t.Errorf("expect expiry in the future, got %v", tokens.Expiry)
+			}
+		})
+	}
+}
+
+func TestOIDCLogout(t *testing.T) {
+	provider := newOIDCTestProvider(t)
+	o := newTestOIDC(provider.URL)
+	o.PostLogoutURL = "https://app.example.com/"
+
+	ctx, session := withTestSession(context.Background(), map[string]string{
+		OIDCSessionSubject: "user",
+		OIDCSessionIDToken: "id-token",
+	})
+
+	resp, err := o.Logout(ctx, APIGatewayProxyRequest{})
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if _, ok := OIDCTokensFromContext(ctx); ok {
+		t.Errorf("expect logged out")
+	}
+	if v := session.Get(OIDCSessionIDToken); len(v) != 0 {
+		t.Errorf("expect session cleared, got %q", v)
+	}
+
+	location := resp.HTTPHeader.Get("Location")
+	if e, a := provider.URL+"/logout?", location; !strings.HasPrefix(a, e) {
+		t.Fatalf("expect %q location prefix, got %q", e, a)
+	}
+	query, _ := url.ParseQuery(location[strings.IndexByte(location, '?')+1:])
+	if e, a := "id-token", query.Get("id_token_hint"); e != a {
+		t.Errorf("expect %q id token hint, got %q", e, a)
+	}
+	if e, a := o.PostLogoutURL, query.Get("post_logout_redirect_uri"); e != a {
+		t.Errorf("expect %q post logout redirect, got %q", e, a)
+	}
+}
+
+func TestOIDCRequiresSession(t *testing.T) {
+	o := newTestOIDC("https://issuer.example.com")
+	if _, err := o.Login(context.Background(), APIGatewayProxyRequest{}); err == nil {
+		t.Errorf("expect error without session, got none")
+	}
+}
@@ -0,0 +1,49 @@
+package openapi
+
+import (
+	"reflect"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// BindInput binds the request to the generated input of an operation. The
+// input's fields are set from the request's path parameters, query, and
+// headers, and the JSON request body decoded into body, if not nil. Returns
+// a 400 Bad Request StatusError if the request is invalid, or the body is
+// required, and the request has none.
+//
+// The input, and body, are validated by their generated "validate", and
+// "pattern", struct tags with the lambdamux.TagValidator, returning a 422
+// Unprocessable Entity StatusError listing the fields failing validation.
+func BindInput(req lambdamux.APIGatewayProxyRequest, in, body interface{}, bodyRequired bool) error {
+	if err := req.BindPath(in); err != nil {
+		return err
+	}
+	if err := req.BindQuery(in); err != nil {
+		return err
+	}
+	if err := req.BindHeader(in); err != nil {
+		return err
+	}
+
+	var hasBody bool
+	if body != nil {
+		switch {
+		case len(req.Body) != 0:
+			if err := req.BindJSON(body); err != nil {
+				return err
+			}
+			hasBody = true
+		case bodyRequired:
+			return lambdamux.BadRequest("request body is required")
+		}
+	}
+
+	if err := (lambdamux.TagValidator{}).Validate(in); err != nil {
+		return err
+	}
+	if hasBody && reflect.Indirect(reflect.ValueOf(body)).Kind() == reflect.Struct {
+		return lambdamux.TagValidator{}.Validate(body)
+	}
+	return nil
+}
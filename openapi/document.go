@@ -0,0 +1,178 @@
+// Package openapi generates the Go models, and typed lambdamux handlers, of
+// the API described by an OpenAPI 3 document, so the API's contract is
+// code-generated from its schema.
+//
+// The models are Go structs of the document's component schemas, with
+// "json" tags, and "validate" tags of the schemas' constraints. Each
+// operation has an input struct of its parameters and request body, and a
+// method of the generated Server interface. NewHandler, also generated,
+// returns the lambdamux resource handler routing requests to the Server's
+// methods, binding their input, and encoding their output as JSON.
+//
+//	lambdamux openapi -package api -o api.gen.go openapi.json
+//
+// Documents must be JSON, YAML documents must be converted to JSON first.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Document is the subset of an OpenAPI 3 document models, and handlers,
+// are generated from.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Components are the document's reusable schemas, and parameters.
+type Components struct {
+	Schemas    map[string]*Schema    `json:"schemas"`
+	Parameters map[string]*Parameter `json:"parameters"`
+}
+
+// PathItem is the operations of a path, by method, and the parameters
+// shared by the operations.
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+
+	Get     *Operation `json:"get"`
+	Put     *Operation `json:"put"`
+	Post    *Operation `json:"post"`
+	Delete  *Operation `json:"delete"`
+	Options *Operation `json:"options"`
+	Head    *Operation `json:"head"`
+	Patch   *Operation `json:"patch"`
+}
+
+// operations returns the path's operations by HTTP method.
+func (p PathItem) operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+	for method, op := range map[string]*Operation{
+		"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete,
+		"OPTIONS": p.Options, "HEAD": p.Head, "PATCH": p.Patch,
+	} {
+		if op != nil {
+			ops[method] = op
+		}
+	}
+	return ops
+}
+
+// Operation is an API operation of a path, and method.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query, or header parameter of an operation.
+type Parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the request body of an operation, by media type.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation, by media type.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// MediaType is the schema of a request, or response, body media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema of a model, property, or parameter.
+type Schema struct {
+	Ref         string `json:"$ref"`
+	Type        string `json:"type"`
+	Format      string `json:"format"`
+	Description string `json:"description"`
+	Nullable    bool   `json:"nullable"`
+
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"-"`
+
+	Items *Schema `json:"items"`
+
+	Enum      []interface{} `json:"enum"`
+	Minimum   *float64      `json:"minimum"`
+	Maximum   *float64      `json:"maximum"`
+	MinLength *int          `json:"minLength"`
+	MaxLength *int          `json:"maxLength"`
+	MinItems  *int          `json:"minItems"`
+	MaxItems  *int          `json:"maxItems"`
+	Pattern   string        `json:"pattern"`
+}
+
+// UnmarshalJSON unmarshals the schema, with its additionalProperties
+// schema, if the document provides one instead of a bool.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	type schema Schema
+	var v struct {
+		*schema
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	v.schema = (*schema)(s)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	if p := strings.TrimSpace(string(v.AdditionalProperties)); len(p) != 0 && p[0] == '{' {
+		s.AdditionalProperties = &Schema{}
+		if err := json.Unmarshal(v.AdditionalProperties, s.AdditionalProperties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse parses the JSON OpenAPI 3 document. Returns an error if the
+// document is not an OpenAPI 3 document.
+func Parse(b []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document, %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI document version %q, expect 3.x", doc.OpenAPI)
+	}
+	return &doc, nil
+}
+
+const (
+	schemaRefPrefix    = "#/components/schemas/"
+	parameterRefPrefix = "#/components/parameters/"
+)
+
+// parameter returns the parameter, resolving its reference.
+func (d *Document) parameter(p *Parameter) (*Parameter, error) {
+	if len(p.Ref) == 0 {
+		return p, nil
+	}
+	if !strings.HasPrefix(p.Ref, parameterRefPrefix) {
+		return nil, fmt.Errorf("unsupported parameter reference %s", p.Ref)
+	}
+	ref, ok := d.Components.Parameters[strings.TrimPrefix(p.Ref, parameterRefPrefix)]
+	if !ok {
+		return nil, fmt.Errorf("parameter reference %s not found", p.Ref)
+	}
+	return ref, nil
+}
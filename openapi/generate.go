@@ -0,0 +1,679 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Config is the configuration of the generated code.
+type Config struct {
+	// Package is the name of the generated code's package.
+	Package string
+}
+
+// Generate returns the generated Go source of the document's models,
+// operation inputs, Server interface, and NewHandler function.
+//
+// Schemas are generated as structs, or named types of other schema types,
+// with fields of the schema's properties. Required properties, and
+// minimum, maximum, length, item count, enum, and pattern constraints, are
+// set as "validate" struct tags, e.g. `validate:"required,min=1,max=64"`,
+// and `pattern:"^[a-z]+$"`. Optional object, and date-time, properties are
+// pointers.
+//
+// The input of an operation is named by its operationId, or method and path
+// if it has none, e.g. "GetOrderInput" for "getOrder". The Server's method
+// of the operation returns the JSON schema of its first 2xx response, or
+// only an error if the response has no JSON content.
+func Generate(doc *Document, cfg Config) ([]byte, error) {
+	if len(cfg.Package) == 0 {
+		return nil, fmt.Errorf("generated code package not set")
+	}
+
+	g := &generator{
+		doc:   doc,
+		types: map[string]bool{},
+		imports: map[string]bool{
+			"context":                              true,
+			"go.jasdel.dev/aws/lambda-mux":         true,
+			"go.jasdel.dev/aws/lambda-mux/openapi": true,
+		},
+	}
+	if err := g.generate(); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by lambdamux openapi. DO NOT EDIT.\n\npackage %s\n\nimport (\n", cfg.Package)
+	imports := make([]string, 0, len(g.imports))
+	for k := range g.imports {
+		imports = append(imports, k)
+	}
+	// Standard library imports are grouped before the others.
+	sort.Slice(imports, func(i, j int) bool {
+		iStd, jStd := !strings.Contains(imports[i], "."), !strings.Contains(imports[j], ".")
+		if iStd != jStd {
+			return iStd
+		}
+		return imports[i] < imports[j]
+	})
+	for i, k := range imports {
+		if i != 0 && !strings.Contains(imports[i-1], ".") && strings.Contains(k, ".") {
+			b.WriteString("\n")
+		}
+		if k == "go.jasdel.dev/aws/lambda-mux" {
+			b.WriteString("\tlambdamux ")
+		}
+		fmt.Fprintf(&b, "\t%q\n", k)
+	}
+	b.WriteString(")\n\n")
+	b.Write(g.buf.Bytes())
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code, %w", err)
+	}
+	return src, nil
+}
+
+type generator struct {
+	doc   *Document
+	buf   bytes.Buffer
+	types map[string]bool
+
+	// pending are the inline object schemas, by type name, to generate.
+	pending []namedSchema
+	imports map[string]bool
+}
+
+type namedSchema struct {
+	name   string
+	schema *Schema
+}
+
+type operation struct {
+	name, method, path string
+	op                 *Operation
+	params             []*Parameter
+
+	bodyType   string
+	bodyReq    bool
+	outType    string
+	statusCode int
+}
+
+// generate writes the declarations of the generated code.
+func (g *generator) generate() error {
+	names := make([]string, 0, len(g.doc.Components.Schemas))
+	for k := range g.doc.Components.Schemas {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		g.types[goName(k)] = true
+	}
+	for _, k := range names {
+		if err := g.schemaType(goName(k), g.doc.Components.Schemas[k]); err != nil {
+			return fmt.Errorf("schema %s, %w", k, err)
+		}
+	}
+
+	ops, err := g.operations()
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if err := g.inputType(op); err != nil {
+			return fmt.Errorf("operation %s, %w", op.name, err)
+		}
+	}
+	if err := g.flushPending(); err != nil {
+		return err
+	}
+
+	g.server(ops)
+	g.handler(ops)
+	return nil
+}
+
+// operations returns the document's operations sorted by path, and method.
+func (g *generator) operations() ([]operation, error) {
+	paths := make([]string, 0, len(g.doc.Paths))
+	for k := range g.doc.Paths {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+
+	var ops []operation
+	seen := map[string]bool{}
+	for _, path := range paths {
+		item := g.doc.Paths[path]
+		byMethod := item.operations()
+		methods := make([]string, 0, len(byMethod))
+		for k := range byMethod {
+			methods = append(methods, k)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			op := operation{method: method, path: path, op: byMethod[method]}
+			op.name = goName(op.op.OperationID)
+			if len(op.name) == 0 {
+				op.name = goName(strings.ToLower(method) + " " + path)
+			}
+			if seen[op.name] {
+				return nil, fmt.Errorf("duplicate operation %s of %s %s", op.name, method, path)
+			}
+			seen[op.name] = true
+
+			params, err := g.parameters(item.Parameters, op.op.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("operation %s, %w", op.name, err)
+			}
+			op.params = params
+
+			if rb := op.op.RequestBody; rb != nil {
+				if mt := jsonMediaType(rb.Content); mt != nil && mt.Schema != nil {
+					op.bodyReq = rb.Required
+					op.bodyType, err = g.goType(op.name+"Body", mt.Schema, true)
+					if err != nil {
+						return nil, fmt.Errorf("operation %s request body, %w", op.name, err)
+					}
+				}
+			}
+
+			op.statusCode, op.outType, err = g.response(op)
+			if err != nil {
+				return nil, fmt.Errorf("operation %s response, %w", op.name, err)
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// parameters returns the operation's parameters, overriding the path's
+// parameters of the same name, and location.
+func (g *generator) parameters(pathParams, opParams []*Parameter) ([]*Parameter, error) {
+	var params []*Parameter
+	index := map[string]int{}
+	for _, list := range [][]*Parameter{pathParams, opParams} {
+		for _, p := range list {
+			p, err := g.doc.parameter(p)
+			if err != nil {
+				return nil, err
+			}
+			switch p.In {
+			case "path", "query", "header":
+			default:
+				return nil, fmt.Errorf("unsupported %s parameter %s", p.In, p.Name)
+			}
+
+			key := p.In + ":" + p.Name
+			if i, ok := index[key]; ok {
+				params[i] = p
+				continue
+			}
+			index[key] = len(params)
+			params = append(params, p)
+		}
+	}
+	return params, nil
+}
+
+// response returns the status code, and Go type, of the operation's first
+// 2xx response. The type is empty if the response has no JSON content.
+func (g *generator) response(op operation) (int, string, error) {
+	codes := make([]string, 0, len(op.op.Responses))
+	for k := range op.op.Responses {
+		if len(k) == 3 && k[0] == '2' {
+			codes = append(codes, k)
+		}
+	}
+	sort.Strings(codes)
+	if len(codes) == 0 {
+		return http.StatusOK, "", nil
+	}
+
+	code, err := strconv.Atoi(codes[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid status code %s", codes[0])
+	}
+	mt := jsonMediaType(op.op.Responses[codes[0]].Content)
+	if mt == nil || mt.Schema == nil || code == http.StatusNoContent {
+		return code, "", nil
+	}
+
+	typ, err := g.goType(op.name+"Output", mt.Schema, true)
+	return code, typ, err
+}
+
+func jsonMediaType(content map[string]*MediaType) *MediaType {
+	if mt, ok := content["application/json"]; ok {
+		return mt
+	}
+	for k, mt := range content {
+		if strings.HasSuffix(k, "+json") {
+			return mt
+		}
+	}
+	return nil
+}
+
+// schemaType writes the named type of the schema.
+func (g *generator) schemaType(name string, s *Schema) error {
+	if d := strings.TrimSpace(s.Description); len(d) != 0 && !strings.HasPrefix(d, name+" ") {
+		g.comment(name, "is "+lowerFirst(d))
+	} else {
+		g.comment("", d)
+	}
+
+	if len(s.Properties) == 0 {
+		typ, err := g.goType(name+"Value", s, true)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.buf, "type %s %s\n\n", name, typ)
+		g.enumConsts(name, s)
+		return nil
+	}
+
+	required := map[string]bool{}
+	for _, k := range s.Required {
+		required[k] = true
+	}
+	props := make([]string, 0, len(s.Properties))
+	for k := range s.Properties {
+		props = append(props, k)
+	}
+	sort.Strings(props)
+
+	fmt.Fprintf(&g.buf, "type %s struct {\n", name)
+	for _, k := range props {
+		p := s.Properties[k]
+		field := goName(k)
+		typ, err := g.goType(name+field, p, required[k] && !p.Nullable)
+		if err != nil {
+			return fmt.Errorf("property %s, %w", k, err)
+		}
+
+		jsonTag := k
+		if !required[k] {
+			jsonTag += ",omitempty"
+		}
+		if len(p.Description) != 0 {
+			g.comment("", p.Description)
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s `json:%s%s`\n", field, typ, strconv.Quote(jsonTag), validateTags(p, required[k]))
+	}
+	g.buf.WriteString("}\n\n")
+	return nil
+}
+
+// enumConsts writes the constants of the enum values of the string type.
+func (g *generator) enumConsts(name string, s *Schema) {
+	if s.Type != "string" || len(s.Enum) == 0 {
+		return
+	}
+	g.buf.WriteString("const (\n")
+	for _, v := range s.Enum {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&g.buf, "\t%s%s %s = %q\n", name, goName(str), name, str)
+	}
+	g.buf.WriteString(")\n\n")
+}
+
+// goType returns the Go type of the schema, queueing inline object schemas
+// to be generated as the named type. Optional object types are pointers.
+func (g *generator) goType(name string, s *Schema, required bool) (string, error) {
+	if len(s.Ref) != 0 {
+		if !strings.HasPrefix(s.Ref, schemaRefPrefix) {
+			return "", fmt.Errorf("unsupported schema reference %s", s.Ref)
+		}
+		ref := strings.TrimPrefix(s.Ref, schemaRefPrefix)
+		rs, ok := g.doc.Components.Schemas[ref]
+		if !ok {
+			return "", fmt.Errorf("schema reference %s not found", s.Ref)
+		}
+		typ := goName(ref)
+		if !required && (rs.Type == "object" || len(rs.Properties) != 0) {
+			typ = "*" + typ
+		}
+		return typ, nil
+	}
+
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			if !required {
+				return "*time.Time", nil
+			}
+			return "time.Time", nil
+		case "byte", "binary":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Format == "int32" {
+			return "int32", nil
+		}
+		return "int64", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}", nil
+		}
+		item, err := g.goType(name+"Item", s.Items, true)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + item, nil
+	case "object", "":
+		if len(s.Properties) != 0 {
+			g.queue(name, s)
+			if !required {
+				return "*" + name, nil
+			}
+			return name, nil
+		}
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(name+"Value", s.AdditionalProperties, true)
+			if err != nil {
+				return "", err
+			}
+			return "map[string]" + value, nil
+		}
+		if s.Type == "object" {
+			return "map[string]interface{}", nil
+		}
+		return "interface{}", nil
+	default:
+		return "", fmt.Errorf("unsupported schema type %s", s.Type)
+	}
+}
+
+func (g *generator) queue(name string, s *Schema) {
+	if g.types[name] {
+		return
+	}
+	g.types[name] = true
+	g.pending = append(g.pending, namedSchema{name: name, schema: s})
+}
+
+func (g *generator) flushPending() error {
+	for len(g.pending) != 0 {
+		s := g.pending[0]
+		g.pending = g.pending[1:]
+		if err := g.schemaType(s.name, s.schema); err != nil {
+			return fmt.Errorf("schema %s, %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// inputType writes the input struct of the operation. Parameter fields are
+// tagged with their location's tag, and "-" for the other locations, so
+// binding one location does not set fields of another by name.
+func (g *generator) inputType(op operation) error {
+	fmt.Fprintf(&g.buf, "// %sInput is the input of the %s %s operation.\n", op.name, op.method, op.path)
+	fmt.Fprintf(&g.buf, "type %sInput struct {\n", op.name)
+	for _, p := range op.params {
+		schema := p.Schema
+		if schema == nil {
+			schema = &Schema{Type: "string"}
+		}
+		required := p.Required || p.In == "path"
+		typ, err := g.goType(op.name+goName(p.Name), schema, true)
+		if err != nil {
+			return fmt.Errorf("parameter %s, %w", p.Name, err)
+		}
+
+		var tags []string
+		for _, in := range []string{"path", "query", "header"} {
+			name := "-"
+			if in == p.In {
+				name = p.Name
+			}
+			tags = append(tags, in+":"+strconv.Quote(name))
+		}
+		tags = append(tags, `json:"-"`)
+
+		if len(p.Description) != 0 {
+			g.comment("", p.Description)
+		}
+		fmt.Fprintf(&g.buf, "\t%s %s `%s%s`\n", goName(p.Name), typ, strings.Join(tags, " "), validateTags(schema, required))
+	}
+	if len(op.bodyType) != 0 {
+		if len(op.params) != 0 {
+			g.buf.WriteString("\n")
+		}
+		g.buf.WriteString("\t// Body is the operation's JSON request body.\n")
+		fmt.Fprintf(&g.buf, "\tBody %s `path:\"-\" query:\"-\" header:\"-\" json:\"-\" validate:\"-\"`\n", op.bodyType)
+	}
+	g.buf.WriteString("}\n\n")
+	return g.flushPending()
+}
+
+// server writes the Server interface of the operations.
+func (g *generator) server(ops []operation) {
+	g.buf.WriteString("// Server is the interface of the API's operations, served by the\n")
+	g.buf.WriteString("// resource handler returned by NewHandler.\n")
+	g.buf.WriteString("type Server interface {\n")
+	for i, op := range ops {
+		if i != 0 {
+			g.buf.WriteString("\n")
+		}
+		summary := op.op.Summary
+		if len(summary) == 0 {
+			summary = "serves the " + op.method + " " + op.path + " operation."
+		}
+		g.comment(op.name, summary)
+		if len(op.outType) != 0 {
+			fmt.Fprintf(&g.buf, "\t%s(ctx context.Context, in %sInput) (%s, error)\n", op.name, op.name, op.outType)
+		} else {
+			fmt.Fprintf(&g.buf, "\t%s(ctx context.Context, in %sInput) error\n", op.name, op.name)
+		}
+	}
+	g.buf.WriteString("}\n\n")
+}
+
+// handler writes the NewHandler function routing the operations to the
+// Server's methods.
+func (g *generator) handler(ops []operation) {
+	g.buf.WriteString("// NewHandler returns the resource handler routing the API's operations to\n")
+	g.buf.WriteString("// the server's methods, by the request's path, and method.\n")
+	g.buf.WriteString("func NewHandler(s Server) lambdamux.ResourceHandler {\n")
+	g.buf.WriteString("\tpaths := lambdamux.NewServePath()\n")
+
+	// Each path's operations are handled in a block of the path's
+	// ServeMethod.
+	for i, op := range ops {
+		if i == 0 || ops[i-1].path != op.path {
+			g.buf.WriteString("\n\t{\n\t\tmethods := lambdamux.NewServeMethod()\n")
+		}
+		g.operationHandler(op)
+		if i == len(ops)-1 || ops[i+1].path != op.path {
+			fmt.Fprintf(&g.buf, "\t\tpaths.Handle(%q, methods)\n\t}\n", op.path)
+		}
+	}
+	g.buf.WriteString("\n\treturn paths\n}\n")
+}
+
+func (g *generator) operationHandler(op operation) {
+	fmt.Fprintf(&g.buf, "\tmethods.Handle(%q, lambdamux.ResourceHandlerFunc(func(\n", op.method)
+	g.buf.WriteString("\t\tctx context.Context, req lambdamux.APIGatewayProxyRequest,\n")
+	g.buf.WriteString("\t) (lambdamux.APIGatewayProxyResponse, error) {\n")
+	fmt.Fprintf(&g.buf, "\t\tvar in %sInput\n", op.name)
+	switch {
+	case len(op.bodyType) == 0:
+		g.buf.WriteString("\t\tif err := openapi.BindInput(req, &in, nil, false); err != nil {\n")
+	default:
+		fmt.Fprintf(&g.buf, "\t\tif err := openapi.BindInput(req, &in, &in.Body, %t); err != nil {\n", op.bodyReq)
+	}
+	g.buf.WriteString("\t\t\treturn lambdamux.APIGatewayProxyResponse{}, err\n\t\t}\n\n")
+
+	if len(op.outType) != 0 {
+		fmt.Fprintf(&g.buf, "\t\tout, err := s.%s(ctx, in)\n", op.name)
+		g.buf.WriteString("\t\tif err != nil {\n\t\t\treturn lambdamux.APIGatewayProxyResponse{}, err\n\t\t}\n")
+		fmt.Fprintf(&g.buf, "\t\treturn lambdamux.JSON(%d, out)\n", op.statusCode)
+	} else {
+		fmt.Fprintf(&g.buf, "\t\tif err := s.%s(ctx, in); err != nil {\n", op.name)
+		g.buf.WriteString("\t\t\treturn lambdamux.APIGatewayProxyResponse{}, err\n\t\t}\n")
+		if op.statusCode == http.StatusNoContent {
+			g.buf.WriteString("\t\treturn lambdamux.NoContent(), nil\n")
+		} else {
+			fmt.Fprintf(&g.buf, "\t\treturn lambdamux.Text(%d, \"\"), nil\n", op.statusCode)
+		}
+	}
+	if len(op.outType) != 0 {
+		// The operation's response type documents the route in its
+		// RouteTable entry.
+		fmt.Fprintf(&g.buf, "\t}), lambdamux.WithResponseType[%s]())\n", op.outType)
+	} else {
+		g.buf.WriteString("\t}))\n")
+	}
+}
+
+// validateTags returns the " validate" and " pattern" struct tags of the
+// schema's constraints, or empty if it has none.
+func validateTags(s *Schema, required bool) string {
+	var rules []string
+	if required {
+		rules = append(rules, "required")
+	}
+
+	formatNum := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	switch {
+	case s.Minimum != nil || s.Maximum != nil:
+		if s.Minimum != nil {
+			rules = append(rules, "min="+formatNum(*s.Minimum))
+		}
+		if s.Maximum != nil {
+			rules = append(rules, "max="+formatNum(*s.Maximum))
+		}
+	case s.MinLength != nil || s.MaxLength != nil:
+		if s.MinLength != nil {
+			rules = append(rules, "min="+strconv.Itoa(*s.MinLength))
+		}
+		if s.MaxLength != nil {
+			rules = append(rules, "max="+strconv.Itoa(*s.MaxLength))
+		}
+	case s.MinItems != nil || s.MaxItems != nil:
+		if s.MinItems != nil {
+			rules = append(rules, "min="+strconv.Itoa(*s.MinItems))
+		}
+		if s.MaxItems != nil {
+			rules = append(rules, "max="+strconv.Itoa(*s.MaxItems))
+		}
+	}
+
+	if len(s.Enum) != 0 {
+		values := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		rules = append(rules, "enum="+strings.Join(values, "|"))
+	}
+
+	var tags string
+	if len(rules) != 0 {
+		tags += " validate:" + strconv.Quote(strings.Join(rules, ","))
+	}
+	if len(s.Pattern) != 0 {
+		tags += " pattern:" + strconv.Quote(s.Pattern)
+	}
+	return tags
+}
+
+// comment writes the description as a doc comment, prefixed with the name
+// if not empty.
+func (g *generator) comment(name, description string) {
+	description = strings.TrimSpace(description)
+	if len(description) == 0 {
+		return
+	}
+	if len(name) != 0 {
+		description = name + " " + lowerFirst(description)
+	}
+	for _, line := range strings.Split(description, "\n") {
+		g.buf.WriteString("// " + strings.TrimRight(line, " ") + "\n")
+	}
+}
+
+// lowerFirst returns s with its first letter in lower case, unless s starts
+// with an initialism, e.g. "URL".
+func lowerFirst(s string) string {
+	r := []rune(s)
+	if len(r) == 0 || (len(r) > 1 && unicode.IsUpper(r[1])) {
+		return s
+	}
+	return string(unicode.ToLower(r[0])) + string(r[1:])
+}
+
+// initialisms are the words goName writes in upper case.
+var initialisms = map[string]bool{
+	"API": true, "ARN": true, "HTML": true, "HTTP": true, "HTTPS": true,
+	"ID": true, "IP": true, "JSON": true, "SQL": true, "TTL": true,
+	"URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// goName returns the exported Go name of s, the CamelCase concatenation of
+// its words, e.g. "OrderID" for "order_id", or "orderId".
+func goName(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) != 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) != 0 &&
+			(unicode.IsLower(word[len(word)-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			flush()
+		}
+		word = append(word, r)
+	}
+	flush()
+
+	var b strings.Builder
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+	}
+
+	name := b.String()
+	if len(name) != 0 && unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
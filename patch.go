@@ -0,0 +1,516 @@
+package lambdamux
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Content types of PATCH request bodies supported by ApplyPatch.
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+// ErrUnsupportedPatchType is returned by ApplyPatch for PATCH requests whose
+// body is not a JSON Merge Patch, or JSON Patch, document.
+var ErrUnsupportedPatchType = errors.New("unsupported patch document content type")
+
+// PatchError is returned by the patch helpers for patch documents that are
+// invalid, or cannot be applied to the resource, e.g. removing a member that
+// does not exist, or a failing JSON Patch test operation.
+type PatchError struct {
+	// Index of the JSON Patch operation, or -1 for the patch document.
+	Index int
+
+	// Op, and Path, of the JSON Patch operation, if any.
+	Op   string
+	Path string
+
+	Err error
+}
+
+func (e *PatchError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("invalid patch, %v", e.Err)
+	}
+	return fmt.Sprintf("invalid patch operation %d, %s %q, %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying cause of the error.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// PatchErrorMapper is an ErrorMapper translating the errors of ApplyPatch
+// into responses. PatchError is mapped to 422 Unprocessable Entity, and
+// ErrUnsupportedPatchType to 415 Unsupported Media Type, with an Accept-Patch
+// header of the supported types.
+type PatchErrorMapper struct{}
+
+// MapError implements the ErrorMapper interface.
+func (PatchErrorMapper) MapError(err error) (ErrorMapping, bool) {
+	var patchErr *PatchError
+	switch {
+	case errors.As(err, &patchErr):
+		return ErrorMapping{
+			StatusCode: http.StatusUnprocessableEntity,
+			Code:       "INVALID_PATCH",
+			Message:    patchErr.Error(),
+		}, true
+	case errors.Is(err, ErrUnsupportedPatchType):
+		return ErrorMapping{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Code:       "UNSUPPORTED_PATCH_TYPE",
+			Header: http.Header{
+				"Accept-Patch": []string{MergePatchContentType + ", " + JSONPatchContentType},
+			},
+		}, true
+	}
+	return ErrorMapping{}, false
+}
+
+// ApplyPatch applies the PATCH request's body to the resource v, a pointer
+// to the resource's current value, e.g. a struct loaded from the database.
+// The patch format is selected by the request's Content-Type, a JSON Merge
+// Patch (RFC 7386), or a JSON Patch (RFC 6902).
+//
+// The patch is applied to the JSON encoding of v, and the result decoded
+// into a new value replacing v, so members removed by the patch are reset to
+// their zero value. v is not modified if the patch fails. Returns a
+// PatchError if the patch is invalid, or ErrUnsupportedPatchType if the body
+// is not a supported patch document.
+func ApplyPatch(req APIGatewayProxyRequest, v interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(req.HTTPHeader.Get("Content-Type"))
+
+	var apply func(doc, patch []byte) ([]byte, error)
+	switch mediaType {
+	case MergePatchContentType:
+		apply = MergePatch
+	case JSONPatchContentType:
+		apply = JSONPatch
+	default:
+		return ErrUnsupportedPatchType
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("patch target must be a non-nil pointer, %T", v)
+	}
+
+	patch, err := requestBody(req)
+	if err != nil {
+		return &PatchError{Index: -1, Err: err}
+	}
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal patch target, %w", err)
+	}
+
+	patched, err := apply(doc, patch)
+	if err != nil {
+		return err
+	}
+
+	result := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(patched, result.Interface()); err != nil {
+		return &PatchError{Index: -1, Err: err}
+	}
+	rv.Elem().Set(result.Elem())
+	return nil
+}
+
+// MergePatch returns the JSON document with the JSON Merge Patch, RFC 7386,
+// applied. Returns a PatchError if either document is not valid JSON.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodePatchJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch target, %w", err)
+	}
+	p, err := decodePatchJSON(patch)
+	if err != nil {
+		return nil, &PatchError{Index: -1, Err: err}
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// JSONPatch returns the JSON document with the JSON Patch, RFC 6902,
+// applied. The patch's operations are validated, and applied in order, with
+// the patch failing as a whole if any operation fails. Returns a PatchError
+// for the first operation that is invalid, or fails.
+func JSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodePatchJSON(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid patch target, %w", err)
+	}
+
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, &PatchError{Index: -1, Err: fmt.Errorf("expect array of operations, %w", err)}
+	}
+
+	for i, raw := range ops {
+		op, err := parseJSONPatchOp(raw)
+		if err == nil {
+			target, err = op.apply(target)
+		}
+		if err != nil {
+			return nil, &PatchError{Index: i, Op: op.op, Path: op.path, Err: err}
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+type jsonPatchOp struct {
+	op         string
+	path, from string
+	value      interface{}
+}
+
+func parseJSONPatchOp(raw map[string]json.RawMessage) (jsonPatchOp, error) {
+	var op jsonPatchOp
+
+	member := func(name string, v *string) error {
+		b, ok := raw[name]
+		if !ok {
+			return fmt.Errorf("missing %q member", name)
+		}
+		if err := json.Unmarshal(b, v); err != nil {
+			return fmt.Errorf("invalid %q member, %w", name, err)
+		}
+		return nil
+	}
+
+	if err := member("op", &op.op); err != nil {
+		return op, err
+	}
+	if err := member("path", &op.path); err != nil {
+		return op, err
+	}
+
+	switch op.op {
+	case "add", "replace", "test":
+		b, ok := raw["value"]
+		if !ok {
+			return op, fmt.Errorf("missing \"value\" member")
+		}
+		v, err := decodePatchJSON(b)
+		if err != nil {
+			return op, fmt.Errorf("invalid \"value\" member, %w", err)
+		}
+		op.value = v
+	case "move", "copy":
+		if err := member("from", &op.from); err != nil {
+			return op, err
+		}
+	case "remove":
+	default:
+		return op, fmt.Errorf("unknown operation")
+	}
+	return op, nil
+}
+
+func (op jsonPatchOp) apply(doc interface{}) (interface{}, error) {
+	path, err := parseJSONPointer(op.path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.op {
+	case "add":
+		return patchAdd(doc, path, op.value)
+	case "remove":
+		doc, _, err = patchRemove(doc, path)
+		return doc, err
+	case "replace":
+		if len(path) == 0 {
+			return op.value, nil
+		}
+		doc, _, err = patchRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, op.value)
+	case "test":
+		v, err := patchGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, op.value) {
+			return nil, fmt.Errorf("test failed, value not equal")
+		}
+		return doc, nil
+	}
+
+	from, err := parseJSONPointer(op.from)
+	if err != nil {
+		return nil, err
+	}
+	switch op.op {
+	case "move":
+		if strings.HasPrefix(op.path, op.from+"/") {
+			return nil, fmt.Errorf("cannot move %q into its own child", op.from)
+		}
+		doc, v, err := patchRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, v)
+	default: // copy
+		v, err := patchGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, copyPatchJSON(v))
+	}
+}
+
+// parseJSONPointer returns the reference tokens of the JSON Pointer, RFC
+// 6901, e.g. "/a~1b/0" is ["a/b", "0"], and nil for the whole document.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if len(pointer) == 0 {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		t = strings.Replace(t, "~1", "/", -1)
+		tokens[i] = strings.Replace(t, "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func patchGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch n := doc.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			doc = v
+		case []interface{}:
+			i, err := patchIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = n[i]
+		default:
+			return nil, fmt.Errorf("cannot reference %q of a JSON value", token)
+		}
+	}
+	return doc, nil
+}
+
+// patchUpdate returns the document with fn applied to the container of the
+// path's last token, and the token, replacing the container with the one fn
+// returns.
+func patchUpdate(
+	doc interface{}, path []string,
+	fn func(container interface{}, token string) (interface{}, error),
+) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch n := doc.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q not found", path[0])
+		}
+		v, err := patchUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = v
+		return n, nil
+	case []interface{}:
+		i, err := patchIndex(path[0], len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := patchUpdate(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = v
+		return n, nil
+	default:
+		return nil, fmt.Errorf("cannot reference %q of a JSON value", path[0])
+	}
+}
+
+func patchAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return patchUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch n := container.(type) {
+		case map[string]interface{}:
+			n[token] = value
+			return n, nil
+		case []interface{}:
+			if token == "-" {
+				return append(n, value), nil
+			}
+			i, err := patchIndex(token, len(n))
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+			return n, nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a JSON value", token)
+		}
+	})
+}
+
+func patchRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+
+	var removed interface{}
+	doc, err := patchUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch n := container.(type) {
+		case map[string]interface{}:
+			v, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			removed = v
+			delete(n, token)
+			return n, nil
+		case []interface{}:
+			i, err := patchIndex(token, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = n[i]
+			return append(n[:i], n[i+1:]...), nil
+		default:
+			return nil, fmt.Errorf("cannot remove %q of a JSON value", token)
+		}
+	})
+	return doc, removed, err
+}
+
+// patchIndex returns the array index of the token, if it is no greater than
+// max.
+func patchIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+func decodePatchJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func copyPatchJSON(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			c[k] = copyPatchJSON(v)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(n))
+		for i, v := range n {
+			c[i] = copyPatchJSON(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual returns if the decoded JSON values are equal, comparing numbers
+// by value, e.g. 1 equals 1.0.
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errX := x.Float64()
+		fy, errY := y.Float64()
+		return errX == nil && errY == nil && fx == fy
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			if w, ok := y[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,276 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"sort"
+)
+
+// ServePath is an API Gateway Proxy resource handler delegating requests to
+// resource handlers by matching the request's concrete path against path
+// templates, e.g. "/users/{id:int}/orders/{orderId}". Allows a single
+// greedy API Gateway resource, e.g. "/{proxy+}", to front many logical
+// routes.
+//
+// Templates use the same syntax as ServeResource resource patterns,
+// including typed, and greedy catch-all, parameters, e.g. "/files/{path+}".
+// The "*" segment is a wildcard matching any single segment without
+// capturing it, e.g. "/assets/*/logo.png".
+//
+// Templates are kept in a trie of their path segments, so matching does not
+// slow as templates are added. Precedence is deterministic, comparing path
+// segments from the start of the path: literal segments precede
+// parameters, parameters precede wildcards, and wildcards precede
+// catch-alls. Matching backtracks, so a path not matching a more specific
+// template's remaining segments, or parameter types, is matched against
+// less specific templates. Otherwise templates with more typed parameters
+// precede those with fewer, and templates are matched in the order they were
+// added.
+//
+// The matched template's parameter values are percent-decoded, and set as
+// the request's PathParameters, and typed PathValues. The request's
+// Resource is set to the matched template's resource, e.g. "/users/{id}",
+// so decorators keyed by resource see the logical route.
+type ServePath struct {
+	routes     []pathRoute
+	root       *pathNode
+	middleware []Middleware
+	errs       registrationErrors
+
+	// DuplicatePolicy is the policy for templates added that already have a
+	// handler. Defaults to DuplicatePanic.
+	DuplicatePolicy DuplicatePolicy
+
+	// NotFoundHandler serves requests whose path matches no template.
+	// Defaults to a 404 Not Found response.
+	NotFoundHandler ResourceHandler
+}
+
+type pathRoute struct {
+	template string
+	pattern  routePattern
+	options  routeOptions
+	handler  ResourceHandler
+
+	// serve is the handler wrapped by the router's middleware.
+	serve ResourceHandler
+}
+
+// NewServePath initializes and returns a ServePath that path templates can
+// be added to via the Handle method.
+func NewServePath() *ServePath {
+	return &ServePath{root: &pathNode{}}
+}
+
+// ServeResource implements the ResourceHandler interface, delegating
+// requests to the ResourceHandler of the first template the request's path
+// matches. If no template matches the request is served by the
+// NotFoundHandler.
+func (s *ServePath) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	// Parameters are decoded before their types are checked, so types match
+	// the decoded values. Values failing their type are not a match of the
+	// route, so the path's other routes are matched.
+	var route pathRoute
+	var params map[string]string
+	var values map[string]interface{}
+	var decodeErr error
+	matched := s.root.match(splitPath(req.Path), 0, func(r pathRoute) bool {
+		raw, ok := r.pattern.capturePath(req.Path)
+		if !ok {
+			return false
+		}
+		decoded, err := decodePathParams(raw, r.options.encodedSlash)
+		if err != nil {
+			route, decodeErr = r, err
+			return true
+		}
+		converted, ok := r.pattern.convert(decoded)
+		if !ok {
+			return false
+		}
+		route, params, values = r, decoded, converted
+		return true
+	})
+	if !matched {
+		return serveNotFound(ctx, s.NotFoundHandler, req)
+	}
+	if decodeErr != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	req.PathParameters = params
+	if len(values) != 0 {
+		req.PathValues = values
+	}
+
+	req.Resource = route.pattern.resource
+	req.RequestContext.ResourcePath = route.pattern.resource
+
+	ctx = withRouteTypes(ctx, route.options.types)
+	return route.serve.ServeResource(ctx, req)
+}
+
+// Handle adds a new resource handler for the path template, configured with
+// the route options. Panics if the template is invalid, or its regular
+// expression parameter types fail to compile. Templates matching the same
+// paths as a template that already has a handler, e.g. "/users/{id}", and
+// "/users/{userId}", are handled according to the DuplicatePolicy.
+func (s *ServePath) Handle(template string, handler ResourceHandler, opts ...RouteOption) *ServePath {
+	pattern, err := parseRoutePattern(template)
+	if err != nil {
+		panic(err)
+	}
+
+	options := newRouteOptions(opts)
+	handler = options.wrap(handler)
+	route := pathRoute{
+		template: template,
+		pattern:  pattern,
+		options:  options,
+		handler:  handler,
+		serve:    options.chain(s.middleware, handler),
+	}
+
+	for i, r := range s.routes {
+		if r.pattern.shape() == pattern.shape() {
+			if s.DuplicatePolicy.duplicate(&s.errs, "ServePath", template) {
+				s.routes[i] = route
+				s.rebuild()
+			}
+			return s
+		}
+	}
+
+	s.routes = append(s.routes, route)
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		return s.routes[i].precedes(s.routes[j])
+	})
+	s.root.insert(route)
+
+	return s
+}
+
+// Use adds middleware applied to the handlers of all templates, including
+// templates added before Use is called. Middleware are applied in the order
+// they are added, with the first middleware the outermost, and see requests
+// after the template's path parameters are decoded.
+func (s *ServePath) Use(middleware ...Middleware) *ServePath {
+	s.middleware = append(s.middleware, middleware...)
+	for i, r := range s.routes {
+		s.routes[i].serve = r.options.chain(s.middleware, r.handler)
+	}
+	s.rebuild()
+	return s
+}
+
+// rebuild rebuilds the trie from the routes.
+func (s *ServePath) rebuild() {
+	s.root = &pathNode{}
+	for _, r := range s.routes {
+		s.root.insert(r)
+	}
+}
+
+// Err returns the errors of duplicate templates added with the
+// DuplicateError policy, or nil if there were none.
+func (s *ServePath) Err() error {
+	return s.errs.err()
+}
+
+func (r pathRoute) precedes(o pathRoute) bool {
+	if moreSpecific(r.pattern.segments, o.pattern.segments) {
+		return true
+	}
+	if moreSpecific(o.pattern.segments, r.pattern.segments) {
+		return false
+	}
+	return len(r.pattern.params) > len(o.pattern.params)
+}
+
+// pathNode is a node of the ServePath trie, for a path segment of the
+// templates added. Templates are added to the node of their last segment, or
+// of their catch-all parameter's segment.
+type pathNode struct {
+	static   map[string]*pathNode
+	param    *pathNode
+	wildcard *pathNode
+
+	// routes ending at the node, and routes with a catch-all parameter
+	// starting at the node's child segment, in order of precedence.
+	routes   []pathRoute
+	catchAll []pathRoute
+}
+
+func (n *pathNode) insert(r pathRoute) {
+	node := n
+	for _, seg := range r.pattern.segments {
+		switch {
+		case seg.greedy:
+			node.catchAll = insertPathRoute(node.catchAll, r)
+			return
+		case seg.wildcard:
+			if node.wildcard == nil {
+				node.wildcard = &pathNode{}
+			}
+			node = node.wildcard
+		case len(seg.param) != 0:
+			if node.param == nil {
+				node.param = &pathNode{}
+			}
+			node = node.param
+		default:
+			if node.static == nil {
+				node.static = map[string]*pathNode{}
+			}
+			child, ok := node.static[seg.literal]
+			if !ok {
+				child = &pathNode{}
+				node.static[seg.literal] = child
+			}
+			node = child
+		}
+	}
+	node.routes = insertPathRoute(node.routes, r)
+}
+
+func insertPathRoute(routes []pathRoute, r pathRoute) []pathRoute {
+	routes = append(routes, r)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].precedes(routes[j])
+	})
+	return routes
+}
+
+// match walks the trie for the path's segments from the i'th segment, in
+// order of precedence, calling fn for the routes of the nodes matching the
+// path until fn returns true.
+func (n *pathNode) match(parts []string, i int, fn func(pathRoute) bool) bool {
+	if i == len(parts) {
+		for _, r := range n.routes {
+			if fn(r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if child, ok := n.static[parts[i]]; ok && child.match(parts, i+1, fn) {
+		return true
+	}
+	if len(parts[i]) != 0 {
+		if n.param != nil && n.param.match(parts, i+1, fn) {
+			return true
+		}
+		if n.wildcard != nil && n.wildcard.match(parts, i+1, fn) {
+			return true
+		}
+	}
+	for _, r := range n.catchAll {
+		if fn(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// namedHandler responds with the handler's name, and the request's
+// resource, and path parameters.
+func namedHandler(name string) ResourceHandler {
+	return ResourceHandlerFunc(func(
+		ctx context.Context, req APIGatewayProxyRequest,
+	) (APIGatewayProxyResponse, error) {
+		return Text(http.StatusOK, fmt.Sprintf("%s %s %v", name, req.Resource, req.PathParameters)), nil
+	})
+}
+
+func TestServePath(t *testing.T) {
+	s := NewServePath().
+		Handle("/users/me", namedHandler("me")).
+		Handle("/users/{id:int}", namedHandler("user-int")).
+		Handle("/users/{name}", namedHandler("user")).
+		Handle("/users/{id}/orders", namedHandler("orders")).
+		Handle("/users/{id:int}/orders/{orderId:int}", namedHandler("order")).
+		Handle("/users/*/avatar", namedHandler("avatar")).
+		Handle("/users/{path+}", namedHandler("users-catch-all")).
+		Handle("/files/{path:*}", namedHandler("files")).
+		Handle("/reports/{date:\\d{4}-\\d{2}}", namedHandler("report")).
+		Handle("/", namedHandler("root"))
+
+	cases := map[string]struct {
+		path         string
+		expectStatus int
+		expectBody   string
+	}{
+		"root": {
+			path: "/", expectStatus: 200, expectBody: "root / map[]",
+		},
+		"literal precedes parameter": {
+			path: "/users/me", expectStatus: 200, expectBody: "me /users/me map[]",
+		},
+		"typed parameter precedes untyped": {
+			path: "/users/123", expectStatus: 200, expectBody: "user-int /users/{id} map[id:123]",
+		},
+		"type mismatch backtracks to untyped": {
+			path: "/users/abc", expectStatus: 200, expectBody: "user /users/{name} map[name:abc]",
+		},
+		"parameter precedes wildcard": {
+			path: "/users/1/orders", expectStatus: 200, expectBody: "orders /users/{id}/orders map[id:1]",
+		},
+		"remaining segments backtrack to wildcard": {
+			path: "/users/1/avatar", expectStatus: 200, expectBody: "avatar /users/*/avatar map[]",
+		},
+		"nested typed parameters": {
+			path: "/users/1/orders/2", expectStatus: 200, expectBody: "order /users/{id}/orders/{orderId} map[id:1 orderId:2]",
+		},
+		"nested type mismatch backtracks to catch-all": {
+			path: "/users/1/orders/abc", expectStatus: 200,
+			expectBody: "users-catch-all /users/{path+} map[path:1/orders/abc]",
+		},
+		"catch-all": {
+			path: "/users/1/a/b", expectStatus: 200, expectBody: "users-catch-all /users/{path+} map[path:1/a/b]",
+		},
+		"greedy type": {
+			path: "/files/a/b.txt", expectStatus: 200, expectBody: "files /files/{path+} map[path:a/b.txt]",
+		},
+		"decoded parameter": {
+			path: "/users/a%20b", expectStatus: 200, expectBody: "user /users/{name} map[name:a b]",
+		},
+		"decoded before type check": {
+			path: "/users/%31%32", expectStatus: 200, expectBody: "user-int /users/{id} map[id:12]",
+		},
+		"invalid escape": {
+			path: "/users/%zz", expectStatus: 400,
+		},
+		"regexp type": {
+			path: "/reports/2024-01", expectStatus: 200, expectBody: "report /reports/{date} map[date:2024-01]",
+		},
+		"regexp type mismatch": {
+			path: "/reports/2024", expectStatus: 404,
+		},
+		"empty segment": {
+			path: "/users//orders", expectStatus: 200, expectBody: "users-catch-all /users/{path+} map[path:/orders]",
+		},
+		"not found": {
+			path: "/other", expectStatus: 404,
+		},
+		"catch-all requires segment": {
+			path: "/files", expectStatus: 404,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var req APIGatewayProxyRequest
+			req.Path = c.path
+
+			resp, err := s.ServeResource(context.Background(), req)
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expectStatus, resp.StatusCode; e != a {
+				t.Fatalf("expect %v status, got %v, %s", e, a, resp.Body)
+			}
+			if c.expectStatus != 200 {
+				return
+			}
+			if e, a := c.expectBody, resp.Body; e != a {
+				t.Errorf("expect %q body, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestServePathPrecedenceOrder(t *testing.T) {
+	// Templates are matched by precedence regardless of the order they are
+	// added in.
+	templates := []string{"/a/{x+}", "/a/*", "/a/{x}", "/a/{x:int}", "/a/b"}
+	paths := map[string]string{
+		"/a/b":   "/a/b",
+		"/a/1":   "/a/{x:int}",
+		"/a/c":   "/a/{x}",
+		"/a/c/d": "/a/{x+}",
+	}
+
+	for i := range templates {
+		order := append(append([]string{}, templates[i:]...), templates[:i]...)
+		t.Run(fmt.Sprint(order), func(t *testing.T) {
+			s := NewServePath()
+			for _, tmpl := range order {
+				s.Handle(tmpl, namedHandler(tmpl))
+			}
+
+			for path, expect := range paths {
+				var req APIGatewayProxyRequest
+				req.Path = path
+				resp, err := s.ServeResource(context.Background(), req)
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+				var actual string
+				fmt.Sscan(resp.Body, &actual)
+				if e, a := expect, actual; e != a {
+					t.Errorf("%s: expect %q template, got %q", path, e, a)
+				}
+			}
+		})
+	}
+}
+
+func TestServePathDuplicates(t *testing.T) {
+	cases := map[string]struct {
+		templates       []string
+		expectDuplicate bool
+	}{
+		"same template": {
+			templates: []string{"/users/{id}", "/users/{id}"}, expectDuplicate: true,
+		},
+		"parameter renamed": {
+			templates: []string{"/users/{id}", "/users/{userId}"}, expectDuplicate: true,
+		},
+		"typed parameter renamed": {
+			templates: []string{"/users/{id:int}", "/users/{userId:int}"}, expectDuplicate: true,
+		},
+		"greedy parameter renamed": {
+			templates: []string{"/files/{path+}", "/files/{rest:*}"}, expectDuplicate: true,
+		},
+		"different types": {
+			templates: []string{"/users/{id:int}", "/users/{id}"},
+		},
+		"literal and parameter": {
+			templates: []string{"/users/me", "/users/{id}"},
+		},
+		"parameter and wildcard": {
+			templates: []string{"/users/{id}/a", "/users/*/a"},
+		},
+		"parameter and greedy": {
+			templates: []string{"/users/{id}", "/users/{id+}"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := NewServePath()
+			s.DuplicatePolicy = DuplicateError
+			for _, tmpl := range c.templates {
+				s.Handle(tmpl, namedHandler(tmpl))
+			}
+
+			err := s.Err()
+			if !c.expectDuplicate {
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+				return
+			}
+			var dupErr *DuplicateRouteError
+			if !errors.As(err, &dupErr) {
+				t.Fatalf("expect %T error, got %v", dupErr, err)
+			}
+			if e, a := c.templates[1], dupErr.Route; e != a {
+				t.Errorf("expect %q duplicate, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestServePathDuplicateReplace(t *testing.T) {
+	s := NewServePath()
+	s.DuplicatePolicy = DuplicateReplace
+	s.Handle("/users/{id}", namedHandler("first")).
+		Handle("/users/{userId}", namedHandler("second"))
+
+	var req APIGatewayProxyRequest
+	req.Path = "/users/1"
+	resp, err := s.ServeResource(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if e, a := "second /users/{userId} map[userId:1]", resp.Body; e != a {
+		t.Errorf("expect %q body, got %q", e, a)
+	}
+}
@@ -0,0 +1,81 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem details
+// documents.
+const ProblemContentType = "application/problem+json"
+
+// ProblemExtender is implemented by errors providing extension members of
+// their problem details document, e.g. the fields failing validation.
+type ProblemExtender interface {
+	ProblemExtensions() map[string]interface{}
+}
+
+// ProblemErrorEncoder encodes errors as RFC 7807 problem details documents:
+//
+//	{"type": "https://example.com/docs/errors/out-of-credit",
+//	 "title": "Forbidden", "status": 403,
+//	 "detail": "Your current balance is 30, but that costs 50.",
+//	 "instance": "/account/12345/msgs/abc",
+//	 "code": "OUT_OF_CREDIT", "requestId": "abc123"}
+//
+// The type is the error's documentation URL, or "about:blank" if it has
+// none, and the instance the request's path. The error's code, request ID,
+// and extension members, if any, are included as extension members.
+type ProblemErrorEncoder struct {
+	// Extensions optionally returns additional extension members of the
+	// error's document, e.g. the API's version.
+	Extensions func(ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo) map[string]interface{}
+}
+
+// EncodeError implements the ErrorEncoder interface.
+func (e ProblemErrorEncoder) EncodeError(
+	ctx context.Context, req APIGatewayProxyRequest, info ErrorInfo,
+) (APIGatewayProxyResponse, error) {
+	doc := map[string]interface{}{}
+
+	var extender ProblemExtender
+	if errors.As(info.Err, &extender) {
+		for k, v := range extender.ProblemExtensions() {
+			doc[k] = v
+		}
+	}
+	if e.Extensions != nil {
+		for k, v := range e.Extensions(ctx, req, info) {
+			doc[k] = v
+		}
+	}
+
+	if len(info.Code) != 0 {
+		doc["code"] = info.Code
+	}
+	if len(info.RequestID) != 0 {
+		doc["requestId"] = info.RequestID
+	}
+
+	problemType := info.DocsURL
+	if len(problemType) == 0 {
+		problemType = "about:blank"
+	}
+	doc["type"] = problemType
+	doc["title"] = http.StatusText(info.StatusCode)
+	doc["status"] = info.StatusCode
+	if len(info.Message) != 0 && info.Message != http.StatusText(info.StatusCode) {
+		doc["detail"] = info.Message
+	}
+	if len(req.Path) != 0 {
+		doc["instance"] = req.Path
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	return newResponse(info.StatusCode, ProblemContentType, string(body)), nil
+}
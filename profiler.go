@@ -0,0 +1,221 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/metrics"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// RouteCost is the cost of a route's requests served by a container,
+// attributed by a Profiler.
+type RouteCost struct {
+	// Route is the HTTP method and resource, e.g. "GET /users/{id}".
+	Route string
+
+	// Count of requests served.
+	Count int64
+
+	// Duration is the total time the requests were served for, the
+	// duration Lambda bills.
+	Duration time.Duration
+
+	// CPU is the total CPU time consumed serving the requests. Zero on
+	// platforms other than Linux.
+	CPU time.Duration
+
+	// AllocBytes is the total bytes allocated on the heap serving the
+	// requests.
+	AllocBytes uint64
+}
+
+// Profiler attributes the duration, CPU time, and heap allocations of
+// requests to their routes, across a container's lifetime, to identify the
+// routes driving the function's duration billing. The report of the routes'
+// costs is written with WriteReport, e.g. at shutdown with ReportOnShutdown.
+//
+// Costs are measured as the process's CPU time, and heap allocations,
+// during each request, as Lambda containers serve one request at a time.
+// Costs of handlers serving requests concurrently, e.g. invoked directly,
+// or by the LocalServer, overlap. Requests are also served with the route
+// as the "lambdamux_route" pprof label, so the samples of CPU profiles are
+// attributed to routes.
+type Profiler struct {
+	start time.Time
+
+	mu     sync.Mutex
+	routes map[string]*RouteCost
+}
+
+// NewProfiler returns an initialized Profiler.
+func NewProfiler() *Profiler {
+	return &Profiler{
+		start:  time.Now(),
+		routes: map[string]*RouteCost{},
+	}
+}
+
+type profilerHandler struct {
+	Profiler *Profiler
+	Handler  ResourceHandler
+}
+
+// ResourceHandlerWithProfiler provides a resource handler attributing the
+// costs of the requests served by handler to their routes with the
+// profiler. Routes are identified by the request's method, and resource, so
+// the middleware should be added to the router, e.g. with ServePath's Use,
+// to see the resource of the matched route.
+func ResourceHandlerWithProfiler(profiler *Profiler, handler ResourceHandler) ResourceHandler {
+	return profilerHandler{
+		Profiler: profiler,
+		Handler:  handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, attributing the costs of
+// the request to its route.
+func (h profilerHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	route := req.HTTPMethod + " " + req.Resource
+
+	startCPU, cpuOK := processCPUTime()
+	startAlloc := heapAllocBytes()
+	start := time.Now()
+
+	pprof.Do(ctx, pprof.Labels("lambdamux_route", route), func(ctx context.Context) {
+		resp, err = h.Handler.ServeResource(ctx, req)
+	})
+
+	cost := RouteCost{Route: route, Count: 1, Duration: time.Since(start)}
+	if endCPU, ok := processCPUTime(); ok && cpuOK {
+		cost.CPU = endCPU - startCPU
+	}
+	if endAlloc := heapAllocBytes(); endAlloc > startAlloc {
+		cost.AllocBytes = endAlloc - startAlloc
+	}
+	h.Profiler.add(cost)
+
+	return resp, err
+}
+
+// add adds the cost to its route's costs.
+func (p *Profiler) add(cost RouteCost) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, ok := p.routes[cost.Route]
+	if !ok {
+		c = &RouteCost{Route: cost.Route}
+		p.routes[cost.Route] = c
+	}
+	c.Count += cost.Count
+	c.Duration += cost.Duration
+	c.CPU += cost.CPU
+	c.AllocBytes += cost.AllocBytes
+}
+
+// Report returns the costs of the routes, most expensive by duration first.
+func (p *Profiler) Report() []RouteCost {
+	p.mu.Lock()
+	costs := make([]RouteCost, 0, len(p.routes))
+	for _, c := range p.routes {
+		costs = append(costs, *c)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].Duration != costs[j].Duration {
+			return costs[i].Duration > costs[j].Duration
+		}
+		return costs[i].Route < costs[j].Route
+	})
+	return costs
+}
+
+// WriteReport writes the report of the routes' costs, a line per route,
+// most expensive first, with the route's share of the total duration:
+//
+//	GET /users/{id} count=120 duration=1.2s avg=10ms cpu=800ms alloc=12582912 share=45.0%
+func (p *Profiler) WriteReport(w io.Writer) error {
+	costs := p.Report()
+
+	var total time.Duration
+	for _, c := range costs {
+		total += c.Duration
+	}
+
+	if _, err := fmt.Fprintf(w, "lambdamux route cost report, uptime %s, %d routes\n",
+		time.Since(p.start).Round(time.Millisecond), len(costs)); err != nil {
+		return err
+	}
+	for _, c := range costs {
+		var share float64
+		if total > 0 {
+			share = float64(c.Duration) / float64(total) * 100
+		}
+		if _, err := fmt.Fprintf(w, "%s count=%d duration=%s avg=%s cpu=%s alloc=%d share=%.1f%%\n",
+			c.Route, c.Count, c.Duration, c.Duration/time.Duration(c.Count), c.CPU,
+			c.AllocBytes, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReportOnShutdown writes the report to w, or the standard logger if nil,
+// when the process receives SIGTERM, which Lambda sends before shutting down
+// containers of functions with extensions registered, and then terminates
+// the process as the signal would have. Returns the function stopping the
+// hook.
+func (p *Profiler) ReportOnShutdown(w io.Writer) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-sigs:
+		case <-done:
+			return
+		}
+
+		if w != nil {
+			p.WriteReport(w)
+		} else {
+			p.WriteReport(log.Writer())
+		}
+
+		signal.Stop(sigs)
+		if proc, err := os.FindProcess(os.Getpid()); err == nil {
+			proc.Signal(syscall.SIGTERM)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+// heapAllocBytes returns the cumulative bytes allocated on the heap by the
+// process.
+func heapAllocBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
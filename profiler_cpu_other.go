@@ -0,0 +1,11 @@
+//go:build !linux
+
+package lambdamux
+
+import "time"
+
+// processCPUTime returns false, as the process's CPU time is only read on
+// Linux, the platform of Lambda functions.
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,149 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// ProfileEnvVar is the environment variable selecting the profile Start
+// serves the handler with. Defaults to "prod".
+const ProfileEnvVar = "LAMBDAMUX_PROFILE"
+
+// Profile is the middleware, and additional routes, of an environment the
+// application is deployed to.
+type Profile struct {
+	// Middleware applied to the application's handler, and the profile's
+	// routes. The first middleware is the outermost.
+	Middleware []Middleware
+
+	// Routes served by the profile in addition to the application's
+	// routes, by resource, e.g. debug routes. The profile's routes take
+	// precedence over the application's.
+	Routes map[string]ResourceHandler
+}
+
+// Apply returns the handler served with the profile's routes and
+// middleware.
+func (p Profile) Apply(handler ResourceHandler) ResourceHandler {
+	if len(p.Routes) != 0 {
+		handler = profileRoutes{routes: p.Routes, Handler: handler}
+	}
+	return Chain(p.Middleware...)(handler)
+}
+
+// Profiles are the profiles of an application by name, e.g. "dev",
+// "staging", and "prod".
+type Profiles map[string]Profile
+
+// DefaultProfiles returns the "dev", "staging", and "prod" profiles.
+//
+// The dev profile logs every request, and serves the "/_lambdamux/routes"
+// debug route, describing the application's route table and handler graph.
+// The staging profile sets the DefaultSecurityHeaders, and logs every
+// request. The prod profile sets the DefaultSecurityHeaders, and logs a 1%
+// sample of requests, and all failed requests.
+func DefaultProfiles(handler ResourceHandler) Profiles {
+	securityHeaders := func(h ResourceHandler) ResourceHandler {
+		return ResourceHandlerWithSecurityHeaders(DefaultSecurityHeaders, h)
+	}
+
+	return Profiles{
+		"dev": {
+			Middleware: []Middleware{requestLogMiddleware(1, true)},
+			Routes: map[string]ResourceHandler{
+				"/_lambdamux/routes": debugRoutesHandler(handler),
+			},
+		},
+		"staging": {
+			Middleware: []Middleware{requestLogMiddleware(1, false), securityHeaders},
+		},
+		"prod": {
+			Middleware: []Middleware{requestLogMiddleware(0.01, false), securityHeaders},
+		},
+	}
+}
+
+// Start starts the Lambda function serving API Gateway Proxy requests with
+// the handler, and the profile selected by the ProfileEnvVar environment
+// variable. Panics if the selected profile is not one of the profiles. Start
+// blocks, and does not return.
+func Start(handler ResourceHandler, profiles Profiles) {
+	name := os.Getenv(ProfileEnvVar)
+	if len(name) == 0 {
+		name = "prod"
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for k := range profiles {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		panic(fmt.Sprintf("unknown %s profile %q, expect one of %s",
+			ProfileEnvVar, name, strings.Join(names, ", ")))
+	}
+
+	lambda.StartHandler(APIGatewayProxy{Handler: profile.Apply(handler)})
+}
+
+type profileRoutes struct {
+	routes  map[string]ResourceHandler
+	Handler ResourceHandler
+}
+
+func (p profileRoutes) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if h, ok := p.routes[req.Resource]; ok {
+		return h.ServeResource(ctx, req)
+	}
+	return p.Handler.ServeResource(ctx, req)
+}
+
+// debugRoutesHandler returns a handler describing the route table, and
+// handler graph, of the handler as JSON.
+func debugRoutesHandler(handler ResourceHandler) ResourceHandler {
+	return ResourceHandlerFunc(func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+		b, err := json.MarshalIndent(struct {
+			Routes RouteTable   `json:"routes"`
+			Graph  *HandlerNode `json:"graph"`
+		}{
+			Routes: Routes(handler),
+			Graph:  HandlerGraph(handler),
+		}, "", "  ")
+		if err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+
+		return APIGatewayProxyResponse{
+			APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Body:       string(b),
+			},
+			HTTPHeader: http.Header{
+				"Content-Type": []string{"application/json"},
+			},
+		}, nil
+	})
+}
+
+// requestLogMiddleware returns a Middleware logging the sample rate of
+// requests, and all failed requests, with the standard logger. Verbose logs
+// include the request's headers and query.
+func requestLogMiddleware(sampleRate float64, verbose bool) Middleware {
+	return func(handler ResourceHandler) ResourceHandler {
+		return ResourceHandlerWithRequestLog(RequestLog{
+			SampleRate: sampleRate,
+			Verbose:    verbose,
+		}, handler)
+	}
+}
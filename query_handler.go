@@ -0,0 +1,229 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ServeQuery is an API Gateway Proxy resource handler delegating resource
+// requests to resource handlers filtered by query string constraints. Allows
+// multiple handlers to be registered for the same resource and method, e.g.
+// action style APIs, "GET /search?type=user" and "GET /search?type=order".
+//
+// Constraints are matched in order of precedence. Constraints requiring a
+// value are matched before constraints only requiring presence, more
+// constraints are matched before fewer, and otherwise constraints are matched
+// in the order they were added.
+type ServeQuery struct {
+	routes     []queryRoute
+	middleware []Middleware
+	errs       registrationErrors
+
+	// DuplicatePolicy is the policy for query constraints added that
+	// already have a handler. Defaults to DuplicatePanic.
+	DuplicatePolicy DuplicatePolicy
+
+	// NotFoundHandler serves requests matching no query constraint.
+	// Defaults to a 404 Not Found response.
+	NotFoundHandler ResourceHandler
+}
+
+type queryRoute struct {
+	query       string
+	constraints []queryConstraint
+	options     routeOptions
+	handler     ResourceHandler
+
+	// serve is the handler wrapped by the router's middleware.
+	serve ResourceHandler
+}
+
+type queryConstraint struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+// NewServeQuery initializes and returns a ServeQuery that query constraints
+// can be added to via the Handle method.
+func NewServeQuery() *ServeQuery {
+	return &ServeQuery{}
+}
+
+// ServeResource implements the ResourceHandler interface, delegating resource
+// requests to the ResourceHandler of the first query constraint the request
+// matches. If no constraint matches the request is served by the
+// NotFoundHandler.
+func (s *ServeQuery) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	query := requestQuery(req)
+	for _, r := range s.routes {
+		if r.matches(query) {
+			ctx = withRouteTypes(ctx, r.options.types)
+			return r.serve.ServeResource(ctx, req)
+		}
+	}
+
+	return serveNotFound(ctx, s.NotFoundHandler, req)
+}
+
+// Handle adds a new ResourceHandler associated with the query constraint,
+// configured with the route options. The query is formatted as a URL query
+// string, with "key=value" requiring the parameter to have the value, and
+// "key" only requiring the parameter be present, e.g. "type=user&verbose".
+// The empty query matches all requests, and can be used as the default
+// handler.
+//
+// Constraints that already have a handler are handled according to the
+// DuplicatePolicy.
+func (s *ServeQuery) Handle(query string, handler ResourceHandler, opts ...RouteOption) *ServeQuery {
+	constraints, err := parseQueryConstraints(query)
+	if err != nil {
+		panic(err)
+	}
+	normalized := formatQueryConstraints(constraints)
+
+	options := newRouteOptions(opts)
+	handler = options.wrap(handler)
+	route := queryRoute{
+		query:       normalized,
+		constraints: constraints,
+		options:     options,
+		handler:     handler,
+		serve:       options.chain(s.middleware, handler),
+	}
+
+	for i, r := range s.routes {
+		if r.query == normalized {
+			if s.DuplicatePolicy.duplicate(&s.errs, "ServeQuery", "?"+normalized) {
+				s.routes[i] = route
+			}
+			return s
+		}
+	}
+
+	s.routes = append(s.routes, route)
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		return s.routes[i].precedes(s.routes[j])
+	})
+
+	return s
+}
+
+// Use adds middleware applied to the handlers of all query constraints,
+// including constraints added before Use is called. Middleware are applied
+// in the order they are added, with the first middleware the outermost.
+func (s *ServeQuery) Use(middleware ...Middleware) *ServeQuery {
+	s.middleware = append(s.middleware, middleware...)
+	for i, r := range s.routes {
+		s.routes[i].serve = r.options.chain(s.middleware, r.handler)
+	}
+	return s
+}
+
+// Err returns the errors of duplicate query constraints added with the
+// DuplicateError policy, or nil if there were none.
+func (s *ServeQuery) Err() error {
+	return s.errs.err()
+}
+
+func (r queryRoute) matches(query url.Values) bool {
+	for _, c := range r.constraints {
+		values, ok := query[c.key]
+		if !ok {
+			return false
+		}
+		if !c.hasValue {
+			continue
+		}
+
+		var found bool
+		for _, v := range values {
+			if v == c.value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (r queryRoute) precedes(o queryRoute) bool {
+	rv, ov := r.valueConstraints(), o.valueConstraints()
+	if rv != ov {
+		return rv > ov
+	}
+	return len(r.constraints) > len(o.constraints)
+}
+
+func (r queryRoute) valueConstraints() (n int) {
+	for _, c := range r.constraints {
+		if c.hasValue {
+			n++
+		}
+	}
+	return n
+}
+
+func parseQueryConstraints(query string) ([]queryConstraint, error) {
+	var constraints []queryConstraint
+	for _, part := range strings.Split(strings.TrimPrefix(query, "?"), "&") {
+		if len(part) == 0 {
+			continue
+		}
+
+		var c queryConstraint
+		if idx := strings.IndexByte(part, '='); idx >= 0 {
+			c.value, c.hasValue = part[idx+1:], true
+			part = part[:idx]
+		}
+
+		var err error
+		if c.key, err = url.QueryUnescape(part); err != nil {
+			return nil, fmt.Errorf("invalid query constraint %q, %w", query, err)
+		}
+		if c.value, err = url.QueryUnescape(c.value); err != nil {
+			return nil, fmt.Errorf("invalid query constraint %q, %w", query, err)
+		}
+		constraints = append(constraints, c)
+	}
+
+	sort.Slice(constraints, func(i, j int) bool {
+		return constraints[i].key < constraints[j].key
+	})
+	return constraints, nil
+}
+
+func formatQueryConstraints(constraints []queryConstraint) string {
+	parts := make([]string, 0, len(constraints))
+	for _, c := range constraints {
+		part := url.QueryEscape(c.key)
+		if c.hasValue {
+			part += "=" + url.QueryEscape(c.value)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "&")
+}
+
+// requestQuery returns the request's query string parameters, preferring
+// the multi value parameters.
+func requestQuery(req APIGatewayProxyRequest) url.Values {
+	query := url.Values{}
+	for k, vs := range req.MultiValueQueryStringParameters {
+		query[k] = append(query[k], vs...)
+	}
+	for k, v := range req.QueryStringParameters {
+		if _, ok := query[k]; !ok {
+			query.Set(k, v)
+		}
+	}
+	return query
+}
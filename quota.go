@@ -0,0 +1,118 @@
+package lambdamux
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// QuotaStore is the interface for storage of usage counters. Stores must
+// increment counters atomically, as counters are shared by concurrent Lambda
+// containers.
+type QuotaStore interface {
+	// Increment adds delta to the counter for the key, returning the
+	// counter's new value. The counter may be discarded after expires.
+	Increment(ctx context.Context, key string, delta int64, expires time.Time) (int64, error)
+
+	// Get returns the counter's value for the key, or zero if the counter
+	// does not exist.
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+// QuotaPeriod is the period usage is counted within.
+type QuotaPeriod int
+
+const (
+	// QuotaDaily counts usage per UTC day.
+	QuotaDaily QuotaPeriod = iota
+
+	// QuotaMonthly counts usage per UTC month.
+	QuotaMonthly
+)
+
+// bucket returns the period's bucket for the time, and when it ends.
+func (p QuotaPeriod) bucket(t time.Time) (string, time.Time) {
+	t = t.UTC()
+	switch p {
+	case QuotaMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	}
+}
+
+// QuotaCounter counts usage per key, e.g. tenant or API key, within a
+// period, backed by a QuotaStore.
+type QuotaCounter struct {
+	Store  QuotaStore
+	Period QuotaPeriod
+}
+
+// Add adds n to the key's usage for the current period, returning the key's
+// usage for the period.
+func (c *QuotaCounter) Add(ctx context.Context, key string, n int64) (int64, error) {
+	bucket, ends := c.Period.bucket(time.Now())
+	return c.Store.Increment(ctx, key+"#"+bucket, n, ends)
+}
+
+// Usage returns the key's usage for the period that includes the time.
+func (c *QuotaCounter) Usage(ctx context.Context, key string, t time.Time) (int64, error) {
+	bucket, _ := c.Period.bucket(t)
+	return c.Store.Get(ctx, key+"#"+bucket)
+}
+
+// Resets returns when the current period ends, and usage is reset.
+func (c *QuotaCounter) Resets() time.Time {
+	_, ends := c.Period.bucket(time.Now())
+	return ends
+}
+
+// MemoryQuotaStore is a QuotaStore keeping counters in memory. Counters are
+// only shared within a single Lambda container, so the store is only
+// suited for testing, and local development.
+type MemoryQuotaStore struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// NewMemoryQuotaStore initializes and returns a MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counters: map[string]memoryCounter{}}
+}
+
+// Increment implements the QuotaStore interface.
+func (s *MemoryQuotaStore) Increment(
+	ctx context.Context, key string, delta int64, expires time.Time,
+) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counters[key]
+	if !c.expires.IsZero() && time.Now().After(c.expires) {
+		c = memoryCounter{}
+	}
+	c.value += delta
+	c.expires = expires
+	s.counters[key] = c
+
+	return c.value, nil
+}
+
+// Get implements the QuotaStore interface.
+func (s *MemoryQuotaStore) Get(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.counters[key]
+	if !ok || time.Now().After(c.expires) {
+		return 0, nil
+	}
+	return c.value, nil
+}
@@ -0,0 +1,172 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Quota configures enforcement of a usage quota, e.g. requests per day per
+// API key, for deployments without API Gateway usage plans, such as Lambda
+// Function URLs or ALB.
+type Quota struct {
+	Counter *QuotaCounter
+
+	// Maximum number of requests per key within the counter's period.
+	Limit int64
+
+	// Key extracts the key usage is counted for, e.g. a RoutingKey's Key.
+	// Defaults to the ID of the API key the request was made with.
+	Key DimensionFunc
+}
+
+type quotaHandler struct {
+	Quota   Quota
+	Handler ResourceHandler
+
+	mu        sync.Mutex
+	exhausted map[string]time.Time
+}
+
+// ResourceHandlerWithQuota provides a resource handler enforcing the usage
+// quota for requests to handler. Requests exceeding the quota are responded
+// to with a 429 Too Many Requests response. Requests without a key are
+// responded to with a 403 Forbidden response.
+//
+// All responses include the X-Quota-Limit, X-Quota-Remaining, and
+// X-Quota-Reset headers. Keys that exhaust their quota are cached locally
+// until the quota resets, so further requests are rejected without calling
+// the quota's store.
+func ResourceHandlerWithQuota(quota Quota, handler ResourceHandler) ResourceHandler {
+	if quota.Key == nil {
+		quota.Key = APIKeyDimension
+	}
+
+	return &quotaHandler{
+		Quota:     quota,
+		Handler:   handler,
+		exhausted: map[string]time.Time{},
+	}
+}
+
+// ServeResource enforces the quota before delegating to the wrapped handler.
+func (h *quotaHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	key := h.Quota.Key(ctx, req)
+	if len(key) == 0 {
+		return statusResponse(http.StatusForbidden), nil
+	}
+
+	resets := h.Quota.Counter.Resets()
+	if h.isExhausted(key) {
+		return h.exceededResponse(resets), nil
+	}
+
+	used, err := h.Quota.Counter.Add(ctx, key, 1)
+	if err != nil {
+		return resp, fmt.Errorf("failed to update quota usage, %w", err)
+	}
+	if used > h.Quota.Limit {
+		h.markExhausted(key, resets)
+		return h.exceededResponse(resets), nil
+	}
+
+	resp, err = h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	h.setHeaders(resp.HTTPHeader, h.Quota.Limit-used, resets)
+
+	return resp, nil
+}
+
+func (h *quotaHandler) exceededResponse(resets time.Time) APIGatewayProxyResponse {
+	resp := statusResponse(http.StatusTooManyRequests)
+	h.setHeaders(resp.HTTPHeader, 0, resets)
+
+	retryAfter := int64(time.Until(resets) / time.Second)
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	resp.HTTPHeader.Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+
+	return resp
+}
+
+func (h *quotaHandler) setHeaders(header http.Header, remaining int64, resets time.Time) {
+	header.Set("X-Quota-Limit", strconv.FormatInt(h.Quota.Limit, 10))
+	header.Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+	header.Set("X-Quota-Reset", strconv.FormatInt(resets.Unix(), 10))
+}
+
+func (h *quotaHandler) isExhausted(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	resets, ok := h.exhausted[key]
+	if ok && time.Now().After(resets) {
+		delete(h.exhausted, key)
+		return false
+	}
+	return ok
+}
+
+func (h *quotaHandler) markExhausted(key string, resets time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.exhausted[key] = resets
+}
+
+// DynamoDBCounterAPI is the interface for the DynamoDB operations
+// DynamoDBQuotaStore is built on. The package does not depend on the AWS
+// SDK, applications adapt their SDK DynamoDB client to the interface.
+type DynamoDBCounterAPI interface {
+	// AddCounter atomically adds delta to the item's numeric attribute,
+	// creating the item if it does not exist, and returns the attribute's
+	// new value, e.g. UpdateItem with the update expression
+	// "ADD #count :delta SET #ttl = :ttl", and UPDATED_NEW return values.
+	// The item's TTL attribute is set to expires.
+	AddCounter(ctx context.Context, table, key, attr string, delta int64, expires time.Time) (int64, error)
+
+	// GetCounter returns the item's numeric attribute, or zero if the item
+	// does not exist.
+	GetCounter(ctx context.Context, table, key, attr string) (int64, error)
+}
+
+// DynamoDBQuotaStore is a QuotaStore keeping counters in a DynamoDB table
+// using atomic counters.
+type DynamoDBQuotaStore struct {
+	Client DynamoDBCounterAPI
+	Table  string
+
+	// Attribute the counter is stored in. Defaults to "count".
+	Attribute string
+}
+
+// Increment implements the QuotaStore interface.
+func (s DynamoDBQuotaStore) Increment(
+	ctx context.Context, key string, delta int64, expires time.Time,
+) (int64, error) {
+	return s.Client.AddCounter(ctx, s.Table, key, s.attribute(), delta, expires)
+}
+
+// Get implements the QuotaStore interface.
+func (s DynamoDBQuotaStore) Get(ctx context.Context, key string) (int64, error) {
+	return s.Client.GetCounter(ctx, s.Table, key, s.attribute())
+}
+
+func (s DynamoDBQuotaStore) attribute() string {
+	if len(s.Attribute) == 0 {
+		return "count"
+	}
+	return s.Attribute
+}
@@ -0,0 +1,84 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ReadOnly configures read-only mode for resource handlers, rejecting
+// requests that could modify state, e.g. during migrations, and incident
+// response.
+//
+// The read-only mode state is read from a MaintenanceSource, in the same
+// format as maintenance mode, e.g. EnvMaintenance("READ_ONLY"), or a
+// PollingMaintenance fetching an SSM parameter. The state's Resources limit
+// read-only mode to the resources, otherwise all resources of the handler
+// are read-only. To scope read-only mode to a group of routes, wrap the
+// group's handler, e.g. with the WithMiddleware route option.
+type ReadOnly struct {
+	Source MaintenanceSource
+
+	// StatusCode of rejected requests, either 503 Service Unavailable, or
+	// 405 Method Not Allowed. Defaults to 503 Service Unavailable.
+	StatusCode int
+
+	// SafeMethods are the methods served in read-only mode. Defaults to
+	// GET, HEAD, and OPTIONS.
+	SafeMethods []string
+}
+
+type readOnlyHandler struct {
+	ReadOnly ReadOnly
+	Handler  ResourceHandler
+}
+
+// ResourceHandlerWithReadOnly provides a resource handler rejecting requests
+// to handler with methods other than the safe methods while read-only mode
+// is enabled for the request's resource. 503 responses include the state's
+// Retry-After header, and 405 responses the Allow header of the safe
+// methods.
+//
+// If the read-only state cannot be retrieved requests are served normally.
+func ResourceHandlerWithReadOnly(readOnly ReadOnly, handler ResourceHandler) ResourceHandler {
+	if readOnly.StatusCode == 0 {
+		readOnly.StatusCode = http.StatusServiceUnavailable
+	}
+	if len(readOnly.SafeMethods) == 0 {
+		readOnly.SafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+	}
+
+	return readOnlyHandler{
+		ReadOnly: readOnly,
+		Handler:  handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, unless the request's
+// method is not safe, and read-only mode is enabled for its resource.
+func (h readOnlyHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	for _, m := range h.ReadOnly.SafeMethods {
+		if strings.EqualFold(m, req.HTTPMethod) {
+			return h.Handler.ServeResource(ctx, req)
+		}
+	}
+
+	state, err := h.ReadOnly.Source.MaintenanceState(ctx)
+	if err != nil || !state.inMaintenance(req.Resource) {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	resp := statusResponse(h.ReadOnly.StatusCode)
+	switch h.ReadOnly.StatusCode {
+	case http.StatusMethodNotAllowed:
+		resp.HTTPHeader.Set("Allow", strings.Join(h.ReadOnly.SafeMethods, ", "))
+	case http.StatusServiceUnavailable:
+		if state.RetryAfter > 0 {
+			resp.HTTPHeader.Set("Retry-After", strconv.Itoa(state.RetryAfter))
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package lambdamux
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recovery is the configuration of the panic recovery middleware.
+type Recovery struct {
+	// Response returns the response of the request whose handler panicked
+	// with the value. Defaults to a 500 Internal Server Error response.
+	Response func(ctx context.Context, req APIGatewayProxyRequest, v interface{}) APIGatewayProxyResponse
+
+	// Log logs the panic's value, and stack trace. Defaults to logging with
+	// the standard logger.
+	Log func(req APIGatewayProxyRequest, v interface{}, stack []byte)
+}
+
+type recoveryHandler struct {
+	Config  Recovery
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithRecovery provides a resource handler that recovers
+// panics of handler, logging the panic's stack trace, and responding with
+// the config's response, instead of failing the Lambda invoke, which API
+// Gateway surfaces as a generic 502 Bad Gateway.
+//
+// Panics are recovered after handler's own decorators, e.g.
+// ResourceHandlerWithErrorReporting, have seen them, so the middleware
+// should be the outermost.
+func ResourceHandlerWithRecovery(cfg Recovery, handler ResourceHandler) ResourceHandler {
+	return recoveryHandler{
+		Config:  cfg,
+		Handler: handler,
+	}
+}
+
+// Recoverer is a Middleware recovering panics of the handler with the
+// default Recovery, e.g. router.Use(lambdamux.Recoverer).
+func Recoverer(handler ResourceHandler) ResourceHandler {
+	return ResourceHandlerWithRecovery(Recovery{}, handler)
+}
+
+// ServeResource delegates to the wrapped handler, recovering its panics.
+func (h recoveryHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+
+		if h.Config.Log != nil {
+			h.Config.Log(req, v, debug.Stack())
+		} else {
+			log.Printf("%s %s %s panic: %v\n%s", req.RequestContext.RequestID,
+				req.HTTPMethod, req.Path, v, debug.Stack())
+		}
+
+		if h.Config.Response != nil {
+			resp = h.Config.Response(ctx, req, v)
+		} else {
+			resp = statusResponse(http.StatusInternalServerError)
+		}
+		err = nil
+	}()
+
+	return h.Handler.ServeResource(ctx, req)
+}
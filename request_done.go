@@ -0,0 +1,88 @@
+package lambdamux
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type requestDoneKey struct{}
+
+// requestDone is the registry of a request's cleanup callbacks, and the
+// time the request's invoke started.
+type requestDone struct {
+	start time.Time
+
+	mu   sync.Mutex
+	fns  []func()
+	done bool
+}
+
+// OnRequestDone registers the callback to be called after the response of
+// the request of the context is produced, e.g. to close temporary files, or
+// release locks, so resources are not leaked across warm invokes.
+// Callbacks are called in the reverse order they were registered, after
+// the handler returns, panics, or times out. Callbacks registered after the
+// request is done, e.g. by a handler that outlived its timeout, are called
+// immediately.
+//
+// The registry is provided by the APIGatewayProxy, APIGatewayV2Proxy,
+// FunctionURLProxy, and EventMux invokes. Returns false, without
+// registering the callback, if the context has no registry, e.g. a handler
+// called directly in a test.
+func OnRequestDone(ctx context.Context, fn func()) bool {
+	r, ok := ctx.Value(requestDoneKey{}).(*requestDone)
+	if !ok {
+		return false
+	}
+
+	r.mu.Lock()
+	if r.done {
+		r.mu.Unlock()
+		runRequestDone(fn)
+		return true
+	}
+	r.fns = append(r.fns, fn)
+	r.mu.Unlock()
+	return true
+}
+
+// withRequestDone returns the context with a cleanup registry, and the
+// function calling the registry's callbacks. If the context already has a
+// registry, e.g. of an enclosing invoke, the context is returned with a
+// no-op function, so callbacks are called when the enclosing invoke is
+// done.
+func withRequestDone(ctx context.Context) (context.Context, func()) {
+	if _, ok := ctx.Value(requestDoneKey{}).(*requestDone); ok {
+		return ctx, func() {}
+	}
+
+	r := &requestDone{start: time.Now()}
+	return context.WithValue(ctx, requestDoneKey{}, r), func() {
+		r.mu.Lock()
+		fns := r.fns
+		r.fns, r.done = nil, true
+		r.mu.Unlock()
+
+		for i := len(fns) - 1; i >= 0; i-- {
+			runRequestDone(fns[i])
+		}
+	}
+}
+
+// requestStart returns the time the invoke of the context's request
+// started, or false if the context has no cleanup registry.
+func requestStart(ctx context.Context) (time.Time, bool) {
+	r, ok := ctx.Value(requestDoneKey{}).(*requestDone)
+	if !ok {
+		return time.Time{}, false
+	}
+	return r.start, true
+}
+
+// runRequestDone calls the callback, recovering from its panic, so a
+// failing callback does not prevent the others from being called.
+func runRequestDone(fn func()) {
+	defer func() { recover() }()
+	fn()
+}
@@ -0,0 +1,166 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Logger is the interface for structured loggers the request log middleware
+// logs with. Log attributes are alternating keys, and values. The interface
+// is implemented by log/slog's *slog.Logger.
+type Logger interface {
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// StdLogger is a Logger writing attributes as key=value pairs with a
+// standard library logger.
+type StdLogger struct {
+	// Logger to write with. Defaults to the standard logger.
+	Logger *log.Logger
+}
+
+// InfoContext implements the Logger interface.
+func (l StdLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("INFO", msg, args)
+}
+
+// ErrorContext implements the Logger interface.
+func (l StdLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("ERROR", msg, args)
+}
+
+func (l StdLogger) log(level, msg string, args []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "level=%s msg=%q", level, msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+			break
+		}
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+
+	if l.Logger != nil {
+		l.Logger.Println(b.String())
+	} else {
+		log.Println(b.String())
+	}
+}
+
+// RequestLog is the configuration of the request log middleware.
+type RequestLog struct {
+	// Logger to log requests with. Defaults to StdLogger.
+	Logger Logger
+
+	// SampleRate is the fraction of successful requests logged, e.g. 0.01.
+	// Failed requests are always logged. Defaults to logging all requests.
+	SampleRate float64
+
+	// Verbose is if the request's query, and headers, are logged.
+	Verbose bool
+
+	// RedactHeaders are the headers whose values are redacted from verbose
+	// logs, in addition to Cookie, and headers whose names look sensitive,
+	// e.g. Authorization.
+	RedactHeaders []string
+}
+
+type requestLogHandler struct {
+	Config  RequestLog
+	Handler ResourceHandler
+
+	served int32
+}
+
+// ResourceHandlerWithRequestLog provides a resource handler logging the
+// requests served by handler, with the request's method, resource, path,
+// response status, latency, request ID, deployment variant, and if the
+// request is the first served by the Lambda container, its cold start. The
+// Cognito identity, and client application, of the request are logged if
+// the request has them. Requests that fail, with an error or 5xx status,
+// are logged as errors.
+func ResourceHandlerWithRequestLog(cfg RequestLog, handler ResourceHandler) ResourceHandler {
+	if cfg.Logger == nil {
+		cfg.Logger = StdLogger{}
+	}
+
+	return &requestLogHandler{
+		Config:  cfg,
+		Handler: handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, logging the request.
+func (h *requestLogHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	coldStart := atomic.CompareAndSwapInt32(&h.served, 0, 1)
+
+	start := time.Now()
+	resp, err := h.Handler.ServeResource(ctx, req)
+	latency := time.Since(start)
+
+	failed := err != nil || resp.StatusCode >= 500
+	if !failed && !coldStart && h.Config.SampleRate > 0 && rand.Float64() >= h.Config.SampleRate {
+		return resp, err
+	}
+
+	args := []interface{}{
+		"method", req.HTTPMethod,
+		"resource", req.Resource,
+		"path", req.Path,
+		"status", resp.StatusCode,
+		"latency", latency,
+		"requestId", req.RequestContext.RequestID,
+		"coldStart", coldStart,
+		"variant", req.DeploymentVariant(),
+	}
+	if id, ok := requestCognitoIdentity(ctx, req); ok {
+		args = append(args, "cognitoIdentityId", id.CognitoIdentityID)
+	}
+	if app := ClientAppDimension(ctx, req); len(app) != 0 {
+		args = append(args, "clientApp", app)
+	}
+	if h.Config.Verbose {
+		args = append(args,
+			"query", requestQuery(req),
+			"headers", redactHeaders(req.HTTPHeader, h.Config.RedactHeaders),
+		)
+	}
+
+	if failed {
+		if err != nil {
+			args = append(args, "error", err.Error())
+		}
+		h.Config.Logger.ErrorContext(ctx, "request failed", args...)
+	} else {
+		h.Config.Logger.InfoContext(ctx, "request", args...)
+	}
+
+	return resp, err
+}
+
+// redactHeaders returns a copy of the headers with the values of sensitive
+// headers, and the additional headers, redacted.
+func redactHeaders(h http.Header, additional []string) http.Header {
+	redacted := make(http.Header, len(h))
+	for k, v := range h {
+		if sensitiveKeyPattern.MatchString(k) || strings.EqualFold(k, "Cookie") {
+			v = []string{"[REDACTED]"}
+		}
+		for _, name := range additional {
+			if strings.EqualFold(k, name) {
+				v = []string{"[REDACTED]"}
+			}
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
@@ -0,0 +1,89 @@
+package lambdamux
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// JSON returns a response with the status code, and the JSON encoding of v
+// as the body. Returns an error if v cannot be encoded.
+func JSON(statusCode int, v interface{}) (APIGatewayProxyResponse, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to marshal %T response, %w", v, err)
+	}
+	return newResponse(statusCode, "application/json", string(b)), nil
+}
+
+// Text returns a response with the status code, and the plain text body.
+func Text(statusCode int, body string) APIGatewayProxyResponse {
+	return newResponse(statusCode, "text/plain; charset=utf-8", body)
+}
+
+// HTML returns a response with the status code, and the HTML body.
+func HTML(statusCode int, body string) APIGatewayProxyResponse {
+	return newResponse(statusCode, "text/html; charset=utf-8", body)
+}
+
+// Bytes returns a response with the status code, content type, and body.
+// The body is base64 encoded, and the response's IsBase64Encoded set, unless
+// the content type is textual, e.g. "text/csv", or "application/json", so
+// binary bodies pass through API Gateway unchanged. API Gateway REST APIs
+// must also have the content type configured as a binary media type.
+func Bytes(statusCode int, contentType string, body []byte) APIGatewayProxyResponse {
+	if isTextMediaType(contentType) {
+		return newResponse(statusCode, contentType, string(body))
+	}
+	return Binary(statusCode, contentType, body)
+}
+
+// Binary returns a response with the status code, content type, and the
+// base64 encoded body, with the response's IsBase64Encoded set, regardless
+// of the content type.
+func Binary(statusCode int, contentType string, body []byte) APIGatewayProxyResponse {
+	resp := newResponse(statusCode, contentType, base64.StdEncoding.EncodeToString(body))
+	resp.IsBase64Encoded = true
+	return resp
+}
+
+// newResponse returns a response with the status code, content type, and
+// body.
+func newResponse(statusCode int, contentType, body string) APIGatewayProxyResponse {
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: statusCode,
+			Body:       body,
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{contentType},
+		},
+	}
+}
+
+// BodyBytes returns the response's body, decoding it if base64 encoded.
+// Returns an error if the body is not valid base64.
+func (r APIGatewayProxyResponse) BodyBytes() ([]byte, error) {
+	if !r.IsBase64Encoded {
+		return []byte(r.Body), nil
+	}
+
+	b, err := base64.StdEncoding.DecodeString(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 encoded response body, %w", err)
+	}
+	return b, nil
+}
+
+// NoContent returns a 204 No Content response.
+func NoContent() APIGatewayProxyResponse {
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: http.StatusNoContent,
+		},
+		HTTPHeader: http.Header{},
+	}
+}
@@ -0,0 +1,351 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrStreamDeadline is returned by ResponseStream writes that could not
+// complete before the stream's write deadline.
+var ErrStreamDeadline = errors.New("response stream write deadline exceeded")
+
+// StreamConfig configures the buffering of streamed responses, keeping the
+// stream within the Lambda function's memory and execution time.
+type StreamConfig struct {
+	// ChunkSize is the size, in bytes, of the chunks written to the
+	// underlying writer. Defaults to 16 KiB.
+	ChunkSize int
+
+	// FlushInterval is the maximum time partial chunks are buffered before
+	// they are written. Defaults to 100ms. Negative disables periodic
+	// flushing.
+	FlushInterval time.Duration
+
+	// MaxBufferedBytes is the maximum bytes buffered while the underlying
+	// writer is slower than the handler. Writes block, applying
+	// backpressure to the handler, until the buffered bytes are written.
+	// Defaults to 1 MiB.
+	MaxBufferedBytes int
+
+	// DeadlineMargin is the time before the Lambda invoke's deadline that
+	// writes fail with ErrStreamDeadline, leaving the function time to end
+	// the response. Defaults to 500ms.
+	DeadlineMargin time.Duration
+}
+
+func (c *StreamConfig) setDefaults() {
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = 16 * 1024
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = 100 * time.Millisecond
+	}
+	if c.MaxBufferedBytes <= 0 {
+		c.MaxBufferedBytes = 1024 * 1024
+	}
+	if c.MaxBufferedBytes < c.ChunkSize {
+		c.MaxBufferedBytes = c.ChunkSize
+	}
+	if c.DeadlineMargin == 0 {
+		c.DeadlineMargin = 500 * time.Millisecond
+	}
+}
+
+// ResponseStream is an io.WriteCloser streaming a response body to an
+// underlying writer in chunks, e.g. an io.Pipe read by the Lambda runtime's
+// streaming response, or a local server's http.ResponseWriter.
+//
+// Writes are buffered into chunks of the configured size, written
+// asynchronously, and flushed if the underlying writer implements
+// http.Flusher, or Flush() error. Partial chunks are written at least every
+// flush interval. Writes block while the maximum bytes are buffered, and
+// fail with ErrStreamDeadline if they could not complete before the Lambda
+// invoke's deadline, less the deadline margin.
+//
+// The stream must be closed, which writes the remaining buffered bytes, and
+// closes the underlying writer if it implements io.Closer.
+type ResponseStream struct {
+	ctx      context.Context
+	config   StreamConfig
+	w        io.Writer
+	deadline time.Time
+
+	// wmu serializes Write, Flush, and Close.
+	wmu     sync.Mutex
+	pending []byte
+
+	mu     sync.Mutex
+	queue  [][]byte
+	queued int
+	err    error
+	closed bool
+
+	wake  chan struct{}
+	space chan struct{}
+	done  chan struct{}
+	stop  chan struct{}
+}
+
+// NewResponseStream returns a ResponseStream writing to w. The write deadline
+// is derived from the context's deadline, e.g. the Lambda invoke's deadline.
+func NewResponseStream(ctx context.Context, w io.Writer, config StreamConfig) *ResponseStream {
+	config.setDefaults()
+
+	s := &ResponseStream{
+		ctx:    ctx,
+		config: config,
+		w:      w,
+		wake:   make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.deadline = deadline.Add(-config.DeadlineMargin)
+	}
+
+	go s.writeLoop()
+	if config.FlushInterval > 0 {
+		go s.flushLoop()
+	}
+
+	return s
+}
+
+// Write buffers p, writing full chunks to the underlying writer. Blocks while
+// the maximum bytes are buffered.
+func (s *ResponseStream) Write(p []byte) (int, error) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.checkWrite(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for len(p) != 0 {
+		m := s.config.ChunkSize - len(s.pending)
+		if m > len(p) {
+			m = len(p)
+		}
+		s.pending = append(s.pending, p[:m]...)
+		p = p[m:]
+		n += m
+
+		if len(s.pending) == s.config.ChunkSize {
+			if err := s.emit(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush writes the buffered partial chunk to the underlying writer.
+func (s *ResponseStream) Flush() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	if err := s.checkWrite(); err != nil {
+		return err
+	}
+	return s.emit()
+}
+
+// Close writes the remaining buffered bytes, waiting for them to be written
+// before the write deadline, and closes the underlying writer if it
+// implements io.Closer. If the bytes are not written before the deadline,
+// or the context is canceled, the remaining bytes are discarded, and the
+// underlying writer is closed once its in progress write returns, so it is
+// never written to, and closed, concurrently.
+func (s *ResponseStream) Close() error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return s.err
+	}
+	s.mu.Unlock()
+
+	err := s.emit()
+
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	close(s.stop)
+	s.signal(s.wake)
+
+	if err == nil {
+		select {
+		case <-s.done:
+			s.mu.Lock()
+			err = s.err
+			s.mu.Unlock()
+		case <-s.deadlineC():
+			err = ErrStreamDeadline
+		case <-s.ctx.Done():
+			err = s.ctx.Err()
+		}
+	}
+
+	select {
+	case <-s.done:
+	default:
+		s.mu.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+
+		if c, ok := s.w.(io.Closer); ok {
+			go func() {
+				<-s.done
+				c.Close()
+			}()
+		}
+		return err
+	}
+
+	if c, ok := s.w.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *ResponseStream) checkWrite() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("response stream closed")
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if !s.deadline.IsZero() && !time.Now().Before(s.deadline) {
+		return ErrStreamDeadline
+	}
+	return nil
+}
+
+// emit queues the pending bytes to be written, waiting for buffer space.
+func (s *ResponseStream) emit() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	for {
+		s.mu.Lock()
+		if s.err != nil {
+			s.mu.Unlock()
+			return s.err
+		}
+		if s.queued == 0 || s.queued+len(s.pending) <= s.config.MaxBufferedBytes {
+			s.queue = append(s.queue, s.pending)
+			s.queued += len(s.pending)
+			s.pending = make([]byte, 0, s.config.ChunkSize)
+			s.mu.Unlock()
+			s.signal(s.wake)
+			return nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.space:
+		case <-s.deadlineC():
+			return ErrStreamDeadline
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+func (s *ResponseStream) writeLoop() {
+	defer close(s.done)
+
+	for {
+		s.mu.Lock()
+		if s.err != nil {
+			s.mu.Unlock()
+			return
+		}
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			<-s.wake
+			continue
+		}
+		chunk := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		err := s.writeChunk(chunk)
+
+		s.mu.Lock()
+		s.queued -= len(chunk)
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+		s.signal(s.space)
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *ResponseStream) writeChunk(chunk []byte) error {
+	if _, err := s.w.Write(chunk); err != nil {
+		return err
+	}
+
+	switch f := s.w.(type) {
+	case http.Flusher:
+		f.Flush()
+	case interface{ Flush() error }:
+		return f.Flush()
+	}
+	return nil
+}
+
+func (s *ResponseStream) flushLoop() {
+	ticker := time.NewTicker(s.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Flush()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// deadlineC returns a channel receiving when the write deadline passes, or
+// nil if the stream has no deadline.
+func (s *ResponseStream) deadlineC() <-chan time.Time {
+	if s.deadline.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(s.deadline))
+}
+
+func (s *ResponseStream) signal(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
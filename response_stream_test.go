@@ -0,0 +1,254 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter records the chunks written to it. Writes block until
+// release is closed, if set.
+type blockingWriter struct {
+	release chan struct{}
+	closedC chan struct{}
+
+	mu                sync.Mutex
+	chunks            []string
+	flushes           int
+	writing           bool
+	closed            bool
+	closedDuringWrite bool
+}
+
+func newBlockingWriter(block bool) *blockingWriter {
+	w := &blockingWriter{closedC: make(chan struct{})}
+	if block {
+		w.release = make(chan struct{})
+	}
+	return w
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.writing = true
+	w.mu.Unlock()
+
+	if w.release != nil {
+		<-w.release
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks = append(w.chunks, string(p))
+	w.writing = false
+	return len(p), nil
+}
+
+func (w *blockingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushes++
+	return nil
+}
+
+func (w *blockingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.writing {
+		w.closedDuringWrite = true
+	}
+	w.closed = true
+	close(w.closedC)
+	return nil
+}
+
+func (w *blockingWriter) written() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.chunks...)
+}
+
+func TestResponseStreamChunks(t *testing.T) {
+	cases := map[string]struct {
+		chunkSize    int
+		writes       []string
+		expectChunks []string
+	}{
+		"full chunks": {
+			chunkSize:    4,
+			writes:       []string{"abcdefgh"},
+			expectChunks: []string{"abcd", "efgh"},
+		},
+		"partial chunk on close": {
+			chunkSize:    4,
+			writes:       []string{"ab", "cdef", "gh", "ij"},
+			expectChunks: []string{"abcd", "efgh", "ij"},
+		},
+		"empty": {
+			chunkSize: 4,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := newBlockingWriter(false)
+			s := NewResponseStream(context.Background(), w, StreamConfig{
+				ChunkSize:     c.chunkSize,
+				FlushInterval: -1,
+			})
+
+			for _, v := range c.writes {
+				if _, err := s.Write([]byte(v)); err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("expect no close error, got %v", err)
+			}
+
+			if e, a := strings.Join(c.expectChunks, "|"), strings.Join(w.written(), "|"); e != a {
+				t.Errorf("expect %q chunks, got %q", e, a)
+			}
+			if !w.closed {
+				t.Errorf("expect writer closed")
+			}
+			if _, err := s.Write([]byte("a")); err == nil {
+				t.Errorf("expect write after close error")
+			}
+		})
+	}
+}
+
+func TestResponseStreamFlushInterval(t *testing.T) {
+	w := newBlockingWriter(false)
+	s := NewResponseStream(context.Background(), w, StreamConfig{
+		ChunkSize:     1024,
+		FlushInterval: 5 * time.Millisecond,
+	})
+	defer s.Close()
+
+	if _, err := s.Write([]byte("partial")); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	timeout := time.After(time.Second)
+	for len(w.written()) == 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("expect partial chunk written by flush interval")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if e, a := "partial", strings.Join(w.written(), ""); e != a {
+		t.Errorf("expect %q written, got %q", e, a)
+	}
+}
+
+func TestResponseStreamBackpressure(t *testing.T) {
+	w := newBlockingWriter(true)
+	s := NewResponseStream(context.Background(), w, StreamConfig{
+		ChunkSize:        2,
+		MaxBufferedBytes: 2,
+		FlushInterval:    -1,
+	})
+
+	// The first chunk is buffered, and blocks in the writer.
+	if _, err := s.Write([]byte("ab")); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.Write([]byte("cd"))
+		written <- err
+	}()
+
+	select {
+	case err := <-written:
+		t.Fatalf("expect write blocked by backpressure, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(w.release)
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("expect no error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expect write unblocked")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("expect no close error, got %v", err)
+	}
+	if e, a := "ab|cd", strings.Join(w.written(), "|"); e != a {
+		t.Errorf("expect %q chunks, got %q", e, a)
+	}
+}
+
+func TestResponseStreamDeadline(t *testing.T) {
+	cases := map[string]struct {
+		cancel    bool
+		expectErr error
+	}{
+		"deadline": {
+			expectErr: ErrStreamDeadline,
+		},
+		"canceled": {
+			cancel:    true,
+			expectErr: context.Canceled,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Millisecond)
+			defer cancel()
+			if c.cancel {
+				ctx, cancel = context.WithCancel(context.Background())
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			w := newBlockingWriter(true)
+			s := NewResponseStream(ctx, w, StreamConfig{
+				ChunkSize:        2,
+				MaxBufferedBytes: 2,
+				FlushInterval:    -1,
+				DeadlineMargin:   20 * time.Millisecond,
+			})
+
+			if _, err := s.Write([]byte("ab")); err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if _, err := s.Write([]byte("cd")); !errors.Is(err, c.expectErr) {
+				t.Fatalf("expect %v error, got %v", c.expectErr, err)
+			}
+			if err := s.Close(); !errors.Is(err, c.expectErr) {
+				t.Fatalf("expect %v close error, got %v", c.expectErr, err)
+			}
+
+			select {
+			case <-w.closedC:
+				t.Fatalf("expect writer not closed during write")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			close(w.release)
+			select {
+			case <-w.closedC:
+			case <-time.After(time.Second):
+				t.Fatalf("expect writer closed after write returned")
+			}
+			if w.closedDuringWrite {
+				t.Errorf("expect writer not closed during write")
+			}
+			if e, a := "ab", strings.Join(w.written(), "|"); e != a {
+				t.Errorf("expect %q chunks, got %q", e, a)
+			}
+		})
+	}
+}
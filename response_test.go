@@ -0,0 +1,81 @@
+package lambdamux
+
+import (
+	"testing"
+)
+
+func TestResponseHelpers(t *testing.T) {
+	jsonResp, err := JSON(200, map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	cases := map[string]struct {
+		resp              APIGatewayProxyResponse
+		expectStatus      int
+		expectContentType string
+		expectBody        string
+		expectBase64      bool
+		expectBytes       string
+	}{
+		"json": {
+			resp:         jsonResp,
+			expectStatus: 200, expectContentType: "application/json",
+			expectBody: `{"a":"b"}`, expectBytes: `{"a":"b"}`,
+		},
+		"text": {
+			resp:         Text(201, "created"),
+			expectStatus: 201, expectContentType: "text/plain; charset=utf-8",
+			expectBody: "created", expectBytes: "created",
+		},
+		"html": {
+			resp:         HTML(200, "<p>hi</p>"),
+			expectStatus: 200, expectContentType: "text/html; charset=utf-8",
+			expectBody: "<p>hi</p>", expectBytes: "<p>hi</p>",
+		},
+		"bytes textual": {
+			resp:         Bytes(200, "text/csv", []byte("a,b")),
+			expectStatus: 200, expectContentType: "text/csv",
+			expectBody: "a,b", expectBytes: "a,b",
+		},
+		"bytes binary": {
+			resp:         Bytes(200, "image/png", []byte{0x89, 'P'}),
+			expectStatus: 200, expectContentType: "image/png",
+			expectBody: "iVA=", expectBase64: true, expectBytes: "\x89P",
+		},
+		"binary": {
+			resp:         Binary(200, "application/json", []byte("{}")),
+			expectStatus: 200, expectContentType: "application/json",
+			expectBody: "e30=", expectBase64: true, expectBytes: "{}",
+		},
+		"no content": {
+			resp:         NoContent(),
+			expectStatus: 204,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			if e, a := c.expectStatus, c.resp.StatusCode; e != a {
+				t.Errorf("expect %v status, got %v", e, a)
+			}
+			if e, a := c.expectContentType, c.resp.HTTPHeader.Get("Content-Type"); e != a {
+				t.Errorf("expect %q content type, got %q", e, a)
+			}
+			if e, a := c.expectBody, c.resp.Body; e != a {
+				t.Errorf("expect %q body, got %q", e, a)
+			}
+			if e, a := c.expectBase64, c.resp.IsBase64Encoded; e != a {
+				t.Errorf("expect %v base64 encoded, got %v", e, a)
+			}
+
+			b, err := c.resp.BodyBytes()
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expectBytes, string(b); e != a {
+				t.Errorf("expect %q body bytes, got %q", e, a)
+			}
+		})
+	}
+}
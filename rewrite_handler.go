@@ -0,0 +1,190 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RedirectRule redirects requests whose path matches the From pattern to the
+// To pattern, e.g. From "/blog/{slug}" To "/posts/{slug}". Parameters
+// captured by From are substituted into To. To may be an absolute URL, or
+// include a query string. If To does not include a query string, the
+// request's query string is preserved.
+type RedirectRule struct {
+	From string
+	To   string
+
+	// HTTP status code of the redirect, one of 301, 302, 307, or 308.
+	// Defaults to 301 Moved Permanently.
+	StatusCode int
+}
+
+// RewriteRule internally rewrites requests whose path matches the From
+// pattern to the To pattern before routing. The request's path, resource,
+// and path parameters are rewritten, so both resource and path based
+// routers route the rewritten request.
+type RewriteRule struct {
+	From string
+	To   string
+}
+
+// Rewrites is a table of redirect and rewrite rules. Redirects are applied
+// before rewrites, and rules are applied in order, with the first matching
+// rule applied.
+type Rewrites struct {
+	Redirects []RedirectRule
+	Rewrites  []RewriteRule
+}
+
+type compiledRedirect struct {
+	from       routePattern
+	to         string
+	toQuery    string
+	toPattern  routePattern
+	statusCode int
+}
+
+type compiledRewrite struct {
+	from routePattern
+	to   routePattern
+}
+
+type rewriteHandler struct {
+	redirects []compiledRedirect
+	rewrites  []compiledRewrite
+	Handler   ResourceHandler
+}
+
+// ResourceHandlerWithRewrites provides a resource handler applying the
+// redirect and rewrite rules to requests before passing them to handler.
+// Panics if a rule's pattern is invalid.
+func ResourceHandlerWithRewrites(rules Rewrites, handler ResourceHandler) ResourceHandler {
+	h := rewriteHandler{Handler: handler}
+
+	for _, r := range rules.Redirects {
+		from := mustParseRoutePattern(r.From)
+
+		to, query := r.To, ""
+		if idx := strings.IndexByte(to, '?'); idx >= 0 {
+			to, query = to[:idx], to[idx:]
+		}
+		var origin string
+		if u, err := url.Parse(to); err == nil && u.IsAbs() {
+			origin = u.Scheme + "://" + u.Host
+			to = u.Path
+		}
+
+		statusCode := r.StatusCode
+		if statusCode == 0 {
+			statusCode = http.StatusMovedPermanently
+		}
+
+		h.redirects = append(h.redirects, compiledRedirect{
+			from:       from,
+			to:         origin,
+			toQuery:    query,
+			toPattern:  mustParseRoutePattern(to),
+			statusCode: statusCode,
+		})
+	}
+
+	for _, r := range rules.Rewrites {
+		h.rewrites = append(h.rewrites, compiledRewrite{
+			from: mustParseRoutePattern(r.From),
+			to:   mustParseRoutePattern(r.To),
+		})
+	}
+
+	return h
+}
+
+func mustParseRoutePattern(pattern string) routePattern {
+	p, err := parseRoutePattern(pattern)
+	if err != nil {
+		panic(fmt.Errorf("invalid rewrite rule, %w", err))
+	}
+	return p
+}
+
+// escapePathParams returns the parameters, path escaped, to expand into the
+// pattern's redirect location. Greedy parameters are escaped per path
+// segment, preserving their slashes.
+func escapePathParams(p routePattern, params map[string]string) map[string]string {
+	escaped := make(map[string]string, len(params))
+	for _, seg := range p.segments {
+		v, ok := params[seg.param]
+		if len(seg.param) == 0 || !ok {
+			continue
+		}
+		if !seg.greedy {
+			escaped[seg.param] = url.PathEscape(v)
+			continue
+		}
+		parts := strings.Split(v, "/")
+		for i, part := range parts {
+			parts[i] = url.PathEscape(part)
+		}
+		escaped[seg.param] = strings.Join(parts, "/")
+	}
+	return escaped
+}
+
+// isLocalRedirect returns if the redirect location is a path of the
+// redirect's origin, and not a scheme relative, "//evil.com", or absolute,
+// URL that could redirect the user to another site, e.g. of a greedy
+// parameter's value, or a client provided return URL.
+func isLocalRedirect(p string) bool {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, `/\`) {
+		return false
+	}
+	u, err := url.Parse(p)
+	return err == nil && len(u.Scheme) == 0 && len(u.Host) == 0
+}
+
+// ServeResource applies the first matching redirect, or rewrite, to the
+// request. Redirects whose expanded location is not a path of the redirect's
+// origin, e.g. of a parameter's value starting with a slash, are responded
+// to with a 400 Bad Request response.
+func (h rewriteHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	for _, r := range h.redirects {
+		params, ok := r.from.matchPath(req.Path)
+		if !ok {
+			continue
+		}
+
+		target := r.toPattern.expand(escapePathParams(r.toPattern, params))
+		if !isLocalRedirect(target) {
+			return statusResponse(http.StatusBadRequest), nil
+		}
+
+		location := r.to + target + r.toQuery
+		if len(r.toQuery) == 0 {
+			if query := requestQuery(req).Encode(); len(query) != 0 {
+				location += "?" + query
+			}
+		}
+
+		resp = statusResponse(r.statusCode)
+		resp.HTTPHeader.Set("Location", location)
+		return resp, nil
+	}
+
+	for _, r := range h.rewrites {
+		params, ok := r.from.matchPath(req.Path)
+		if !ok {
+			continue
+		}
+
+		req.Path = r.to.expand(params)
+		req.Resource = r.to.resource
+		req.PathParameters = params
+		break
+	}
+
+	return h.Handler.ServeResource(ctx, req)
+}
@@ -0,0 +1,247 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RouteOption configures a route added to a router via its Handle method.
+type RouteOption func(*routeOptions)
+
+type routeOptions struct {
+	encodedSlash EncodedSlashPolicy
+	types        RouteTypes
+	transforms   []BodyTransformer
+	middleware   []Middleware
+	strictEvent  bool
+}
+
+func newRouteOptions(opts []RouteOption) routeOptions {
+	var o routeOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+	return o
+}
+
+// wrap returns the route's handler decorated with the route's options that
+// are applied by wrapping the handler.
+func (o routeOptions) wrap(handler ResourceHandler) ResourceHandler {
+	if len(o.transforms) != 0 {
+		handler = ResourceHandlerWithBodyTransforms(handler, o.transforms...)
+	}
+	if o.strictEvent {
+		handler = strictEventHandler{Handler: handler}
+	}
+	if o.types.Request != nil || o.types.Response != nil {
+		handler = routeTypesHandler{Types: o.types, Handler: handler}
+	}
+	return handler
+}
+
+// chain returns the route's handler decorated with the router's middleware,
+// and then the route's middleware, so router middleware are the outermost.
+// The middleware are applied separately from wrap, so the route's handler is
+// still found by Routes, and HandlerGraph, through function middleware.
+func (o routeOptions) chain(router []Middleware, handler ResourceHandler) ResourceHandler {
+	return Chain(router...)(Chain(o.middleware...)(handler))
+}
+
+// WithMiddleware returns a RouteOption applying the middleware to only the
+// route's handler, e.g. authentication of protected resources. Route
+// middleware are applied within the router's middleware, in the order they
+// are provided, with the first middleware the outermost.
+func WithMiddleware(middleware ...Middleware) RouteOption {
+	return func(o *routeOptions) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// EncodedSlashPolicy is the policy for percent-encoded slashes, "%2F", in
+// path parameter values when the values are decoded.
+type EncodedSlashPolicy int
+
+const (
+	// EncodedSlashKeep decodes path parameter values, but leaves encoded
+	// slashes encoded, so the value can be split on "/" safely. This is the
+	// default policy.
+	EncodedSlashKeep EncodedSlashPolicy = iota
+
+	// EncodedSlashDecode decodes encoded slashes along with all other
+	// percent-encoded characters.
+	EncodedSlashDecode
+
+	// EncodedSlashReject fails the request with a 400 Bad Request response
+	// if a path parameter value contains an encoded slash.
+	EncodedSlashReject
+)
+
+// WithEncodedSlash returns a RouteOption setting the route's policy for
+// percent-encoded slashes in path parameter values.
+func WithEncodedSlash(policy EncodedSlashPolicy) RouteOption {
+	return func(o *routeOptions) {
+		o.encodedSlash = policy
+	}
+}
+
+// decodePathParams returns a copy of the path parameters with the values
+// percent-decoded according to the policy.
+func decodePathParams(params map[string]string, policy EncodedSlashPolicy) (map[string]string, error) {
+	if len(params) == 0 {
+		return params, nil
+	}
+
+	decoded := make(map[string]string, len(params))
+	for k, v := range params {
+		d, err := decodePathParam(v, policy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path parameter %q, %w", k, err)
+		}
+		decoded[k] = d
+	}
+	return decoded, nil
+}
+
+func decodePathParam(v string, policy EncodedSlashPolicy) (string, error) {
+	if strings.IndexByte(v, '%') < 0 {
+		return v, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] != '%' {
+			b.WriteByte(v[i])
+			continue
+		}
+		if i+2 >= len(v) || !isHex(v[i+1]) || !isHex(v[i+2]) {
+			return "", fmt.Errorf("invalid percent-encoding %q", v)
+		}
+
+		c := unhex(v[i+1])<<4 | unhex(v[i+2])
+		if c == '/' {
+			switch policy {
+			case EncodedSlashReject:
+				return "", fmt.Errorf("encoded slash not allowed, %q", v)
+			case EncodedSlashKeep:
+				b.WriteString("%2F")
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(c)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// RouteTypes are the Go types a route declares for its request and response
+// bodies. The types are declared at registration so a single declaration is
+// used for the documentation of the route in its RouteTable entry, and the
+// decoding, and validation, of the route's requests.
+type RouteTypes struct {
+	Request  reflect.Type
+	Response reflect.Type
+}
+
+// WithRequestType returns a RouteOption declaring T, e.g. CreateUserInput,
+// the Go type of the route's request.
+//
+// Requests to the route are decoded into a new T, as Typed decodes its
+// input, from the request's body, and its fields tagged with the "path",
+// "query", or "header" struct tags, and validated by the TagValidator,
+// before the route's handler is served. Requests failing to decode, or
+// validate, are returned as StatusErrors, e.g. 400 Bad Request, or 422
+// Unprocessable Entity, without serving the handler. The decoded value is
+// available to the handler with RouteRequest, and is the input of Typed
+// handlers with an input of type T, or *T.
+func WithRequestType[T any]() RouteOption {
+	return func(o *routeOptions) {
+		o.types.Request = reflect.TypeOf((*T)(nil)).Elem()
+	}
+}
+
+// WithResponseType returns a RouteOption declaring T, e.g. User, the Go
+// type of the route's response body, documented by the route's RouteTable
+// entry.
+func WithResponseType[T any]() RouteOption {
+	return func(o *routeOptions) {
+		o.types.Response = reflect.TypeOf((*T)(nil)).Elem()
+	}
+}
+
+type routeTypesKey struct{}
+
+// RouteTypesFromContext returns the request and response types declared for
+// the route being served, if any were declared.
+func RouteTypesFromContext(ctx context.Context) (RouteTypes, bool) {
+	t, ok := ctx.Value(routeTypesKey{}).(RouteTypes)
+	return t, ok
+}
+
+// withRouteTypes returns a context with the route's declared types merged
+// with any types declared by parent routes.
+func withRouteTypes(ctx context.Context, types RouteTypes) context.Context {
+	if types.Request == nil && types.Response == nil {
+		return ctx
+	}
+
+	parent, _ := RouteTypesFromContext(ctx)
+	if types.Request == nil {
+		types.Request = parent.Request
+	}
+	if types.Response == nil {
+		types.Response = parent.Response
+	}
+	return context.WithValue(ctx, routeTypesKey{}, types)
+}
+
+type routeRequestKey struct{}
+
+// RouteRequest returns the request decoded into the type declared for the
+// route being served with WithRequestType, and true, or false if the route
+// did not declare the type T, or *T.
+//
+//	in, ok := lambdamux.RouteRequest[CreateUserInput](ctx)
+func RouteRequest[T any](ctx context.Context) (T, bool) {
+	var zero T
+	switch v := ctx.Value(routeRequestKey{}).(type) {
+	case *T:
+		return *v, true
+	case T:
+		return v, true
+	}
+	return zero, false
+}
+
+// routeTypesHandler decodes, and validates, the requests of routes
+// declaring their request type, before serving the route's handler. The
+// decorator's exported Types are documented by the route's RouteTable
+// entry.
+type routeTypesHandler struct {
+	Types   RouteTypes
+	Handler ResourceHandler
+}
+
+// ServeResource delegates to the wrapped handler, with the request decoded
+// into the route's request type.
+func (h routeTypesHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if h.Types.Request == nil {
+		return h.Handler.ServeResource(ctx, req)
+	}
+
+	t := h.Types.Request
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Interface()
+	if err := bindTypedInput(req, v); err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	ctx = context.WithValue(ctx, routeRequestKey{}, v)
+	return h.Handler.ServeResource(ctx, req)
+}
@@ -0,0 +1,187 @@
+package lambdamux
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDecodePathParam(t *testing.T) {
+	cases := map[string]struct {
+		value     string
+		policy    EncodedSlashPolicy
+		expect    string
+		expectErr bool
+	}{
+		"no escapes": {
+			value: "abc", policy: EncodedSlashKeep, expect: "abc",
+		},
+		"space": {
+			value: "a%20b", policy: EncodedSlashKeep, expect: "a b",
+		},
+		"keep slash": {
+			value: "a%2Fb", policy: EncodedSlashKeep, expect: "a%2Fb",
+		},
+		"keep lower case slash": {
+			value: "a%2fb", policy: EncodedSlashKeep, expect: "a%2Fb",
+		},
+		"decode slash": {
+			value: "a%2Fb", policy: EncodedSlashDecode, expect: "a/b",
+		},
+		"reject slash": {
+			value: "a%2Fb", policy: EncodedSlashReject, expectErr: true,
+		},
+		"keep double encoded slash": {
+			value: "a%252Fb", policy: EncodedSlashKeep, expect: "a%2Fb",
+		},
+		"decode double encoded slash": {
+			value: "a%252Fb", policy: EncodedSlashDecode, expect: "a%2Fb",
+		},
+		"reject double encoded slash not a slash": {
+			value: "a%252Fb", policy: EncodedSlashReject, expect: "a%2Fb",
+		},
+		"invalid hex keep": {
+			value: "a%zzb", policy: EncodedSlashKeep, expectErr: true,
+		},
+		"invalid hex decode": {
+			value: "a%zzb", policy: EncodedSlashDecode, expectErr: true,
+		},
+		"invalid hex reject": {
+			value: "a%zzb", policy: EncodedSlashReject, expectErr: true,
+		},
+		"truncated escape": {
+			value: "a%2", policy: EncodedSlashKeep, expectErr: true,
+		},
+		"trailing percent": {
+			value: "a%", policy: EncodedSlashDecode, expectErr: true,
+		},
+		"utf-8 two byte": {
+			value: "caf%C3%A9", policy: EncodedSlashKeep, expect: "café",
+		},
+		"utf-8 three byte": {
+			value: "%E2%82%AC100", policy: EncodedSlashDecode, expect: "€100",
+		},
+		"utf-8 four byte": {
+			value: "%F0%9F%98%80", policy: EncodedSlashReject, expect: "😀",
+		},
+		"utf-8 unescaped": {
+			value: "日本", policy: EncodedSlashKeep, expect: "日本",
+		},
+		"utf-8 with slash": {
+			value: "%E6%97%A5%2F%E6%9C%AC", policy: EncodedSlashKeep, expect: "日%2F本",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := decodePathParam(c.value, c.policy)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none, %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expect, actual; e != a {
+				t.Errorf("expect %q, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestDecodePathParams(t *testing.T) {
+	cases := map[string]struct {
+		params    map[string]string
+		policy    EncodedSlashPolicy
+		expect    map[string]string
+		expectErr bool
+	}{
+		"nil": {
+			params: nil, expect: nil,
+		},
+		"unicode keys": {
+			params: map[string]string{
+				"名前":    "%E5%A4%AA%E9%83%8E",
+				"città": "Roma",
+			},
+			expect: map[string]string{
+				"名前":    "太郎",
+				"città": "Roma",
+			},
+		},
+		"unicode key rejected slash": {
+			params:    map[string]string{"ключ": "a%2Fb"},
+			policy:    EncodedSlashReject,
+			expectErr: true,
+		},
+		"invalid value": {
+			params:    map[string]string{"id": "%G1"},
+			expectErr: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := decodePathParams(c.params, c.policy)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none, %v", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := len(c.expect), len(actual); e != a {
+				t.Fatalf("expect %v params, got %v, %v", e, a, actual)
+			}
+			for k, v := range c.expect {
+				if e, a := v, actual[k]; e != a {
+					t.Errorf("expect %q %q, got %q", k, e, a)
+				}
+			}
+		})
+	}
+}
+
+func TestServeResourceEncodedSlashPolicy(t *testing.T) {
+	cases := map[string]struct {
+		policy       EncodedSlashPolicy
+		expectStatus int
+		expectKey    string
+	}{
+		"keep":   {policy: EncodedSlashKeep, expectStatus: 204, expectKey: "a%2Fb"},
+		"decode": {policy: EncodedSlashDecode, expectStatus: 204, expectKey: "a/b"},
+		"reject": {policy: EncodedSlashReject, expectStatus: 400},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var key string
+			s := NewServeResource()
+			s.Handle("/objects/{key}", ResourceHandlerFunc(func(
+				ctx context.Context, req APIGatewayProxyRequest,
+			) (APIGatewayProxyResponse, error) {
+				key = req.PathParameters["key"]
+				return NoContent(), nil
+			}), WithEncodedSlash(c.policy))
+
+			var req APIGatewayProxyRequest
+			req.Resource = "/objects/{key}"
+			req.Path = "/objects/a%2Fb"
+			req.PathParameters = map[string]string{"key": "a%2Fb"}
+
+			resp, err := s.ServeResource(context.Background(), req)
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expectStatus, resp.StatusCode; e != a {
+				t.Fatalf("expect %v status, got %v", e, a)
+			}
+			if e, a := c.expectKey, key; e != a {
+				t.Errorf("expect %q key, got %q", e, a)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package lambdamux
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParamConverter validates and converts a raw path parameter value into a
+// typed value. Returning an error marks the value as not matching the
+// parameter's type, and the request fails routing.
+type ParamConverter func(value string) (interface{}, error)
+
+var paramTypes = struct {
+	sync.RWMutex
+	converters map[string]ParamConverter
+}{
+	converters: map[string]ParamConverter{
+		"string": convertString,
+		"int":    convertInt,
+		"uuid":   convertUUID,
+		"*":      convertString,
+	},
+}
+
+// RegisterParamType registers a custom path parameter type that can be used
+// in resource patterns, e.g. "/users/{id:userid}". Registering a type with
+// the name of an existing type replaces it.
+//
+// RegisterParamType is safe to call concurrently, but is expected to be called
+// during initialization before resource handlers are added.
+func RegisterParamType(name string, converter ParamConverter) {
+	paramTypes.Lock()
+	defer paramTypes.Unlock()
+
+	paramTypes.converters[name] = converter
+}
+
+func lookupParamType(name string) (ParamConverter, bool) {
+	paramTypes.RLock()
+	defer paramTypes.RUnlock()
+
+	c, ok := paramTypes.converters[name]
+	return c, ok
+}
+
+func convertString(v string) (interface{}, error) {
+	if len(v) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return v, nil
+}
+
+func convertInt(v string) (interface{}, error) {
+	return strconv.ParseInt(v, 10, 64)
+}
+
+var uuidPattern = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func convertUUID(v string) (interface{}, error) {
+	if !uuidPattern.MatchString(v) {
+		return nil, fmt.Errorf("invalid UUID, %q", v)
+	}
+	return strings.ToLower(v), nil
+}
+
+var paramRegexps = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{
+	compiled: map[string]*regexp.Regexp{},
+}
+
+// compileParamRegexp compiles the expression anchored to match the whole
+// parameter value. Compiled expressions are cached so patterns sharing an
+// expression share the compiled value.
+func compileParamRegexp(expr string) (*regexp.Regexp, error) {
+	paramRegexps.Lock()
+	defer paramRegexps.Unlock()
+
+	if re, ok := paramRegexps.compiled[expr]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return nil, err
+	}
+	paramRegexps.compiled[expr] = re
+
+	return re, nil
+}
+
+func regexpConverter(re *regexp.Regexp) ParamConverter {
+	return func(v string) (interface{}, error) {
+		if !re.MatchString(v) {
+			return nil, fmt.Errorf("value %q does not match %s", v, re)
+		}
+		return v, nil
+	}
+}
+
+// routePattern is a parsed resource pattern. Typed parameters, "{id:int}",
+// are stripped of their type so the pattern's resource matches the resource
+// API Gateway provides, "{id}". The greedy "{path:*}" parameter is
+// translated to API Gateway's "{path+}".
+type routePattern struct {
+	resource string
+	params   []routeParam
+	segments []patternSegment
+}
+
+type routeParam struct {
+	name    string
+	typ     string
+	convert ParamConverter
+}
+
+// patternSegment is a path segment of the pattern, either a literal value,
+// a parameter matching any value, or a "*" wildcard matching any value
+// without capturing it.
+type patternSegment struct {
+	literal  string
+	param    string
+	greedy   bool
+	wildcard bool
+}
+
+// parseRoutePattern parses the resource pattern, returning an error if the
+// pattern is malformed. Parameter types that are not registered are compiled
+// as regular expressions the whole value must match, e.g.
+// "/reports/{date:\d{4}-\d{2}-\d{2}}".
+func parseRoutePattern(pattern string) (routePattern, error) {
+	var p routePattern
+	var resource strings.Builder
+
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '{' {
+			resource.WriteByte(pattern[i])
+			continue
+		}
+
+		end := matchingBrace(pattern, i)
+		if end < 0 {
+			return p, fmt.Errorf("unterminated parameter in pattern %q", pattern)
+		}
+		if i > 0 && pattern[i-1] != '/' ||
+			end+1 < len(pattern) && pattern[end+1] != '/' {
+			return p, fmt.Errorf("parameter must be a full path segment in pattern %q", pattern)
+		}
+
+		name, typ := pattern[i+1:end], ""
+		if idx := strings.IndexByte(name, ':'); idx >= 0 {
+			name, typ = name[:idx], name[idx+1:]
+		}
+		greedy := strings.HasSuffix(name, "+") || typ == "*"
+		name = strings.TrimSuffix(name, "+")
+		if len(name) == 0 {
+			return p, fmt.Errorf("parameter name missing in pattern %q", pattern)
+		}
+
+		resource.WriteString("{" + name)
+		if greedy {
+			resource.WriteString("+")
+		}
+		resource.WriteString("}")
+		i = end
+
+		if len(typ) == 0 {
+			continue
+		}
+		convert, ok := lookupParamType(typ)
+		if !ok {
+			re, err := compileParamRegexp(typ)
+			if err != nil {
+				return p, fmt.Errorf("invalid parameter type %q in pattern %q, %w", typ, pattern, err)
+			}
+			convert = regexpConverter(re)
+		}
+		p.params = append(p.params, routeParam{
+			name: name, typ: typ, convert: convert,
+		})
+	}
+
+	p.resource = resource.String()
+	p.segments = parseSegments(p.resource)
+	return p, nil
+}
+
+// parseSegments splits the API Gateway resource into its path segments.
+func parseSegments(resource string) []patternSegment {
+	var segments []patternSegment
+	for _, s := range splitPath(resource) {
+		if len(s) > 2 && s[0] == '{' && s[len(s)-1] == '}' {
+			name := s[1 : len(s)-1]
+			segments = append(segments, patternSegment{
+				param:  strings.TrimSuffix(name, "+"),
+				greedy: strings.HasSuffix(name, "+"),
+			})
+			continue
+		}
+		if s == "*" {
+			segments = append(segments, patternSegment{wildcard: true})
+			continue
+		}
+		segments = append(segments, patternSegment{literal: s})
+	}
+	return segments
+}
+
+// splitPath splits the path into its segments, ignoring the leading slash.
+func splitPath(p string) []string {
+	p = strings.TrimPrefix(p, "/")
+	if len(p) == 0 {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// matchPath matches the concrete request path against the pattern,
+// returning the path parameter values captured. Greedy parameters capture
+// the remainder of the path, including slashes, and wildcards match any
+// segment without capturing it. Typed parameters must match their type.
+func (p routePattern) matchPath(path string) (map[string]string, bool) {
+	params, ok := p.capturePath(path)
+	if !ok {
+		return nil, false
+	}
+	return params, p.matchesTypes(params)
+}
+
+// capturePath matches the concrete request path against the pattern's
+// segments, as matchPath does, without checking the parameters' types,
+// returning the raw path parameter values captured.
+func (p routePattern) capturePath(path string) (map[string]string, bool) {
+	parts := splitPath(path)
+	params := map[string]string{}
+
+	for i, seg := range p.segments {
+		if seg.greedy {
+			if i >= len(parts) {
+				return nil, false
+			}
+			params[seg.param] = strings.Join(parts[i:], "/")
+			return params, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		if seg.wildcard {
+			if len(parts[i]) == 0 {
+				return nil, false
+			}
+			continue
+		}
+		if len(seg.param) == 0 {
+			if seg.literal != parts[i] {
+				return nil, false
+			}
+			continue
+		}
+		if len(parts[i]) == 0 {
+			return nil, false
+		}
+		params[seg.param] = parts[i]
+	}
+
+	if len(parts) != len(p.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// matchesTypes returns if the parameter values match the pattern's
+// parameter types.
+func (p routePattern) matchesTypes(params map[string]string) bool {
+	_, ok := p.convert(params)
+	return ok
+}
+
+// expand returns the pattern's resource with its parameters substituted by
+// the values provided.
+func (p routePattern) expand(params map[string]string) string {
+	var b strings.Builder
+	for _, seg := range p.segments {
+		b.WriteByte('/')
+		if seg.wildcard {
+			b.WriteByte('*')
+		} else if len(seg.param) == 0 {
+			b.WriteString(seg.literal)
+		} else {
+			b.WriteString(params[seg.param])
+		}
+	}
+	if b.Len() == 0 {
+		return "/"
+	}
+	return b.String()
+}
+
+// shape returns the pattern's segments, and parameter types, without the
+// parameters' names, so patterns matching the same paths, e.g.
+// "/users/{id}", and "/users/{userId}", have the same shape.
+func (p routePattern) shape() string {
+	types := make(map[string]string, len(p.params))
+	for _, param := range p.params {
+		if param.typ != "*" {
+			types[param.name] = param.typ
+		}
+	}
+
+	var b strings.Builder
+	for _, seg := range p.segments {
+		b.WriteByte('/')
+		switch {
+		case seg.wildcard:
+			b.WriteByte('*')
+		case len(seg.param) == 0:
+			b.WriteString(seg.literal)
+		default:
+			b.WriteByte('{')
+			if seg.greedy {
+				b.WriteByte('+')
+			}
+			b.WriteString(":" + types[seg.param] + "}")
+		}
+	}
+	return b.String()
+}
+
+// matchingBrace returns the index of the brace closing the one opened at
+// start, or -1 if there is none.
+func matchingBrace(s string, start int) int {
+	var depth int
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// convert converts the typed parameters of the pattern from the path
+// parameters. Returns false if any of the values do not match their type.
+func (p routePattern) convert(pathParams map[string]string) (map[string]interface{}, bool) {
+	if len(p.params) == 0 {
+		return nil, true
+	}
+
+	values := make(map[string]interface{}, len(p.params))
+	for _, param := range p.params {
+		v, err := param.convert(pathParams[param.name])
+		if err != nil {
+			return nil, false
+		}
+		values[param.name] = v
+	}
+	return values, true
+}
@@ -0,0 +1,194 @@
+package lambdamux
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// RouteEntry is a route of a handler tree, identified by its resource, HTTP
+// method, and query constraint. Fields of routes not filtered by a router of
+// the kind are empty, e.g. the Method of a resource served by a handler
+// without a ServeMethod. The request, and response, types declared for the
+// route, with WithRequestType, and WithResponseType, document the route's
+// bodies, e.g. "users.CreateUserInput".
+type RouteEntry struct {
+	Resource string `json:"resource,omitempty"`
+	Method   string `json:"method,omitempty"`
+	Query    string `json:"query,omitempty"`
+
+	RequestType  string `json:"requestType,omitempty"`
+	ResponseType string `json:"responseType,omitempty"`
+}
+
+// String returns the route formatted as "METHOD /resource?query".
+func (e RouteEntry) String() string {
+	var b strings.Builder
+	if len(e.Method) != 0 {
+		b.WriteString(e.Method + " ")
+	}
+	b.WriteString(e.Resource)
+	if len(e.Query) != 0 {
+		b.WriteString("?" + e.Query)
+	}
+	return b.String()
+}
+
+func (e RouteEntry) less(o RouteEntry) bool {
+	if e.Resource != o.Resource {
+		return e.Resource < o.Resource
+	}
+	if e.Method != o.Method {
+		return e.Method < o.Method
+	}
+	return e.Query < o.Query
+}
+
+// RouteTable is a read-only snapshot of the routes of a handler tree,
+// sorted by resource, method, and query. The table can be serialized, e.g.
+// as JSON, and compared with the table of another release with Diff.
+type RouteTable []RouteEntry
+
+// Snapshot returns the RouteTable of the router's current handler tree.
+func (r *Router) Snapshot() RouteTable {
+	return Routes(r.Handler())
+}
+
+// Routes returns the RouteTable of the handler tree. The tree is walked
+// through the ServeResource, ServePath, ServeMethod, ServeQuery, and Router
+// handlers of the tree, and the decorators wrapping them. Decorators are
+// unwrapped by their exported Handler field.
+func Routes(handler ResourceHandler) RouteTable {
+	var table RouteTable
+	walkRoutes(handler, RouteEntry{}, func(e RouteEntry, _ ResourceHandler) {
+		table = append(table, e)
+	})
+	sort.SliceStable(table, func(i, j int) bool {
+		return table[i].less(table[j])
+	})
+	return table
+}
+
+// walkRoutes calls fn with each route of the handler tree, and the route's
+// handler, after all routers and decorators are unwrapped.
+func walkRoutes(handler ResourceHandler, route RouteEntry, fn func(RouteEntry, ResourceHandler)) {
+	switch h := handler.(type) {
+	case *Router:
+		walkRoutes(h.Handler(), route, fn)
+
+	case *ServeResource:
+		resources := make([]string, 0, len(h.resources))
+		for k := range h.resources {
+			resources = append(resources, k)
+		}
+		sort.Strings(resources)
+		for _, k := range resources {
+			r := route
+			r.Resource = k
+			walkRoutes(h.resources[k].handler, r, fn)
+		}
+
+	case *ServeMethod:
+		methods := make([]string, 0, len(h.methods))
+		for k := range h.methods {
+			methods = append(methods, k)
+		}
+		sort.Strings(methods)
+		for _, k := range methods {
+			r := route
+			r.Method = k
+			walkRoutes(h.methods[k].handler, r, fn)
+		}
+
+	case *ServePath:
+		for _, p := range h.routes {
+			r := route
+			r.Resource = p.template
+			walkRoutes(p.handler, r, fn)
+		}
+
+	case routeTypesHandler:
+		r := route
+		if h.Types.Request != nil {
+			r.RequestType = h.Types.Request.String()
+		}
+		if h.Types.Response != nil {
+			r.ResponseType = h.Types.Response.String()
+		}
+		walkRoutes(h.Handler, r, fn)
+
+	case *ServeQuery:
+		for _, q := range h.routes {
+			r := route
+			r.Query = q.query
+			walkRoutes(q.handler, r, fn)
+		}
+
+	default:
+		if inner := unwrapHandler(handler); inner != nil {
+			walkRoutes(inner, route, fn)
+			return
+		}
+		fn(route, handler)
+	}
+}
+
+var resourceHandlerType = reflect.TypeOf((*ResourceHandler)(nil)).Elem()
+
+// unwrapHandler returns the handler wrapped by the decorator, the value of
+// its exported Handler field, or nil if the handler is not a decorator.
+func unwrapHandler(handler ResourceHandler) ResourceHandler {
+	v := reflect.ValueOf(handler)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f, ok := v.Type().FieldByName("Handler")
+	if !ok || f.PkgPath != "" || f.Type != resourceHandlerType {
+		return nil
+	}
+	inner, _ := v.FieldByIndex(f.Index).Interface().(ResourceHandler)
+	return inner
+}
+
+// RouteTableDiff is the difference between two route tables.
+type RouteTableDiff struct {
+	Added   RouteTable `json:"added,omitempty"`
+	Removed RouteTable `json:"removed,omitempty"`
+}
+
+// Empty returns if the route tables are the same.
+func (d RouteTableDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// Diff returns the routes of b added to, and removed from, a.
+func Diff(a, b RouteTable) RouteTableDiff {
+	inA := make(map[RouteEntry]struct{}, len(a))
+	for _, e := range a {
+		inA[e] = struct{}{}
+	}
+	inB := make(map[RouteEntry]struct{}, len(b))
+	for _, e := range b {
+		inB[e] = struct{}{}
+	}
+
+	var d RouteTableDiff
+	for _, e := range b {
+		if _, ok := inA[e]; !ok {
+			d.Added = append(d.Added, e)
+		}
+	}
+	for _, e := range a {
+		if _, ok := inB[e]; !ok {
+			d.Removed = append(d.Removed, e)
+		}
+	}
+	return d
+}
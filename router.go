@@ -0,0 +1,57 @@
+package lambdamux
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Router is an API Gateway Proxy resource handler delegating requests to a
+// handler tree that can be replaced at runtime, e.g. re-routing after
+// polling an AppConfig configuration within a warm Lambda container.
+//
+// The Router is safe for concurrent use. Requests already being served
+// complete with the handler tree they started with.
+type Router struct {
+	handler atomic.Value
+
+	// Serializes swaps, so the previous handler tree returned is exact.
+	swapMu sync.Mutex
+}
+
+// routerTree wraps the handler so the atomic value always stores the same
+// concrete type.
+type routerTree struct {
+	handler ResourceHandler
+}
+
+// NewRouter initializes and returns a Router delegating to the handler.
+func NewRouter(handler ResourceHandler) *Router {
+	r := &Router{}
+	r.handler.Store(routerTree{handler: handler})
+	return r
+}
+
+// ServeResource implements the ResourceHandler interface, delegating to the
+// router's current handler tree.
+func (r *Router) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	return r.Handler().ServeResource(ctx, req)
+}
+
+// Handler returns the router's current handler tree.
+func (r *Router) Handler() ResourceHandler {
+	return r.handler.Load().(routerTree).handler
+}
+
+// Swap atomically replaces the router's handler tree, returning the
+// previous handler tree.
+func (r *Router) Swap(handler ResourceHandler) ResourceHandler {
+	r.swapMu.Lock()
+	defer r.swapMu.Unlock()
+
+	old := r.Handler()
+	r.handler.Store(routerTree{handler: handler})
+	return old
+}
@@ -0,0 +1,210 @@
+package lambdamux
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RoutingKey is a stable key of requests composed of request attributes,
+// e.g. the request's tenant, and user, for sharding, sticky routing, and
+// per key features. Features keyed by the same RoutingKey, e.g. the Split
+// handler, and a Quota keyed by the RoutingKey's Key method, hash requests
+// identically.
+//
+//	key := lambdamux.RoutingKey{
+//		lambdamux.ClaimDimension("custom:tenant"),
+//		lambdamux.ClaimDimension("sub"),
+//	}
+type RoutingKey []DimensionFunc
+
+// Key returns the key of the request, the path escaped values of the key's
+// attributes joined by "/", or empty if all the values are empty. Key is a
+// DimensionFunc, so features keyed by a DimensionFunc, e.g. Quota, can be
+// keyed by the RoutingKey.
+func (k RoutingKey) Key(ctx context.Context, req APIGatewayProxyRequest) string {
+	values := make([]string, len(k))
+	var found bool
+	for i, fn := range k {
+		values[i] = url.PathEscape(fn(ctx, req))
+		found = found || len(values[i]) != 0
+	}
+	if !found {
+		return ""
+	}
+	return strings.Join(values, "/")
+}
+
+// Hash returns the hash of the request's key, HashKey, or false if the
+// request has no key.
+func (k RoutingKey) Hash(ctx context.Context, req APIGatewayProxyRequest) (uint64, bool) {
+	key := k.Key(ctx, req)
+	if len(key) == 0 {
+		return 0, false
+	}
+	return HashKey(key), true
+}
+
+// Bucket returns the bucket, of the number of buckets, of the request's
+// key, JumpHash, or false if the request has no key.
+func (k RoutingKey) Bucket(ctx context.Context, req APIGatewayProxyRequest, buckets int) (int, bool) {
+	h, ok := k.Hash(ctx, req)
+	if !ok {
+		return 0, false
+	}
+	return JumpHash(h, buckets), true
+}
+
+// HashKey returns the 64-bit FNV-1a hash of the key. The hash is stable
+// across Lambda containers, and releases.
+func HashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// JumpHash returns the bucket, of the number of buckets, of the hash with
+// Lamping, and Veach's jump consistent hash, so changing the number of
+// buckets from n to n+1 only moves 1/(n+1) of the keys. Returns 0 if
+// buckets is less than 1.
+func JumpHash(hash uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		hash = hash*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((hash>>33)+1)))
+	}
+	if b < 0 {
+		return 0
+	}
+	return int(b)
+}
+
+type routingKeyContextKey struct{}
+
+// RoutingKeyFromContext returns the routing key, and its hash, of the
+// request set by the routing key middleware, or false if the request is not
+// served by the middleware, or has no key.
+func RoutingKeyFromContext(ctx context.Context) (string, uint64, bool) {
+	key, _ := ctx.Value(routingKeyContextKey{}).(string)
+	if len(key) == 0 {
+		return "", 0, false
+	}
+	return key, HashKey(key), true
+}
+
+type routingKeyHandler struct {
+	Key     RoutingKey
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithRoutingKey provides a resource handler passing the
+// routing key of requests to handler via the context, so handlers, e.g. of
+// sharded data, use the same key as the other features keyed by it.
+func ResourceHandlerWithRoutingKey(key RoutingKey, handler ResourceHandler) ResourceHandler {
+	return routingKeyHandler{
+		Key:     key,
+		Handler: handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, with the request's
+// routing key in the context.
+func (h routingKeyHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if key := h.Key.Key(ctx, req); len(key) != 0 {
+		ctx = context.WithValue(ctx, routingKeyContextKey{}, key)
+	}
+	return h.Handler.ServeResource(ctx, req)
+}
+
+// SplitVariant is a variant of a Split, served for its weight's share of
+// routing keys.
+type SplitVariant struct {
+	Name    string
+	Weight  int
+	Handler ResourceHandler
+}
+
+// Split is a resource handler splitting requests between variants, e.g. of
+// an A/B test, by the hash of the request's routing key, so the requests of
+// a key are always served by the same variant, while the variants' weights
+// are unchanged. Requests without a key are served by the first variant.
+//
+// The name of the variant serving the request is passed to its handler via
+// the context, and set as the response's "X-Split-Variant" header.
+type Split struct {
+	Key      RoutingKey
+	Variants []SplitVariant
+}
+
+type splitVariantKey struct{}
+
+// SplitVariantFromContext returns the name of the Split variant serving the
+// request, or empty string if the request is not served by a Split.
+func SplitVariantFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(splitVariantKey{}).(string)
+	return v
+}
+
+// SplitVariantDimension is a DimensionFunc extracting the Split variant
+// serving the request, so the metrics of the variants are compared.
+func SplitVariantDimension(ctx context.Context, req APIGatewayProxyRequest) string {
+	return SplitVariantFromContext(ctx)
+}
+
+// ServeResource implements the ResourceHandler interface, delegating to
+// the handler of the variant of the request's routing key.
+func (s Split) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	if len(s.Variants) == 0 {
+		return serveNotFound(ctx, nil, req)
+	}
+
+	variant := s.Variants[0]
+	if h, ok := s.Key.Hash(ctx, req); ok {
+		variant = s.variant(h)
+	}
+
+	ctx = context.WithValue(ctx, splitVariantKey{}, variant.Name)
+	resp, err := variant.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	resp.HTTPHeader.Set("X-Split-Variant", variant.Name)
+	return resp, nil
+}
+
+// variant returns the variant whose cumulative weight range the hash falls
+// in.
+func (s Split) variant(hash uint64) SplitVariant {
+	var total uint64
+	for _, v := range s.Variants {
+		if v.Weight > 0 {
+			total += uint64(v.Weight)
+		}
+	}
+	if total == 0 {
+		return s.Variants[0]
+	}
+
+	n := hash % total
+	for _, v := range s.Variants {
+		if v.Weight <= 0 {
+			continue
+		}
+		if n < uint64(v.Weight) {
+			return v
+		}
+		n -= uint64(v.Weight)
+	}
+	return s.Variants[len(s.Variants)-1]
+}
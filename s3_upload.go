@@ -0,0 +1,300 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3CompletedPart is a part of a multipart upload uploaded by the client.
+type S3CompletedPart struct {
+	PartNumber int    `json:"partNumber"`
+	ETag       string `json:"etag"`
+}
+
+// S3MultipartAPI is the interface for the S3 multipart upload operations used
+// by S3Uploads. Implemented by the application with the AWS SDK, e.g. the
+// S3 client's CreateMultipartUpload, CompleteMultipartUpload, and
+// AbortMultipartUpload operations, and the presign client's
+// PresignUploadPart.
+type S3MultipartAPI interface {
+	CreateMultipartUpload(ctx context.Context, bucket, key, contentType string) (uploadID string, err error)
+	PresignUploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, expires time.Duration) (url string, err error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []S3CompletedPart) error
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+}
+
+// S3Uploads provides routes for clients to upload large objects directly to
+// S3 with multipart uploads, without the object passing through API Gateway,
+// and Lambda, payload limits. The function initiates, and completes, the
+// upload, and the client uploads the parts with presigned part URLs.
+//
+// The routes added by Register, relative to the prefix, are:
+//
+//	POST   {prefix}                    initiate an upload
+//	POST   {prefix}/{upload}/parts     presign part URLs
+//	POST   {prefix}/{upload}/complete  complete an upload
+//	DELETE {prefix}/{upload}           abort an upload
+//
+// Initiating an upload takes a JSON document with the object's filename,
+// content type, and number of parts, and responds with the upload's token,
+// and presigned URLs of the parts:
+//
+//	{"filename": "video.mp4", "contentType": "video/mp4", "parts": 3}
+//
+//	{"upload": "...", "key": "uploads/video.mp4", "parts": [{"partNumber": 1, "url": "..."}]}
+//
+// The client PUTs each part to its URL, and completes the upload with the
+// part numbers, and ETag response headers, of the uploaded parts:
+//
+//	{"parts": [{"partNumber": 1, "etag": "\"...\""}]}
+//
+// Presigned part URLs that expired are refreshed by requesting the part
+// numbers, {"partNumbers": [2, 3]}. The upload token identifies the object
+// key, and S3 upload ID, and is signed with the Secret, so clients can only
+// complete, or abort, uploads they initiated.
+type S3Uploads struct {
+	Client S3MultipartAPI
+	Bucket string
+
+	// Secret signs the upload tokens.
+	Secret []byte
+
+	// Key returns the object key of the upload of the filename. The
+	// filename is provided by the client, and must be sanitized.
+	Key func(ctx context.Context, req APIGatewayProxyRequest, filename string) (string, error)
+
+	// PartURLExpires is the time presigned part URLs are valid for.
+	// Defaults to 1 hour.
+	PartURLExpires time.Duration
+
+	// MaxParts is the maximum number of parts of an upload. Defaults to
+	// 10000, the S3 limit.
+	MaxParts int
+}
+
+// Register adds the upload routes to s with the resource prefix, e.g.
+// "/uploads", returning s.
+func (u *S3Uploads) Register(s *ServeResource, prefix string) *ServeResource {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return s.
+		Handle(prefix, NewServeMethod().
+			Handle(http.MethodPost, ResourceHandlerFunc(u.Initiate))).
+		Handle(prefix+"/{upload}/parts", NewServeMethod().
+			Handle(http.MethodPost, ResourceHandlerFunc(u.PresignParts))).
+		Handle(prefix+"/{upload}/complete", NewServeMethod().
+			Handle(http.MethodPost, ResourceHandlerFunc(u.Complete))).
+		Handle(prefix+"/{upload}", NewServeMethod().
+			Handle(http.MethodDelete, ResourceHandlerFunc(u.Abort)))
+}
+
+type s3Upload struct {
+	Key      string `json:"k"`
+	UploadID string `json:"u"`
+}
+
+type s3PartURL struct {
+	PartNumber int    `json:"partNumber"`
+	URL        string `json:"url"`
+}
+
+// Initiate initiates a multipart upload, responding with the upload's token,
+// and presigned part URLs.
+func (u *S3Uploads) Initiate(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	var input struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"contentType"`
+		Parts       int    `json:"parts"`
+	}
+	if err := decodeRequestJSON(req, &input); err != nil || len(input.Filename) == 0 {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	if input.Parts < 1 || input.Parts > u.maxParts() {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	if len(input.ContentType) == 0 {
+		input.ContentType = "application/octet-stream"
+	}
+
+	key, err := u.Key(ctx, req, input.Filename)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to get upload key, %w", err)
+	}
+
+	uploadID, err := u.Client.CreateMultipartUpload(ctx, u.Bucket, key, input.ContentType)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to create multipart upload, %w", err)
+	}
+	upload := s3Upload{Key: key, UploadID: uploadID}
+
+	partNumbers := make([]int, input.Parts)
+	for i := range partNumbers {
+		partNumbers[i] = i + 1
+	}
+	parts, err := u.presign(ctx, upload, partNumbers)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	token, err := u.token(upload)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	return JSON(http.StatusCreated, map[string]interface{}{
+		"upload": token,
+		"key":    key,
+		"parts":  parts,
+	})
+}
+
+// PresignParts responds with presigned URLs of the upload's requested part
+// numbers.
+func (u *S3Uploads) PresignParts(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	upload, ok := u.parseToken(req.PathParameters["upload"])
+	if !ok {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	var input struct {
+		PartNumbers []int `json:"partNumbers"`
+	}
+	if err := decodeRequestJSON(req, &input); err != nil || len(input.PartNumbers) == 0 {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	for _, n := range input.PartNumbers {
+		if n < 1 || n > u.maxParts() {
+			return statusResponse(http.StatusBadRequest), nil
+		}
+	}
+
+	parts, err := u.presign(ctx, upload, input.PartNumbers)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	return JSON(http.StatusOK, map[string]interface{}{
+		"parts": parts,
+	})
+}
+
+// Complete completes the upload with the uploaded parts.
+func (u *S3Uploads) Complete(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	upload, ok := u.parseToken(req.PathParameters["upload"])
+	if !ok {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	var input struct {
+		Parts []S3CompletedPart `json:"parts"`
+	}
+	if err := decodeRequestJSON(req, &input); err != nil || len(input.Parts) == 0 {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	for _, p := range input.Parts {
+		if p.PartNumber < 1 || p.PartNumber > u.maxParts() || len(p.ETag) == 0 {
+			return statusResponse(http.StatusBadRequest), nil
+		}
+	}
+	sort.Slice(input.Parts, func(i, j int) bool {
+		return input.Parts[i].PartNumber < input.Parts[j].PartNumber
+	})
+
+	if err := u.Client.CompleteMultipartUpload(ctx, u.Bucket, upload.Key, upload.UploadID, input.Parts); err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to complete multipart upload, %w", err)
+	}
+
+	return JSON(http.StatusOK, map[string]interface{}{
+		"key": upload.Key,
+	})
+}
+
+// Abort aborts the upload, deleting the uploaded parts.
+func (u *S3Uploads) Abort(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	upload, ok := u.parseToken(req.PathParameters["upload"])
+	if !ok {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	if err := u.Client.AbortMultipartUpload(ctx, u.Bucket, upload.Key, upload.UploadID); err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to abort multipart upload, %w", err)
+	}
+
+	return NoContent(), nil
+}
+
+func (u *S3Uploads) presign(ctx context.Context, upload s3Upload, partNumbers []int) ([]s3PartURL, error) {
+	expires := u.PartURLExpires
+	if expires == 0 {
+		expires = time.Hour
+	}
+
+	parts := make([]s3PartURL, 0, len(partNumbers))
+	for _, n := range partNumbers {
+		url, err := u.Client.PresignUploadPart(ctx, u.Bucket, upload.Key, upload.UploadID, n, expires)
+		if err != nil {
+			return nil, fmt.Errorf("failed to presign upload part %d, %w", n, err)
+		}
+		parts = append(parts, s3PartURL{PartNumber: n, URL: url})
+	}
+	return parts, nil
+}
+
+func (u *S3Uploads) maxParts() int {
+	if u.MaxParts <= 0 || u.MaxParts > 10000 {
+		return 10000
+	}
+	return u.MaxParts
+}
+
+func (u *S3Uploads) token(upload s3Upload) (string, error) {
+	b, err := json.Marshal(upload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal upload token, %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + u.sign(payload), nil
+}
+
+func (u *S3Uploads) parseToken(token string) (s3Upload, bool) {
+	var upload s3Upload
+
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return upload, false
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(u.sign(payload))) {
+		return upload, false
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return upload, false
+	}
+	if err := json.Unmarshal(b, &upload); err != nil {
+		return upload, false
+	}
+	return upload, true
+}
+
+func (u *S3Uploads) sign(payload string) string {
+	mac := hmac.New(sha256.New, u.Secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func decodeRequestJSON(req APIGatewayProxyRequest, v interface{}) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
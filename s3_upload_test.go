@@ -0,0 +1,212 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockS3Multipart struct {
+	completed []S3CompletedPart
+	aborted   string
+}
+
+func (m *mockS3Multipart) CreateMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	return "upload-id", nil
+}
+
+func (m *mockS3Multipart) PresignUploadPart(
+	ctx context.Context, bucket, key, uploadID string, partNumber int, expires time.Duration,
+) (string, error) {
+	return fmt.Sprintf("https://%s/%s?uploadId=%s&partNumber=%d", bucket, key, uploadID, partNumber), nil
+}
+
+func (m *mockS3Multipart) CompleteMultipartUpload(
+	ctx context.Context, bucket, key, uploadID string, parts []S3CompletedPart,
+) error {
+	m.completed = parts
+	return nil
+}
+
+func (m *mockS3Multipart) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	m.aborted = key + " " + uploadID
+	return nil
+}
+
+func newTestS3Uploads(client S3MultipartAPI) *S3Uploads {
+	return &S3Uploads{
+		Client: client,
+		Bucket: "bucket",
+		Secret: []byte("secret"),
+		Key: func(ctx context.Context, req APIGatewayProxyRequest, filename string) (string, error) {
+			return "uploads/" + filename, nil
+		},
+	}
+}
+
+func TestS3UploadsToken(t *testing.T) {
+	u := newTestS3Uploads(&mockS3Multipart{})
+	upload := s3Upload{Key: "uploads/a.txt", UploadID: "upload-id"}
+
+	token, err := u.token(upload)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	i := strings.LastIndexByte(token, '.')
+	payload, sig := token[:i], token[i+1:]
+
+	other := newTestS3Uploads(&mockS3Multipart{})
+	other.Secret = []byte("other")
+	otherToken, err := other.token(upload)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	forged, err := u.token(s3Upload{Key: "uploads/b.txt", UploadID: "upload-id"})
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	forgedPayload := forged[:strings.LastIndexByte(forged, '.')]
+
+	cases := map[string]struct {
+		token  string
+		expect bool
+	}{
+		"valid":              {token: token, expect: true},
+		"other secret":       {token: otherToken},
+		"payload swapped":    {token: forgedPayload + "." + sig},
+		"signature modified": {token: payload + "." + sig[1:]},
+		"no signature":       {token: payload},
+		"empty signature":    {token: payload + "."},
+		"invalid payload":    {token: "!!!." + u.sign("!!!")},
+		"payload not json":   {token: "bm90IGpzb24." + u.sign("bm90IGpzb24")},
+		"empty":              {},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, ok := u.parseToken(c.token)
+			if e, a := c.expect, ok; e != a {
+				t.Fatalf("expect %v valid, got %v", e, a)
+			}
+			if !c.expect {
+				return
+			}
+			if e, a := upload, actual; e != a {
+				t.Errorf("expect %v upload, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestS3Uploads(t *testing.T) {
+	client := &mockS3Multipart{}
+	u := newTestS3Uploads(client)
+	s := u.Register(NewServeResource(), "/uploads/")
+
+	serve := func(method, resource, upload, body string) APIGatewayProxyResponse {
+		t.Helper()
+		var req APIGatewayProxyRequest
+		req.HTTPMethod = method
+		req.Resource = resource
+		req.PathParameters = map[string]string{"upload": upload}
+		req.Body = body
+
+		resp, err := s.ServeResource(context.Background(), req)
+		if err != nil {
+			t.Fatalf("expect no error, got %v", err)
+		}
+		return resp
+	}
+
+	resp := serve("POST", "/uploads", "", `{"filename":"a.txt","parts":2}`)
+	if e, a := http.StatusCreated, resp.StatusCode; e != a {
+		t.Fatalf("expect %v status, got %v, %s", e, a, resp.Body)
+	}
+	if e, a := "application/json", resp.HTTPHeader.Get("Content-Type"); e != a {
+		t.Errorf("expect %q content type, got %q", e, a)
+	}
+	var initiated struct {
+		Upload string      `json:"upload"`
+		Key    string      `json:"key"`
+		Parts  []s3PartURL `json:"parts"`
+	}
+	if err := json.Unmarshal([]byte(resp.Body), &initiated); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if e, a := "uploads/a.txt", initiated.Key; e != a {
+		t.Errorf("expect %q key, got %q", e, a)
+	}
+	if e, a := 2, len(initiated.Parts); e != a {
+		t.Errorf("expect %v parts, got %v", e, a)
+	}
+
+	cases := map[string]struct {
+		method, resource string
+		upload, body     string
+		expectStatus     int
+	}{
+		"presign": {
+			method: "POST", resource: "/uploads/{upload}/parts",
+			upload: initiated.Upload, body: `{"partNumbers":[2]}`,
+			expectStatus: http.StatusOK,
+		},
+		"presign invalid part": {
+			method: "POST", resource: "/uploads/{upload}/parts",
+			upload: initiated.Upload, body: `{"partNumbers":[10001]}`,
+			expectStatus: http.StatusBadRequest,
+		},
+		"presign unknown upload": {
+			method: "POST", resource: "/uploads/{upload}/parts",
+			upload: "unknown.upload", body: `{"partNumbers":[1]}`,
+			expectStatus: http.StatusNotFound,
+		},
+		"complete missing etag": {
+			method: "POST", resource: "/uploads/{upload}/complete",
+			upload: initiated.Upload, body: `{"parts":[{"partNumber":1}]}`,
+			expectStatus: http.StatusBadRequest,
+		},
+		"abort unknown upload": {
+			method: "DELETE", resource: "/uploads/{upload}",
+			upload: initiated.Upload + "x", body: "",
+			expectStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			resp := serve(c.method, c.resource, c.upload, c.body)
+			if e, a := c.expectStatus, resp.StatusCode; e != a {
+				t.Errorf("expect %v status, got %v, %s", e, a, resp.Body)
+			}
+		})
+	}
+
+	resp = serve("POST", "/uploads/{upload}/complete", initiated.Upload,
+		`{"parts":[{"partNumber":2,"etag":"b"},{"partNumber":1,"etag":"a"}]}`)
+	if e, a := http.StatusOK, resp.StatusCode; e != a {
+		t.Fatalf("expect %v status, got %v, %s", e, a, resp.Body)
+	}
+	expectParts := []S3CompletedPart{{PartNumber: 1, ETag: "a"}, {PartNumber: 2, ETag: "b"}}
+	if e, a := fmt.Sprint(expectParts), fmt.Sprint(client.completed); e != a {
+		t.Errorf("expect %v parts completed, got %v", e, a)
+	}
+
+	resp = serve("DELETE", "/uploads/{upload}", initiated.Upload, "")
+	if e, a := http.StatusNoContent, resp.StatusCode; e != a {
+		t.Fatalf("expect %v status, got %v", e, a)
+	}
+	if len(resp.Body) != 0 {
+		t.Errorf("expect no body, got %q", resp.Body)
+	}
+	if v := resp.HTTPHeader.Get("Content-Type"); len(v) != 0 {
+		t.Errorf("expect no content type, got %q", v)
+	}
+	if e, a := "uploads/a.txt upload-id", client.aborted; e != a {
+		t.Errorf("expect %q aborted, got %q", e, a)
+	}
+}
@@ -0,0 +1,201 @@
+// Package scaffold generates the skeleton of a lambdamux Lambda function
+// project, and the SAM template deploying a handler's routes, as used by
+// the "lambdamux new" command.
+//
+// The generated project has a main.go starting the function with
+// lambdamux.Start, a routes.go with the function's route table, an example
+// handler, tests using the lambdamuxtest package, and a SAM template. The
+// template is regenerated from the route table with:
+//
+//	go run . -template > template.yaml
+package scaffold
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// Project is the configuration of a generated project.
+type Project struct {
+	// Dir is the directory the project is generated in. The directory is
+	// created if it does not exist, and must be empty if it does.
+	Dir string
+
+	// Module is the Go module path of the project. Defaults to the base name
+	// of Dir.
+	Module string
+
+	// Function is the logical ID of the project's Lambda function in its
+	// SAM template. Defaults to the base name of Dir, in CamelCase, suffixed
+	// with "Function", e.g. "OrdersApiFunction" for "orders-api".
+	Function string
+}
+
+// New generates the project's skeleton in the project's directory,
+// returning the names of the files generated. Returns an error if the
+// directory is not empty.
+func New(p Project) ([]string, error) {
+	if len(p.Dir) == 0 {
+		return nil, fmt.Errorf("project directory not set")
+	}
+	name := filepath.Base(filepath.Clean(p.Dir))
+	if len(p.Module) == 0 {
+		p.Module = name
+	}
+	if len(p.Function) == 0 {
+		p.Function = logicalID(name) + "Function"
+	}
+	if logicalID(p.Function) != p.Function {
+		return nil, fmt.Errorf("invalid function logical ID %q, must be alphanumeric", p.Function)
+	}
+
+	if entries, err := ioutil.ReadDir(p.Dir); err == nil && len(entries) != 0 {
+		return nil, fmt.Errorf("project directory %s is not empty", p.Dir)
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read project directory %s, %w", p.Dir, err)
+	}
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create project directory %s, %w", p.Dir, err)
+	}
+
+	// The template is generated from the example's routes, as
+	// "go run . -template" would.
+	files := map[string][]byte{
+		"template.yaml": SAMTemplate(p.Function, lambdamux.Routes(exampleRoutes())),
+	}
+	for _, f := range projectFiles {
+		var b bytes.Buffer
+		if err := f.template.Execute(&b, p); err != nil {
+			return nil, fmt.Errorf("failed to generate %s, %w", f.name, err)
+		}
+		content := b.Bytes()
+		if strings.HasSuffix(f.name, ".go") {
+			formatted, err := format.Source(content)
+			if err != nil {
+				return nil, fmt.Errorf("failed to format %s, %w", f.name, err)
+			}
+			content = formatted
+		}
+		files[f.name] = content
+	}
+
+	names := make([]string, 0, len(files))
+	for _, f := range projectFiles {
+		names = append(names, f.name)
+	}
+	names = append(names, "template.yaml")
+
+	for _, name := range names {
+		if err := ioutil.WriteFile(filepath.Join(p.Dir, name), files[name], 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s, %w", name, err)
+		}
+	}
+	return names, nil
+}
+
+// SAMTemplate returns the AWS SAM template of a Lambda function, with the
+// logical ID, serving the routes of the table with API Gateway REST API
+// events. Routes without a method are served for ANY method, and routes
+// without a resource, e.g. of a handler not routing by resource, are served
+// for all paths. Routes differing only by their query constraint are served
+// by the same event.
+func SAMTemplate(function string, table lambdamux.RouteTable) []byte {
+	type event struct {
+		name, path, method string
+	}
+
+	var events []event
+	seen := map[string]int{}
+	served := map[[2]string]bool{}
+	for _, e := range table {
+		path, method := e.Resource, e.Method
+		if len(path) == 0 {
+			path = "/{proxy+}"
+		}
+		if len(method) == 0 {
+			method = "ANY"
+		}
+		if served[[2]string{path, method}] {
+			continue
+		}
+		served[[2]string{path, method}] = true
+
+		name := logicalID(strings.ToLower(method) + " " + path)
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, seen[name])
+		}
+		events = append(events, event{name: name, path: path, method: method})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, samTemplateHeader, function)
+	if len(events) != 0 {
+		b.WriteString("      Events:\n")
+	}
+	for _, e := range events {
+		fmt.Fprintf(&b, "        %s:\n", e.name)
+		b.WriteString("          Type: Api\n")
+		b.WriteString("          Properties:\n")
+		fmt.Fprintf(&b, "            Path: %s\n", e.path)
+		fmt.Fprintf(&b, "            Method: %s\n", e.method)
+	}
+	fmt.Fprintf(&b, "\nOutputs:\n  FunctionArn:\n    Value: !GetAtt %s.Arn\n", function)
+	if len(events) != 0 {
+		b.WriteString(samTemplateAPIOutput)
+	}
+	return []byte(b.String())
+}
+
+// logicalID returns the CamelCase concatenation of the alphanumeric words
+// of s, e.g. "GetUsersId" for "get /users/{id}".
+func logicalID(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+const samTemplateHeader = `AWSTemplateFormatVersion: "2010-09-09"
+Transform: AWS::Serverless-2016-10-31
+Description: Generated by lambdamux, regenerate with "go run . -template > template.yaml".
+
+Resources:
+  %s:
+    Type: AWS::Serverless::Function
+    Metadata:
+      BuildMethod: go1.x
+    Properties:
+      CodeUri: .
+      Handler: bootstrap
+      Runtime: provided.al2
+      Architectures: [arm64]
+      Timeout: 10
+      Environment:
+        Variables:
+          LAMBDAMUX_PROFILE: prod
+`
+
+// samTemplateAPIOutput is the output of the implicit API SAM creates for
+// the function's Api events.
+const samTemplateAPIOutput = `  ApiURL:
+    Description: URL of the API's Prod stage.
+    Value: !Sub "https://${ServerlessRestApi}.execute-api.${AWS::Region}.amazonaws.com/Prod/"
+`
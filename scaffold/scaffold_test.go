@@ -0,0 +1,161 @@
+package scaffold
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+func TestNew(t *testing.T) {
+	cases := map[string]struct {
+		project      func(dir string) Project
+		expectErr    string
+		expectModule string
+		expectID     string
+	}{
+		"defaults": {
+			project: func(dir string) Project {
+				return Project{Dir: filepath.Join(dir, "orders-api")}
+			},
+			expectModule: "module orders-api", expectID: "OrdersApiFunction:",
+		},
+		"module and function": {
+			project: func(dir string) Project {
+				return Project{
+					Dir:      filepath.Join(dir, "orders"),
+					Module:   "example.com/orders",
+					Function: "Orders",
+				}
+			},
+			expectModule: "module example.com/orders", expectID: "Orders:",
+		},
+		"no dir": {
+			project:   func(dir string) Project { return Project{} },
+			expectErr: "project directory not set",
+		},
+		"invalid function": {
+			project: func(dir string) Project {
+				return Project{Dir: filepath.Join(dir, "orders"), Function: "orders-fn"}
+			},
+			expectErr: "invalid function logical ID",
+		},
+		"not empty": {
+			project: func(dir string) Project {
+				ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0644)
+				return Project{Dir: dir}
+			},
+			expectErr: "is not empty",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := c.project(t.TempDir())
+
+			names, err := New(p)
+			if len(c.expectErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), c.expectErr) {
+					t.Fatalf("expect %q error, got %v", c.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+
+			expect := []string{"go.mod", "main.go", "routes.go", "hello.go", "hello_test.go", ".gitignore", "template.yaml"}
+			if e, a := strings.Join(expect, ","), strings.Join(names, ","); e != a {
+				t.Errorf("expect %v files, got %v", e, a)
+			}
+			for _, name := range names {
+				if _, err := os.Stat(filepath.Join(p.Dir, name)); err != nil {
+					t.Errorf("expect %s generated, got %v", name, err)
+				}
+			}
+
+			goMod, _ := ioutil.ReadFile(filepath.Join(p.Dir, "go.mod"))
+			if e, a := c.expectModule, string(goMod); !strings.Contains(a, e) {
+				t.Errorf("expect go.mod to contain %q, got\n%s", e, a)
+			}
+			template, _ := ioutil.ReadFile(filepath.Join(p.Dir, "template.yaml"))
+			if e, a := c.expectID, string(template); !strings.Contains(a, e) {
+				t.Errorf("expect template to contain %q, got\n%s", e, a)
+			}
+		})
+	}
+}
+
+func TestSAMTemplate(t *testing.T) {
+	template := string(SAMTemplate("Fn", lambdamux.RouteTable{
+		{Resource: "/users/{id}", Method: "GET"},
+		{Resource: "/users/{id}", Method: "GET", Query: "verbose"},
+		{Resource: "/users", Method: ""},
+		{Method: "POST"},
+	}))
+
+	for _, expect := range []string{
+		"  Fn:\n",
+		"        GetUsersId:\n          Type: Api\n          Properties:\n            Path: /users/{id}\n            Method: GET\n",
+		"Path: /users\n            Method: ANY\n",
+		"Path: /{proxy+}\n            Method: POST\n",
+	} {
+		if !strings.Contains(template, expect) {
+			t.Errorf("expect template to contain\n%s\ngot\n%s", expect, template)
+		}
+	}
+	if e, a := 1, strings.Count(template, "Path: /users/{id}\n"); e != a {
+		t.Errorf("expect %v /users/{id} event, got %v", e, a)
+	}
+}
+
+// TestNewProjectTests runs the tests of a generated project against this
+// module, so the generated tests are kept passing.
+func TestNewProjectTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping generated project tests in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	if _, err := New(Project{Dir: dir}); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	// The project requires this module, instead of the published version,
+	// and the module's checksums, as "go mod tidy" would add.
+	goMod, err := os.OpenFile(filepath.Join(dir, "go.mod"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	_, err = goMod.WriteString("\nrequire go.jasdel.dev/aws/lambda-mux v0.0.0\n\n" +
+		"replace go.jasdel.dev/aws/lambda-mux => " + root + "\n")
+	goMod.Close()
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	goSum, err := ioutil.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), goSum, 0644); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	cmd := exec.Command(goBin, "test", "-mod=mod", "./...")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("expect generated tests to pass, got %v\n%s", err, out)
+	}
+}
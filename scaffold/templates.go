@@ -0,0 +1,189 @@
+package scaffold
+
+import (
+	"context"
+	"net/http"
+	"text/template"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+type projectFile struct {
+	name     string
+	template *template.Template
+}
+
+func newProjectFile(name, text string) projectFile {
+	return projectFile{name: name, template: template.Must(template.New(name).Parse(text))}
+}
+
+// projectFiles are the files of a generated project, other than its SAM
+// template.
+var projectFiles = []projectFile{
+	newProjectFile("go.mod", goModTemplate),
+	newProjectFile("main.go", mainTemplate),
+	newProjectFile("routes.go", routesTemplate),
+	newProjectFile("hello.go", helloTemplate),
+	newProjectFile("hello_test.go", helloTestTemplate),
+	newProjectFile(".gitignore", gitignoreTemplate),
+}
+
+// exampleRoutes returns the route tree of the generated routes.go, for its
+// initial SAM template. Must be kept in sync with routesTemplate.
+func exampleRoutes() lambdamux.ResourceHandler {
+	var h lambdamux.ResourceHandlerFunc = func(
+		context.Context, lambdamux.APIGatewayProxyRequest,
+	) (lambdamux.APIGatewayProxyResponse, error) {
+		return lambdamux.NoContent(), nil
+	}
+
+	return lambdamux.NewServePath().
+		Handle("/health", lambdamux.NewServeMethod().
+			Handle(http.MethodGet, h)).
+		Handle("/hello/{name}", lambdamux.NewServeMethod().
+			Handle(http.MethodGet, h))
+}
+
+const goModTemplate = `module {{.Module}}
+
+go 1.18
+`
+
+const mainTemplate = `package main
+
+import (
+	"flag"
+	"os"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+	"go.jasdel.dev/aws/lambda-mux/scaffold"
+)
+
+func main() {
+	samTemplate := flag.Bool("template", false,
+		"write the SAM template of the function's routes to stdout, and exit")
+	flag.Parse()
+
+	handler := routes()
+	if *samTemplate {
+		os.Stdout.Write(scaffold.SAMTemplate("{{.Function}}", lambdamux.Routes(handler)))
+		return
+	}
+
+	// The profile is selected by the LAMBDAMUX_PROFILE environment
+	// variable, e.g. "dev" serves the /_lambdamux/routes debug route.
+	lambdamux.Start(handler, lambdamux.DefaultProfiles(handler))
+}
+`
+
+const routesTemplate = `package main
+
+import (
+	"context"
+	"net/http"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// routes returns the function's route table. After changing the routes,
+// regenerate the SAM template with:
+//
+//	go run . -template > template.yaml
+func routes() lambdamux.ResourceHandler {
+	return lambdamux.NewServePath().
+		Handle("/health", lambdamux.NewServeMethod().
+			Handle(http.MethodGet, lambdamux.ResourceHandlerFunc(health))).
+		Handle("/hello/{name}", lambdamux.NewServeMethod().
+			Handle(http.MethodGet, helloHandler{Greeting: "Hello"}))
+}
+
+func health(
+	ctx context.Context, req lambdamux.APIGatewayProxyRequest,
+) (lambdamux.APIGatewayProxyResponse, error) {
+	return lambdamux.NoContent(), nil
+}
+`
+
+const helloTemplate = `package main
+
+import (
+	"context"
+	"net/http"
+	"unicode/utf8"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// helloHandler is an example resource handler, greeting the name of the
+// request's path.
+type helloHandler struct {
+	Greeting string
+}
+
+// ServeResource implements the lambdamux.ResourceHandler interface.
+func (h helloHandler) ServeResource(
+	ctx context.Context, req lambdamux.APIGatewayProxyRequest,
+) (lambdamux.APIGatewayProxyResponse, error) {
+	name := req.PathParameters["name"]
+	if utf8.RuneCountInString(name) > 64 {
+		return lambdamux.APIGatewayProxyResponse{}, lambdamux.BadRequest("name is longer than 64 characters")
+	}
+
+	return lambdamux.JSON(http.StatusOK, map[string]string{
+		"message": h.Greeting + ", " + name + "!",
+	})
+}
+`
+
+const helloTestTemplate = `package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.jasdel.dev/aws/lambda-mux/lambdamuxtest"
+)
+
+func TestHello(t *testing.T) {
+	req := lambdamuxtest.NewRequest(http.MethodGet, "/hello/gopher").Build()
+
+	resp, err := routes().ServeResource(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	lambdamuxtest.AssertStatus(t, resp, http.StatusOK)
+
+	var body struct {
+		Message string ` + "`json:\"message\"`" + `
+	}
+	lambdamuxtest.DecodeJSON(t, resp, &body)
+	if e, a := "Hello, gopher!", body.Message; e != a {
+		t.Errorf("expect %v message, got %v", e, a)
+	}
+}
+
+func TestHelloNameTooLong(t *testing.T) {
+	req := lambdamuxtest.NewRequest(http.MethodGet, "/hello/"+strings.Repeat("a", 65)).Build()
+
+	_, err := routes().ServeResource(context.Background(), req)
+	if err == nil {
+		t.Fatalf("expect error, got none")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	req := lambdamuxtest.NewRequest(http.MethodPost, "/hello/gopher").Build()
+
+	resp, err := routes().ServeResource(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	lambdamuxtest.AssertStatus(t, resp, http.StatusMethodNotAllowed)
+}
+`
+
+const gitignoreTemplate = `.aws-sam/
+bootstrap
+`
@@ -0,0 +1,125 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSPNoncePlaceholder is replaced in a SecurityHeaders content security
+// policy with the request's nonce, e.g. "script-src 'nonce-{nonce}'".
+const CSPNoncePlaceholder = "{nonce}"
+
+// SecurityHeaders are the security related headers set on responses. Empty
+// fields are not set. Headers already set by the handler are not replaced.
+type SecurityHeaders struct {
+	// Content-Security-Policy of responses. If the policy contains the
+	// CSPNoncePlaceholder, a nonce is generated for each request, passed to
+	// the handler via the context, and replaces the placeholder.
+	ContentSecurityPolicy string
+
+	// Max age of the Strict-Transport-Security header, and if it includes
+	// subdomains.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+
+	FrameOptions   string
+	ReferrerPolicy string
+
+	// NoSniff sets the "X-Content-Type-Options: nosniff" header.
+	NoSniff bool
+}
+
+// DefaultSecurityHeaders are security headers suitable for most
+// applications.
+var DefaultSecurityHeaders = SecurityHeaders{
+	ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; " +
+		"style-src 'self' 'nonce-{nonce}'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	HSTSMaxAge:     365 * 24 * time.Hour,
+	FrameOptions:   "DENY",
+	ReferrerPolicy: "strict-origin-when-cross-origin",
+	NoSniff:        true,
+}
+
+type cspNonceKey struct{}
+
+// CSPNonceFromContext returns the content security policy nonce of the
+// request, or empty string if the policy does not use a nonce.
+func CSPNonceFromContext(ctx context.Context) string {
+	v, _ := ctx.Value(cspNonceKey{}).(string)
+	return v
+}
+
+type securityHeadersHandler struct {
+	Headers SecurityHeaders
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithSecurityHeaders provides a resource handler that sets
+// the security headers on responses of handler.
+func ResourceHandlerWithSecurityHeaders(headers SecurityHeaders, handler ResourceHandler) ResourceHandler {
+	return securityHeadersHandler{
+		Headers: headers,
+		Handler: handler,
+	}
+}
+
+// ServeResource delegates to the wrapped handler, and sets the security
+// headers on its response.
+func (h securityHeadersHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	csp := h.Headers.ContentSecurityPolicy
+	if strings.Contains(csp, CSPNoncePlaceholder) {
+		nonce, err := cspNonce()
+		if err != nil {
+			return resp, err
+		}
+		csp = strings.Replace(csp, CSPNoncePlaceholder, nonce, -1)
+		ctx = context.WithValue(ctx, cspNonceKey{}, nonce)
+	}
+
+	resp, err = h.Handler.ServeResource(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+
+	setDefault := func(k, v string) {
+		if len(v) != 0 && len(resp.HTTPHeader.Get(k)) == 0 {
+			resp.HTTPHeader.Set(k, v)
+		}
+	}
+
+	setDefault("Content-Security-Policy", csp)
+	if h.Headers.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.FormatInt(int64(h.Headers.HSTSMaxAge/time.Second), 10)
+		if h.Headers.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		setDefault("Strict-Transport-Security", hsts)
+	}
+	setDefault("X-Frame-Options", h.Headers.FrameOptions)
+	setDefault("Referrer-Policy", h.Headers.ReferrerPolicy)
+	if h.Headers.NoSniff {
+		setDefault("X-Content-Type-Options", "nosniff")
+	}
+
+	return resp, nil
+}
+
+// cspNonce returns a random 128 bit nonce.
+func cspNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate CSP nonce, %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
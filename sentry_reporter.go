@@ -0,0 +1,175 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SentryReporter is an ErrorReporter sending reports to Sentry's event store
+// API. Reports are batched in memory, and sent when Flush is called.
+type SentryReporter struct {
+	endpoint string
+	auth     string
+
+	// HTTP client reports are sent with.
+	Client *http.Client
+
+	// Environment and release tags added to every event.
+	Environment string
+	Release     string
+
+	mu     sync.Mutex
+	events []sentryEvent
+}
+
+// NewSentryReporter returns a SentryReporter for the project identified by
+// the Sentry DSN, e.g. "https://<key>@o0.ingest.sentry.io/<project>".
+// Returns an error if the DSN is invalid.
+func NewSentryReporter(dsn string) (*SentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Sentry DSN, %w", err)
+	}
+	if u.User == nil || len(u.User.Username()) == 0 {
+		return nil, fmt.Errorf("invalid Sentry DSN, missing public key")
+	}
+
+	project := strings.TrimPrefix(u.Path, "/")
+	if len(project) == 0 {
+		return nil, fmt.Errorf("invalid Sentry DSN, missing project ID")
+	}
+
+	return &SentryReporter{
+		endpoint: fmt.Sprintf("%s://%s/api/%s/store/", u.Scheme, u.Host, project),
+		auth: fmt.Sprintf("Sentry sentry_version=7, sentry_client=lambdamux/1.0, sentry_key=%s",
+			u.User.Username()),
+		Client: &http.Client{Timeout: 2 * time.Second},
+	}, nil
+}
+
+type sentryEvent struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Message     string                 `json:"message"`
+	Transaction string                 `json:"transaction,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	User        *sentryUser            `json:"user,omitempty"`
+	Request     sentryRequest          `json:"request"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+type sentryUser struct {
+	ID string `json:"id"`
+}
+
+type sentryRequest struct {
+	URL    string `json:"url"`
+	Method string `json:"method"`
+	Data   string `json:"data,omitempty"`
+}
+
+// Report implements the ErrorReporter interface, adding the report to the
+// batch of events to be sent.
+func (r *SentryReporter) Report(ctx context.Context, report ErrorReport) {
+	level := "error"
+	extra := map[string]interface{}{}
+	if report.Panic != nil {
+		level = "fatal"
+		extra["stack"] = string(report.Stack)
+	}
+	if len(report.PathParameters) != 0 {
+		extra["pathParameters"] = report.PathParameters
+	}
+
+	event := sentryEvent{
+		EventID:     newSentryEventID(),
+		Timestamp:   report.Time.UTC().Format(time.RFC3339),
+		Level:       level,
+		Platform:    "go",
+		Environment: r.Environment,
+		Release:     r.Release,
+		Message:     report.Err.Error(),
+		Transaction: report.Method + " " + report.Resource,
+		Tags: map[string]string{
+			"route":      report.Resource,
+			"request_id": report.RequestID,
+		},
+		Request: sentryRequest{
+			URL:    report.Path,
+			Method: report.Method,
+			Data:   report.Body,
+		},
+		Extra: extra,
+	}
+	if len(report.User) != 0 {
+		event.User = &sentryUser{ID: report.User}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, event)
+}
+
+// Flush implements the ErrorReporter interface, sending the batched events
+// to Sentry. Returns the first error encountered sending events. Events are
+// not retried.
+func (r *SentryReporter) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	events := r.events
+	r.events = nil
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, event := range events {
+		if err := r.send(ctx, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *SentryReporter) send(ctx context.Context, event sentryEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Sentry event, %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Sentry request, %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", r.auth)
+
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Sentry event, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to send Sentry event, status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newSentryEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
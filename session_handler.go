@@ -0,0 +1,251 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCookieLen is the maximum length of a cookie browsers are guaranteed to
+// store.
+const maxCookieLen = 4096
+
+// Session is the key value session of a request, loaded from, and saved to,
+// the session cookie by the session middleware.
+type Session struct {
+	mu      sync.Mutex
+	values  map[string]string
+	changed bool
+}
+
+// Get returns the session's value for the key, or empty string if not set.
+func (s *Session) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// Set sets the session's value for the key.
+func (s *Session) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes the key from the session.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Clear removes all values from the session, deleting the session cookie.
+func (s *Session) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = map[string]string{}
+	s.changed = true
+}
+
+type sessionKey struct{}
+
+// SessionFromContext returns the request's Session, or nil if the request
+// is not served by the session middleware.
+func SessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
+
+// SessionCookie configures the cookie sessions are stored in. Sessions are
+// encrypted and authenticated with AES-GCM, so their values are not visible
+// to, or modifiable by, the client. The encoded session must fit within the
+// 4KB browsers limit cookies to.
+type SessionCookie struct {
+	// AES key of 16, 24, or 32 bytes.
+	Key []byte
+
+	// Name of the cookie. Defaults to "session".
+	Name string
+
+	// Duration sessions are valid for after they are last saved. Defaults
+	// to 24 hours.
+	MaxAge time.Duration
+
+	// Path and domain of the cookie. Path defaults to "/".
+	Path   string
+	Domain string
+
+	// Insecure omits the cookie's Secure attribute, e.g. for local
+	// development over HTTP.
+	Insecure bool
+
+	// SameSite attribute of the cookie. Defaults to Lax.
+	SameSite http.SameSite
+}
+
+func (c SessionCookie) name() string {
+	if len(c.Name) == 0 {
+		return "session"
+	}
+	return c.Name
+}
+
+func (c SessionCookie) maxAge() time.Duration {
+	if c.MaxAge == 0 {
+		return 24 * time.Hour
+	}
+	return c.MaxAge
+}
+
+type sessionPayload struct {
+	Values  map[string]string `json:"v"`
+	Expires int64             `json:"e"`
+}
+
+// load returns the values of the request's session cookie. Missing, expired,
+// and invalid cookies are an empty session.
+func (c SessionCookie) load(req APIGatewayProxyRequest, aead cipher.AEAD) map[string]string {
+	values := map[string]string{}
+
+	cookie, err := (&http.Request{Header: req.HTTPHeader}).Cookie(c.name())
+	if err != nil {
+		return values
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return values
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	b, err := aead.Open(nil, nonce, sealed, []byte(c.name()))
+	if err != nil {
+		return values
+	}
+
+	var payload sessionPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return values
+	}
+	if time.Now().Unix() > payload.Expires || payload.Values == nil {
+		return values
+	}
+	return payload.Values
+}
+
+// cookie returns the Set-Cookie value saving the session's values, or
+// deleting the cookie if the session is empty.
+func (c SessionCookie) cookie(values map[string]string, aead cipher.AEAD) (string, error) {
+	cookie := &http.Cookie{
+		Name:     c.name(),
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Secure:   !c.Insecure,
+		HttpOnly: true,
+		SameSite: c.SameSite,
+	}
+	if len(cookie.Path) == 0 {
+		cookie.Path = "/"
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = http.SameSiteLaxMode
+	}
+
+	if len(values) == 0 {
+		cookie.MaxAge = -1
+		return cookie.String(), nil
+	}
+
+	b, err := json.Marshal(sessionPayload{
+		Values:  values,
+		Expires: time.Now().Add(c.maxAge()).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session, %w", err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate session nonce, %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, b, []byte(c.name()))
+
+	cookie.Value = base64.RawURLEncoding.EncodeToString(sealed)
+	cookie.MaxAge = int(c.maxAge() / time.Second)
+
+	v := cookie.String()
+	if len(v) > maxCookieLen {
+		return "", fmt.Errorf("session cookie length %d exceeds %d bytes", len(v), maxCookieLen)
+	}
+	return v, nil
+}
+
+type sessionHandler struct {
+	Cookie  SessionCookie
+	Handler ResourceHandler
+
+	aead cipher.AEAD
+}
+
+// ResourceHandlerWithSession provides a resource handler that loads the
+// request's Session from the session cookie, and passes it to handler via
+// the context. Handlers retrieve the session with SessionFromContext. If the
+// handler modifies the session, the session cookie is set on the response.
+//
+// Panics if the cookie's key is not a valid AES key.
+func ResourceHandlerWithSession(cookie SessionCookie, handler ResourceHandler) ResourceHandler {
+	block, err := aes.NewCipher(cookie.Key)
+	if err != nil {
+		panic(fmt.Sprintf("invalid session cookie key, %v", err))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("invalid session cookie key, %v", err))
+	}
+
+	return sessionHandler{
+		Cookie:  cookie,
+		Handler: handler,
+		aead:    aead,
+	}
+}
+
+// ServeResource loads the request's session, delegates to the wrapped
+// handler, and saves the session if modified.
+func (h sessionHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	session := &Session{values: h.Cookie.load(req, h.aead)}
+
+	resp, err = h.Handler.ServeResource(context.WithValue(ctx, sessionKey{}, session), req)
+	if err != nil {
+		return resp, err
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if !session.changed {
+		return resp, nil
+	}
+
+	cookie, err := h.Cookie.cookie(session.values, h.aead)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	if resp.HTTPHeader == nil {
+		resp.HTTPHeader = http.Header{}
+	}
+	resp.HTTPHeader.Add("Set-Cookie", cookie)
+	return resp, nil
+}
@@ -0,0 +1,152 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLSigner mints, and verifies, expiring signed links, e.g. email
+// verification, or download, links, that authorize the request without a
+// session. Links are signed with an HMAC-SHA256 over the link's path, and
+// query parameters, including the expiry, and claims, e.g. the user's email.
+type URLSigner struct {
+	Secret []byte
+
+	// ExpiresParam is the query parameter of the link's expiry, in Unix
+	// seconds. Defaults to "expires".
+	ExpiresParam string
+
+	// SignatureParam is the query parameter of the link's signature.
+	// Defaults to "signature".
+	SignatureParam string
+}
+
+// Sign returns the link with the claims added as query parameters, and
+// signed to expire at the time. Query parameters already in the link are
+// signed too.
+func (s URLSigner) Sign(link string, expires time.Time, claims url.Values) (string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", fmt.Errorf("invalid link, %w", err)
+	}
+
+	query := u.Query()
+	for k, vs := range claims {
+		query[k] = append(query[k], vs...)
+	}
+	query.Del(s.signatureParam())
+	query.Set(s.expiresParam(), strconv.FormatInt(expires.Unix(), 10))
+	query.Set(s.signatureParam(), s.signature(u.EscapedPath(), query))
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Verify returns the claims, the query parameters other than the signature,
+// and expiry, of the signed request. Returns an error if the request's
+// signature is missing, or invalid, or the link expired.
+func (s URLSigner) Verify(req APIGatewayProxyRequest) (url.Values, error) {
+	query := requestQuery(req)
+
+	sig := query.Get(s.signatureParam())
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("signed link signature missing")
+	}
+	query.Del(s.signatureParam())
+
+	path := (&url.URL{Path: req.Path}).EscapedPath()
+	if !hmac.Equal([]byte(sig), []byte(s.signature(path, query))) {
+		return nil, fmt.Errorf("signed link signature invalid")
+	}
+
+	expires, err := strconv.ParseInt(query.Get(s.expiresParam()), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("signed link expiry invalid, %w", err)
+	}
+	if time.Now().Unix() >= expires {
+		return nil, errSignedURLExpired
+	}
+	query.Del(s.expiresParam())
+
+	return query, nil
+}
+
+var errSignedURLExpired = fmt.Errorf("signed link expired")
+
+// signature returns the signature of the path, and query parameters. The
+// query is encoded sorted by key, so the signature does not depend on the
+// parameters' order.
+func (s URLSigner) signature(path string, query url.Values) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(query.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s URLSigner) expiresParam() string {
+	if len(s.ExpiresParam) == 0 {
+		return "expires"
+	}
+	return s.ExpiresParam
+}
+
+func (s URLSigner) signatureParam() string {
+	if len(s.SignatureParam) == 0 {
+		return "signature"
+	}
+	return s.SignatureParam
+}
+
+type signedURLClaimsKey struct{}
+
+// SignedURLClaimsFromContext returns the claims of the request's signed
+// link verified by the signed link middleware, or nil if the request is not
+// served by the middleware.
+func SignedURLClaimsFromContext(ctx context.Context) url.Values {
+	v, _ := ctx.Value(signedURLClaimsKey{}).(url.Values)
+	return v
+}
+
+type signedURLHandler struct {
+	Signer  URLSigner
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithSignedURL provides a resource handler that verifies the
+// request is a signed link minted by the signer before passing it to
+// handler, with the link's claims available via SignedURLClaimsFromContext.
+// Requests with a missing, or invalid, signature are responded to with a
+// 403 Forbidden response, and expired links with a 410 Gone response.
+//
+// The link's path is verified against the request's Path, so links must be
+// minted with the path the function receives, e.g. including the base path
+// of a custom domain's API mapping only if API Gateway passes it through.
+func ResourceHandlerWithSignedURL(signer URLSigner, handler ResourceHandler) ResourceHandler {
+	return signedURLHandler{
+		Signer:  signer,
+		Handler: handler,
+	}
+}
+
+// ServeResource verifies the request's signed link, and delegates to the
+// wrapped handler.
+func (h signedURLHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	claims, err := h.Signer.Verify(req)
+	if err == errSignedURLExpired {
+		return statusResponse(http.StatusGone), nil
+	} else if err != nil {
+		return statusResponse(http.StatusForbidden), nil
+	}
+
+	return h.Handler.ServeResource(context.WithValue(ctx, signedURLClaimsKey{}, claims), req)
+}
@@ -0,0 +1,406 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// SlackAckTimeout is the time Slack allows for acknowledging slash commands
+// and interactive requests, less a buffer for the response to be delivered.
+const SlackAckTimeout = 2500 * time.Millisecond
+
+// SlackCommand is a Slack slash command request.
+type SlackCommand struct {
+	Command     string
+	Text        string
+	ResponseURL string
+	TriggerID   string
+	UserID      string
+	UserName    string
+	ChannelID   string
+	ChannelName string
+	TeamID      string
+	TeamDomain  string
+	APIAppID    string
+}
+
+func parseSlackCommand(form url.Values) SlackCommand {
+	return SlackCommand{
+		Command:     form.Get("command"),
+		Text:        form.Get("text"),
+		ResponseURL: form.Get("response_url"),
+		TriggerID:   form.Get("trigger_id"),
+		UserID:      form.Get("user_id"),
+		UserName:    form.Get("user_name"),
+		ChannelID:   form.Get("channel_id"),
+		ChannelName: form.Get("channel_name"),
+		TeamID:      form.Get("team_id"),
+		TeamDomain:  form.Get("team_domain"),
+		APIAppID:    form.Get("api_app_id"),
+	}
+}
+
+// SlackInteraction is a Slack interactive payload, e.g. a block action or
+// view submission. Payload is the complete payload, for members not
+// provided by the type.
+type SlackInteraction struct {
+	Type        string `json:"type"`
+	CallbackID  string `json:"callback_id"`
+	TriggerID   string `json:"trigger_id"`
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	Channel struct {
+		ID string `json:"id"`
+	} `json:"channel"`
+
+	Payload json.RawMessage `json:"-"`
+}
+
+// SlackMessage is a message replying to a slash command or interaction.
+type SlackMessage struct {
+	Text string `json:"text,omitempty"`
+
+	// "ephemeral", the default, or "in_channel".
+	ResponseType string `json:"response_type,omitempty"`
+
+	Blocks          []interface{} `json:"blocks,omitempty"`
+	ReplaceOriginal bool          `json:"replace_original,omitempty"`
+	DeleteOriginal  bool          `json:"delete_original,omitempty"`
+}
+
+// SlackCommandHandler handles a slash command, returning the reply message.
+type SlackCommandHandler func(context.Context, SlackCommand) (SlackMessage, error)
+
+// SlackInteractionHandler handles an interactive payload, returning the
+// reply message. Returning the zero SlackMessage responds with an empty
+// acknowledgment.
+type SlackInteractionHandler func(context.Context, SlackInteraction) (SlackMessage, error)
+
+// SlackDeferrer is the interface for handing off slash commands and
+// interactions for processing after Slack's acknowledgment deadline, e.g.
+// by sending the job to an SQS queue, or asynchronously invoking the Lambda
+// function. The job is processed by passing it to SlackApp.RunDeferred.
+type SlackDeferrer interface {
+	Defer(ctx context.Context, job []byte) error
+}
+
+// SlackDeferrerFunc provides wrapping of a function as the SlackDeferrer.
+type SlackDeferrerFunc func(ctx context.Context, job []byte) error
+
+// Defer implements the SlackDeferrer interface and delegates to the
+// function.
+func (f SlackDeferrerFunc) Defer(ctx context.Context, job []byte) error {
+	return f(ctx, job)
+}
+
+type slackJob struct {
+	Command     *SlackCommand   `json:"command,omitempty"`
+	Interaction json.RawMessage `json:"interaction,omitempty"`
+}
+
+type slackCommandRoute struct {
+	handler  SlackCommandHandler
+	deferred bool
+}
+
+type slackInteractionRoute struct {
+	handler  SlackInteractionHandler
+	deferred bool
+}
+
+// SlackApp is an API Gateway Proxy resource handler for a Slack app's slash
+// commands and interactive payloads. Requests are verified with the app's
+// signing secret, and routed to handlers by command, or interaction type.
+//
+// Handlers added with HandleCommand and HandleInteraction are run within
+// Slack's acknowledgment deadline, and their reply is the response. If the
+// handler does not complete by the deadline, an acknowledgment is sent
+// instead, and the handler's reply is lost. Handlers that may take longer
+// are added with DeferCommand and DeferInteraction, and are acknowledged
+// immediately, with the work handed off to the Deferrer. The deferred
+// handler's reply is posted to the request's response_url.
+type SlackApp struct {
+	verifier     HMACVerifier
+	commands     map[string]slackCommandRoute
+	interactions map[string]slackInteractionRoute
+
+	// Deferrer deferred commands and interactions are handed off to.
+	Deferrer SlackDeferrer
+
+	// Message acknowledging deferred commands. Defaults to an empty
+	// acknowledgment.
+	AckMessage SlackMessage
+
+	// HTTP client replies are posted to response_url with.
+	Client *http.Client
+}
+
+// NewSlackApp initializes and returns a SlackApp verifying requests with
+// the signing secret.
+func NewSlackApp(signingSecret string) *SlackApp {
+	return &SlackApp{
+		verifier:     SlackWebhook(signingSecret).Verifier,
+		commands:     map[string]slackCommandRoute{},
+		interactions: map[string]slackInteractionRoute{},
+		Client:       &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// HandleCommand adds the handler for the slash command, e.g. "/deploy".
+func (a *SlackApp) HandleCommand(command string, handler SlackCommandHandler) *SlackApp {
+	a.commands[command] = slackCommandRoute{handler: handler}
+	return a
+}
+
+// DeferCommand adds the deferred handler for the slash command.
+func (a *SlackApp) DeferCommand(command string, handler SlackCommandHandler) *SlackApp {
+	a.commands[command] = slackCommandRoute{handler: handler, deferred: true}
+	return a
+}
+
+// HandleInteraction adds the handler for the interaction type, e.g.
+// "block_actions".
+func (a *SlackApp) HandleInteraction(typ string, handler SlackInteractionHandler) *SlackApp {
+	a.interactions[typ] = slackInteractionRoute{handler: handler}
+	return a
+}
+
+// DeferInteraction adds the deferred handler for the interaction type.
+func (a *SlackApp) DeferInteraction(typ string, handler SlackInteractionHandler) *SlackApp {
+	a.interactions[typ] = slackInteractionRoute{handler: handler, deferred: true}
+	return a
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (a *SlackApp) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	if err := a.verifier.verify(req, body); err != nil {
+		return statusResponse(http.StatusUnauthorized), nil
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	if payload := form.Get("payload"); len(payload) != 0 {
+		return a.serveInteraction(ctx, []byte(payload))
+	}
+	return a.serveCommand(ctx, parseSlackCommand(form))
+}
+
+func (a *SlackApp) serveCommand(ctx context.Context, cmd SlackCommand) (APIGatewayProxyResponse, error) {
+	r, ok := a.commands[cmd.Command]
+	if !ok {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	if r.deferred {
+		if err := a.deferJob(ctx, slackJob{Command: &cmd}); err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+		return slackResponse(a.AckMessage)
+	}
+
+	return a.runWithinAck(ctx, func(ctx context.Context) (SlackMessage, error) {
+		return r.handler(ctx, cmd)
+	})
+}
+
+func (a *SlackApp) serveInteraction(ctx context.Context, payload []byte) (APIGatewayProxyResponse, error) {
+	interaction, err := parseSlackInteraction(payload)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+
+	r, ok := a.interactions[interaction.Type]
+	if !ok {
+		return statusResponse(http.StatusNotFound), nil
+	}
+
+	if r.deferred {
+		if err := a.deferJob(ctx, slackJob{Interaction: payload}); err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+		return slackResponse(SlackMessage{})
+	}
+
+	return a.runWithinAck(ctx, func(ctx context.Context) (SlackMessage, error) {
+		return r.handler(ctx, interaction)
+	})
+}
+
+func parseSlackInteraction(payload []byte) (SlackInteraction, error) {
+	var interaction SlackInteraction
+	if err := json.Unmarshal(payload, &interaction); err != nil {
+		return interaction, fmt.Errorf("invalid Slack interaction payload, %w", err)
+	}
+	interaction.Payload = payload
+	return interaction, nil
+}
+
+func (a *SlackApp) deferJob(ctx context.Context, job slackJob) error {
+	if a.Deferrer == nil {
+		return fmt.Errorf("slack app deferrer not set")
+	}
+
+	b, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack job, %w", err)
+	}
+	if err := a.Deferrer.Defer(ctx, b); err != nil {
+		return fmt.Errorf("failed to defer Slack job, %w", err)
+	}
+	return nil
+}
+
+// runWithinAck runs the handler, responding with its reply if it completes
+// within the acknowledgment deadline, or an acknowledgment otherwise.
+func (a *SlackApp) runWithinAck(
+	ctx context.Context, fn func(context.Context) (SlackMessage, error),
+) (APIGatewayProxyResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, SlackAckTimeout)
+	defer cancel()
+
+	type result struct {
+		msg SlackMessage
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		msg, err := fn(ctx)
+		done <- result{msg: msg, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return slackResponse(a.AckMessage)
+	case r := <-done:
+		if r.err != nil {
+			return APIGatewayProxyResponse{}, r.err
+		}
+		return slackResponse(r.msg)
+	}
+}
+
+func slackResponse(msg SlackMessage) (APIGatewayProxyResponse, error) {
+	if msg.isZero() {
+		return APIGatewayProxyResponse{
+			APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+			},
+			HTTPHeader: http.Header{},
+		}, nil
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to marshal Slack message, %w", err)
+	}
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       string(body),
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+	}, nil
+}
+
+func (m SlackMessage) isZero() bool {
+	return len(m.Text) == 0 && len(m.Blocks) == 0 && !m.DeleteOriginal
+}
+
+// RunDeferred runs the handler of a deferred slash command or interaction
+// job, posting the handler's reply to the job's response_url.
+func (a *SlackApp) RunDeferred(ctx context.Context, job []byte) error {
+	var j slackJob
+	if err := json.Unmarshal(job, &j); err != nil {
+		return fmt.Errorf("invalid Slack job, %w", err)
+	}
+
+	var msg SlackMessage
+	var responseURL string
+	var err error
+
+	switch {
+	case j.Command != nil:
+		r, ok := a.commands[j.Command.Command]
+		if !ok {
+			return fmt.Errorf("slack command handler not found for %s", j.Command.Command)
+		}
+		responseURL = j.Command.ResponseURL
+		msg, err = r.handler(ctx, *j.Command)
+
+	case len(j.Interaction) != 0:
+		interaction, perr := parseSlackInteraction(j.Interaction)
+		if perr != nil {
+			return perr
+		}
+		r, ok := a.interactions[interaction.Type]
+		if !ok {
+			return fmt.Errorf("slack interaction handler not found for %s", interaction.Type)
+		}
+		responseURL = interaction.ResponseURL
+		msg, err = r.handler(ctx, interaction)
+
+	default:
+		return fmt.Errorf("invalid Slack job, no command or interaction")
+	}
+
+	if err != nil {
+		return err
+	}
+	if msg.isZero() || len(responseURL) == 0 {
+		return nil
+	}
+	return SlackReply(ctx, a.Client, responseURL, msg)
+}
+
+// SlackReply posts the message to a slash command, or interaction's,
+// response_url.
+func SlackReply(ctx context.Context, client *http.Client, responseURL string, msg SlackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack message, %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack reply request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to post Slack reply, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to post Slack reply, status %d", resp.StatusCode)
+	}
+	return nil
+}
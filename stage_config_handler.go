@@ -0,0 +1,97 @@
+package lambdamux
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// BindStageVariables sets the fields of the struct pointed to by v from the
+// request's API Gateway stage variables. Fields are named by the "stage"
+// struct tag, e.g. `stage:"tableName"`, and the "default" struct tag
+// provides the value of stage variables that are not set.
+//
+// Supported field types are strings, bools, integers, floats,
+// time.Duration, slices of those parsed from comma separated values, and
+// types implementing encoding.TextUnmarshaler.
+func (r APIGatewayProxyRequest) BindStageVariables(v interface{}) error {
+	return bindValues(v, "stage", func(name string) (string, bool) {
+		value, ok := r.StageVariables[name]
+		return value, ok
+	})
+}
+
+type stageConfigKey struct{}
+
+// StageConfigFromContext returns the stage configuration bound by the stage
+// config middleware, a pointer to the middleware's configuration type, or
+// nil if the request is not served by the middleware.
+func StageConfigFromContext(ctx context.Context) interface{} {
+	return ctx.Value(stageConfigKey{})
+}
+
+type stageConfigHandler struct {
+	Type    reflect.Type
+	Handler ResourceHandler
+
+	mu      sync.Mutex
+	configs map[string]interface{}
+}
+
+// ResourceHandlerWithStageConfig provides a resource handler that binds the
+// request's stage variables into a configuration of config's struct type,
+// with BindStageVariables, and passes it to handler via the context.
+// Handlers retrieve the configuration with StageConfigFromContext, as a
+// pointer to the type, e.g.
+//
+//	cfg := lambdamux.StageConfigFromContext(ctx).(*Config)
+//
+// The configuration is bound once per stage for the lifetime of the Lambda
+// container, and shared by requests of the stage, so must not be modified.
+// The config value is only used for its type, e.g. Config{}.
+func ResourceHandlerWithStageConfig(config interface{}, handler ResourceHandler) ResourceHandler {
+	t := reflect.TypeOf(config)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("stage config must be a struct, %T", config))
+	}
+
+	return &stageConfigHandler{
+		Type:    t,
+		Handler: handler,
+		configs: map[string]interface{}{},
+	}
+}
+
+// ServeResource binds the stage configuration of the request, and delegates
+// to the wrapped handler.
+func (h *stageConfigHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	config, err := h.config(req)
+	if err != nil {
+		return resp, err
+	}
+	return h.Handler.ServeResource(context.WithValue(ctx, stageConfigKey{}, config), req)
+}
+
+func (h *stageConfigHandler) config(req APIGatewayProxyRequest) (interface{}, error) {
+	stage := req.RequestContext.Stage
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if config, ok := h.configs[stage]; ok {
+		return config, nil
+	}
+
+	config := reflect.New(h.Type).Interface()
+	if err := req.BindStageVariables(config); err != nil {
+		return nil, fmt.Errorf("failed to bind stage %q config, %w", stage, err)
+	}
+	h.configs[stage] = config
+	return config, nil
+}
@@ -0,0 +1,196 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StateKey is an AES key state is sealed with, identified by its ID so
+// state sealed with previous keys can be opened after the key is rotated.
+type StateKey struct {
+	ID string
+
+	// Key is the AES-128, AES-192, or AES-256 key, 16, 24, or 32 bytes.
+	Key []byte
+}
+
+// StateSealer encrypts, and authenticates, small state blobs with AES-GCM,
+// e.g. cookies, continuation tokens, or pagination cursors, so clients can
+// carry state, such as a cursor's query, without reading or modifying it.
+//
+// State is sealed with the first key, and opened with the key it was sealed
+// with, by the key's ID. Keys are rotated by adding a new first key, and
+// removing old keys once the state they sealed is no longer valid.
+type StateSealer struct {
+	keys  []StateKey
+	aeads map[string]cipher.AEAD
+}
+
+// NewStateSealer returns a StateSealer for the keys, with the first key used
+// to seal state. Returns an error if there are no keys, or a key is not a
+// valid AES key, or key IDs are not unique.
+func NewStateSealer(keys ...StateKey) (*StateSealer, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("state sealer requires at least one key")
+	}
+
+	s := &StateSealer{keys: keys, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for _, k := range keys {
+		if len(k.ID) == 0 || len(k.ID) > 255 {
+			return nil, fmt.Errorf("invalid state key ID %q", k.ID)
+		}
+		if _, ok := s.aeads[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate state key ID %q", k.ID)
+		}
+
+		block, err := aes.NewCipher(k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state key %q, %w", k.ID, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state key %q, %w", k.ID, err)
+		}
+		s.aeads[k.ID] = aead
+	}
+	return s, nil
+}
+
+// Seal returns the state encrypted, and authenticated with the additional
+// data, e.g. the state's purpose, as URL safe base64. The additional data is
+// not included in the sealed state, and must be provided to Open.
+func (s *StateSealer) Seal(state, additionalData []byte) (string, error) {
+	key := s.keys[0]
+	aead := s.aeads[key.ID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce, %w", err)
+	}
+
+	b := make([]byte, 0, 1+len(key.ID)+len(nonce)+len(state)+aead.Overhead())
+	b = append(b, byte(len(key.ID)))
+	b = append(b, key.ID...)
+	b = append(b, nonce...)
+	b = aead.Seal(b, nonce, state, additionalData)
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Open returns the state of the sealed value, or an error if the value was
+// not sealed with one of the sealer's keys, and the additional data, or was
+// modified.
+func (s *StateSealer) Open(sealed string, additionalData []byte) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil || len(b) == 0 || len(b) < 1+int(b[0]) {
+		return nil, fmt.Errorf("invalid sealed state")
+	}
+
+	id := string(b[1 : 1+int(b[0])])
+	b = b[1+int(b[0]):]
+
+	aead, ok := s.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown state key %q", id)
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed state")
+	}
+
+	state, err := aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed state, %w", err)
+	}
+	return state, nil
+}
+
+// SealJSON returns the JSON encoding of v sealed with Seal.
+func (s *StateSealer) SealJSON(v interface{}, additionalData []byte) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal state, %w", err)
+	}
+	return s.Seal(b, additionalData)
+}
+
+// OpenJSON opens the sealed value with Open, and decodes the state's JSON
+// encoding into v.
+func (s *StateSealer) OpenJSON(sealed string, additionalData []byte, v interface{}) error {
+	b, err := s.Open(sealed, additionalData)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid state, %w", err)
+	}
+	return nil
+}
+
+// ParseStateKeys parses state keys formatted as comma separated "id:key"
+// pairs, with the key base64 encoded, e.g. "2024-06:3q2+7w...,2024-01:...".
+// The first key is the key state is sealed with.
+func ParseStateKeys(v string) ([]StateKey, error) {
+	var keys []StateKey
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+
+		i := strings.IndexByte(pair, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid state key, expect id:key")
+		}
+		key, err := base64.StdEncoding.DecodeString(pair[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid state key %q, %w", pair[:i], err)
+		}
+		keys = append(keys, StateKey{ID: pair[:i], Key: key})
+	}
+	return keys, nil
+}
+
+// StateKeysFromEnv returns the state keys of the environment variable,
+// formatted as ParseStateKeys expects.
+func StateKeysFromEnv(name string) ([]StateKey, error) {
+	keys, err := ParseStateKeys(os.Getenv(name))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, %w", name, err)
+	}
+	return keys, nil
+}
+
+// KMSDecryptAPI is the interface for the KMS Decrypt operation
+// KMSStateKeys is built on. The package does not depend on the AWS SDK,
+// applications adapt their SDK KMS client to the interface.
+type KMSDecryptAPI interface {
+	// Decrypt returns the plaintext of the KMS ciphertext blob.
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// KMSStateKeys returns the state keys of KMS data keys. The encrypted data
+// keys, e.g. from GenerateDataKey's CiphertextBlob, are formatted as
+// ParseStateKeys expects, and decrypted with KMS. Called once, e.g. at cold
+// start, so the plaintext keys are only held in memory.
+func KMSStateKeys(ctx context.Context, client KMSDecryptAPI, encrypted string) ([]StateKey, error) {
+	keys, err := ParseStateKeys(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, k := range keys {
+		plaintext, err := client.Decrypt(ctx, k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt state key %q, %w", k.ID, err)
+		}
+		keys[i].Key = plaintext
+	}
+	return keys, nil
+}
@@ -0,0 +1,269 @@
+package lambdamux
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testStateKey(id string, size int) StateKey {
+	return StateKey{ID: id, Key: bytes.Repeat([]byte(id[:1]), size)}
+}
+
+func TestNewStateSealer(t *testing.T) {
+	cases := map[string]struct {
+		keys      []StateKey
+		expectErr string
+	}{
+		"aes-128": {keys: []StateKey{testStateKey("a", 16)}},
+		"aes-192": {keys: []StateKey{testStateKey("a", 24)}},
+		"aes-256": {keys: []StateKey{testStateKey("a", 32), testStateKey("b", 16)}},
+		"no keys": {
+			expectErr: "at least one key",
+		},
+		"invalid key size": {
+			keys:      []StateKey{testStateKey("a", 15)},
+			expectErr: "invalid state key",
+		},
+		"empty ID": {
+			keys:      []StateKey{{Key: make([]byte, 16)}},
+			expectErr: "invalid state key ID",
+		},
+		"ID too long": {
+			keys:      []StateKey{{ID: strings.Repeat("a", 256), Key: make([]byte, 16)}},
+			expectErr: "invalid state key ID",
+		},
+		"duplicate ID": {
+			keys:      []StateKey{testStateKey("a", 16), testStateKey("a", 32)},
+			expectErr: "duplicate state key ID",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewStateSealer(c.keys...)
+			if len(c.expectErr) == 0 {
+				if err != nil {
+					t.Fatalf("expect no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.expectErr) {
+				t.Fatalf("expect %q error, got %v", c.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestStateSealerOpen(t *testing.T) {
+	current := testStateKey("new", 32)
+	previous := testStateKey("old", 32)
+
+	oldSealer, err := NewStateSealer(previous)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	sealer, err := NewStateSealer(current, previous)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	otherSealer, err := NewStateSealer(testStateKey("new", 16))
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	state, ad := []byte(`{"cursor":"abc"}`), []byte("cursor")
+	sealed, err := sealer.Seal(state, ad)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	oldSealed, err := oldSealer.Seal(state, ad)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	otherSealed, err := otherSealer.Seal(state, ad)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	raw, _ := base64.RawURLEncoding.DecodeString(sealed)
+	tamper := func(i int) string {
+		b := append([]byte{}, raw...)
+		b[i] ^= 1
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	cases := map[string]struct {
+		sealed    string
+		ad        []byte
+		expectErr bool
+	}{
+		"current key":           {sealed: sealed, ad: ad},
+		"rotated key":           {sealed: oldSealed, ad: ad},
+		"wrong additional data": {sealed: sealed, ad: []byte("session"), expectErr: true},
+		"missing additional":    {sealed: sealed, expectErr: true},
+		"same ID other key":     {sealed: otherSealed, ad: ad, expectErr: true},
+		"modified key ID":       {sealed: tamper(1), ad: ad, expectErr: true},
+		"modified nonce":        {sealed: tamper(1 + len("new")), ad: ad, expectErr: true},
+		"modified ciphertext":   {sealed: tamper(len(raw) - 20), ad: ad, expectErr: true},
+		"modified tag":          {sealed: tamper(len(raw) - 1), ad: ad, expectErr: true},
+		"truncated":             {sealed: base64.RawURLEncoding.EncodeToString(raw[:10]), ad: ad, expectErr: true},
+		"key ID length only":    {sealed: base64.RawURLEncoding.EncodeToString([]byte{200}), expectErr: true},
+		"empty":                 {expectErr: true},
+		"not base64":            {sealed: "!!!", ad: ad, expectErr: true},
+		"standard base64":       {sealed: sealed + "==", ad: ad, expectErr: true},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := sealer.Open(c.sealed, c.ad)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none, %q", actual)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := string(state), string(actual); e != a {
+				t.Errorf("expect %q state, got %q", e, a)
+			}
+		})
+	}
+
+	if _, err := oldSealer.Open(sealed, ad); err == nil {
+		t.Errorf("expect state sealed with unknown key to fail")
+	}
+	again, err := sealer.Seal(state, ad)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if again == sealed {
+		t.Errorf("expect unique nonce per seal")
+	}
+}
+
+func TestStateSealerJSON(t *testing.T) {
+	sealer, err := NewStateSealer(testStateKey("a", 32))
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	type cursor struct {
+		Query string `json:"q"`
+		Page  int    `json:"p"`
+	}
+	sealed, err := sealer.SealJSON(cursor{Query: "name", Page: 2}, nil)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+
+	var actual cursor
+	if err := sealer.OpenJSON(sealed, nil, &actual); err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if e, a := (cursor{Query: "name", Page: 2}), actual; e != a {
+		t.Errorf("expect %v, got %v", e, a)
+	}
+
+	notJSON, err := sealer.Seal([]byte("not json"), nil)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if err := sealer.OpenJSON(notJSON, nil, &actual); err == nil {
+		t.Errorf("expect invalid JSON state error")
+	}
+}
+
+type mockKMSDecrypt map[string][]byte
+
+func (m mockKMSDecrypt) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	if v, ok := m[string(ciphertext)]; ok {
+		return v, nil
+	}
+	return nil, errors.New("invalid ciphertext")
+}
+
+func TestParseStateKeys(t *testing.T) {
+	k1, k2 := base64.StdEncoding.EncodeToString([]byte("key1")), base64.StdEncoding.EncodeToString([]byte("key2"))
+
+	cases := map[string]struct {
+		value     string
+		expect    []StateKey
+		expectErr bool
+	}{
+		"single": {
+			value:  "a:" + k1,
+			expect: []StateKey{{ID: "a", Key: []byte("key1")}},
+		},
+		"rotated": {
+			value:  " b:" + k2 + " , a:" + k1 + ",",
+			expect: []StateKey{{ID: "b", Key: []byte("key2")}, {ID: "a", Key: []byte("key1")}},
+		},
+		"ID with colon key": {
+			value:  "2024-06:" + k1,
+			expect: []StateKey{{ID: "2024-06", Key: []byte("key1")}},
+		},
+		"empty": {},
+		"missing ID": {
+			value: ":" + k1, expectErr: true,
+		},
+		"missing separator": {
+			value: k1, expectErr: true,
+		},
+		"invalid base64": {
+			value: "a:!!!", expectErr: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			keys, err := ParseStateKeys(c.value)
+			if c.expectErr {
+				if err == nil {
+					t.Fatalf("expect error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := len(c.expect), len(keys); e != a {
+				t.Fatalf("expect %v keys, got %v", e, a)
+			}
+			for i := range c.expect {
+				if e, a := c.expect[i].ID, keys[i].ID; e != a {
+					t.Errorf("%d, expect %q ID, got %q", i, e, a)
+				}
+				if e, a := c.expect[i].Key, keys[i].Key; !bytes.Equal(e, a) {
+					t.Errorf("%d, expect %q key, got %q", i, e, a)
+				}
+			}
+		})
+	}
+}
+
+func TestKMSStateKeys(t *testing.T) {
+	client := mockKMSDecrypt{"encrypted": bytes.Repeat([]byte("k"), 32)}
+	encrypted := base64.StdEncoding.EncodeToString([]byte("encrypted"))
+
+	keys, err := KMSStateKeys(context.Background(), client, "a:"+encrypted)
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if e, a := string(client["encrypted"]), string(keys[0].Key); e != a {
+		t.Errorf("expect %q key, got %q", e, a)
+	}
+	if _, err := NewStateSealer(keys...); err != nil {
+		t.Errorf("expect no error, got %v", err)
+	}
+
+	other := base64.StdEncoding.EncodeToString([]byte("other"))
+	if _, err := KMSStateKeys(context.Background(), client, "a:"+other); err == nil {
+		t.Errorf("expect decrypt error, got none")
+	}
+}
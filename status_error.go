@@ -0,0 +1,107 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// StatusError is an error with the HTTP status code, and public message, of
+// the response it is translated to. Handlers return status errors, e.g.
+// lambdamux.NotFound("order not found"), instead of building the error
+// response, and the proxy translates them into JSON error responses.
+type StatusError struct {
+	StatusCode int
+
+	// Public message of the error response. Defaults to the status code's
+	// text.
+	Message string
+
+	// Err is the underlying cause of the error, if any. Not included in the
+	// response.
+	Err error
+}
+
+// NewStatusError returns a StatusError with the status code, and message.
+func NewStatusError(statusCode int, message string) error {
+	return &StatusError{StatusCode: statusCode, Message: message}
+}
+
+// BadRequest returns a 400 Bad Request StatusError with the message.
+func BadRequest(message string) error {
+	return NewStatusError(http.StatusBadRequest, message)
+}
+
+// Unauthorized returns a 401 Unauthorized StatusError with the message.
+func Unauthorized(message string) error {
+	return NewStatusError(http.StatusUnauthorized, message)
+}
+
+// Forbidden returns a 403 Forbidden StatusError with the message.
+func Forbidden(message string) error {
+	return NewStatusError(http.StatusForbidden, message)
+}
+
+// NotFound returns a 404 Not Found StatusError with the message.
+func NotFound(message string) error {
+	return NewStatusError(http.StatusNotFound, message)
+}
+
+// Conflict returns a 409 Conflict StatusError with the message.
+func Conflict(message string) error {
+	return NewStatusError(http.StatusConflict, message)
+}
+
+// UnprocessableEntity returns a 422 Unprocessable Entity StatusError with
+// the message.
+func UnprocessableEntity(message string) error {
+	return NewStatusError(http.StatusUnprocessableEntity, message)
+}
+
+func (e *StatusError) Error() string {
+	msg := e.Message
+	if len(msg) == 0 {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Err == nil {
+		return msg
+	}
+	return msg + ", " + e.Err.Error()
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// StatusErrorMapper is an ErrorMapper mapping StatusError to its status
+// code, and message.
+type StatusErrorMapper struct{}
+
+// MapError implements the ErrorMapper interface.
+func (StatusErrorMapper) MapError(err error) (ErrorMapping, bool) {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return ErrorMapping{}, false
+	}
+	return ErrorMapping{
+		StatusCode: statusErr.StatusCode,
+		Message:    statusErr.Message,
+	}, true
+}
+
+// handlerErrorResponse returns the response of the error returned by a
+// proxy's handler, translated by the proxy's error handler, or if the proxy
+// has none, and the error is a StatusError, its JSON error response.
+// Otherwise the error is returned, failing the invoke.
+func handlerErrorResponse(
+	ctx context.Context, errorHandler ErrorHandlerFunc, req APIGatewayProxyRequest, err error,
+) (APIGatewayProxyResponse, error) {
+	if errorHandler != nil {
+		return errorHandler(ctx, req, err), nil
+	}
+	if mapping, ok := (StatusErrorMapper{}).MapError(err); ok {
+		return encodeError(ctx, JSONErrorEncoder{}, req, mapping, err)
+	}
+	return APIGatewayProxyResponse{}, err
+}
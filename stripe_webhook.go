@@ -0,0 +1,127 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// StripeEvent is a Stripe webhook event.
+type StripeEvent struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	APIVersion string `json:"api_version"`
+	Created    int64  `json:"created"`
+	Livemode   bool   `json:"livemode"`
+
+	// Connected account the event occurred in, if any.
+	Account string `json:"account"`
+
+	Data struct {
+		Object             json.RawMessage `json:"object"`
+		PreviousAttributes json.RawMessage `json:"previous_attributes"`
+	} `json:"data"`
+}
+
+// BindObject unmarshals the event's data object, e.g. the PaymentIntent of a
+// "payment_intent.succeeded" event, into v.
+func (e StripeEvent) BindObject(v interface{}) error {
+	if len(e.Data.Object) == 0 {
+		return fmt.Errorf("stripe event %s has no data object", e.ID)
+	}
+	return json.Unmarshal(e.Data.Object, v)
+}
+
+// StripeHandlerFunc is the handler of a Stripe event type.
+type StripeHandlerFunc func(context.Context, StripeEvent) error
+
+// StripeMux is an API Gateway Proxy resource handler for a Stripe webhook
+// endpoint. Deliveries are verified with the endpoint's signing secret,
+// deduplicated by event ID, and routed to the handler added for the event's
+// type.
+//
+// Stripe delivers events at least once, and retries deliveries that fail or
+// time out, possibly to a different Lambda container. So that an event, e.g.
+// a completed checkout, is not handled twice, the replay cache must be
+// shared by all containers, e.g. a DynamoDBReplayCache. Events are claimed
+// before their handler is run, and released if the handler fails, so the
+// retried delivery is handled.
+type StripeMux struct {
+	mux *WebhookMux
+}
+
+// NewStripeMux initializes and returns a StripeMux verifying deliveries with
+// the signing secret, and deduplicating events with the replay cache. If
+// replay is nil, events are only deduplicated within the Lambda container.
+func NewStripeMux(signingSecret string, replay WebhookReplayCache) *StripeMux {
+	mux := NewWebhookMux(StripeWebhook(signingSecret))
+	if replay != nil {
+		mux.Replay = replay
+	}
+	return &StripeMux{mux: mux}
+}
+
+// Handle adds the handler for the event type, e.g.
+// "checkout.session.completed". The empty event type is the default handler
+// for event types without a handler. Events without a handler are
+// acknowledged without being handled.
+func (m *StripeMux) Handle(eventType string, handler StripeHandlerFunc) *StripeMux {
+	m.mux.Handle(eventType, WebhookHandlerFunc(
+		func(ctx context.Context, e WebhookEvent) error {
+			var event StripeEvent
+			if err := e.Bind(&event); err != nil {
+				return fmt.Errorf("invalid stripe event, %w", err)
+			}
+			return handler(ctx, event)
+		}))
+	return m
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (m *StripeMux) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	return m.mux.ServeResource(ctx, req)
+}
+
+// DynamoDBClaimAPI is the interface for the DynamoDB operations
+// DynamoDBReplayCache is built on. The package does not depend on the AWS
+// SDK, applications adapt their SDK DynamoDB client to the interface.
+type DynamoDBClaimAPI interface {
+	// PutClaim creates the item for the key, returning false if the item
+	// already exists and has not expired, e.g. PutItem with the condition
+	// expression "attribute_not_exists(#key) OR #ttl < :now". The item's
+	// TTL attribute is set to expires.
+	PutClaim(ctx context.Context, table, key string, expires time.Time) (bool, error)
+
+	// DeleteClaim deletes the item for the key.
+	DeleteClaim(ctx context.Context, table, key string) error
+}
+
+// DynamoDBReplayCache is a WebhookReplayCache keeping claimed delivery IDs
+// in a DynamoDB table, shared by all Lambda containers.
+type DynamoDBReplayCache struct {
+	Client DynamoDBClaimAPI
+	Table  string
+
+	// TTL delivery IDs are remembered for. Defaults to 72 hours, the
+	// period Stripe retries deliveries for. If a handler is interrupted,
+	// e.g. by the Lambda timing out, the delivery's claim is not released,
+	// and retries are not handled until the claim expires.
+	TTL time.Duration
+}
+
+// Claim implements the WebhookReplayCache interface.
+func (c DynamoDBReplayCache) Claim(ctx context.Context, deliveryID string) (bool, error) {
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = 72 * time.Hour
+	}
+	return c.Client.PutClaim(ctx, c.Table, deliveryID, time.Now().Add(ttl))
+}
+
+// Release implements the WebhookReplayCache interface.
+func (c DynamoDBReplayCache) Release(ctx context.Context, deliveryID string) error {
+	return c.Client.DeleteClaim(ctx, c.Table, deliveryID)
+}
@@ -0,0 +1,144 @@
+// Package taskgroup provides structured concurrency for handlers making
+// parallel downstream calls: a group of tasks sharing a context canceled
+// when a task fails, with bounded concurrency, panics of tasks captured as
+// errors, and a margin reserved before the context's deadline, e.g. the
+// Lambda invoke's deadline, so the handler can still respond when the tasks
+// run out of time.
+//
+//	g, ctx := taskgroup.New(ctx, taskgroup.Config{Limit: 4, Margin: 500 * time.Millisecond})
+//	for _, id := range ids {
+//		id := id
+//		g.Go(func(ctx context.Context) error {
+//			return fetch(ctx, id)
+//		})
+//	}
+//	if err := g.Wait(); err != nil {
+//		return APIGatewayProxyResponse{}, err
+//	}
+package taskgroup
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Config configures a Group.
+type Config struct {
+	// Limit is the maximum number of tasks running concurrently. Go blocks
+	// while the limit is reached. Zero, or less, is unlimited.
+	Limit int
+
+	// Margin is reserved before the parent context's deadline, e.g. the
+	// Lambda invoke's deadline, the tasks' context being canceled Margin
+	// before it, so the handler has time to respond, e.g. with partial
+	// results. Ignored if the parent context has no deadline.
+	Margin time.Duration
+}
+
+// PanicError is the error of a task that panicked.
+type PanicError struct {
+	// Value the task panicked with.
+	Value interface{}
+
+	// Stack is the stack trace of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panic: %v", e.Value)
+}
+
+// Unwrap returns the value the task panicked with if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Group is a group of tasks sharing a context, canceled when a task fails,
+// or the group's tasks are waited for. A Group must be created with New.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sem    chan struct{}
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// New returns a Group, and the context of its tasks, derived from ctx. The
+// context is canceled when a task returns an error, or panics, when Wait
+// returns, or Margin before ctx's deadline.
+func New(ctx context.Context, cfg Config) (*Group, context.Context) {
+	var cancel context.CancelFunc
+	if deadline, ok := ctx.Deadline(); ok && cfg.Margin > 0 {
+		ctx, cancel = context.WithDeadline(ctx, deadline.Add(-cfg.Margin))
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	g := &Group{ctx: ctx, cancel: cancel}
+	if cfg.Limit > 0 {
+		g.sem = make(chan struct{}, cfg.Limit)
+	}
+	return g, ctx
+}
+
+// Go runs the task in a new goroutine with the group's context, blocking
+// while the group's Limit of tasks are running. Tasks are not started once
+// the group's context is done, failing with the context's error instead.
+//
+// The first error returned by a task, or the PanicError of a task that
+// panicked, cancels the group's context, and is returned by Wait.
+func (g *Group) Go(task func(ctx context.Context) error) {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.fail(g.ctx.Err())
+			return
+		}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		if err := run(g.ctx, task); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// Wait waits for the group's tasks to return, cancels the group's context,
+// and returns the first error of the tasks, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// fail records the first error of the group's tasks, and cancels the
+// group's context.
+func (g *Group) fail(err error) {
+	g.errOnce.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+// run calls the task, returning its panic as a PanicError.
+func run(ctx context.Context, task func(context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return task(ctx)
+}
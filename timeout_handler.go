@@ -2,42 +2,321 @@ package lambdamux
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
 	"time"
 )
 
+// TimeoutInfo describes a request whose handler timed out.
+type TimeoutInfo struct {
+	// Route is the HTTP method and resource, e.g. "GET /users/{id}".
+	Route string
+
+	// Timeout of the handler.
+	Timeout time.Duration
+
+	// Elapsed is the time the handler was served for before timing out.
+	Elapsed time.Duration
+
+	// Middleware is the time elapsed between the start of the invoke, and
+	// the timeout handler being called, spent in the middleware wrapping
+	// it, e.g. authentication. Zero if the handler is not served by a proxy
+	// invoke.
+	Middleware time.Duration
+
+	// Returned is whether the handler returned, observing the cancellation
+	// of its context, within the wait configured with WithTimeoutWait.
+	Returned bool
+}
+
+// TimeoutOption configures the timeout middleware.
+type TimeoutOption func(*timeoutOptions)
+
+type timeoutOptions struct {
+	response func(context.Context, APIGatewayProxyRequest, TimeoutInfo) APIGatewayProxyResponse
+	report   func(context.Context, APIGatewayProxyRequest, TimeoutInfo)
+	cleanup  func(context.Context, APIGatewayProxyRequest, APIGatewayProxyResponse, error)
+	grace    time.Duration
+	wait     time.Duration
+}
+
+// WithSoftTimeout returns a TimeoutOption adding a soft timeout, the grace
+// duration before the hard timeout, to the handler's context. The soft
+// context, SoftContext, is canceled at the soft timeout, so the handler can
+// abandon its remaining work, e.g. downstream calls made with the soft
+// context, and respond with a partial, or degraded, response before the hard
+// timeout. Grace durations not less than the timeout are ignored.
+func WithSoftTimeout(grace time.Duration) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.grace = grace
+	}
+}
+
+type softContextKey struct{}
+
+// SoftContext returns the soft context of the timeout handler serving the
+// request, canceled at the handler's soft timeout, before the context's hard
+// timeout. Returns ctx if the handler has no soft timeout.
+//
+//	items, err := search(lambdamux.SoftContext(ctx), query)
+//	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+//		return lambdamux.JSON(http.StatusOK, partialResults(items))
+//	}
+func SoftContext(ctx context.Context) context.Context {
+	if soft, ok := ctx.Value(softContextKey{}).(context.Context); ok {
+		return soft
+	}
+	return ctx
+}
+
+// WithRouteTimeout returns a RouteOption serving the route's handler with
+// the timeout, and timeout options, e.g. a route specific soft timeout, as
+// ResourceHandlerWithTimeout does.
+func WithRouteTimeout(dur time.Duration, opts ...TimeoutOption) RouteOption {
+	return WithMiddleware(func(handler ResourceHandler) ResourceHandler {
+		return ResourceHandlerWithTimeout(dur, handler, opts...)
+	})
+}
+
+// WithTimeoutResponse returns a TimeoutOption responding to timed out
+// requests with the response returned by fn, e.g. TimeoutProblemResponse,
+// instead of returning the context's error.
+func WithTimeoutResponse(
+	fn func(context.Context, APIGatewayProxyRequest, TimeoutInfo) APIGatewayProxyResponse,
+) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.response = fn
+	}
+}
+
+// WithTimeoutReport returns a TimeoutOption calling fn with the timed out
+// request, e.g. LogTimeout, to log, or record, timeouts.
+func WithTimeoutReport(fn func(context.Context, APIGatewayProxyRequest, TimeoutInfo)) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.report = fn
+	}
+}
+
+// WithTimeoutWait returns a TimeoutOption waiting, up to max, for the timed
+// out handler to return, observing the cancellation of its context, before
+// responding, so the handler's goroutine does not keep running into the
+// container's next invoke, or while the container is frozen. The handler's
+// result is discarded. Handlers that do not return within the wait are
+// abandoned, and reported with TimeoutInfo's Returned false.
+func WithTimeoutWait(max time.Duration) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.wait = max
+	}
+}
+
+// WithOnTimeout returns a TimeoutOption calling fn with the result the
+// timed out handler returned, once it returns, to clean up the discarded
+// response, or log the handler's late error. fn is called with the
+// handler's canceled context, from the handler's goroutine, which may be
+// after the timed out request was responded to. Panics of the timed out
+// handler are passed as errors.
+func WithOnTimeout(
+	fn func(context.Context, APIGatewayProxyRequest, APIGatewayProxyResponse, error),
+) TimeoutOption {
+	return func(o *timeoutOptions) {
+		o.cleanup = fn
+	}
+}
+
+// TimeoutProblemResponse returns a 504 Gateway Timeout RFC 7807 problem
+// details response for the timed out request, with the request's route, and
+// timeout in milliseconds, as extension members.
+func TimeoutProblemResponse(
+	ctx context.Context, req APIGatewayProxyRequest, info TimeoutInfo,
+) APIGatewayProxyResponse {
+	encoder := ProblemErrorEncoder{
+		Extensions: func(context.Context, APIGatewayProxyRequest, ErrorInfo) map[string]interface{} {
+			return map[string]interface{}{
+				"route":     info.Route,
+				"timeoutMs": info.Timeout.Milliseconds(),
+			}
+		},
+	}
+
+	resp, err := encoder.EncodeError(ctx, req, ErrorInfo{
+		StatusCode: http.StatusGatewayTimeout,
+		Code:       "TIMEOUT",
+		Message:    "request timed out after " + info.Timeout.String(),
+		RequestID:  req.RequestContext.RequestID,
+		Err:        context.DeadlineExceeded,
+	})
+	if err != nil {
+		return statusResponse(http.StatusGatewayTimeout)
+	}
+	return resp
+}
+
+// LogTimeout logs the timed out request, with its route, and timings, with
+// the standard logger.
+func LogTimeout(ctx context.Context, req APIGatewayProxyRequest, info TimeoutInfo) {
+	log.Printf("%s %s timed out after %s, elapsed %s, middleware %s",
+		req.RequestContext.RequestID, info.Route, info.Timeout, info.Elapsed, info.Middleware)
+}
+
 type timeoutHandler struct {
 	Timeout time.Duration
 	Handler ResourceHandler
+
+	options timeoutOptions
 }
 
 // ResourceHandlerWithTimeout provides a resource handler with a configured
 // timeout that will be invoked per serve resource.
-func ResourceHandlerWithTimeout(dur time.Duration, handler ResourceHandler) ResourceHandler {
+//
+// The handler is served in its own goroutine, which is not stopped when the
+// request times out, the handler must return when its context is canceled.
+// The timed out handler's result is discarded, or passed to the
+// WithOnTimeout function, if any. WithTimeoutWait waits for the handler to
+// return before responding. Panics of the handler are recovered, and
+// re-panicked by the timeout handler's caller, if not timed out.
+//
+// Timed out requests return the context's error, unless a response is
+// configured with WithTimeoutResponse. The "Timeout" custom metric is added
+// for timed out requests, as AddMetric does, and the request reported to the
+// WithTimeoutReport function, if any. Requests whose context is canceled,
+// or exceeds its deadline, before the handler's timeout are not timed out,
+// and return the context's error.
+func ResourceHandlerWithTimeout(dur time.Duration, handler ResourceHandler, opts ...TimeoutOption) ResourceHandler {
+	var o timeoutOptions
+	for _, fn := range opts {
+		fn(&o)
+	}
+
 	return timeoutHandler{
 		Timeout: dur,
 		Handler: handler,
+		options: o,
 	}
 }
 
-// ServeResources wraps a resource handler with a timeout
+// timeoutResult is the result of the handler served by a timeout handler.
+type timeoutResult struct {
+	resp     APIGatewayProxyResponse
+	err      error
+	panicked bool
+	panicV   interface{}
+}
+
+// ServeResource delegates to the wrapped handler, with the handler's
+// timeout.
 func (h timeoutHandler) ServeResource(
 	ctx context.Context, req APIGatewayProxyRequest,
-) (resp APIGatewayProxyResponse, err error) {
+) (APIGatewayProxyResponse, error) {
 	var cancelFn func()
 
-	ctx, cancelFn = context.WithTimeout(ctx, h.Timeout)
+	start := time.Now()
+	parent := ctx
+	ctx, cancelFn = context.WithTimeout(parent, h.Timeout)
 	defer cancelFn()
 
-	done := make(chan struct{})
+	if grace := h.options.grace; grace > 0 && grace < h.Timeout {
+		soft, cancelSoft := context.WithTimeout(ctx, h.Timeout-grace)
+		defer cancelSoft()
+		ctx = context.WithValue(ctx, softContextKey{}, soft)
+	}
+
+	// The handler's result is sent on a buffered channel, so the handler's
+	// goroutine returns, instead of blocking, when the request timed out.
+	// The state is claimed by the first of the handler returning, or the
+	// timeout, so handlers returning concurrently with the timeout are
+	// either served, or passed to the cleanup function.
+	var state int32
+	results := make(chan timeoutResult, 1)
 	go func() {
-		resp, err = h.Handler.ServeResource(ctx, req)
-		close(done)
+		result := h.serve(ctx, req)
+		if !atomic.CompareAndSwapInt32(&state, 0, 1) && h.options.cleanup != nil {
+			err := result.err
+			if result.panicked {
+				err = fmt.Errorf("timed out handler panicked, %v", result.panicV)
+			}
+			h.options.cleanup(ctx, req, result.resp, err)
+		}
+		results <- result
 	}()
 
 	select {
+	case result := <-results:
+		return result.response()
 	case <-ctx.Done():
+	}
+
+	if !atomic.CompareAndSwapInt32(&state, 0, 2) {
+		return (<-results).response()
+	}
+
+	// The context's cancellation is only the handler's timeout if the
+	// handler's own deadline was exceeded, not the parent context's.
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil
+
+	var returned bool
+	if h.options.wait > 0 {
+		t := time.NewTimer(h.options.wait)
+		select {
+		case <-results:
+			returned = true
+		case <-t.C:
+		}
+		t.Stop()
+	}
+
+	if !timedOut {
 		return APIGatewayProxyResponse{}, ctx.Err()
-	case <-done:
-		return resp, err
 	}
+	return h.timedOut(ctx, req, start, returned)
+}
+
+// serve serves the request with the wrapped handler, recovering its panic.
+func (h timeoutHandler) serve(ctx context.Context, req APIGatewayProxyRequest) (result timeoutResult) {
+	defer func() {
+		if v := recover(); v != nil {
+			result = timeoutResult{panicked: true, panicV: v}
+		}
+	}()
+
+	result.resp, result.err = h.Handler.ServeResource(ctx, req)
+	return result
+}
+
+// response returns the handler's result, panicking with the handler's
+// panic, so it is recovered by the middleware wrapping the timeout handler,
+// e.g. ResourceHandlerWithRecovery.
+func (r timeoutResult) response() (APIGatewayProxyResponse, error) {
+	if r.panicked {
+		panic(r.panicV)
+	}
+	return r.resp, r.err
+}
+
+// timedOut returns the response of the request timed out after being served
+// since start, reporting the timeout, and whether the handler returned.
+func (h timeoutHandler) timedOut(
+	ctx context.Context, req APIGatewayProxyRequest, start time.Time, returned bool,
+) (APIGatewayProxyResponse, error) {
+	info := TimeoutInfo{
+		Route:    req.HTTPMethod + " " + req.Resource,
+		Timeout:  h.Timeout,
+		Elapsed:  time.Since(start),
+		Returned: returned,
+	}
+	if invokeStart, ok := requestStart(ctx); ok {
+		info.Middleware = start.Sub(invokeStart)
+	}
+
+	AddMetric(ctx, "Timeout", 1)
+	if h.options.report != nil {
+		h.options.report(ctx, req, info)
+	}
+
+	if h.options.response != nil {
+		return h.options.response(ctx, req, info), nil
+	}
+	return APIGatewayProxyResponse{}, ctx.Err()
 }
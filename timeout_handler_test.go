@@ -0,0 +1,314 @@
+package lambdamux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResourceHandlerWithTimeout(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+
+	// block returns the delay after the handler's context is canceled. The
+	// delay is not zero, so the timeout, not the handler, claims the request.
+	block := func(delay time.Duration, resp APIGatewayProxyResponse) ResourceHandlerFunc {
+		return func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+			<-ctx.Done()
+			time.Sleep(delay)
+			return resp, nil
+		}
+	}
+
+	cases := map[string]struct {
+		handler        ResourceHandlerFunc
+		parent         func(context.Context) (context.Context, func())
+		opts           []TimeoutOption
+		expectStatus   int
+		expectErr      error
+		expectReports  int
+		expectReturned bool
+	}{
+		"returns before timeout": {
+			handler: func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+				return Text(http.StatusOK, "ok"), nil
+			},
+			expectStatus: http.StatusOK,
+		},
+		"handler error before timeout": {
+			handler: func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+				return APIGatewayProxyResponse{}, errors.New("handler error")
+			},
+			expectErr: errors.New("handler error"),
+		},
+		"timeout error": {
+			handler:       block(5*time.Millisecond, Text(http.StatusOK, "late")),
+			expectErr:     context.DeadlineExceeded,
+			expectReports: 1,
+		},
+		"timeout response": {
+			handler:       block(5*time.Millisecond, Text(http.StatusOK, "late")),
+			opts:          []TimeoutOption{WithTimeoutResponse(TimeoutProblemResponse)},
+			expectStatus:  http.StatusGatewayTimeout,
+			expectReports: 1,
+		},
+		"wait returned": {
+			handler:        block(5*time.Millisecond, Text(http.StatusOK, "late")),
+			opts:           []TimeoutOption{WithTimeoutWait(time.Second)},
+			expectErr:      context.DeadlineExceeded,
+			expectReports:  1,
+			expectReturned: true,
+		},
+		"wait abandoned": {
+			handler:       block(200*time.Millisecond, Text(http.StatusOK, "late")),
+			opts:          []TimeoutOption{WithTimeoutWait(time.Millisecond)},
+			expectErr:     context.DeadlineExceeded,
+			expectReports: 1,
+		},
+		"parent canceled": {
+			handler: block(5*time.Millisecond, APIGatewayProxyResponse{}),
+			parent: func(ctx context.Context) (context.Context, func()) {
+				ctx, cancel := context.WithCancel(ctx)
+				time.AfterFunc(time.Millisecond, cancel)
+				return ctx, cancel
+			},
+			opts:      []TimeoutOption{WithTimeoutResponse(TimeoutProblemResponse)},
+			expectErr: context.Canceled,
+		},
+		"parent deadline": {
+			handler: block(5*time.Millisecond, APIGatewayProxyResponse{}),
+			parent: func(ctx context.Context) (context.Context, func()) {
+				return context.WithTimeout(ctx, time.Millisecond)
+			},
+			opts:      []TimeoutOption{WithTimeoutResponse(TimeoutProblemResponse)},
+			expectErr: context.DeadlineExceeded,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if c.parent != nil {
+				var cancel func()
+				ctx, cancel = c.parent(ctx)
+				defer cancel()
+			}
+
+			var mu sync.Mutex
+			var reports []TimeoutInfo
+			opts := append([]TimeoutOption{
+				WithTimeoutReport(func(ctx context.Context, req APIGatewayProxyRequest, info TimeoutInfo) {
+					mu.Lock()
+					defer mu.Unlock()
+					reports = append(reports, info)
+				}),
+			}, c.opts...)
+
+			var req APIGatewayProxyRequest
+			req.HTTPMethod, req.Resource = "GET", "/users/{id}"
+
+			handler := ResourceHandlerWithTimeout(timeout, c.handler, opts...)
+			resp, err := handler.ServeResource(ctx, req)
+
+			if c.expectErr != nil {
+				if err == nil || !(errors.Is(err, c.expectErr) || err.Error() == c.expectErr.Error()) {
+					t.Fatalf("expect %v error, got %v", c.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if e, a := c.expectStatus, resp.StatusCode; e != a {
+				t.Errorf("expect %v status, got %v", e, a)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if e, a := c.expectReports, len(reports); e != a {
+				t.Fatalf("expect %v reports, got %v", e, a)
+			}
+			if len(reports) == 0 {
+				return
+			}
+			info := reports[0]
+			if e, a := "GET /users/{id}", info.Route; e != a {
+				t.Errorf("expect %q route, got %q", e, a)
+			}
+			if e, a := timeout, info.Timeout; e != a {
+				t.Errorf("expect %v timeout, got %v", e, a)
+			}
+			if info.Elapsed < timeout {
+				t.Errorf("expect elapsed at least %v, got %v", timeout, info.Elapsed)
+			}
+			if e, a := c.expectReturned, info.Returned; e != a {
+				t.Errorf("expect %v returned, got %v", e, a)
+			}
+		})
+	}
+}
+
+func TestResourceHandlerWithTimeoutCleanup(t *testing.T) {
+	cases := map[string]struct {
+		handler   ResourceHandlerFunc
+		expectErr string
+	}{
+		"late response": {
+			handler: func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+				<-ctx.Done()
+				time.Sleep(5 * time.Millisecond)
+				return Text(http.StatusOK, "late"), nil
+			},
+		},
+		"late error": {
+			handler: func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+				<-ctx.Done()
+				time.Sleep(5 * time.Millisecond)
+				return APIGatewayProxyResponse{}, ctx.Err()
+			},
+			expectErr: context.DeadlineExceeded.Error(),
+		},
+		"late panic": {
+			handler: func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+				<-ctx.Done()
+				time.Sleep(5 * time.Millisecond)
+				panic("late")
+			},
+			expectErr: "timed out handler panicked, late",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			type cleanup struct {
+				resp APIGatewayProxyResponse
+				err  error
+			}
+			cleanups := make(chan cleanup, 2)
+
+			handler := ResourceHandlerWithTimeout(10*time.Millisecond, c.handler,
+				WithOnTimeout(func(ctx context.Context, req APIGatewayProxyRequest, resp APIGatewayProxyResponse, err error) {
+					cleanups <- cleanup{resp: resp, err: err}
+				}),
+			)
+			if _, err := handler.ServeResource(context.Background(), APIGatewayProxyRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expect timeout error, got %v", err)
+			}
+
+			var actual cleanup
+			select {
+			case actual = <-cleanups:
+			case <-time.After(time.Second):
+				t.Fatalf("expect cleanup called, got none")
+			}
+			if len(c.expectErr) != 0 {
+				if actual.err == nil || !strings.Contains(actual.err.Error(), c.expectErr) {
+					t.Errorf("expect %q error, got %v", c.expectErr, actual.err)
+				}
+			} else {
+				if actual.err != nil {
+					t.Errorf("expect no error, got %v", actual.err)
+				}
+				if e, a := "late", actual.resp.Body; e != a {
+					t.Errorf("expect %q body, got %q", e, a)
+				}
+			}
+
+			select {
+			case <-cleanups:
+				t.Errorf("expect cleanup called once")
+			case <-time.After(10 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestResourceHandlerWithTimeoutNoCleanupWhenServed(t *testing.T) {
+	var called bool
+	handler := ResourceHandlerWithTimeout(time.Second,
+		ResourceHandlerFunc(func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+			return NoContent(), nil
+		}),
+		WithOnTimeout(func(context.Context, APIGatewayProxyRequest, APIGatewayProxyResponse, error) {
+			called = true
+		}),
+	)
+
+	resp, err := handler.ServeResource(context.Background(), APIGatewayProxyRequest{})
+	if err != nil {
+		t.Fatalf("expect no error, got %v", err)
+	}
+	if e, a := http.StatusNoContent, resp.StatusCode; e != a {
+		t.Errorf("expect %v status, got %v", e, a)
+	}
+	if called {
+		t.Errorf("expect cleanup not called for served handler")
+	}
+}
+
+func TestResourceHandlerWithTimeoutPanic(t *testing.T) {
+	handler := ResourceHandlerWithTimeout(time.Second,
+		ResourceHandlerFunc(func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+			panic("handler panic")
+		}),
+	)
+
+	defer func() {
+		if e, a := "handler panic", recover(); e != a {
+			t.Errorf("expect %v panic, got %v", e, a)
+		}
+	}()
+	handler.ServeResource(context.Background(), APIGatewayProxyRequest{})
+	t.Errorf("expect panic, got none")
+}
+
+func TestSoftContext(t *testing.T) {
+	if ctx := context.Background(); SoftContext(ctx) != ctx {
+		t.Errorf("expect context without soft timeout returned")
+	}
+
+	cases := map[string]struct {
+		grace      time.Duration
+		expectSoft bool
+	}{
+		"soft timeout":        {grace: 40 * time.Millisecond, expectSoft: true},
+		"grace not less":      {grace: 50 * time.Millisecond},
+		"no soft timeout":     {},
+		"negative grace":      {grace: -time.Millisecond},
+		"grace over deadline": {grace: time.Second},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			handler := ResourceHandlerWithTimeout(50*time.Millisecond,
+				ResourceHandlerFunc(func(ctx context.Context, req APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+					soft := SoftContext(ctx)
+					if e, a := c.expectSoft, soft != ctx; e != a {
+						t.Fatalf("expect %v soft context, got %v", e, a)
+					}
+					if !c.expectSoft {
+						return NoContent(), nil
+					}
+
+					<-soft.Done()
+					if ctx.Err() != nil {
+						t.Errorf("expect soft timeout before hard timeout, got %v", ctx.Err())
+					}
+					return Text(http.StatusOK, "partial"), nil
+				}),
+				WithSoftTimeout(c.grace),
+			)
+
+			resp, err := handler.ServeResource(context.Background(), APIGatewayProxyRequest{})
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if c.expectSoft {
+				if e, a := "partial", resp.Body; e != a {
+					t.Errorf("expect %q body, got %q", e, a)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TokenStore is the interface for storing one-time tokens, e.g. password
+// reset, or email confirmation, tokens.
+type TokenStore interface {
+	// Put stores the token's value until it expires.
+	Put(ctx context.Context, key string, value []byte, expires time.Time) error
+
+	// Consume atomically removes the token, returning its value, and false
+	// if the token does not exist, was already consumed, or expired.
+	Consume(ctx context.Context, key string) (value []byte, ok bool, err error)
+}
+
+// MemoryTokenStore is a TokenStore keeping tokens in memory. Tokens are only
+// shared within a single Lambda container, so the store is only suited for
+// testing, and local development.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]memoryToken
+}
+
+type memoryToken struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryTokenStore initializes and returns a MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[string]memoryToken{}}
+}
+
+// Put implements the TokenStore interface.
+func (s *MemoryTokenStore) Put(ctx context.Context, key string, value []byte, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, t := range s.tokens {
+		if now.After(t.expires) {
+			delete(s.tokens, k)
+		}
+	}
+
+	s.tokens[key] = memoryToken{value: value, expires: expires}
+	return nil
+}
+
+// Consume implements the TokenStore interface.
+func (s *MemoryTokenStore) Consume(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[key]
+	if !ok {
+		return nil, false, nil
+	}
+	delete(s.tokens, key)
+
+	if time.Now().After(t.expires) {
+		return nil, false, nil
+	}
+	return t.value, true, nil
+}
+
+// DynamoDBTokenAPI is the interface for the DynamoDB operations
+// DynamoDBTokenStore is built on. The package does not depend on the AWS
+// SDK, applications adapt their SDK DynamoDB client to the interface.
+type DynamoDBTokenAPI interface {
+	// PutToken creates the item for the key, with the value, and the
+	// item's TTL attribute set to expires.
+	PutToken(ctx context.Context, table, key string, value []byte, expires time.Time) error
+
+	// DeleteToken deletes the item for the key, returning the deleted
+	// item's value, and TTL, e.g. DeleteItem with ReturnValues ALL_OLD.
+	// Returns false if the item did not exist.
+	DeleteToken(ctx context.Context, table, key string) (value []byte, expires time.Time, ok bool, err error)
+}
+
+// DynamoDBTokenStore is a TokenStore keeping tokens in a DynamoDB table,
+// shared by all Lambda containers. Tokens are consumed by deleting their
+// item, so only one request consumes a token. DynamoDB deletes expired items
+// lazily, so the token's expiry is checked when consumed.
+type DynamoDBTokenStore struct {
+	Client DynamoDBTokenAPI
+	Table  string
+}
+
+// Put implements the TokenStore interface.
+func (s DynamoDBTokenStore) Put(ctx context.Context, key string, value []byte, expires time.Time) error {
+	return s.Client.PutToken(ctx, s.Table, key, value, expires)
+}
+
+// Consume implements the TokenStore interface.
+func (s DynamoDBTokenStore) Consume(ctx context.Context, key string) ([]byte, bool, error) {
+	value, expires, ok, err := s.Client.DeleteToken(ctx, s.Table, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	if time.Now().After(expires) {
+		return nil, false, nil
+	}
+	return value, true, nil
+}
+
+// OneTimeTokens issues, and verifies, single use tokens for a purpose, e.g.
+// "password-reset". Tokens are random, and stored by their SHA-256 hash, and
+// purpose, so the store's contents do not reveal usable tokens, and tokens
+// issued for one purpose are not accepted for another.
+type OneTimeTokens struct {
+	Store TokenStore
+
+	// TTL of issued tokens. Defaults to 1 hour.
+	TTL time.Duration
+
+	// Param is the query parameter, or form field, of the token in requests
+	// verified by the one-time token middleware. Defaults to "token".
+	Param string
+}
+
+// Issue returns a new token for the purpose, with the value stored for the
+// token, e.g. the ID of the user resetting their password.
+func (t OneTimeTokens) Issue(ctx context.Context, purpose string, value []byte) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	ttl := t.TTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	if err := t.Store.Put(ctx, tokenKey(purpose, token), value, time.Now().Add(ttl)); err != nil {
+		return "", fmt.Errorf("failed to store one-time token, %w", err)
+	}
+	return token, nil
+}
+
+// Consume consumes the token for the purpose, returning the token's value,
+// and false if the token is not valid for the purpose, was already used, or
+// expired.
+func (t OneTimeTokens) Consume(ctx context.Context, purpose, token string) ([]byte, bool, error) {
+	if len(token) == 0 {
+		return nil, false, nil
+	}
+	value, ok, err := t.Store.Consume(ctx, tokenKey(purpose, token))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to consume one-time token, %w", err)
+	}
+	return value, ok, nil
+}
+
+func tokenKey(purpose, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return purpose + "#" + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type oneTimeTokenValueKey struct{}
+
+// OneTimeTokenValueFromContext returns the value of the request's one-time
+// token consumed by the one-time token middleware, or nil if the request is
+// not served by the middleware.
+func OneTimeTokenValueFromContext(ctx context.Context) []byte {
+	v, _ := ctx.Value(oneTimeTokenValueKey{}).([]byte)
+	return v
+}
+
+type oneTimeTokenHandler struct {
+	Tokens  OneTimeTokens
+	Purpose string
+	Handler ResourceHandler
+}
+
+// ResourceHandlerWithOneTimeToken provides a resource handler that consumes
+// the request's one-time token for the purpose before passing the request
+// to handler, with the token's value available via
+// OneTimeTokenValueFromContext. The token is read from the request's query
+// parameter, or URL encoded form field. Requests with a missing, used, or
+// expired, token are responded to with a 403 Forbidden response.
+//
+// Tokens are consumed even if handler fails. Email link scanners may follow
+// links, so links should lead to a page submitting the token, with a POST
+// request, to the route consuming it.
+func ResourceHandlerWithOneTimeToken(tokens OneTimeTokens, purpose string, handler ResourceHandler) ResourceHandler {
+	return oneTimeTokenHandler{
+		Tokens:  tokens,
+		Purpose: purpose,
+		Handler: handler,
+	}
+}
+
+// ServeResource consumes the request's one-time token, and delegates to the
+// wrapped handler.
+func (h oneTimeTokenHandler) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	param := h.Tokens.Param
+	if len(param) == 0 {
+		param = "token"
+	}
+
+	token := requestQuery(req).Get(param)
+	if len(token) == 0 {
+		if form, err := requestForm(req); err == nil {
+			token = form.Get(param)
+		}
+	}
+
+	value, ok, err := h.Tokens.Consume(ctx, h.Purpose, token)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+	if !ok {
+		return statusResponse(http.StatusForbidden), nil
+	}
+
+	return h.Handler.ServeResource(context.WithValue(ctx, oneTimeTokenValueKey{}, value), req)
+}
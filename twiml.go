@@ -0,0 +1,122 @@
+package lambdamux
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// TwiML is a Twilio Markup Language document responding to a Twilio voice,
+// or messaging, webhook. Verbs are the TwiML verb types, e.g. TwiMLSay, or
+// TwiMLMessage, in the order Twilio executes them.
+type TwiML struct {
+	XMLName xml.Name `xml:"Response"`
+	Verbs   []interface{}
+}
+
+// TwiMLSay speaks the text to the caller.
+type TwiMLSay struct {
+	XMLName  xml.Name `xml:"Say"`
+	Voice    string   `xml:"voice,attr,omitempty"`
+	Language string   `xml:"language,attr,omitempty"`
+	Loop     int      `xml:"loop,attr,omitempty"`
+	Text     string   `xml:",chardata"`
+}
+
+// TwiMLPlay plays the audio file at the URL to the caller.
+type TwiMLPlay struct {
+	XMLName xml.Name `xml:"Play"`
+	Loop    int      `xml:"loop,attr,omitempty"`
+	Digits  string   `xml:"digits,attr,omitempty"`
+	URL     string   `xml:",chardata"`
+}
+
+// TwiMLPause waits silently for the length in seconds.
+type TwiMLPause struct {
+	XMLName xml.Name `xml:"Pause"`
+	Length  int      `xml:"length,attr,omitempty"`
+}
+
+// TwiMLGather collects digits, or speech, from the caller, sending them to
+// the Action URL. Verbs nested in the gather, e.g. TwiMLSay, are executed
+// while waiting for input.
+type TwiMLGather struct {
+	XMLName   xml.Name `xml:"Gather"`
+	Input     string   `xml:"input,attr,omitempty"`
+	Action    string   `xml:"action,attr,omitempty"`
+	Method    string   `xml:"method,attr,omitempty"`
+	NumDigits int      `xml:"numDigits,attr,omitempty"`
+	Timeout   int      `xml:"timeout,attr,omitempty"`
+	Verbs     []interface{}
+}
+
+// TwiMLDial connects the caller to the number.
+type TwiMLDial struct {
+	XMLName  xml.Name `xml:"Dial"`
+	CallerID string   `xml:"callerId,attr,omitempty"`
+	Timeout  int      `xml:"timeout,attr,omitempty"`
+	Action   string   `xml:"action,attr,omitempty"`
+	Number   string   `xml:",chardata"`
+}
+
+// TwiMLRedirect transfers control of the call, or message, to the TwiML at
+// the URL.
+type TwiMLRedirect struct {
+	XMLName xml.Name `xml:"Redirect"`
+	Method  string   `xml:"method,attr,omitempty"`
+	URL     string   `xml:",chardata"`
+}
+
+// TwiMLHangup ends the call.
+type TwiMLHangup struct {
+	XMLName xml.Name `xml:"Hangup"`
+}
+
+// TwiMLReject rejects the incoming call without answering it.
+type TwiMLReject struct {
+	XMLName xml.Name `xml:"Reject"`
+	Reason  string   `xml:"reason,attr,omitempty"`
+}
+
+// TwiMLMessage replies with an SMS, or MMS, message. To and From default to
+// the sender and recipient of the incoming message.
+type TwiMLMessage struct {
+	XMLName xml.Name `xml:"Message"`
+	To      string   `xml:"to,attr,omitempty"`
+	From    string   `xml:"from,attr,omitempty"`
+	Body    string   `xml:"Body,omitempty"`
+	Media   []string `xml:"Media,omitempty"`
+}
+
+// TwiMLResponse returns a 200 OK response with the TwiML document as the
+// body.
+func TwiMLResponse(doc TwiML) (APIGatewayProxyResponse, error) {
+	b, err := xml.Marshal(doc)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to marshal TwiML, %w", err)
+	}
+
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       xml.Header + string(b),
+		},
+		HTTPHeader: http.Header{
+			"Content-Type": []string{"text/xml; charset=utf-8"},
+		},
+	}, nil
+}
+
+// ResourceHandlerWithTwilioSignature provides a resource handler that
+// verifies the X-Twilio-Signature of requests with the account's auth token
+// before passing them to handler. Requests that fail verification are
+// responded to with a 401 Unauthorized response.
+//
+// The signed URL is reconstructed from the request's Host header, as
+// described by TwilioWebhook. If the webhook's public URL differs, use
+// ResourceHandlerWithHMAC with a verifier whose Message is TwilioMessage.
+func ResourceHandlerWithTwilioSignature(authToken string, handler ResourceHandler) ResourceHandler {
+	return ResourceHandlerWithHMAC(TwilioWebhook(authToken).Verifier, handler)
+}
@@ -0,0 +1,114 @@
+package lambdamux
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Typed returns a resource handler adapting the RPC-style function, with
+// typed input, and output, to a ResourceHandler.
+//
+// The request is decoded into the function's input. The request's body, if
+// any, is decoded by its Content-Type, as Bind does. Then the input's
+// fields tagged with the "path", "query", or "header" struct tags are set
+// from the request's path parameters, query, and headers, as BindPath,
+// BindQuery, and BindHeader do. Untagged fields are only set from the body.
+// The decoded input is validated by its "validate" struct tags with the
+// TagValidator. Requests failing to decode, or validate, are returned as
+// StatusErrors, e.g. 400 Bad Request, or 422 Unprocessable Entity. Requests
+// of routes declaring In as their request type, with WithRequestType, are
+// already decoded, and validated, and are not decoded again.
+//
+// The function's output is encoded as the JSON body of a 200 OK response,
+// or a 204 No Content response if the output type is struct{}. Errors
+// returned by the function are returned by the handler, for the proxy's
+// ErrorHandler, or an ErrorResponder, to map, e.g. StatusErrors to their
+// JSON error response.
+//
+//	lambdamux.Typed(func(ctx context.Context, in GetOrderInput) (Order, error) {
+//		return store.Order(ctx, in.ID)
+//	})
+func Typed[In, Out any](fn func(ctx context.Context, in In) (Out, error)) ResourceHandler {
+	return typedHandler[In, Out]{fn: fn}
+}
+
+type typedHandler[In, Out any] struct {
+	fn func(context.Context, In) (Out, error)
+}
+
+// ServeResource implements the ResourceHandler interface, decoding the
+// function's input from the request, and encoding its output.
+func (h typedHandler[In, Out]) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	in, ok := RouteRequest[In](ctx)
+	if !ok {
+		target := interface{}(&in)
+		if t := reflect.TypeOf(in); t != nil && t.Kind() == reflect.Ptr {
+			in = reflect.New(t.Elem()).Interface().(In)
+			target = in
+		}
+
+		if err := bindTypedInput(req, target); err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+	}
+
+	out, err := h.fn(ctx, in)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	if _, ok := interface{}(out).(struct{}); ok {
+		return NoContent(), nil
+	}
+	return JSON(http.StatusOK, out)
+}
+
+// bindTypedInput decodes the request into the input pointed to by v, and
+// validates it if it is a struct.
+func bindTypedInput(req APIGatewayProxyRequest, v interface{}) error {
+	if len(req.Body) != 0 {
+		if err := req.Bind(v); err != nil {
+			return err
+		}
+	}
+
+	if _, err := bindTarget(v); err != nil {
+		// Inputs other than structs are only decoded from the body.
+		return nil
+	}
+
+	params := make(map[string][]string, len(req.PathParameters))
+	for k, p := range req.PathParameters {
+		params[k] = []string{p}
+	}
+	query := requestQuery(req)
+
+	lookups := []struct {
+		key    string
+		lookup func(string) (string, bool)
+	}{
+		{"path", func(name string) (string, bool) {
+			vs, ok := params[name]
+			return strings.Join(vs, ","), ok
+		}},
+		{"query", func(name string) (string, bool) {
+			vs, ok := query[name]
+			return strings.Join(vs, ","), ok
+		}},
+		{"header", func(name string) (string, bool) {
+			vs := req.HTTPHeader.Values(name)
+			return strings.Join(vs, ","), len(vs) != 0
+		}},
+	}
+	for _, l := range lookups {
+		if err := bindTaggedValues(v, l.key, l.lookup); err != nil {
+			return BadRequest(err.Error())
+		}
+	}
+
+	return TagValidator{}.Validate(v)
+}
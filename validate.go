@@ -0,0 +1,330 @@
+package lambdamux
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator is the interface for validating the values requests are bound
+// to, e.g. with BindAndValidate. Validate returns a ValidationError, or a
+// StatusError wrapping one, if the value is invalid.
+type Validator interface {
+	Validate(v interface{}) error
+}
+
+// ValidatorFunc provides wrapping of a function as the Validator.
+type ValidatorFunc func(v interface{}) error
+
+// Validate implements the Validator interface and delegates to the
+// function.
+func (f ValidatorFunc) Validate(v interface{}) error {
+	return f(v)
+}
+
+// FieldError is a field failing validation, and the rule it fails.
+type FieldError struct {
+	// Field is the path of the field, named by its json, or binding, struct
+	// tag, e.g. "items[0].sku".
+	Field string `json:"field"`
+
+	// Rule is the rule the field fails, e.g. "required", or "max".
+	Rule string `json:"rule"`
+
+	// Message is the public message describing the failure.
+	Message string `json:"message"`
+}
+
+// ValidationError is the error of a value failing validation, listing the
+// fields that fail. The ValidationError is a ProblemExtender, so error
+// responses include the fields as the "errors" member.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ProblemExtensions implements the ProblemExtender interface, returning the
+// fields failing validation as the "errors" member.
+func (e *ValidationError) ProblemExtensions() map[string]interface{} {
+	return map[string]interface{}{"errors": e.Fields}
+}
+
+// BindAndValidate binds the request into v, as Bind does, and validates
+// the bound value with the validator, or TagValidator if nil. Returns a 400
+// Bad Request StatusError if the request cannot be bound, or the
+// validator's error, e.g. a 422 Unprocessable Entity StatusError wrapping
+// the ValidationError listing the fields failing validation.
+func (r APIGatewayProxyRequest) BindAndValidate(v interface{}, validator Validator) error {
+	if err := r.Bind(v); err != nil {
+		return err
+	}
+	if validator == nil {
+		validator = TagValidator{}
+	}
+	return validator.Validate(v)
+}
+
+// TagValidator is a Validator of structs, by the rules of their fields'
+// "validate" struct tags, e.g. `validate:"required,max=64"`, and the
+// regular expression of their "pattern" struct tags. The rules are:
+//
+//	required     the field is not its zero value, or empty
+//	min=n        numbers are at least n, strings have at least n
+//	             characters, and slices, and maps, at least n elements
+//	max=n        as min, but at most n
+//	enum=a|b|c   the field's value is one of the values
+//
+// Fields tagged `validate:"-"`, and their nested structs, are not validated.
+// Fields other than required fields are only validated if they are not
+// their zero value. Nested structs, and structs of slices, and maps, are
+// validated. Failing values are returned as a 422 Unprocessable Entity
+// StatusError wrapping the ValidationError listing them.
+type TagValidator struct{}
+
+// Validate implements the Validator interface. Returns an error if v is not
+// a struct, or pointer to a struct, or a tag's rule is invalid.
+func (TagValidator) Validate(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot validate %T, expect struct", v)
+	}
+
+	var fields []FieldError
+	if err := validateStruct(rv, "", &fields); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return &StatusError{
+		StatusCode: http.StatusUnprocessableEntity,
+		Message:    "request validation failed",
+		Err:        &ValidationError{Fields: fields},
+	}
+}
+
+func validateStruct(rv reflect.Value, prefix string, fields *[]FieldError) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("validate") == "-" {
+			continue
+		}
+
+		name := prefix + validationFieldName(f)
+		fv := rv.Field(i)
+
+		failed, err := validateField(fv, f.Tag, name, fields)
+		if err != nil {
+			return err
+		}
+		if !failed {
+			if err := validateNested(fv, name, fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateNested validates the structs of the value, a struct, pointer to
+// a struct, or slice, or map, of structs.
+func validateNested(fv reflect.Value, name string, fields *[]FieldError) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type().PkgPath() == "time" {
+			return nil
+		}
+		return validateStruct(fv, name+".", fields)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			if err := validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", name, i), fields); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := fv.MapRange()
+		for iter.Next() {
+			if err := validateNested(iter.Value(), fmt.Sprintf("%s[%v]", name, iter.Key()), fields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validationFieldName returns the name of the field in validation errors,
+// its json, or binding, struct tag name, or its Go name.
+func validationFieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "query", "path", "header", "form"} {
+		name := strings.Split(f.Tag.Get(key), ",")[0]
+		if len(name) != 0 && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// validateField appends the rules the field fails to the fields, returning
+// if it failed any.
+func validateField(fv reflect.Value, tag reflect.StructTag, name string, fields *[]FieldError) (bool, error) {
+	rules := tag.Get("validate")
+	pattern, hasPattern := tag.Lookup("pattern")
+	if len(rules) == 0 && !hasPattern {
+		return false, nil
+	}
+
+	v := fv
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	empty := isEmptyValue(v)
+
+	fail := func(rule, format string, args ...interface{}) {
+		*fields = append(*fields, FieldError{
+			Field:   name,
+			Rule:    rule,
+			Message: name + " " + fmt.Sprintf(format, args...),
+		})
+	}
+
+	var required bool
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == "required" {
+			required = true
+		}
+	}
+	if empty {
+		if required {
+			fail("required", "is required")
+			return true, nil
+		}
+		return false, nil
+	}
+
+	before := len(*fields)
+	for _, rule := range strings.Split(rules, ",") {
+		if len(rule) == 0 || rule == "required" {
+			continue
+		}
+		parts := strings.SplitN(rule, "=", 2)
+		if len(parts) != 2 {
+			return false, fmt.Errorf("invalid %s validate rule %q", name, rule)
+		}
+
+		switch key, arg := parts[0], parts[1]; key {
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid %s validate rule %q, %w", name, rule, err)
+			}
+			n, unit, ok := validationSize(v)
+			if !ok {
+				return false, fmt.Errorf("invalid %s validate rule %q, unsupported type %s", name, rule, v.Type())
+			}
+			if key == "min" && n < limit {
+				fail(key, "must be at least %s%s", arg, unit)
+			} else if key == "max" && n > limit {
+				fail(key, "must be at most %s%s", arg, unit)
+			}
+
+		case "enum":
+			value := fmt.Sprint(v.Interface())
+			var found bool
+			for _, e := range strings.Split(arg, "|") {
+				if e == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fail(key, "must be one of %s", strings.Replace(arg, "|", ", ", -1))
+			}
+
+		default:
+			return false, fmt.Errorf("unknown %s validate rule %q", name, rule)
+		}
+	}
+
+	if hasPattern {
+		if v.Kind() != reflect.String {
+			return false, fmt.Errorf("invalid %s pattern, unsupported type %s", name, v.Type())
+		}
+		re, err := validationPattern(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid %s pattern, %w", name, err)
+		}
+		if !re.MatchString(v.String()) {
+			fail("pattern", "must match %s", pattern)
+		}
+	}
+	return len(*fields) != before, nil
+}
+
+// validationSize returns the size the min, and max, rules compare, and its
+// unit.
+func validationSize(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " elements", true
+	default:
+		return 0, "", false
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+var validationPatterns sync.Map
+
+// validationPattern returns the compiled regular expression of the
+// pattern, caching it for subsequent validations.
+func validationPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := validationPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	validationPatterns.Store(pattern, re)
+	return re, nil
+}
@@ -0,0 +1,445 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebhookTolerance is the maximum age of signed webhook timestamps accepted
+// by the webhook providers that sign a timestamp, protecting against
+// replayed deliveries.
+const WebhookTolerance = 5 * time.Minute
+
+// DefaultWebhookReplayTTL is the duration the WebhookMux's default replay
+// cache remembers deliveries for. Providers retry failed deliveries, with
+// the same delivery ID, for much longer than the WebhookTolerance, e.g.
+// Stripe for up to 3 days, so the replay TTL is independent of it.
+const DefaultWebhookReplayTTL = 72 * time.Hour
+
+// WebhookProvider describes how a webhook provider's deliveries are verified
+// and routed.
+type WebhookProvider struct {
+	Name     string
+	Verifier HMACVerifier
+
+	// EventType returns the type of event delivered. Events are routed to
+	// the WebhookMux handler registered for the type.
+	EventType func(req APIGatewayProxyRequest, body []byte) string
+
+	// DeliveryID returns the unique ID of the delivery, used to detect
+	// replayed deliveries. Empty IDs are not checked for replay.
+	DeliveryID func(req APIGatewayProxyRequest, body []byte) string
+}
+
+// StripeWebhook returns the WebhookProvider for Stripe webhooks signed with
+// the endpoint's signing secret. Events are routed by their "type", e.g.
+// "checkout.session.completed".
+func StripeWebhook(secret string) WebhookProvider {
+	return WebhookProvider{
+		Name: "stripe",
+		Verifier: HMACVerifier{
+			Secret: []byte(secret),
+			Signatures: func(req APIGatewayProxyRequest) []string {
+				return stripeSignatureValues(req)["v1"]
+			},
+			Message: func(req APIGatewayProxyRequest, body []byte) ([]byte, error) {
+				ts := stripeSignatureValues(req)["t"]
+				if len(ts) == 0 {
+					return nil, fmt.Errorf("stripe signature timestamp missing")
+				}
+				if err := checkWebhookTimestamp(ts[0]); err != nil {
+					return nil, err
+				}
+				return []byte(ts[0] + "." + string(body)), nil
+			},
+		},
+		EventType:  jsonMember("type"),
+		DeliveryID: jsonMember("id"),
+	}
+}
+
+func stripeSignatureValues(req APIGatewayProxyRequest) map[string][]string {
+	values := map[string][]string{}
+	for _, part := range strings.Split(req.HTTPHeader.Get("Stripe-Signature"), ",") {
+		if idx := strings.IndexByte(part, '='); idx > 0 {
+			k := strings.TrimSpace(part[:idx])
+			values[k] = append(values[k], strings.TrimSpace(part[idx+1:]))
+		}
+	}
+	return values
+}
+
+// GitHubWebhook returns the WebhookProvider for GitHub webhooks signed with
+// the webhook's secret. Events are routed by the X-GitHub-Event header, e.g.
+// "push".
+func GitHubWebhook(secret string) WebhookProvider {
+	return WebhookProvider{
+		Name: "github",
+		Verifier: HMACVerifier{
+			Secret: []byte(secret),
+			Header: "X-Hub-Signature-256",
+			Prefix: "sha256=",
+		},
+		EventType: func(req APIGatewayProxyRequest, body []byte) string {
+			return req.HTTPHeader.Get("X-GitHub-Event")
+		},
+		DeliveryID: func(req APIGatewayProxyRequest, body []byte) string {
+			return req.HTTPHeader.Get("X-GitHub-Delivery")
+		},
+	}
+}
+
+// TwilioWebhook returns the WebhookProvider for Twilio webhooks signed with
+// the account's auth token. Twilio signs the URL the webhook was delivered
+// to, which is reconstructed as "https://" + Host header + path + query. The
+// query is the request's RawQuery, as Twilio sent it. Events without the
+// raw query, e.g. of REST APIs, are reconstructed from the multi value query
+// parameters, with the values of each parameter in the order received, but
+// re-encoded, and sorted by name, so deliveries whose query differs in
+// encoding, or order, fail verification. If a custom domain, or stage,
+// changes the URL, the provider's Verifier.Message must be replaced with
+// TwilioMessage for the public URL.
+//
+// Twilio events are not typed, and are all routed to the default handler.
+func TwilioWebhook(authToken string) WebhookProvider {
+	return WebhookProvider{
+		Name: "twilio",
+		Verifier: HMACVerifier{
+			Secret:   []byte(authToken),
+			Hash:     sha1.New,
+			Header:   "X-Twilio-Signature",
+			Encoding: Base64Signature,
+			Message: TwilioMessage(func(req APIGatewayProxyRequest) string {
+				u := "https://" + req.HTTPHeader.Get("Host") + req.Path
+				if query := twilioQuery(req); len(query) != 0 {
+					u += "?" + query
+				}
+				return u
+			}),
+		},
+		EventType: func(req APIGatewayProxyRequest, body []byte) string {
+			return ""
+		},
+		DeliveryID: func(req APIGatewayProxyRequest, body []byte) string {
+			return req.HTTPHeader.Get("I-Twilio-Idempotency-Token")
+		},
+	}
+}
+
+// twilioQuery returns the query string of the URL Twilio delivered the
+// webhook to.
+func twilioQuery(req APIGatewayProxyRequest) string {
+	if len(req.RawQuery) != 0 {
+		return req.RawQuery
+	}
+	if len(req.MultiValueQueryStringParameters) != 0 {
+		return url.Values(req.MultiValueQueryStringParameters).Encode()
+	}
+	return requestQuery(req).Encode()
+}
+
+// TwilioMessage returns a HMACVerifier Message function building the message
+// Twilio signs, the URL followed by the sorted form parameters of POST
+// requests.
+func TwilioMessage(publicURL func(APIGatewayProxyRequest) string) func(APIGatewayProxyRequest, []byte) ([]byte, error) {
+	return func(req APIGatewayProxyRequest, body []byte) ([]byte, error) {
+		message := publicURL(req)
+
+		if mediaType, _, _ := mime.ParseMediaType(req.HTTPHeader.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+			form, err := url.ParseQuery(string(body))
+			if err != nil {
+				return nil, fmt.Errorf("invalid form body, %w", err)
+			}
+			keys := make([]string, 0, len(form))
+			for k := range form {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				for _, v := range form[k] {
+					message += k + v
+				}
+			}
+		}
+		return []byte(message), nil
+	}
+}
+
+// SlackWebhook returns the WebhookProvider for Slack requests signed with
+// the app's signing secret. Events API deliveries are routed by their inner
+// event's type, e.g. "app_mention", interactive payloads by the payload's
+// type, e.g. "block_actions", and slash commands by the command, e.g.
+// "/deploy".
+func SlackWebhook(signingSecret string) WebhookProvider {
+	return WebhookProvider{
+		Name: "slack",
+		Verifier: HMACVerifier{
+			Secret: []byte(signingSecret),
+			Header: "X-Slack-Signature",
+			Prefix: "v0=",
+			Message: func(req APIGatewayProxyRequest, body []byte) ([]byte, error) {
+				ts := req.HTTPHeader.Get("X-Slack-Request-Timestamp")
+				if err := checkWebhookTimestamp(ts); err != nil {
+					return nil, err
+				}
+				return []byte("v0:" + ts + ":" + string(body)), nil
+			},
+		},
+		EventType:  slackEventType,
+		DeliveryID: jsonMember("event_id"),
+	}
+}
+
+func slackEventType(req APIGatewayProxyRequest, body []byte) string {
+	if form, err := url.ParseQuery(string(body)); err == nil && len(form) != 0 && body[0] != '{' {
+		if payload := form.Get("payload"); len(payload) != 0 {
+			return jsonMember("type")(req, []byte(payload))
+		}
+		return form.Get("command")
+	}
+
+	var doc struct {
+		Type  string `json:"type"`
+		Event struct {
+			Type string `json:"type"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return ""
+	}
+	if doc.Type == "event_callback" {
+		return doc.Event.Type
+	}
+	return doc.Type
+}
+
+// jsonMember returns a function extracting the string member of a JSON body.
+func jsonMember(name string) func(APIGatewayProxyRequest, []byte) string {
+	return func(req APIGatewayProxyRequest, body []byte) string {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return ""
+		}
+		v, _ := doc[name].(string)
+		return v
+	}
+}
+
+func checkWebhookTimestamp(ts string) error {
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp, %q", ts)
+	}
+
+	age := time.Since(time.Unix(sec, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("signature timestamp outside tolerance, %s", age)
+	}
+	return nil
+}
+
+// WebhookEvent is a verified webhook delivery.
+type WebhookEvent struct {
+	Provider   string
+	Type       string
+	DeliveryID string
+	Body       []byte
+	Request    APIGatewayProxyRequest
+}
+
+// Bind decodes the event's payload into v. JSON bodies, and the JSON
+// "payload" form field of Slack interactive requests, are unmarshaled into
+// v. Other form bodies can only be bound to *url.Values.
+func (e WebhookEvent) Bind(v interface{}) error {
+	if len(e.Body) != 0 && e.Body[0] == '{' {
+		return json.Unmarshal(e.Body, v)
+	}
+
+	form, err := url.ParseQuery(string(e.Body))
+	if err != nil {
+		return fmt.Errorf("invalid webhook payload, %w", err)
+	}
+	if values, ok := v.(*url.Values); ok {
+		*values = form
+		return nil
+	}
+	if payload := form.Get("payload"); len(payload) != 0 {
+		return json.Unmarshal([]byte(payload), v)
+	}
+	return fmt.Errorf("cannot bind form webhook payload to %T", v)
+}
+
+// WebhookHandler is the interface for handlers of verified webhook events.
+type WebhookHandler interface {
+	ServeWebhook(context.Context, WebhookEvent) error
+}
+
+// WebhookHandlerFunc provides wrapping of a function as the WebhookHandler.
+type WebhookHandlerFunc func(context.Context, WebhookEvent) error
+
+// ServeWebhook implements the WebhookHandler interface and delegates to the
+// function.
+func (f WebhookHandlerFunc) ServeWebhook(ctx context.Context, e WebhookEvent) error {
+	return f(ctx, e)
+}
+
+// WebhookReplayCache is the interface for detecting replayed webhook
+// deliveries by their delivery ID.
+type WebhookReplayCache interface {
+	// Claim records the delivery ID as being handled, returning false if
+	// the ID was already claimed.
+	Claim(ctx context.Context, deliveryID string) (bool, error)
+
+	// Release removes the claim of a delivery ID whose handling failed, so
+	// the provider's retry of the delivery is handled.
+	Release(ctx context.Context, deliveryID string) error
+}
+
+// WebhookMux is an API Gateway Proxy resource handler receiving webhook
+// deliveries from a provider. Deliveries are verified, checked for replay,
+// and routed to the WebhookHandler registered for the event's type.
+//
+// Deliveries failing verification are responded to with a 401 Unauthorized
+// response. Replayed deliveries, and deliveries of event types without a
+// handler, are acknowledged with a 200 OK response without being handled.
+// Errors returned by handlers are returned, so the provider retries the
+// delivery.
+type WebhookMux struct {
+	provider WebhookProvider
+	handlers map[string]WebhookHandler
+
+	// Replay detects replayed deliveries. Defaults to an in-memory cache
+	// within the Lambda container, remembering deliveries for the
+	// DefaultWebhookReplayTTL. Use NewWebhookReplayCache for another TTL, or
+	// a shared store, e.g. DynamoDB, to detect replays across containers.
+	Replay WebhookReplayCache
+}
+
+// NewWebhookMux initializes and returns a WebhookMux for the provider, that
+// event handlers can be added to via the Handle method.
+func NewWebhookMux(provider WebhookProvider) *WebhookMux {
+	return &WebhookMux{
+		provider: provider,
+		handlers: map[string]WebhookHandler{},
+		Replay:   NewWebhookReplayCache(DefaultWebhookReplayTTL),
+	}
+}
+
+// Handle adds the handler for the event type, replacing any existing handler
+// for the type. The empty event type is the default handler for event types
+// without a handler.
+func (m *WebhookMux) Handle(eventType string, handler WebhookHandler) *WebhookMux {
+	m.handlers[eventType] = handler
+	return m
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (m *WebhookMux) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (resp APIGatewayProxyResponse, err error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return statusResponse(http.StatusBadRequest), nil
+	}
+	if err := m.provider.Verifier.verify(req, body); err != nil {
+		return statusResponse(http.StatusUnauthorized), nil
+	}
+
+	event := WebhookEvent{
+		Provider: m.provider.Name,
+		Body:     body,
+		Request:  req,
+	}
+	if m.provider.EventType != nil {
+		event.Type = m.provider.EventType(req, body)
+	}
+	if m.provider.DeliveryID != nil {
+		event.DeliveryID = m.provider.DeliveryID(req, body)
+	}
+
+	h, ok := m.handlers[event.Type]
+	if !ok {
+		if h, ok = m.handlers[""]; !ok {
+			return statusResponse(http.StatusOK), nil
+		}
+	}
+
+	var claimID string
+	if len(event.DeliveryID) != 0 && m.Replay != nil {
+		claimID = m.provider.Name + "#" + event.DeliveryID
+		claimed, err := m.Replay.Claim(ctx, claimID)
+		if err != nil {
+			return resp, fmt.Errorf("failed to check webhook replay, %w", err)
+		}
+		if !claimed {
+			return statusResponse(http.StatusOK), nil
+		}
+	}
+
+	if err := h.ServeWebhook(ctx, event); err != nil {
+		if len(claimID) != 0 {
+			m.Replay.Release(ctx, claimID)
+		}
+		return resp, err
+	}
+	return statusResponse(http.StatusOK), nil
+}
+
+// memoryReplaySweepInterval is the minimum interval between sweeps of the
+// expired deliveries of the in-memory replay cache.
+const memoryReplaySweepInterval = time.Minute
+
+type memoryReplayCache struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	seen  map[string]time.Time
+	swept time.Time
+}
+
+// NewWebhookReplayCache returns an in-memory WebhookReplayCache, within the
+// Lambda container, remembering deliveries for the ttl.
+func NewWebhookReplayCache(ttl time.Duration) WebhookReplayCache {
+	return &memoryReplayCache{ttl: ttl, seen: map[string]time.Time{}}
+}
+
+// Claim claims the delivery ID. Expired deliveries are expired when looked
+// up, and swept at most once per sweep interval, so claims do not scan all
+// deliveries.
+func (c *memoryReplayCache) Claim(ctx context.Context, id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.swept) >= memoryReplaySweepInterval {
+		for k, expires := range c.seen {
+			if now.After(expires) {
+				delete(c.seen, k)
+			}
+		}
+		c.swept = now
+	}
+
+	if expires, ok := c.seen[id]; ok && !now.After(expires) {
+		return false, nil
+	}
+	c.seen[id] = now.Add(c.ttl)
+	return true, nil
+}
+
+func (c *memoryReplayCache) Release(ctx context.Context, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.seen, id)
+	return nil
+}
@@ -0,0 +1,351 @@
+package lambdamux
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func hmacHex(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookRequest(body string, header http.Header) APIGatewayProxyRequest {
+	var req APIGatewayProxyRequest
+	req.HTTPMethod = "POST"
+	req.Path = "/hooks"
+	req.Body = body
+	req.HTTPHeader = header
+	return req
+}
+
+func TestWebhookProviders(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*WebhookTolerance).Unix(), 10)
+
+	const stripeBody = `{"id":"evt_1","type":"invoice.paid"}`
+	const slackBody = `{"type":"event_callback","event_id":"Ev1","event":{"type":"app_mention"}}`
+	const twilioBody = `To=%2B15550001&From=%2B15550002&Body=hi`
+
+	twilioSig := func(token, u string) string {
+		mac := hmac.New(sha1.New, []byte(token))
+		mac.Write([]byte(u + "Bodyhi" + "From+15550002" + "To+15550001"))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	cases := map[string]struct {
+		provider       WebhookProvider
+		req            APIGatewayProxyRequest
+		expectVerified bool
+		expectType     string
+		expectID       string
+	}{
+		"stripe": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(stripeBody, http.Header{
+				"Stripe-Signature": {"t=" + now + ",v1=" + hmacHex("secret", now+"."+stripeBody)},
+			}),
+			expectVerified: true, expectType: "invoice.paid", expectID: "evt_1",
+		},
+		"stripe rotated secret": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(stripeBody, http.Header{
+				"Stripe-Signature": {"t=" + now + ",v1=" + hmacHex("old", now+"."+stripeBody) +
+					",v1=" + hmacHex("secret", now+"."+stripeBody)},
+			}),
+			expectVerified: true, expectType: "invoice.paid", expectID: "evt_1",
+		},
+		"stripe wrong secret": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(stripeBody, http.Header{
+				"Stripe-Signature": {"t=" + now + ",v1=" + hmacHex("other", now+"."+stripeBody)},
+			}),
+		},
+		"stripe stale timestamp": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(stripeBody, http.Header{
+				"Stripe-Signature": {"t=" + stale + ",v1=" + hmacHex("secret", stale+"."+stripeBody)},
+			}),
+		},
+		"stripe missing timestamp": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(stripeBody, http.Header{
+				"Stripe-Signature": {"v1=" + hmacHex("secret", "."+stripeBody)},
+			}),
+		},
+		"stripe modified body": {
+			provider: StripeWebhook("secret"),
+			req: webhookRequest(`{"id":"evt_2","type":"invoice.paid"}`, http.Header{
+				"Stripe-Signature": {"t=" + now + ",v1=" + hmacHex("secret", now+"."+stripeBody)},
+			}),
+		},
+		"github": {
+			provider: GitHubWebhook("secret"),
+			req: webhookRequest(`{"ref":"main"}`, http.Header{
+				"X-Hub-Signature-256": {"sha256=" + hmacHex("secret", `{"ref":"main"}`)},
+				"X-Github-Event":      {"push"},
+				"X-Github-Delivery":   {"d1"},
+			}),
+			expectVerified: true, expectType: "push", expectID: "d1",
+		},
+		"github missing prefix": {
+			provider: GitHubWebhook("secret"),
+			req: webhookRequest(`{"ref":"main"}`, http.Header{
+				"X-Hub-Signature-256": {hmacHex("secret", `{"ref":"main"}`)},
+			}),
+		},
+		"github missing signature": {
+			provider: GitHubWebhook("secret"),
+			req:      webhookRequest(`{"ref":"main"}`, http.Header{}),
+		},
+		"slack": {
+			provider: SlackWebhook("secret"),
+			req: webhookRequest(slackBody, http.Header{
+				"X-Slack-Request-Timestamp": {now},
+				"X-Slack-Signature":         {"v0=" + hmacHex("secret", "v0:"+now+":"+slackBody)},
+			}),
+			expectVerified: true, expectType: "app_mention", expectID: "Ev1",
+		},
+		"slack slash command": {
+			provider: SlackWebhook("secret"),
+			req: webhookRequest("command=%2Fdeploy&text=prod", http.Header{
+				"X-Slack-Request-Timestamp": {now},
+				"X-Slack-Signature":         {"v0=" + hmacHex("secret", "v0:"+now+":command=%2Fdeploy&text=prod")},
+			}),
+			expectVerified: true, expectType: "/deploy",
+		},
+		"slack stale timestamp": {
+			provider: SlackWebhook("secret"),
+			req: webhookRequest(slackBody, http.Header{
+				"X-Slack-Request-Timestamp": {stale},
+				"X-Slack-Signature":         {"v0=" + hmacHex("secret", "v0:"+stale+":"+slackBody)},
+			}),
+		},
+		"twilio": {
+			provider: TwilioWebhook("token"),
+			req: func() APIGatewayProxyRequest {
+				req := webhookRequest(twilioBody, http.Header{
+					"Host":                       {"example.com"},
+					"Content-Type":               {"application/x-www-form-urlencoded"},
+					"X-Twilio-Signature":         {twilioSig("token", "https://example.com/hooks?b=2&a=1")},
+					"I-Twilio-Idempotency-Token": {"t1"},
+				})
+				req.RawQuery = "b=2&a=1"
+				return req
+			}(),
+			expectVerified: true, expectID: "t1",
+		},
+		"twilio query reordered": {
+			provider: TwilioWebhook("token"),
+			req: func() APIGatewayProxyRequest {
+				req := webhookRequest(twilioBody, http.Header{
+					"Host":               {"example.com"},
+					"Content-Type":       {"application/x-www-form-urlencoded"},
+					"X-Twilio-Signature": {twilioSig("token", "https://example.com/hooks?b=2&a=1")},
+				})
+				req.RawQuery = "a=1&b=2"
+				return req
+			}(),
+		},
+		"twilio multi value query": {
+			provider: TwilioWebhook("token"),
+			req: func() APIGatewayProxyRequest {
+				req := webhookRequest(twilioBody, http.Header{
+					"Host":               {"example.com"},
+					"Content-Type":       {"application/x-www-form-urlencoded"},
+					"X-Twilio-Signature": {twilioSig("token", "https://example.com/hooks?a=1&a=0&b=2")},
+				})
+				req.MultiValueQueryStringParameters = map[string][]string{"b": {"2"}, "a": {"1", "0"}}
+				return req
+			}(),
+			expectVerified: true,
+		},
+		"twilio wrong host": {
+			provider: TwilioWebhook("token"),
+			req: webhookRequest(twilioBody, http.Header{
+				"Host":               {"evil.example.com"},
+				"Content-Type":       {"application/x-www-form-urlencoded"},
+				"X-Twilio-Signature": {twilioSig("token", "https://example.com/hooks")},
+			}),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			var event WebhookEvent
+			var handled bool
+			m := NewWebhookMux(c.provider).
+				Handle("", WebhookHandlerFunc(func(ctx context.Context, e WebhookEvent) error {
+					event, handled = e, true
+					return nil
+				}))
+
+			resp, err := m.ServeResource(context.Background(), c.req)
+			if err != nil {
+				t.Fatalf("expect no error, got %v", err)
+			}
+			if !c.expectVerified {
+				if e, a := http.StatusUnauthorized, resp.StatusCode; e != a {
+					t.Errorf("expect %v status, got %v", e, a)
+				}
+				if handled {
+					t.Errorf("expect unverified delivery not handled")
+				}
+				return
+			}
+
+			if e, a := http.StatusOK, resp.StatusCode; e != a {
+				t.Fatalf("expect %v status, got %v", e, a)
+			}
+			if !handled {
+				t.Fatalf("expect delivery handled")
+			}
+			if e, a := c.provider.Name, event.Provider; e != a {
+				t.Errorf("expect %q provider, got %q", e, a)
+			}
+			if e, a := c.expectType, event.Type; e != a {
+				t.Errorf("expect %q type, got %q", e, a)
+			}
+			if e, a := c.expectID, event.DeliveryID; e != a {
+				t.Errorf("expect %q delivery ID, got %q", e, a)
+			}
+		})
+	}
+}
+
+func TestWebhookMuxReplay(t *testing.T) {
+	const body = `{"ref":"main"}`
+	req := webhookRequest(body, http.Header{
+		"X-Hub-Signature-256": {"sha256=" + hmacHex("secret", body)},
+		"X-Github-Event":      {"push"},
+		"X-Github-Delivery":   {"d1"},
+	})
+
+	var calls int
+	var fail bool
+	m := NewWebhookMux(GitHubWebhook("secret")).
+		Handle("push", WebhookHandlerFunc(func(ctx context.Context, e WebhookEvent) error {
+			calls++
+			if fail {
+				return errors.New("handler failed")
+			}
+			return nil
+		}))
+
+	steps := []struct {
+		fail        bool
+		expectErr   bool
+		expectCalls int
+	}{
+		{fail: true, expectErr: true, expectCalls: 1},
+		{expectCalls: 2},
+		{expectCalls: 2},
+		{expectCalls: 2},
+	}
+
+	for i, step := range steps {
+		fail = step.fail
+		resp, err := m.ServeResource(context.Background(), req)
+		if step.expectErr {
+			if err == nil {
+				t.Fatalf("%d, expect error, got none", i)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%d, expect no error, got %v", i, err)
+			}
+			if e, a := http.StatusOK, resp.StatusCode; e != a {
+				t.Errorf("%d, expect %v status, got %v", i, e, a)
+			}
+		}
+		if e, a := step.expectCalls, calls; e != a {
+			t.Errorf("%d, expect %v calls, got %v", i, e, a)
+		}
+	}
+}
+
+func TestWebhookReplayCache(t *testing.T) {
+	ctx := context.Background()
+
+	cases := map[string]struct {
+		ttl    time.Duration
+		steps  func(WebhookReplayCache) []bool
+		expect []bool
+	}{
+		"claimed once": {
+			ttl: time.Hour,
+			steps: func(c WebhookReplayCache) []bool {
+				a, _ := c.Claim(ctx, "a")
+				b, _ := c.Claim(ctx, "a")
+				other, _ := c.Claim(ctx, "b")
+				return []bool{a, b, other}
+			},
+			expect: []bool{true, false, true},
+		},
+		"released": {
+			ttl: time.Hour,
+			steps: func(c WebhookReplayCache) []bool {
+				a, _ := c.Claim(ctx, "a")
+				c.Release(ctx, "a")
+				b, _ := c.Claim(ctx, "a")
+				return []bool{a, b}
+			},
+			expect: []bool{true, true},
+		},
+		"expired": {
+			ttl: time.Millisecond,
+			steps: func(c WebhookReplayCache) []bool {
+				a, _ := c.Claim(ctx, "a")
+				time.Sleep(5 * time.Millisecond)
+				b, _ := c.Claim(ctx, "a")
+				return []bool{a, b}
+			},
+			expect: []bool{true, true},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := c.steps(NewWebhookReplayCache(c.ttl))
+			for i := range c.expect {
+				if e, a := c.expect[i], actual[i]; e != a {
+					t.Errorf("%d, expect %v claimed, got %v", i, e, a)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookReplayCacheSweep(t *testing.T) {
+	ctx := context.Background()
+	c := NewWebhookReplayCache(time.Millisecond).(*memoryReplayCache)
+
+	c.Claim(ctx, "a")
+	time.Sleep(5 * time.Millisecond)
+
+	// Expired deliveries are not swept until the sweep interval passes.
+	c.Claim(ctx, "b")
+	if _, ok := c.seen["a"]; !ok {
+		t.Fatalf("expect expired delivery kept until sweep")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	c.swept = time.Now().Add(-memoryReplaySweepInterval)
+	c.Claim(ctx, "c")
+	if _, ok := c.seen["a"]; ok {
+		t.Errorf("expect expired delivery swept")
+	}
+	if e, a := 1, len(c.seen); e != a {
+		t.Errorf("expect %v deliveries, got %v, %v", e, a, c.seen)
+	}
+}
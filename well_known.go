@@ -0,0 +1,281 @@
+package lambdamux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WellKnown provides resource handlers for common well-known resources.
+// Resources that are nil are not registered.
+type WellKnown struct {
+	SecurityTxt             *SecurityTxt
+	RobotsTxt               *RobotsTxt
+	AppleAppSiteAssociation *AppleAppSiteAssociation
+	OpenIDConfiguration     *OpenIDConfiguration
+}
+
+// Register adds the well-known resource handlers to the ServeResource.
+func (w WellKnown) Register(s *ServeResource) *ServeResource {
+	if w.SecurityTxt != nil {
+		s.Handle("/.well-known/security.txt", w.SecurityTxt)
+	}
+	if w.RobotsTxt != nil {
+		s.Handle("/robots.txt", w.RobotsTxt)
+	}
+	if w.AppleAppSiteAssociation != nil {
+		s.Handle("/.well-known/apple-app-site-association", w.AppleAppSiteAssociation)
+	}
+	if w.OpenIDConfiguration != nil {
+		s.Handle("/.well-known/openid-configuration", w.OpenIDConfiguration)
+	}
+	return s
+}
+
+func wellKnownResponse(contentType, body string) APIGatewayProxyResponse {
+	return APIGatewayProxyResponse{
+		APIGatewayProxyResponse: events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Body:       body,
+		},
+		HTTPHeader: http.Header{
+			"Content-Type":  []string{contentType},
+			"Cache-Control": []string{"public, max-age=3600"},
+		},
+	}
+}
+
+// SecurityTxt is a resource handler serving a RFC 9116 security.txt
+// document.
+type SecurityTxt struct {
+	// Required fields.
+	Contact []string
+	Expires time.Time
+
+	// Optional fields.
+	Encryption         []string
+	Acknowledgments    []string
+	PreferredLanguages []string
+	Canonical          []string
+	Policy             []string
+	Hiring             []string
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (t *SecurityTxt) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	var b strings.Builder
+	writeFields := func(name string, values []string) {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+
+	writeFields("Contact", t.Contact)
+	fmt.Fprintf(&b, "Expires: %s\n", t.Expires.UTC().Format(time.RFC3339))
+	writeFields("Encryption", t.Encryption)
+	writeFields("Acknowledgments", t.Acknowledgments)
+	if len(t.PreferredLanguages) != 0 {
+		writeFields("Preferred-Languages", []string{strings.Join(t.PreferredLanguages, ", ")})
+	}
+	writeFields("Canonical", t.Canonical)
+	writeFields("Policy", t.Policy)
+	writeFields("Hiring", t.Hiring)
+
+	return wellKnownResponse("text/plain; charset=utf-8", b.String()), nil
+}
+
+// RobotsTxt is a resource handler serving a robots.txt document.
+type RobotsTxt struct {
+	Groups   []RobotsGroup
+	Sitemaps []string
+}
+
+// RobotsGroup is a group of robots.txt rules for user agents.
+type RobotsGroup struct {
+	UserAgents []string
+	Allow      []string
+	Disallow   []string
+
+	// Seconds crawlers should wait between requests, if not zero.
+	CrawlDelay int
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (t *RobotsTxt) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	var b strings.Builder
+	for i, g := range t.Groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, ua := range g.UserAgents {
+			fmt.Fprintf(&b, "User-agent: %s\n", ua)
+		}
+		for _, p := range g.Allow {
+			fmt.Fprintf(&b, "Allow: %s\n", p)
+		}
+		for _, p := range g.Disallow {
+			fmt.Fprintf(&b, "Disallow: %s\n", p)
+		}
+		if g.CrawlDelay > 0 {
+			fmt.Fprintf(&b, "Crawl-delay: %d\n", g.CrawlDelay)
+		}
+	}
+	if len(t.Sitemaps) != 0 {
+		b.WriteString("\n")
+	}
+	for _, s := range t.Sitemaps {
+		fmt.Fprintf(&b, "Sitemap: %s\n", s)
+	}
+
+	return wellKnownResponse("text/plain; charset=utf-8", b.String()), nil
+}
+
+// AppleAppSiteAssociation is a resource handler serving an
+// apple-app-site-association document for universal links, and shared web
+// credentials.
+type AppleAppSiteAssociation struct {
+	AppLinks []AppLinkDetail
+
+	// App IDs sharing web credentials with the domain, e.g.
+	// "ABCDE12345.com.example.app".
+	WebCredentials []string
+}
+
+// AppLinkDetail associates app IDs with the URL paths the apps handle.
+// Paths may use "*" and "?" wildcards, e.g. "/buy/*".
+type AppLinkDetail struct {
+	AppIDs []string
+	Paths  []string
+}
+
+type aasaDocument struct {
+	AppLinks struct {
+		Details []aasaDetail `json:"details"`
+	} `json:"applinks"`
+	WebCredentials *struct {
+		Apps []string `json:"apps"`
+	} `json:"webcredentials,omitempty"`
+}
+
+type aasaDetail struct {
+	AppIDs     []string            `json:"appIDs"`
+	Components []map[string]string `json:"components"`
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (a *AppleAppSiteAssociation) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	var doc aasaDocument
+	doc.AppLinks.Details = []aasaDetail{}
+	for _, d := range a.AppLinks {
+		detail := aasaDetail{AppIDs: d.AppIDs, Components: []map[string]string{}}
+		for _, p := range d.Paths {
+			detail.Components = append(detail.Components, map[string]string{"/": p})
+		}
+		doc.AppLinks.Details = append(doc.AppLinks.Details, detail)
+	}
+	if len(a.WebCredentials) != 0 {
+		doc.WebCredentials = &struct {
+			Apps []string `json:"apps"`
+		}{Apps: a.WebCredentials}
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return APIGatewayProxyResponse{}, fmt.Errorf("failed to marshal apple-app-site-association, %w", err)
+	}
+	return wellKnownResponse("application/json", string(body)), nil
+}
+
+// OpenIDConfiguration is a resource handler passing through the OpenID
+// Connect discovery document of an identity provider, e.g. when the
+// application fronts its IdP's issuer URL. The document is cached for the
+// TTL.
+type OpenIDConfiguration struct {
+	// Issuer URL of the identity provider. The document is fetched from
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer string
+
+	// HTTP client the document is fetched with. Defaults to a client with
+	// a 5 second timeout.
+	Client *http.Client
+
+	// Duration the document is cached for. Defaults to one hour.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	doc     []byte
+	fetched time.Time
+}
+
+// ServeResource implements the ResourceHandler interface.
+func (o *OpenIDConfiguration) ServeResource(
+	ctx context.Context, req APIGatewayProxyRequest,
+) (APIGatewayProxyResponse, error) {
+	doc, err := o.document(ctx)
+	if err != nil {
+		return APIGatewayProxyResponse{}, err
+	}
+
+	resp := wellKnownResponse("application/json", string(doc))
+	resp.HTTPHeader.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(o.ttl()/time.Second)))
+	return resp, nil
+}
+
+func (o *OpenIDConfiguration) ttl() time.Duration {
+	if o.TTL == 0 {
+		return time.Hour
+	}
+	return o.TTL
+}
+
+func (o *OpenIDConfiguration) document(ctx context.Context) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.doc != nil && time.Since(o.fetched) < o.ttl() {
+		return o.doc, nil
+	}
+
+	client := o.Client
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	u := strings.TrimSuffix(o.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenID configuration request, %w", err)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenID configuration, %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch OpenID configuration, status %d", resp.StatusCode)
+	}
+
+	doc, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenID configuration, %w", err)
+	}
+
+	o.doc, o.fetched = doc, time.Now()
+	return doc, nil
+}
@@ -0,0 +1,460 @@
+// Package xlsx provides Excel workbook (xlsx) export responses of slices of
+// structs. Each struct field is a column, configured by the "xlsx" struct
+// tag, and each slice element a row.
+//
+//	type Order struct {
+//		ID      string    `xlsx:"Order ID,width=20"`
+//		Total   float64   `xlsx:"Total,format=0.00"`
+//		Placed  time.Time `xlsx:"Placed"`
+//		Secret  string    `xlsx:"-"`
+//	}
+//
+//	return xlsx.Response("orders.xlsx", xlsx.Sheet{Name: "Orders", Rows: orders})
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	lambdamux "go.jasdel.dev/aws/lambda-mux"
+)
+
+// ContentType is the media type of xlsx workbooks.
+const ContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Sheet is a worksheet of a workbook.
+type Sheet struct {
+	// Name of the sheet, at most 31 characters, and not containing any of
+	// the characters []:*?/\. Defaults to "Sheet" and the sheet's number.
+	Name string
+
+	// Rows is a slice of structs, or pointers to structs, of the sheet's
+	// rows. The struct's exported fields are the sheet's columns, in field
+	// order. The "xlsx" struct tag sets the column's header, defaulting to
+	// the field's name, and options, e.g. `xlsx:"Total,width=12,format=0.00"`.
+	// Fields tagged "-" are skipped.
+	//
+	// The width option sets the column's width in characters, and the
+	// format option the Excel number format of numeric, and time, cells.
+	Rows interface{}
+
+	// NoHeader omits the header row of column headers.
+	NoHeader bool
+
+	// FreezeHeader keeps the header row visible while scrolling.
+	FreezeHeader bool
+
+	// AutoFilter adds filter controls to the header row.
+	AutoFilter bool
+}
+
+// Response returns a file download response of the workbook of the sheets,
+// with the filename.
+func Response(filename string, sheets ...Sheet) (lambdamux.APIGatewayProxyResponse, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, sheets...); err != nil {
+		return lambdamux.APIGatewayProxyResponse{}, err
+	}
+
+	var resp lambdamux.APIGatewayProxyResponse
+	resp.File(filename, ContentType, buf.Bytes())
+	return resp, nil
+}
+
+// Write writes the workbook of the sheets to w.
+func Write(w io.Writer, sheets ...Sheet) error {
+	if len(sheets) == 0 {
+		return fmt.Errorf("workbook must have at least one sheet")
+	}
+
+	var styles styleSheet
+	docs := make([][]byte, len(sheets))
+	names := make([]string, len(sheets))
+	seen := map[string]bool{}
+
+	for i, sheet := range sheets {
+		name := sheet.Name
+		if len(name) == 0 {
+			name = "Sheet" + strconv.Itoa(i+1)
+		}
+		if err := validateSheetName(name); err != nil {
+			return err
+		}
+		if seen[strings.ToLower(name)] {
+			return fmt.Errorf("duplicate sheet name %q", name)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+
+		doc, err := writeSheet(sheet, &styles)
+		if err != nil {
+			return fmt.Errorf("invalid sheet %q, %w", name, err)
+		}
+		docs[i] = doc
+	}
+
+	z := zip.NewWriter(w)
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", []byte(rootRels)},
+		{"xl/workbook.xml", workbook(names)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", styles.xml()},
+	}
+	for i, doc := range docs {
+		files = append(files, struct {
+			name string
+			body []byte
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), doc})
+	}
+
+	for _, f := range files {
+		fw, err := z.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.body); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func validateSheetName(name string) error {
+	if len([]rune(name)) > 31 {
+		return fmt.Errorf("sheet name %q longer than 31 characters", name)
+	}
+	if strings.ContainsAny(name, `[]:*?/\`) {
+		return fmt.Errorf("sheet name %q contains invalid characters", name)
+	}
+	return nil
+}
+
+type column struct {
+	index  []int
+	header string
+	width  float64
+	style  int
+}
+
+func columns(t reflect.Type, styles *styleSheet) ([]column, error) {
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("xlsx")
+		if tag == "-" {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		col := column{index: f.Index, header: parts[0]}
+		if len(col.header) == 0 {
+			col.header = f.Name
+		}
+
+		format := ""
+		for _, opt := range parts[1:] {
+			switch {
+			case strings.HasPrefix(opt, "width="):
+				w, err := strconv.ParseFloat(opt[len("width="):], 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid %s column width, %w", f.Name, err)
+				}
+				col.width = w
+			case strings.HasPrefix(opt, "format="):
+				format = opt[len("format="):]
+			default:
+				return nil, fmt.Errorf("unknown %s column option %q", f.Name, opt)
+			}
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if len(format) == 0 && ft == reflect.TypeOf(time.Time{}) {
+			format = "yyyy-mm-dd hh:mm:ss"
+		}
+		if len(format) != 0 {
+			col.style = styles.numFmt(format)
+		}
+
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+func writeSheet(sheet Sheet, styles *styleSheet) ([]byte, error) {
+	rows := reflect.ValueOf(sheet.Rows)
+	if rows.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("rows must be a slice of structs, %T", sheet.Rows)
+	}
+	elem := rows.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("rows must be a slice of structs, %T", sheet.Rows)
+	}
+
+	cols, err := columns(elem, styles)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<worksheet xmlns="` + mainNS + `">`)
+
+	if sheet.FreezeHeader && !sheet.NoHeader {
+		buf.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+			`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>` +
+			`</sheetView></sheetViews>`)
+	}
+
+	hasWidths := false
+	for _, col := range cols {
+		hasWidths = hasWidths || col.width > 0
+	}
+	if hasWidths {
+		buf.WriteString(`<cols>`)
+		for i, col := range cols {
+			if col.width > 0 {
+				fmt.Fprintf(&buf, `<col min="%d" max="%d" width="%s" customWidth="1"/>`,
+					i+1, i+1, strconv.FormatFloat(col.width, 'f', -1, 64))
+			}
+		}
+		buf.WriteString(`</cols>`)
+	}
+
+	buf.WriteString(`<sheetData>`)
+	r := 0
+	if !sheet.NoHeader {
+		r++
+		fmt.Fprintf(&buf, `<row r="%d">`, r)
+		for i, col := range cols {
+			writeStringCell(&buf, cellRef(i, r), col.header, headerStyle)
+		}
+		buf.WriteString(`</row>`)
+	}
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		for row.Kind() == reflect.Ptr {
+			if row.IsNil() {
+				break
+			}
+			row = row.Elem()
+		}
+
+		r++
+		fmt.Fprintf(&buf, `<row r="%d">`, r)
+		if row.Kind() == reflect.Struct {
+			for c, col := range cols {
+				writeCell(&buf, cellRef(c, r), row.FieldByIndex(col.index), col.style)
+			}
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData>`)
+
+	if sheet.AutoFilter && !sheet.NoHeader && len(cols) != 0 {
+		fmt.Fprintf(&buf, `<autoFilter ref="%s:%s"/>`, cellRef(0, 1), cellRef(len(cols)-1, r))
+	}
+
+	buf.WriteString(`</worksheet>`)
+	return buf.Bytes(), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func writeCell(buf *bytes.Buffer, ref string, v reflect.Value, style int) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return
+		}
+		writeNumberCell(buf, ref, excelTime(t), style)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		writeStringCell(buf, ref, v.String(), 0)
+	case reflect.Bool:
+		b := "0"
+		if v.Bool() {
+			b = "1"
+		}
+		fmt.Fprintf(buf, `<c r="%s" t="b"><v>%s</v></c>`, ref, b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeNumberCell(buf, ref, strconv.FormatInt(v.Int(), 10), style)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		writeNumberCell(buf, ref, strconv.FormatUint(v.Uint(), 10), style)
+	case reflect.Float32, reflect.Float64:
+		writeNumberCell(buf, ref, strconv.FormatFloat(v.Float(), 'f', -1, 64), style)
+	default:
+		writeStringCell(buf, ref, fmt.Sprint(v.Interface()), 0)
+	}
+}
+
+func writeNumberCell(buf *bytes.Buffer, ref, v string, style int) {
+	if style != 0 {
+		fmt.Fprintf(buf, `<c r="%s" s="%d"><v>%s</v></c>`, ref, style, v)
+		return
+	}
+	fmt.Fprintf(buf, `<c r="%s"><v>%s</v></c>`, ref, v)
+}
+
+func writeStringCell(buf *bytes.Buffer, ref, v string, style int) {
+	fmt.Fprintf(buf, `<c r="%s" t="inlineStr"`, ref)
+	if style != 0 {
+		fmt.Fprintf(buf, ` s="%d"`, style)
+	}
+	buf.WriteString(`><is><t xml:space="preserve">`)
+	xml.EscapeText(buf, []byte(v))
+	buf.WriteString(`</t></is></c>`)
+}
+
+// excelTime returns the Excel serial date of the time, the days since
+// 1899-12-30, in the time's location.
+func excelTime(t time.Time) string {
+	_, offset := t.Zone()
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	days := float64(t.Unix()+int64(offset)-epoch.Unix()) / 86400
+	days += float64(t.Nanosecond()) / 86400e9
+	return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+// cellRef returns the A1 style reference of the zero based column, and one
+// based row.
+func cellRef(col, row int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row)
+}
+
+const (
+	mainNS = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	relsNS = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	// headerStyle is the cell style of header cells, the cellXfs index.
+	headerStyle = 1
+)
+
+const rootRels = xml.Header +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="` + relsNS + `/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func contentTypes(sheets int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&buf, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	buf.WriteString(`</Types>`)
+	return buf.Bytes()
+}
+
+func workbook(names []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<workbook xmlns="` + mainNS + `" xmlns:r="` + relsNS + `"><sheets>`)
+	for i, name := range names {
+		buf.WriteString(`<sheet name="`)
+		xml.EscapeText(&buf, []byte(name))
+		fmt.Fprintf(&buf, `" sheetId="%d" r:id="rId%d"/>`, i+1, i+1)
+	}
+	buf.WriteString(`</sheets></workbook>`)
+	return buf.Bytes()
+}
+
+func workbookRels(sheets int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		fmt.Fprintf(&buf, `<Relationship Id="rId%d" Type="%s/worksheet" Target="worksheets/sheet%d.xml"/>`, i, relsNS, i)
+	}
+	fmt.Fprintf(&buf, `<Relationship Id="rId%d" Type="%s/styles" Target="styles.xml"/>`, sheets+1, relsNS)
+	buf.WriteString(`</Relationships>`)
+	return buf.Bytes()
+}
+
+// styleSheet collects the number formats of the workbook's columns.
+type styleSheet struct {
+	formats []string
+}
+
+// numFmt returns the cell style of the number format, adding it if needed.
+func (s *styleSheet) numFmt(format string) int {
+	for i, f := range s.formats {
+		if f == format {
+			return i + 2
+		}
+	}
+	s.formats = append(s.formats, format)
+	return len(s.formats) + 1
+}
+
+func (s *styleSheet) xml() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<styleSheet xmlns="` + mainNS + `">`)
+
+	// Custom number formats start at 164, after the built-in formats.
+	if len(s.formats) != 0 {
+		fmt.Fprintf(&buf, `<numFmts count="%d">`, len(s.formats))
+		for i, f := range s.formats {
+			fmt.Fprintf(&buf, `<numFmt numFmtId="%d" formatCode="`, 164+i)
+			xml.EscapeText(&buf, []byte(f))
+			buf.WriteString(`"/>`)
+		}
+		buf.WriteString(`</numFmts>`)
+	}
+
+	buf.WriteString(`<fonts count="2">` +
+		`<font><sz val="11"/><name val="Calibri"/></font>` +
+		`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+		`</fonts>` +
+		`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+		`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+		`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>`)
+
+	fmt.Fprintf(&buf, `<cellXfs count="%d">`, len(s.formats)+2)
+	buf.WriteString(`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>`)
+	buf.WriteString(`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>`)
+	for i := range s.formats {
+		fmt.Fprintf(&buf, `<xf numFmtId="%d" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>`, 164+i)
+	}
+	buf.WriteString(`</cellXfs></styleSheet>`)
+	return buf.Bytes()
+}